@@ -19,6 +19,7 @@ var (
 	libraries     string
 	compilerLocal bool
 	optimizeSolc  bool
+	solcVersion   string
 )
 
 var compileCmd = &cobra.Command{
@@ -31,7 +32,7 @@ var compileCmd = &cobra.Command{
 			os.Exit(0)
 		}
 
-		output, err := perform.RequestCompile(args[0], optimizeSolc, libraries)
+		output, err := perform.RequestCompile(args[0], optimizeSolc, libraries, solcVersion)
 		if err != nil {
 			log.Error(err)
 		}
@@ -44,6 +45,8 @@ func addCompileFlags() {
 	compileCmd.Flags().StringVarP(&libraries, "libs", "L", "", "libraries string (libName:Address[, or whitespace]...)")
 	compileCmd.Flags().BoolVarP(&compilerLocal, "local", "l", setCompilerLocal(), "use local compilers to compile message (good for debugging or if server goes down)")
 	compileCmd.Flags().BoolVarP(&optimizeSolc, "optimize", "o", setOptimizeSolc(), "optimize code (solidity only)")
+	compileCmd.Flags().BoolVarP(&perform.AllowOversize, "allow-oversize", "", false, "warn about contracts whose deployed bytecode exceeds the EVM limit of 24576 bytes rather than failing")
+	compileCmd.Flags().StringVarP(&solcVersion, "solc", "", "", "version of solc to compile with, downloading it if needed (otherwise resolved from the version pragma)")
 }
 
 func setOptimizeSolc() bool {
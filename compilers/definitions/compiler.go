@@ -15,6 +15,8 @@ import (
 type Compiler struct {
 	Config LangConfig
 	Lang   string
+	// import remappings of prefix=target applied when reading included files
+	Remappings []string
 }
 
 // New Request object from script and map of include files
@@ -85,7 +87,10 @@ func (c *Compiler) includeReplacer(r *regexp.Regexp, originCode []byte, dir stri
 	match := m[3]
 	log.WithField("=>", match).Debug("Match")
 	// load the file
-	newFilePath := path.Join(dir, match)
+	newFilePath, ok := c.remap(match)
+	if !ok {
+		newFilePath = path.Join(dir, match)
+	}
 	incl_code, err := ioutil.ReadFile(newFilePath)
 	if err != nil {
 		log.Errorln("failed to read include file", err)
@@ -124,6 +129,28 @@ func (c *Compiler) includeReplacer(r *regexp.Regexp, originCode []byte, dir stri
 	return ret, nil
 }
 
+// Returns the path an import is remapped to by the longest matching remapping prefix
+func (c *Compiler) remap(file string) (string, bool) {
+	var prefix, target string
+	for _, remapping := range c.Remappings {
+		// solc remappings may be limited to a context which we do not distinguish
+		if i := strings.Index(remapping, ":"); i >= 0 {
+			remapping = remapping[i+1:]
+		}
+		parts := strings.SplitN(remapping, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			continue
+		}
+		if strings.HasPrefix(file, parts[0]) && len(parts[0]) > len(prefix) {
+			prefix, target = parts[0], parts[1]
+		}
+	}
+	if prefix == "" {
+		return "", false
+	}
+	return target + file[len(prefix):], true
+}
+
 // Return the regex string to match include statements
 func (c *Compiler) IncludeRegex() string {
 	return c.Config.IncludeRegex
@@ -10,6 +10,11 @@ type Request struct {
 	Libraries       string                    `json:"libraries"` // string of libName:LibAddr separated by comma
 	Optimize        bool                      `json:"optimize"`  // run with optimize flag
 	FileReplacement map[string]string         `json:"replacement"`
+	Compiler        string                    `json:"compiler"`        // binary to compile with, the language default if empty
+	CompilerVersion string                    `json:"compilerVersion"` // version of the compiler binary if known
+	EVMVersion      string                    `json:"evmVersion"`      // solidity only, the compiler default if empty
+	Remappings      []string                  `json:"remappings"`      // solidity only, import remappings of prefix=target
+	OptimizerRuns   int                       `json:"optimizerRuns"`   // solidity only, DefaultOptimizerRuns if 0
 }
 
 type BinaryRequest struct {
@@ -77,15 +82,15 @@ var Languages = map[string]LangConfig{
 	SOLIDITY: {
 		CacheDir:     config.SolcScratchPath,
 		IncludeRegex: `import (.+?)??("|')(.+?)("|')(as)?(.+)?;`,
+		// sources and settings are passed as standard-json on stdin rather than as arguments
 		CompileCmd: []string{
 			"solc",
-			"--combined-json", "bin,abi",
-			"_",
+			"--standard-json",
 		},
 	},
 }
 
-// individual contract items
+// individual contract items of the legacy --combined-json output
 type SolcItem struct {
 	Bin string `json:"bin"`
 	Abi string `json:"abi"`
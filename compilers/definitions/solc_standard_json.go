@@ -0,0 +1,199 @@
+package definitions
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Optimizer runs used when optimizing unless otherwise given, as for solc
+const DefaultOptimizerRuns = 200
+
+// solc standard-json input (see https://solidity.readthedocs.io/en/develop/using-the-compiler.html)
+type SolcInput struct {
+	Language string                 `json:"language"`
+	Sources  map[string]*SolcSource `json:"sources"`
+	Settings SolcSettings           `json:"settings"`
+}
+
+type SolcSource struct {
+	Content string `json:"content"`
+}
+
+type SolcSettings struct {
+	Remappings []string      `json:"remappings,omitempty"`
+	Optimizer  SolcOptimizer `json:"optimizer"`
+	EVMVersion string        `json:"evmVersion,omitempty"`
+	// source file to contract name to outputs
+	OutputSelection map[string]map[string][]string `json:"outputSelection"`
+}
+
+type SolcOptimizer struct {
+	Enabled bool `json:"enabled"`
+	Runs    int  `json:"runs"`
+}
+
+// Outputs requested for every contract
+var SolcOutputs = []string{"abi", "evm.bytecode.object", "evm.bytecode.linkReferences",
+	"evm.deployedBytecode.object", "metadata"}
+
+// solc standard-json output
+type SolcOutput struct {
+	Errors []*SolcError `json:"errors"`
+	// source file to contract name to contract
+	Contracts map[string]map[string]*SolcContract `json:"contracts"`
+}
+
+type SolcContract struct {
+	ABI      json.RawMessage `json:"abi"`
+	Metadata string          `json:"metadata"`
+	EVM      struct {
+		Bytecode         SolcBytecode `json:"bytecode"`
+		DeployedBytecode SolcBytecode `json:"deployedBytecode"`
+	} `json:"evm"`
+}
+
+type SolcBytecode struct {
+	Object string `json:"object"`
+	// source file to library name to the positions of its placeholders
+	LinkReferences map[string]map[string]json.RawMessage `json:"linkReferences"`
+}
+
+// Returns the fully qualified file:Name of each library the bytecode references in order
+func (b SolcBytecode) Libraries() []string {
+	var libraries []string
+	for file, libs := range b.LinkReferences {
+		for lib := range libs {
+			libraries = append(libraries, file+":"+lib)
+		}
+	}
+	sort.Strings(libraries)
+	return libraries
+}
+
+// An error or warning from solc
+type SolcError struct {
+	SourceLocation   *SolcSourceLocation `json:"sourceLocation"`
+	Type             string              `json:"type"`
+	Severity         string              `json:"severity"`
+	Message          string              `json:"message"`
+	FormattedMessage string              `json:"formattedMessage"`
+}
+
+// Byte offsets into a source file
+type SolcSourceLocation struct {
+	File  string `json:"file"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// The metadata solc records for a contract, the hash of which it appends to the contract's bytecode
+type SolcMetadata struct {
+	Language string `json:"language"`
+	Compiler struct {
+		Version string `json:"version"`
+	} `json:"compiler"`
+	// source file to its hash and, when solc was asked to include it, content
+	Sources  map[string]*SolcMetadataSource `json:"sources"`
+	Settings struct {
+		// source file to the name of the contract the metadata is for
+		CompilationTarget map[string]string `json:"compilationTarget"`
+		Remappings        []string          `json:"remappings"`
+		Optimizer         SolcOptimizer     `json:"optimizer"`
+		EVMVersion        string            `json:"evmVersion"`
+	} `json:"settings"`
+}
+
+type SolcMetadataSource struct {
+	Keccak256 string `json:"keccak256"`
+	Content   string `json:"content,omitempty"`
+}
+
+// Returns the source file and name of the contract the metadata is for
+func (m *SolcMetadata) Target() (string, string, error) {
+	if len(m.Settings.CompilationTarget) != 1 {
+		return "", "", fmt.Errorf("metadata should have a single compilation target but has %v",
+			len(m.Settings.CompilationTarget))
+	}
+	for file, name := range m.Settings.CompilationTarget {
+		return file, name, nil
+	}
+	return "", "", nil
+}
+
+// New standard-json input that compiles the target of the metadata with the settings it records from sources, the
+// content of each source file it names
+func SolcInputFromMetadata(metadata *SolcMetadata, sources map[string][]byte) (*SolcInput, error) {
+	file, _, err := metadata.Target()
+	if err != nil {
+		return nil, err
+	}
+	input := &SolcInput{
+		Language: metadata.Language,
+		Sources:  make(map[string]*SolcSource, len(metadata.Sources)),
+		Settings: SolcSettings{
+			Remappings: metadata.Settings.Remappings,
+			Optimizer:  metadata.Settings.Optimizer,
+			EVMVersion: metadata.Settings.EVMVersion,
+			OutputSelection: map[string]map[string][]string{
+				file: {"*": SolcOutputs},
+			},
+		},
+	}
+	for name := range metadata.Sources {
+		content, ok := sources[name]
+		if !ok {
+			return nil, fmt.Errorf("no content for source file %s named by metadata", name)
+		}
+		input.Sources[name] = &SolcSource{Content: string(content)}
+	}
+	return input, nil
+}
+
+// New standard-json input compiling the included files of the request
+func SolcInputFromRequest(req *Request) *SolcInput {
+	input := &SolcInput{
+		Language: "Solidity",
+		Sources:  make(map[string]*SolcSource, len(req.Includes)),
+		Settings: SolcSettings{
+			Remappings: req.Remappings,
+			Optimizer: SolcOptimizer{
+				Enabled: req.Optimize,
+				Runs:    req.OptimizerRuns,
+			},
+			EVMVersion: req.EVMVersion,
+			OutputSelection: map[string]map[string][]string{
+				"*": {"*": SolcOutputs},
+			},
+		},
+	}
+	if input.Settings.Optimizer.Runs == 0 {
+		input.Settings.Optimizer.Runs = DefaultOptimizerRuns
+	}
+	// libraries are linked after compiling since their placeholders are not always named as they are given
+	for name, include := range req.Includes {
+		input.Sources[name] = &SolcSource{Content: string(include.Script)}
+	}
+	return input
+}
+
+// Parses a libraries string of libName:LibAddr separated by commas or whitespace into a map of library
+// name to 0x prefixed address, libName may be fully qualified as file:Name
+func SolcLibraries(libs string) map[string]string {
+	libraries := make(map[string]string)
+	for _, lib := range strings.FieldsFunc(libs, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	}) {
+		i := strings.LastIndex(lib, ":")
+		if i <= 0 || i == len(lib)-1 {
+			continue
+		}
+		addr := lib[i+1:]
+		if !strings.HasPrefix(addr, "0x") {
+			addr = "0x" + addr
+		}
+		libraries[lib[:i]] = addr
+	}
+	return libraries
+}
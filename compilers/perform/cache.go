@@ -1,72 +1,129 @@
 package perform
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
 
 	"github.com/monax/bosmarmot/compilers/definitions"
+	"github.com/monax/bosmarmot/monax/config"
+	"github.com/monax/bosmarmot/monax/log"
 )
 
-// check/cache all includes, hash the code, return whether or not there was a full cache hit
-func CheckCached(includes map[string]*definitions.IncludedFiles, lang string) bool {
-	cached := true
-	for name, metadata := range includes {
-		hashPath := path.Join(definitions.Languages[lang].CacheDir, name)
-		if _, scriptErr := os.Stat(hashPath); os.IsNotExist(scriptErr) {
-			cached = false
-			break
-		}
-		for _, object := range metadata.ObjectNames {
-			objectFile := path.Join(hashPath, object+".json")
-			if _, objErr := os.Stat(objectFile); objErr != nil {
-				cached = false
-				break
-			}
-		}
-		if cached == false {
-			break
-		}
-	}
+var (
+	// Directory holding compiler output keyed by the hash of everything that determines it
+	CachePath = config.CompilersCachePath
+	// Always compile, without reading or writing the cache
+	NoCache bool
+)
 
-	return cached
+// Everything that determines compiler output
+type cacheKey struct {
+	Language        string            `json:"language"`
+	CompilerVersion string            `json:"compilerVersion"`
+	Optimize        bool              `json:"optimize"`
+	EVMVersion      string            `json:"evmVersion"`
+	Libraries       string            `json:"libraries"`
+	Remappings      []string          `json:"remappings,omitempty"`
+	OptimizerRuns   int               `json:"optimizerRuns,omitempty"`
+	Sources         map[string][]byte `json:"sources"`
 }
 
-// return cached byte code as a response
-func CachedResponse(includes map[string]*definitions.IncludedFiles, lang string) (*Response, error) {
+// Returns the hash identifying the output of the request, or false if the request cannot be cached
+// since we do not know which compiler would compile it
+func CacheKey(req *definitions.Request) (string, bool) {
+	if req.CompilerVersion == "" {
+		return "", false
+	}
+	key := cacheKey{
+		Language:        req.Language,
+		CompilerVersion: req.CompilerVersion,
+		Optimize:        req.Optimize,
+		EVMVersion:      req.EVMVersion,
+		Libraries:       req.Libraries,
+		Remappings:      req.Remappings,
+		Sources:         make(map[string][]byte, len(req.Includes)),
+	}
+	// optimizer runs only matter when optimizing
+	if req.Optimize {
+		key.OptimizerRuns = req.OptimizerRuns
+	}
+	// the includes are named after the hash of their flattened source
+	for name, include := range req.Includes {
+		key.Sources[name] = include.Script
+	}
+	// json encodes maps with sorted keys so this is deterministic
+	bs, err := json.Marshal(key)
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.Sum256(bs)
+	return hex.EncodeToString(hash[:]), true
+}
 
-	var resp *Response
-	var respItemArray []ResponseItem
-	for name, metadata := range includes {
-		dir := path.Join(definitions.Languages[lang].CacheDir, name)
-		for _, object := range metadata.ObjectNames {
-			jsonBytes, err := ioutil.ReadFile(path.Join(dir, object+".json"))
-			if err != nil {
-				return nil, err
-			}
-			respItem := &ResponseItem{}
-			err = json.Unmarshal(jsonBytes, respItem)
-			if err != nil {
-				return nil, err
-			}
-			respItemArray = append(respItemArray, *respItem)
-		}
-	}
-	resp = &Response{
-		Objects: respItemArray,
-		Warning: "",
-		Error:   "",
+// Returns the cached response to the request if there is one
+func CachedResponse(req *definitions.Request) (*Response, bool) {
+	if NoCache {
+		return nil, false
+	}
+	key, ok := CacheKey(req)
+	if !ok {
+		return nil, false
+	}
+	bs, err := ioutil.ReadFile(filepath.Join(CachePath, key+".json"))
+	if err != nil {
+		return nil, false
 	}
+	resp := new(Response)
+	if err := json.Unmarshal(bs, resp); err != nil {
+		log.WithField("key", key).Warn("Ignoring unreadable cached compiler output")
+		return nil, false
+	}
+	log.WithField("key", key).Info("Using cached compiler output")
+	return resp, true
+}
 
-	return resp, nil
+// Caches a successful response to the request
+func CacheResponse(req *definitions.Request, resp *Response) error {
+	if NoCache || resp.Error != "" {
+		return nil
+	}
+	key, ok := CacheKey(req)
+	if !ok {
+		return nil
+	}
+	bs, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(CachePath, 0755); err != nil {
+		return err
+	}
+	// write then rename so concurrent compiles never read a partial entry
+	tmp, err := ioutil.TempFile(CachePath, key)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(CachePath, key+".json"))
 }
 
-// cache ABI and Binary to
-func CacheResult(object ResponseItem, cacheLocation, warning, version, errorString string) error {
-	os.Chdir(cacheLocation)
-	fullResponse := Response{[]ResponseItem{object}, warning, version, errorString}
-	cachedObject, err := json.Marshal(fullResponse)
-	ioutil.WriteFile(object.Objectname+".json", []byte(cachedObject), 0644)
-	return err
+// Removes all cached compiler output
+func CleanCache() error {
+	err := os.RemoveAll(CachePath)
+	if err != nil {
+		return err
+	}
+	log.WithField("=>", CachePath).Warn("Cleaned compiler cache")
+	return nil
 }
@@ -0,0 +1,190 @@
+package perform
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/monax/bosmarmot/compilers/definitions"
+)
+
+// Library placeholders in hex bytecode take the place of a 20 byte address
+const placeholderLength = 40
+
+// A placeholder left in bytecode for the address of a library
+type Placeholder struct {
+	// As it appears in the bytecode
+	Placeholder string
+	// The (possibly truncated) name of the library for the legacy __Name____ format, and for the __$hash$__
+	// format the fully qualified name the hash is of if it is known
+	Library string
+}
+
+type ErrUnresolvedLibraries struct {
+	Contract     string
+	Placeholders []Placeholder
+}
+
+func (err ErrUnresolvedLibraries) Error() string {
+	names := make([]string, len(err.Placeholders))
+	for i, p := range err.Placeholders {
+		if p.Library != "" {
+			names[i] = fmt.Sprintf("%s (%s)", p.Library, p.Placeholder)
+		} else {
+			names[i] = p.Placeholder
+		}
+	}
+	return fmt.Sprintf("bytecode of %s has unresolved library references %s, give the address of each library "+
+		"with libraries", err.Contract, strings.Join(names, ", "))
+}
+
+// Substitutes the addresses of libraries, keyed by name or fully qualified file:Name, for their placeholders
+// in hex bytecode. Placeholders are in the legacy format of __ followed by the (truncated) fully qualified
+// name padded with underscores, or __$ followed by 34 hex characters of the Keccak-256 hash of the fully
+// qualified name then $__. linkReferences are the fully qualified names of the libraries the bytecode is known
+// to reference which are needed to recognise placeholders by library name alone.
+func LinkBytecode(bytecode string, libraries map[string]string, linkReferences []string) (string, error) {
+	if len(libraries) == 0 || !strings.Contains(bytecode, "__") {
+		return bytecode, nil
+	}
+	addresses := make(map[string]string)
+	for _, placeholder := range Placeholders(bytecode, linkReferences) {
+		if _, ok := addresses[placeholder.Placeholder]; ok {
+			continue
+		}
+		address, ok := libraryAddress(placeholder, libraries, linkReferences)
+		if !ok {
+			continue
+		}
+		address = strings.TrimPrefix(address, "0x")
+		if _, err := hex.DecodeString(address); err != nil || len(address) != placeholderLength {
+			return "", fmt.Errorf("address %s of library %s is not a 20 byte hex address", address,
+				placeholder.Library)
+		}
+		addresses[placeholder.Placeholder] = address
+	}
+	for placeholder, address := range addresses {
+		bytecode = strings.Replace(bytecode, placeholder, address, -1)
+	}
+	return bytecode, nil
+}
+
+// Returns an ErrUnresolvedLibraries if any library placeholders are left in the hex bytecode of contract
+func CheckLinked(contract, bytecode string, linkReferences []string) error {
+	placeholders := Placeholders(bytecode, linkReferences)
+	if len(placeholders) == 0 {
+		return nil
+	}
+	return ErrUnresolvedLibraries{Contract: contract, Placeholders: placeholders}
+}
+
+// Returns the distinct library placeholders in hex bytecode in the order they first appear
+func Placeholders(bytecode string, linkReferences []string) []Placeholder {
+	hashes := make(map[string]string, len(linkReferences))
+	for _, name := range linkReferences {
+		hashes[libraryHashPlaceholder(name)] = name
+	}
+	var placeholders []Placeholder
+	seen := make(map[string]bool)
+	for i := strings.Index(bytecode, "__"); i >= 0; i = strings.Index(bytecode, "__") {
+		bytecode = bytecode[i:]
+		if len(bytecode) < placeholderLength {
+			// not a placeholder we can link but not valid hex either
+			placeholders = append(placeholders, Placeholder{Placeholder: bytecode})
+			break
+		}
+		placeholder := bytecode[:placeholderLength]
+		bytecode = bytecode[placeholderLength:]
+		if seen[placeholder] {
+			continue
+		}
+		seen[placeholder] = true
+		p := Placeholder{Placeholder: placeholder}
+		if strings.HasPrefix(placeholder, "__$") {
+			p.Library = hashes[placeholder]
+		} else {
+			p.Library = strings.Trim(placeholder, "_")
+		}
+		placeholders = append(placeholders, p)
+	}
+	return placeholders
+}
+
+// Finds the address of the library a placeholder is for by its name or fully qualified name
+func libraryAddress(placeholder Placeholder, libraries map[string]string, linkReferences []string) (string, bool) {
+	// fully qualified names take precedence over names alone and are checked in a deterministic order
+	names := make([]string, 0, len(libraries))
+	for name := range libraries {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		qi, qj := strings.Contains(names[i], ":"), strings.Contains(names[j], ":")
+		if qi != qj {
+			return qi
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		// legacy placeholders of binaries name the library even without link references
+		if !strings.HasPrefix(placeholder.Placeholder, "__$") && !strings.Contains(name, ":") &&
+			placeholder.Library[strings.LastIndex(placeholder.Library, ":")+1:] == name {
+			return libraries[name], true
+		}
+		for _, qualified := range qualifiedNames(name, linkReferences) {
+			if placeholder.Placeholder == libraryHashPlaceholder(qualified) ||
+				placeholder.Placeholder == libraryLegacyPlaceholder(qualified) {
+				return libraries[name], true
+			}
+		}
+	}
+	return "", false
+}
+
+// Returns the fully qualified names a library name may stand for, which includes the name itself since
+// older compilers only used the name
+func qualifiedNames(name string, linkReferences []string) []string {
+	names := []string{name}
+	if strings.Contains(name, ":") {
+		return names
+	}
+	for _, qualified := range linkReferences {
+		if qualified[strings.LastIndex(qualified, ":")+1:] == name {
+			names = append(names, qualified)
+		}
+	}
+	return names
+}
+
+func libraryHashPlaceholder(qualifiedName string) string {
+	hash := sha3.NewKeccak256()
+	hash.Write([]byte(qualifiedName))
+	return "__$" + hex.EncodeToString(hash.Sum(nil))[:34] + "$__"
+}
+
+func libraryLegacyPlaceholder(qualifiedName string) string {
+	name := qualifiedName
+	if len(name) > placeholderLength-4 {
+		name = name[:placeholderLength-4]
+	}
+	return "__" + name + strings.Repeat("_", placeholderLength-2-len(name))
+}
+
+// Links the solidity objects of a response against libraries, a string of libName:LibAddr separated by
+// commas or whitespace, leaving any other placeholders in place
+func linkResponse(resp *Response, libraries string) error {
+	libs := definitions.SolcLibraries(libraries)
+	for i, object := range resp.Objects {
+		bytecode, err := LinkBytecode(object.Bytecode, libs, object.LinkReferences)
+		if err != nil {
+			return fmt.Errorf("could not link %s: %v", object.Objectname, err)
+		}
+		deployedBytecode, err := LinkBytecode(object.DeployedBytecode, libs, object.LinkReferences)
+		if err != nil {
+			return fmt.Errorf("could not link %s: %v", object.Objectname, err)
+		}
+		resp.Objects[i].Bytecode, resp.Objects[i].DeployedBytecode = bytecode, deployedBytecode
+	}
+	return nil
+}
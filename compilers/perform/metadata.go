@@ -0,0 +1,298 @@
+package perform
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/monax/bosmarmot/compilers/definitions"
+	"github.com/monax/bosmarmot/monax/config"
+	"github.com/monax/bosmarmot/monax/log"
+)
+
+// Directory holding the metadata and sources of compiled contracts by metadata hash
+var ArtifactsPath = config.ArtifactsPath
+
+// The keys under which solc records the hash of the metadata in bytecode, by the versions of solc that use them
+var metadataHashKeys = []string{"bzzr0", "bzzr1", "ipfs"}
+
+// What solc appends to the bytecode of a contract: a CBOR encoded map holding the hash of the contract's metadata,
+// followed by the length of the map as two big-endian bytes
+type CodeMetadata struct {
+	// Hex of the hash of the contract's metadata
+	Hash string
+	// Version of solc that compiled the contract as recorded by solc 0.5.9 onwards, empty for earlier versions
+	SolcVersion string
+	// Number of bytes taken up at the end of the bytecode, including the length
+	Length int
+}
+
+// Splits bytecode into the code proper and the metadata solc appended to it, the metadata is nil if the bytecode does
+// not end with any
+func SplitMetadata(code []byte) ([]byte, *CodeMetadata) {
+	if len(code) < 2 {
+		return code, nil
+	}
+	n := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	if n == 0 || n+2 > len(code) {
+		return code, nil
+	}
+	fields, ok := decodeCBORMap(code[len(code)-2-n : len(code)-2])
+	if !ok {
+		return code, nil
+	}
+	metadata := &CodeMetadata{Length: n + 2}
+	for _, key := range metadataHashKeys {
+		if hash, ok := fields[key].([]byte); ok {
+			metadata.Hash = hex.EncodeToString(hash)
+			break
+		}
+	}
+	if metadata.Hash == "" {
+		return code, nil
+	}
+	switch solc := fields["solc"].(type) {
+	case []byte:
+		if len(solc) == 3 {
+			metadata.SolcVersion = fmt.Sprintf("%d.%d.%d", solc[0], solc[1], solc[2])
+		}
+	case string:
+		// pre-release builds record their full version
+		if i := strings.IndexAny(solc, "-+"); i >= 0 {
+			solc = solc[:i]
+		}
+		metadata.SolcVersion = solc
+	}
+	return code[:len(code)-metadata.Length], metadata
+}
+
+// As SplitMetadata for hex bytecode, which may hold library placeholders before its metadata
+func splitHexMetadata(bytecode string) (string, *CodeMetadata) {
+	bytecode = strings.TrimPrefix(bytecode, "0x")
+	if len(bytecode) < 4 {
+		return bytecode, nil
+	}
+	length, err := hex.DecodeString(bytecode[len(bytecode)-4:])
+	if err != nil {
+		return bytecode, nil
+	}
+	n := int(length[0])<<8 | int(length[1])
+	if 2*(n+2) > len(bytecode) {
+		return bytecode, nil
+	}
+	tail, err := hex.DecodeString(bytecode[len(bytecode)-2*(n+2):])
+	if err != nil {
+		return bytecode, nil
+	}
+	_, metadata := SplitMetadata(tail)
+	if metadata == nil {
+		return bytecode, nil
+	}
+	return bytecode[:len(bytecode)-2*metadata.Length], metadata
+}
+
+// Decodes a CBOR map of text keys to byte string, text string, or boolean values, which is all solc uses for the
+// metadata it appends to bytecode
+func decodeCBORMap(bs []byte) (map[string]interface{}, bool) {
+	if len(bs) == 0 || bs[0]>>5 != 5 || bs[0]&0x1f >= 24 {
+		return nil, false
+	}
+	count := int(bs[0] & 0x1f)
+	bs = bs[1:]
+	fields := make(map[string]interface{}, count)
+	for i := 0; i < count; i++ {
+		key, rest, ok := decodeCBORItem(bs)
+		name, isText := key.(string)
+		if !ok || !isText {
+			return nil, false
+		}
+		value, rest, ok := decodeCBORItem(rest)
+		if !ok {
+			return nil, false
+		}
+		fields[name] = value
+		bs = rest
+	}
+	return fields, len(bs) == 0
+}
+
+func decodeCBORItem(bs []byte) (interface{}, []byte, bool) {
+	if len(bs) == 0 {
+		return nil, nil, false
+	}
+	major, info := bs[0]>>5, int(bs[0]&0x1f)
+	bs = bs[1:]
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, bs, true
+		case 21:
+			return true, bs, true
+		}
+		return nil, nil, false
+	}
+	if major != 2 && major != 3 {
+		return nil, nil, false
+	}
+	length := info
+	switch {
+	case info == 24 && len(bs) >= 1:
+		length, bs = int(bs[0]), bs[1:]
+	case info == 25 && len(bs) >= 2:
+		length, bs = int(bs[0])<<8|int(bs[1]), bs[2:]
+	case info >= 24:
+		return nil, nil, false
+	}
+	if length > len(bs) {
+		return nil, nil, false
+	}
+	if major == 2 {
+		return bs[:length], bs[length:], true
+	}
+	return string(bs[:length]), bs[length:], true
+}
+
+// What is recorded about a compiled contract so that deployed code can be verified against its source
+type Artifact struct {
+	MetadataHash    string `json:"metadataHash"`
+	Contract        string `json:"contract"`
+	CompilerVersion string `json:"compilerVersion"`
+	// source file as named in the metadata, after the hash of its flattened content, to the file it was read from
+	Files    map[string]string         `json:"files"`
+	Metadata *definitions.SolcMetadata `json:"-"`
+	// source file as named in the metadata to its content
+	Sources map[string][]byte `json:"-"`
+}
+
+// Returns the file the contract of the artifact was compiled from
+func (a *Artifact) File() string {
+	file, _, _ := a.Metadata.Target()
+	if original, ok := a.Files[file]; ok {
+		return original
+	}
+	return file
+}
+
+func artifactPath(metadataHash string) string {
+	return filepath.Join(ArtifactsPath, metadataHash)
+}
+
+// Saves the metadata and sources of each contract in a successful solidity response to ArtifactsPath under the hash
+// of its metadata, leaving any already saved in place since the hash identifies them
+func SaveArtifacts(req *definitions.Request, resp *Response) error {
+	if resp.Error != "" {
+		return nil
+	}
+	for _, object := range resp.Objects {
+		if object.Metadata == "" {
+			continue
+		}
+		// abstract contracts have no code to carry a hash
+		_, codeMetadata := splitHexMetadata(object.DeployedBytecode)
+		if codeMetadata == nil {
+			continue
+		}
+		if _, err := os.Stat(artifactPath(codeMetadata.Hash)); err == nil {
+			continue
+		}
+		if err := saveArtifact(req, object, codeMetadata.Hash); err != nil {
+			return fmt.Errorf("could not save metadata of %s: %v", object.Objectname, err)
+		}
+	}
+	return nil
+}
+
+func saveArtifact(req *definitions.Request, object ResponseItem, metadataHash string) error {
+	metadata := new(definitions.SolcMetadata)
+	if err := json.Unmarshal([]byte(object.Metadata), metadata); err != nil {
+		return err
+	}
+	artifact := &Artifact{
+		MetadataHash:    metadataHash,
+		Contract:        object.Objectname,
+		CompilerVersion: metadataCompilerVersion(object.Metadata),
+		Files:           make(map[string]string, len(metadata.Sources)),
+	}
+	if err := os.MkdirAll(ArtifactsPath, 0755); err != nil {
+		return err
+	}
+	// written to a temporary directory then renamed so that an artifact is never seen partially written
+	tmp, err := ioutil.TempDir(ArtifactsPath, metadataHash)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.Mkdir(filepath.Join(tmp, "sources"), 0755); err != nil {
+		return err
+	}
+	for name := range metadata.Sources {
+		include, ok := req.Includes[name]
+		if !ok {
+			return fmt.Errorf("metadata names source file %s that was not compiled", name)
+		}
+		if err := ioutil.WriteFile(filepath.Join(tmp, "sources", filepath.Base(name)), include.Script,
+			0644); err != nil {
+			return err
+		}
+		artifact.Files[name] = req.FileReplacement[name]
+	}
+	bs, err := json.MarshalIndent(artifact, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmp, "artifact.json"), bs, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmp, "metadata.json"), []byte(object.Metadata), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, artifactPath(metadataHash)); err != nil {
+		// another compile saved the same artifact first
+		if _, statErr := os.Stat(artifactPath(metadataHash)); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	log.WithFields(log.Fields{
+		"contract": object.Objectname,
+		"hash":     metadataHash,
+	}).Debug("Saved contract metadata")
+	return nil
+}
+
+// Reads the artifact saved under metadataHash by SaveArtifacts
+func ReadArtifact(metadataHash string) (*Artifact, error) {
+	dir := artifactPath(metadataHash)
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "artifact.json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no metadata saved in %s under hash %s, the source must be compiled with bos first",
+			ArtifactsPath, metadataHash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	artifact := new(Artifact)
+	if err := json.Unmarshal(bs, artifact); err != nil {
+		return nil, fmt.Errorf("could not read artifact %s: %v", metadataHash, err)
+	}
+	bs, err = ioutil.ReadFile(filepath.Join(dir, "metadata.json"))
+	if err != nil {
+		return nil, err
+	}
+	artifact.Metadata = new(definitions.SolcMetadata)
+	if err := json.Unmarshal(bs, artifact.Metadata); err != nil {
+		return nil, fmt.Errorf("could not read metadata of artifact %s: %v", metadataHash, err)
+	}
+	artifact.Sources = make(map[string][]byte, len(artifact.Metadata.Sources))
+	for name := range artifact.Metadata.Sources {
+		artifact.Sources[name], err = ioutil.ReadFile(filepath.Join(dir, "sources", filepath.Base(name)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return artifact, nil
+}
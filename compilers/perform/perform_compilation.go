@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/monax/bosmarmot/compilers/definitions"
@@ -21,8 +22,21 @@ type Response struct {
 	Warning string         `json:"warning"`
 	Version string         `json:"version"`
 	Error   string         `json:"error"`
+	// errors and warnings from solc
+	Diagnostics []*Diagnostic `json:"diagnostics,omitempty"`
 }
 
+var (
+	// Version of the EVM solc should target, the compiler default if empty
+	EVMVersion string
+	// Import remappings of prefix=target for solc
+	Remappings []string
+	// Optimizer runs for solc when optimizing
+	OptimizerRuns = definitions.DefaultOptimizerRuns
+	// Only warn about contracts whose deployed bytecode is larger than the EVM allows rather than failing
+	AllowOversize bool
+)
+
 type BinaryResponse struct {
 	Binary string `json:"binary"`
 	Error  string `json:"error"`
@@ -30,58 +44,37 @@ type BinaryResponse struct {
 
 // Compile response object
 type ResponseItem struct {
-	Objectname string `json:"objectname"`
-	Bytecode   string `json:"bytecode"`
-	ABI        string `json:"abi"` // json encoded
+	Objectname       string `json:"objectname"`
+	Bytecode         string `json:"bytecode"`
+	DeployedBytecode string `json:"deployedBytecode"` // solidity only
+	ABI              string `json:"abi"`              // json encoded
+	Metadata         string `json:"metadata"`         // solidity only, json encoded
+	Version          string `json:"version"`          // of the compiler that produced the bytecode
+	// solidity only, fully qualified file:Name of each library the bytecode must be linked against
+	LinkReferences []string `json:"linkReferences,omitempty"`
 }
 
-func (resp Response) CacheNewResponse(req definitions.Request) {
-	objects := resp.Objects
-	//log.Debug(objects)
-	cacheLocation := definitions.Languages[req.Language].CacheDir
-	cur, _ := os.Getwd()
-	os.Chdir(cacheLocation)
-	defer func() {
-		os.Chdir(cur)
-	}()
-	for fileDir, metadata := range req.Includes {
-		dir := path.Join(cacheLocation, strings.TrimRight(fileDir, "."+req.Language))
-		os.MkdirAll(dir, 0700)
-		objectNames := metadata.ObjectNames
-		for _, name := range objectNames {
-			for _, object := range objects {
-				if object.Objectname == name {
-					//log.WithField("=>", resp.Objects).Debug("Response objects over the loop")
-					CacheResult(object, dir, resp.Warning, resp.Version, resp.Error)
-					break
-				}
-			}
-		}
-	}
+// An error or warning from the compiler about a source file
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"` // 0 if the compiler did not give a location
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// the message along with an excerpt of the source it is about
+	FormattedMessage string `json:"formattedMessage"`
 }
 
 func linkBinaries(req *definitions.BinaryRequest) *BinaryResponse {
-	// purely for solidity and solidity alone as this is soon to be deprecated.
-	if req.Libraries == "" {
+	binary, err := LinkBytecode(strings.TrimSpace(req.BinaryFile), definitions.SolcLibraries(req.Libraries), nil)
+	if err != nil {
 		return &BinaryResponse{
 			Binary: req.BinaryFile,
-			Error:  "",
+			Error:  err.Error(),
 		}
 	}
-
-	buf := bytes.NewBufferString(req.BinaryFile)
-	var output bytes.Buffer
-	var stderr bytes.Buffer
-	linkCmd := exec.Command("solc", "--link", "--libraries", req.Libraries)
-	linkCmd.Stdin = buf
-	linkCmd.Stderr = &stderr
-	linkCmd.Stdout = &output
-	linkCmd.Start()
-	linkCmd.Wait()
-
 	return &BinaryResponse{
-		Binary: strings.TrimSpace(output.String()),
-		Error:  stderr.String(),
+		Binary: binary,
+		Error:  "",
 	}
 }
 
@@ -99,44 +92,62 @@ func RequestBinaryLinkage(file string, libraries string) (*BinaryResponse, error
 }
 
 //todo: Might also need to add in a map of library names to addrs
-func RequestCompile(file string, optimize bool, libraries string) (*Response, error) {
+// solcVersion pins the version of solc used for solidity files, otherwise it is resolved from their
+// version pragmas
+func RequestCompile(file string, optimize bool, libraries string, solcVersion string) (*Response, error) {
 	config.InitMonaxDir()
 	request, err := CreateRequest(file, libraries, optimize)
 	if err != nil {
 		return nil, err
 	}
-	//todo: check server for newer version of same files...
-	// go through all includes, check if they have changed
-	cached := CheckCached(request.Includes, request.Language)
-
-	log.WithField("cached?", cached).Debug("Cached Item(s)")
-
-	/*for k, v := range request.Includes {
-		log.WithFields(log.Fields{
-			"k": k,
-			"v": string(v.Script),
-		}).Debug("check request loop")
-	}*/
-
-	var resp *Response
-	// if everything is cached, no need for request
-	if cached {
-		// TODO: need to return all contracts/libs tied to the original src file
-		resp, err = CachedResponse(request.Includes, request.Language)
+	if request.Language == definitions.SOLIDITY {
+		var sources [][]byte
+		for _, include := range request.Includes {
+			sources = append(sources, include.Script)
+		}
+		request.Compiler, request.CompilerVersion, err = ResolveSolc(solcVersion, sources...)
 		if err != nil {
+			return nil, fmt.Errorf("could not resolve solc version for %s: %v", file, err)
+		}
+		log.WithFields(log.Fields{
+			"solc":    request.Compiler,
+			"version": request.CompilerVersion,
+		}).Info("Using Compiler")
+	}
+	resp := cachedCompile(request)
+
+	if request.Language == definitions.SOLIDITY && resp.Error == "" {
+		if err := linkResponse(resp, request.Libraries); err != nil {
 			return nil, err
 		}
-	} else {
-		log.Debug("Could not find cached object, compiling...")
-		resp = compile(request)
-		resp.CacheNewResponse(*request)
+		if err := checkCodeSize(request, resp); err != nil {
+			return nil, err
+		}
+		if err := SaveArtifacts(request, resp); err != nil {
+			log.WithField("error", err).Warn("Could not save contract metadata for verification")
+		}
 	}
 
+	printWarnings(resp)
 	PrintResponse(*resp, false)
 
 	return resp, nil
 }
 
+// Returns the cached response to the request if there is one, otherwise compiles and caches it
+func cachedCompile(request *definitions.Request) *Response {
+	resp, cached := CachedResponse(request)
+	log.WithField("cached?", cached).Debug("Cached Item(s)")
+	if !cached {
+		log.Debug("Could not find cached object, compiling...")
+		resp = compile(request)
+		if err := CacheResponse(request, resp); err != nil {
+			log.WithField("error", err).Warn("Could not cache compiler output")
+		}
+	}
+	return resp
+}
+
 // Compile takes a dir and some code, replaces all includes, checks cache, compiles, caches
 func compile(req *definitions.Request) *Response {
 
@@ -144,6 +155,10 @@ func compile(req *definitions.Request) *Response {
 		return compilerResponse("", "", "", "", "", fmt.Errorf("No script provided"))
 	}
 
+	if req.Language == definitions.SOLIDITY {
+		return compileSolidity(req)
+	}
+
 	lang := definitions.Languages[req.Language]
 
 	includes := []string{}
@@ -167,6 +182,9 @@ func compile(req *definitions.Request) *Response {
 	}
 	defer os.Remove(libsFile.Name())
 	command := lang.Cmd(includes, libsFile.Name(), req.Optimize)
+	if req.Compiler != "" {
+		command[0] = req.Compiler
+	}
 	log.WithField("Command: ", command).Debug("Command Input")
 	output, err := runCommand(command...)
 
@@ -202,16 +220,26 @@ func compile(req *definitions.Request) *Response {
 		return compilerResponse("", "", "", "", "", err)
 	}
 	respItemArray := make([]ResponseItem, 0)
+	version := req.CompilerVersion
+	if version == "" {
+		version = solcResp.Version
+	}
 
 	for contract, item := range solcResp.Contracts {
 		respItem := ResponseItem{
 			Objectname: objectName(contract),
 			Bytecode:   strings.TrimSpace(item.Bin),
 			ABI:        strings.TrimSpace(item.Abi),
+			Version:    version,
 		}
 		respItemArray = append(respItemArray, respItem)
 	}
 
+	// solc gives contracts in a map so put them in a deterministic order
+	sort.Slice(respItemArray, func(i, j int) bool {
+		return respItemArray[i].Objectname < respItemArray[j].Objectname
+	})
+
 	for _, re := range respItemArray {
 		log.WithFields(log.Fields{
 			"name": re.Objectname,
@@ -223,10 +251,156 @@ func compile(req *definitions.Request) *Response {
 	return &Response{
 		Objects: respItemArray,
 		Warning: warning,
+		Version: version,
 		Error:   "",
 	}
 }
 
+// Compiles the included files of the request with solc's standard-json interface
+func compileSolidity(req *definitions.Request) *Response {
+	solcOutput, err := runSolc(req.Compiler, definitions.SolcInputFromRequest(req))
+	if err != nil {
+		return compilerResponse("", "", "", "", "", err)
+	}
+
+	diagnostics := solcDiagnostics(req, solcOutput.Errors)
+	var warnings, errs []string
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == "error" {
+			errs = append(errs, diagnostic.FormattedMessage)
+		} else {
+			warnings = append(warnings, diagnostic.FormattedMessage)
+		}
+	}
+	if len(errs) > 0 {
+		resp := compilerResponse("", "", "", strings.Join(warnings, "\n"), req.CompilerVersion,
+			fmt.Errorf("%s", strings.Join(errs, "\n")))
+		resp.Diagnostics = diagnostics
+		return resp
+	}
+
+	version := req.CompilerVersion
+	respItemArray := make([]ResponseItem, 0)
+	for _, contracts := range solcOutput.Contracts {
+		for name, contract := range contracts {
+			abi := new(bytes.Buffer)
+			if len(contract.ABI) > 0 {
+				if err := json.Compact(abi, contract.ABI); err != nil {
+					return compilerResponse("", "", "", "", "", fmt.Errorf("invalid ABI for %s: %v", name, err))
+				}
+			}
+			if version == "" {
+				version = metadataCompilerVersion(contract.Metadata)
+			}
+			respItemArray = append(respItemArray, ResponseItem{
+				Objectname:       name,
+				Bytecode:         contract.EVM.Bytecode.Object,
+				DeployedBytecode: contract.EVM.DeployedBytecode.Object,
+				ABI:              abi.String(),
+				Metadata:         contract.Metadata,
+				LinkReferences:   contract.EVM.Bytecode.Libraries(),
+			})
+		}
+	}
+	for i := range respItemArray {
+		respItemArray[i].Version = version
+	}
+
+	// solc gives contracts in a map so put them in a deterministic order
+	sort.Slice(respItemArray, func(i, j int) bool {
+		return respItemArray[i].Objectname < respItemArray[j].Objectname
+	})
+
+	for _, re := range respItemArray {
+		log.WithFields(log.Fields{
+			"name": re.Objectname,
+			"bin":  re.Bytecode,
+			"abi":  re.ABI,
+		}).Debug("Response formulated")
+	}
+
+	return &Response{
+		Objects:     respItemArray,
+		Warning:     strings.Join(warnings, "\n"),
+		Version:     version,
+		Error:       "",
+		Diagnostics: diagnostics,
+	}
+}
+
+// Runs standard-json input through compiler, or the solc on the PATH if it is empty
+func runSolc(compiler string, solcInput *definitions.SolcInput) (*definitions.SolcOutput, error) {
+	input, err := json.Marshal(solcInput)
+	if err != nil {
+		return nil, err
+	}
+	command := definitions.Languages[definitions.SOLIDITY].CompileCmd
+	if compiler != "" {
+		command = append([]string{compiler}, command[1:]...)
+	}
+	log.WithField("Command: ", command).Debug("Command Input")
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	log.WithField("=>", string(output)).Debug("Output from command: ")
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)+stderr.String()))
+	}
+
+	solcOutput := new(definitions.SolcOutput)
+	if err := json.Unmarshal(output, solcOutput); err != nil {
+		log.Debug("Could not unmarshal json")
+		return nil, fmt.Errorf("could not read solc output: %v", err)
+	}
+	return solcOutput, nil
+}
+
+// Locates solc errors and warnings in the original source files rather than the include files named after
+// their hashes that solc compiled
+func solcDiagnostics(req *definitions.Request, solcErrors []*definitions.SolcError) []*Diagnostic {
+	var diagnostics []*Diagnostic
+	for _, solcError := range solcErrors {
+		diagnostic := &Diagnostic{
+			Severity:         strings.ToLower(solcError.Severity),
+			Message:          solcError.Message,
+			FormattedMessage: strings.TrimSpace(solcError.FormattedMessage),
+		}
+		if diagnostic.FormattedMessage == "" {
+			diagnostic.FormattedMessage = solcError.Message
+		}
+		if location := solcError.SourceLocation; location != nil {
+			diagnostic.File = location.File
+			if include, ok := req.Includes[location.File]; ok && location.Start >= 0 &&
+				location.Start <= len(include.Script) {
+				diagnostic.Line = bytes.Count(include.Script[:location.Start], []byte("\n")) + 1
+			}
+		}
+		for include, file := range req.FileReplacement {
+			diagnostic.FormattedMessage = strings.Replace(diagnostic.FormattedMessage, include, file, -1)
+			if diagnostic.File == include {
+				diagnostic.File = file
+			}
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+// Returns the compiler version in the contract metadata, without the commit and platform
+func metadataCompilerVersion(metadata string) string {
+	var m struct {
+		Compiler struct {
+			Version string `json:"version"`
+		} `json:"compiler"`
+	}
+	if err := json.Unmarshal([]byte(metadata), &m); err != nil {
+		return ""
+	}
+	return strings.SplitN(m.Compiler.Version, "+", 2)[0]
+}
+
 func objectName(contract string) string {
 	if contract == "" {
 		return ""
@@ -254,8 +428,9 @@ func CreateRequest(file string, libraries string, optimize bool) (*definitions.R
 		return &definitions.Request{}, err
 	}
 	compiler := &definitions.Compiler{
-		Config: definitions.Languages[language],
-		Lang:   language,
+		Config:     definitions.Languages[language],
+		Lang:       language,
+		Remappings: Remappings,
 	}
 	code, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -268,7 +443,13 @@ func CreateRequest(file string, libraries string, optimize bool) (*definitions.R
 		return &definitions.Request{}, err
 	}
 
-	return compiler.CompilerRequest(file, includes, libraries, optimize, hashFileReplacement), nil
+	request := compiler.CompilerRequest(file, includes, libraries, optimize, hashFileReplacement)
+	if language == definitions.SOLIDITY {
+		request.EVMVersion = EVMVersion
+		request.Remappings = Remappings
+		request.OptimizerRuns = OptimizerRuns
+	}
+	return request, nil
 }
 
 // New response object from bytecode and an error
@@ -294,15 +475,28 @@ func compilerResponse(objectname, bytecode, abi, warning, version string, err er
 	}
 }
 
+// Warnings are printed rather than failing the compilation
+func printWarnings(resp *Response) {
+	for _, diagnostic := range resp.Diagnostics {
+		if diagnostic.Severity != "error" {
+			log.WithFields(log.Fields{
+				"file": diagnostic.File,
+				"line": diagnostic.Line,
+			}).Warn(diagnostic.FormattedMessage)
+		}
+	}
+}
+
 func PrintResponse(resp Response, cli bool) {
 	if resp.Error != "" {
 		log.Warn(resp.Error)
 	} else {
 		for _, r := range resp.Objects {
 			message := log.WithFields((log.Fields{
-				"name": r.Objectname,
-				"bin":  r.Bytecode,
-				"abi":  r.ABI,
+				"name":    r.Objectname,
+				"bin":     r.Bytecode,
+				"abi":     r.ABI,
+				"version": r.Version,
 			}))
 			if cli {
 				message.Warn("Response")
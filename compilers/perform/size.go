@@ -0,0 +1,123 @@
+package perform
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/monax/bosmarmot/compilers/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+)
+
+// The largest deployed bytecode the EVM will store for a contract, as set by EIP-170
+const MaxDeployedCodeSize = 24576
+
+// A contract whose deployed bytecode is larger than MaxDeployedCodeSize
+type OversizeContract struct {
+	Objectname string
+	// Length in bytes of the deployed bytecode
+	Size int
+	// Length in bytes of the deployed bytecode when compiled with the optimizer, 0 if it was not estimated
+	OptimizedSize int
+}
+
+// Number of bytes the deployed bytecode is over MaxDeployedCodeSize
+func (oc OversizeContract) Delta() int {
+	return oc.Size - MaxDeployedCodeSize
+}
+
+type ErrOversizeContracts struct {
+	Contracts []OversizeContract
+}
+
+func (err ErrOversizeContracts) Error() string {
+	reports := make([]string, len(err.Contracts))
+	for i, oc := range err.Contracts {
+		reports[i] = oc.String()
+	}
+	return fmt.Sprintf("deployed bytecode exceeds the EVM limit of %d bytes so could not be deployed: %s "+
+		"(use --allow-oversize to compile regardless)", MaxDeployedCodeSize, strings.Join(reports, "; "))
+}
+
+func (oc OversizeContract) String() string {
+	report := fmt.Sprintf("%s is %d bytes, %d over the limit", oc.Objectname, oc.Size, oc.Delta())
+	switch {
+	case oc.OptimizedSize == 0:
+	case oc.OptimizedSize <= MaxDeployedCodeSize:
+		report += fmt.Sprintf(", it would be %d bytes with the optimizer enabled so try compiling with it",
+			oc.OptimizedSize)
+	default:
+		report += fmt.Sprintf(", and still %d bytes with the optimizer enabled", oc.OptimizedSize)
+	}
+	return report
+}
+
+// Returns the length in bytes of hex bytecode, counting each unlinked library placeholder as the 20 byte
+// address that will take its place
+func CodeSize(bytecode string) (int, error) {
+	bytecode = strings.TrimPrefix(strings.TrimSpace(bytecode), "0x")
+	for _, placeholder := range Placeholders(bytecode, nil) {
+		if len(placeholder.Placeholder) == placeholderLength {
+			bytecode = strings.Replace(bytecode, placeholder.Placeholder, strings.Repeat("0", placeholderLength), -1)
+		}
+	}
+	code, err := hex.DecodeString(bytecode)
+	if err != nil {
+		return 0, fmt.Errorf("could not read bytecode: %v", err)
+	}
+	return len(code), nil
+}
+
+// Returns the objects of the response whose deployed bytecode is larger than MaxDeployedCodeSize
+func OversizeContracts(resp *Response) ([]OversizeContract, error) {
+	var oversize []OversizeContract
+	for _, object := range resp.Objects {
+		size, err := CodeSize(object.DeployedBytecode)
+		if err != nil {
+			return nil, fmt.Errorf("could not determine size of %s: %v", object.Objectname, err)
+		}
+		if size > MaxDeployedCodeSize {
+			oversize = append(oversize, OversizeContract{Objectname: object.Objectname, Size: size})
+		}
+	}
+	return oversize, nil
+}
+
+// Returns an ErrOversizeContracts if the deployed bytecode of any contract in the response of a solidity request
+// is too large, or only warns about them if AllowOversize is set. When the request did not use the optimizer the
+// sources are also compiled with it to report whether it would bring the contracts under the limit.
+func checkCodeSize(req *definitions.Request, resp *Response) error {
+	oversize, err := OversizeContracts(resp)
+	if err != nil || len(oversize) == 0 {
+		return err
+	}
+	if !req.Optimize {
+		estimateOptimizedSizes(req, oversize)
+	}
+	if AllowOversize {
+		for _, oc := range oversize {
+			log.WithField("contract", oc.Objectname).Warn("Deployed bytecode exceeds EVM limit: " + oc.String())
+		}
+		return nil
+	}
+	return ErrOversizeContracts{Contracts: oversize}
+}
+
+func estimateOptimizedSizes(req *definitions.Request, oversize []OversizeContract) {
+	optimized := *req
+	optimized.Optimize = true
+	resp := cachedCompile(&optimized)
+	if resp.Error != "" {
+		log.WithField("error", resp.Error).Debug("Could not compile with optimizer to estimate contract sizes")
+		return
+	}
+	sizes := make(map[string]int)
+	for _, object := range resp.Objects {
+		if size, err := CodeSize(object.DeployedBytecode); err == nil {
+			sizes[object.Objectname] = size
+		}
+	}
+	for i := range oversize {
+		oversize[i].OptimizedSize = sizes[oversize[i].Objectname]
+	}
+}
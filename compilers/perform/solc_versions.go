@@ -0,0 +1,387 @@
+package perform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/monax/bosmarmot/monax/config"
+	"github.com/monax/bosmarmot/monax/log"
+)
+
+// Where solc release binaries are downloaded from, laid out as <platform>/list.json and <platform>/<build path>
+var SolcReleasesURL = "https://solc-bin.ethereum.org"
+
+func init() {
+	if url := os.Getenv("MONAX_SOLC_RELEASES_URL"); url != "" {
+		SolcReleasesURL = url
+	}
+}
+
+const defaultSolc = "solc"
+
+var (
+	pragmaRegex      = regexp.MustCompile(`pragma\s+solidity\s+([^;]+);`)
+	comparatorRegex  = regexp.MustCompile(`(\^|~|>=|<=|>|<|=)?\s*v?(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+	solcVersionRegex = regexp.MustCompile(`Version: (\d+\.\d+\.\d+)`)
+)
+
+type SolcVersion struct {
+	Major, Minor, Patch int
+}
+
+func ParseSolcVersion(s string) (SolcVersion, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) != 3 {
+		return SolcVersion{}, fmt.Errorf("solc version %s should be of the form major.minor.patch", s)
+	}
+	var v [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return SolcVersion{}, fmt.Errorf("solc version %s should be of the form major.minor.patch", s)
+		}
+		v[i] = n
+	}
+	return SolcVersion{v[0], v[1], v[2]}, nil
+}
+
+func (v SolcVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func (v SolcVersion) Less(o SolcVersion) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// A range of versions from a solidity version pragma. Versions must be at least min and less than max.
+type versionRange struct {
+	min, max  SolcVersion
+	unbounded bool
+}
+
+func (r versionRange) contains(v SolcVersion) bool {
+	return !v.Less(r.min) && (r.unbounded || v.Less(r.max))
+}
+
+// SolcConstraint is the set of versions allowed by a pragma: any one of a set of ranges,
+// each the intersection of the ranges of its comparators
+type SolcConstraint [][]versionRange
+
+// ParseSolcPragma parses version pragma expressions such as ^0.4.24, >=0.4.22 <0.6.0 or 0.4.24 || 0.4.25
+// with the semantics of npm semver as used by solidity
+func ParseSolcPragma(pragma string) (SolcConstraint, error) {
+	var constraint SolcConstraint
+	for _, alternative := range strings.Split(pragma, "||") {
+		matches := comparatorRegex.FindAllStringSubmatch(alternative, -1)
+		if len(matches) == 0 || strings.TrimSpace(comparatorRegex.ReplaceAllString(alternative, "")) != "" {
+			return nil, fmt.Errorf("could not parse solidity version pragma '%s'", pragma)
+		}
+		var ranges []versionRange
+		for _, m := range matches {
+			ranges = append(ranges, comparatorRange(m[1], m[2], m[3], m[4]))
+		}
+		constraint = append(constraint, ranges)
+	}
+	return constraint, nil
+}
+
+func comparatorRange(op, major, minor, patch string) versionRange {
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	v := SolcVersion{atoi(major), atoi(minor), atoi(patch)}
+	// the version following the most significant part given, for partial versions such as 0.4
+	next := SolcVersion{v.Major + 1, 0, 0}
+	if minor != "" {
+		next = SolcVersion{v.Major, v.Minor + 1, 0}
+	}
+	if patch != "" {
+		next = SolcVersion{v.Major, v.Minor, v.Patch + 1}
+	}
+
+	switch op {
+	case "^":
+		// the left-most non-zero part may not change
+		switch {
+		case v.Major > 0 || minor == "":
+			return versionRange{min: v, max: SolcVersion{v.Major + 1, 0, 0}}
+		case v.Minor > 0 || patch == "":
+			return versionRange{min: v, max: SolcVersion{0, v.Minor + 1, 0}}
+		default:
+			return versionRange{min: v, max: SolcVersion{0, 0, v.Patch + 1}}
+		}
+	case "~":
+		if minor == "" {
+			return versionRange{min: v, max: SolcVersion{v.Major + 1, 0, 0}}
+		}
+		return versionRange{min: v, max: SolcVersion{v.Major, v.Minor + 1, 0}}
+	case ">=":
+		return versionRange{min: v, unbounded: true}
+	case ">":
+		return versionRange{min: next, unbounded: true}
+	case "<=":
+		return versionRange{max: next}
+	case "<":
+		return versionRange{max: v}
+	default:
+		return versionRange{min: v, max: next}
+	}
+}
+
+func (c SolcConstraint) Allows(v SolcVersion) bool {
+	for _, ranges := range c {
+		allowed := true
+		for _, r := range ranges {
+			allowed = allowed && r.contains(v)
+		}
+		if allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// SolcPragmas returns the constraints from the version pragmas of each source. Sources without
+// a pragma are not constrained.
+func SolcPragmas(sources ...[]byte) ([]SolcConstraint, error) {
+	var constraints []SolcConstraint
+	for _, source := range sources {
+		for _, m := range pragmaRegex.FindAllSubmatch(source, -1) {
+			constraint, err := ParseSolcPragma(string(m[1]))
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, constraint)
+		}
+	}
+	return constraints, nil
+}
+
+// Returns the highest version allowed by every constraint
+func highestAllowed(versions []SolcVersion, constraints []SolcConstraint) (SolcVersion, bool) {
+	sort.Slice(versions, func(i, j int) bool { return versions[j].Less(versions[i]) })
+	for _, v := range versions {
+		allowed := true
+		for _, c := range constraints {
+			allowed = allowed && c.Allows(v)
+		}
+		if allowed {
+			return v, true
+		}
+	}
+	return SolcVersion{}, false
+}
+
+// ResolveSolc returns the path to the solc binary that should compile the sources and its version.
+// The override version takes precedence over the version pragmas of the sources. The solc on the PATH
+// is used when there are no pragmas or it satisfies them, otherwise the highest release satisfying
+// them is used. Release binaries are kept under config.SolcBinariesPath and downloaded on demand.
+func ResolveSolc(override string, sources ...[]byte) (string, string, error) {
+	if override != "" {
+		v, err := ParseSolcVersion(override)
+		if err != nil {
+			return "", "", err
+		}
+		binary, err := solcBinary(v)
+		return binary, v.String(), err
+	}
+
+	constraints, err := SolcPragmas(sources...)
+	if err != nil {
+		return "", "", err
+	}
+	local := localSolcVersion()
+	if len(constraints) == 0 {
+		return defaultSolc, local, nil
+	}
+	if v, err := ParseSolcVersion(local); err == nil {
+		if _, ok := highestAllowed([]SolcVersion{v}, constraints); ok {
+			return defaultSolc, local, nil
+		}
+	}
+
+	// prefer releases we already have to going over the network
+	if v, ok := highestAllowed(cachedSolcVersions(), constraints); ok {
+		return solcPath(v), v.String(), nil
+	}
+	releases, err := solcReleases()
+	if err != nil {
+		return "", "", err
+	}
+	var versions []SolcVersion
+	for release := range releases.Releases {
+		if v, err := ParseSolcVersion(release); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	v, ok := highestAllowed(versions, constraints)
+	if !ok {
+		return "", "", fmt.Errorf("no solc release satisfies the version pragmas of the sources")
+	}
+	binary, err := downloadSolc(releases, v)
+	return binary, v.String(), err
+}
+
+func solcPath(v SolcVersion) string {
+	name := "solc"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(config.SolcBinariesPath, v.String(), name)
+}
+
+func solcBinary(v SolcVersion) (string, error) {
+	if _, err := os.Stat(solcPath(v)); err == nil {
+		return solcPath(v), nil
+	}
+	releases, err := solcReleases()
+	if err != nil {
+		return "", err
+	}
+	return downloadSolc(releases, v)
+}
+
+func cachedSolcVersions() []SolcVersion {
+	fileInfos, err := ioutil.ReadDir(config.SolcBinariesPath)
+	if err != nil {
+		return nil
+	}
+	var versions []SolcVersion
+	for _, fileInfo := range fileInfos {
+		v, err := ParseSolcVersion(fileInfo.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(solcPath(v)); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// The version of the solc on the PATH, if we can tell
+func localSolcVersion() string {
+	output, err := runCommand(defaultSolc, "--version")
+	if err != nil {
+		return ""
+	}
+	if m := solcVersionRegex.FindStringSubmatch(output); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+type solcBuild struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+}
+
+type solcReleaseList struct {
+	Builds []solcBuild `json:"builds"`
+	// version -> path of release build
+	Releases map[string]string `json:"releases"`
+}
+
+func solcPlatform() (string, error) {
+	if runtime.GOARCH != "amd64" {
+		return "", fmt.Errorf("solc release binaries are only available for amd64, not %s", runtime.GOARCH)
+	}
+	switch runtime.GOOS {
+	case "linux":
+		return "linux-amd64", nil
+	case "darwin":
+		return "macosx-amd64", nil
+	case "windows":
+		return "windows-amd64", nil
+	default:
+		return "", fmt.Errorf("solc release binaries are not available for %s", runtime.GOOS)
+	}
+}
+
+func solcReleases() (*solcReleaseList, error) {
+	platform, err := solcPlatform()
+	if err != nil {
+		return nil, err
+	}
+	bs, err := httpGet(fmt.Sprintf("%s/%s/list.json", SolcReleasesURL, platform))
+	if err != nil {
+		return nil, fmt.Errorf("could not get list of solc releases: %v", err)
+	}
+	releases := new(solcReleaseList)
+	if err := json.Unmarshal(bs, releases); err != nil {
+		return nil, fmt.Errorf("could not read list of solc releases: %v", err)
+	}
+	return releases, nil
+}
+
+func downloadSolc(releases *solcReleaseList, v SolcVersion) (string, error) {
+	platform, err := solcPlatform()
+	if err != nil {
+		return "", err
+	}
+	buildPath, ok := releases.Releases[v.String()]
+	if !ok {
+		return "", fmt.Errorf("there is no solc %s release for %s", v, platform)
+	}
+	var checksum string
+	for _, build := range releases.Builds {
+		if build.Path == buildPath {
+			checksum = strings.TrimPrefix(build.SHA256, "0x")
+		}
+	}
+	if checksum == "" {
+		return "", fmt.Errorf("solc %s release has no checksum so will not be used", v)
+	}
+
+	log.WithField("version", v).Warn("Downloading solc")
+	bs, err := httpGet(fmt.Sprintf("%s/%s/%s", SolcReleasesURL, platform, buildPath))
+	if err != nil {
+		return "", fmt.Errorf("could not download solc %s: %v", v, err)
+	}
+	hash := sha256.Sum256(bs)
+	if hex.EncodeToString(hash[:]) != strings.ToLower(checksum) {
+		return "", fmt.Errorf("checksum of downloaded solc %s is %x but expected %s", v, hash, checksum)
+	}
+
+	binary := solcPath(v)
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		return "", err
+	}
+	// write then rename so that a partial download is never taken for a release
+	tmp := binary + ".download"
+	if err := ioutil.WriteFile(tmp, bs, 0755); err != nil {
+		return "", err
+	}
+	return binary, os.Rename(tmp, binary)
+}
+
+func httpGet(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,231 @@
+package perform
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/monax/bosmarmot/compilers/definitions"
+)
+
+type VerificationStatus string
+
+const (
+	// The deployed code is that compiled from the recorded source, apart from any libraries linked into it
+	VerificationMatch VerificationStatus = "match"
+	// The recorded source compiles to different code
+	VerificationMismatch VerificationStatus = "mismatch"
+	// The deployed code was compiled with settings, such as a solc minor version, that differ from those recorded
+	VerificationSettingsMismatch VerificationStatus = "settings mismatch"
+)
+
+// A run of bytes that differ between deployed and compiled code, as hex, at an offset into the code without its
+// metadata. One of Deployed or Compiled is short of the other where the code lengths differ.
+type CodeSegment struct {
+	Offset   int
+	Deployed string
+	Compiled string
+}
+
+type Verification struct {
+	Status       VerificationStatus
+	MetadataHash string
+	Contract     string
+	// The file the contract was compiled from
+	File string
+	// Of solc as recorded with the source
+	CompilerVersion string
+	// Of solc as recorded in the deployed code, which solc does from 0.5.9 onwards
+	DeployedCompilerVersion string
+	// Why the settings do not match for a VerificationSettingsMismatch
+	Reason string
+	// Whether the compiled code carries the metadata hash of the deployed code, which it only does when the sources
+	// are identical rather than merely compile to the same code
+	MetadataMatches bool
+	// Library name (or placeholder when the name is not known) to the address found in its place in the deployed code
+	Libraries map[string]string
+	// Empty for a match
+	Mismatches []CodeSegment
+}
+
+// Verifies deployed code against the source recorded under the hash of the metadata it carries, or under
+// metadataHash if given. Deployed code carries no constructor arguments, they are only part of the transaction that
+// created it, so only the metadata solc appends is stripped before comparing it against the code compiled from the
+// recorded source with the recorded settings.
+func VerifyCode(code []byte, metadataHash string) (*Verification, error) {
+	runtime, codeMetadata := SplitMetadata(code)
+	if metadataHash == "" {
+		if codeMetadata == nil {
+			return nil, fmt.Errorf("deployed code carries no metadata hash to find its source by")
+		}
+		metadataHash = codeMetadata.Hash
+	}
+	artifact, err := ReadArtifact(metadataHash)
+	if err != nil {
+		return nil, err
+	}
+	verification := &Verification{
+		MetadataHash:    metadataHash,
+		Contract:        artifact.Contract,
+		File:            artifact.File(),
+		CompilerVersion: artifact.CompilerVersion,
+	}
+	if codeMetadata != nil {
+		verification.DeployedCompilerVersion = codeMetadata.SolcVersion
+	}
+	// compiling with a different compiler could never reproduce the deployed code
+	if reason := settingsMismatch(verification.DeployedCompilerVersion, artifact.CompilerVersion); reason != "" {
+		verification.Status = VerificationSettingsMismatch
+		verification.Reason = reason
+		return verification, nil
+	}
+
+	compiled, linkReferences, err := recompile(artifact)
+	if err != nil {
+		return nil, err
+	}
+	compiledRuntime, compiledMetadata := splitHexMetadata(compiled)
+	verification.MetadataMatches = codeMetadata != nil && compiledMetadata != nil &&
+		compiledMetadata.Hash == codeMetadata.Hash
+	verification.Libraries, verification.Mismatches, err = compareCode(runtime, compiledRuntime, linkReferences)
+	if err != nil {
+		return nil, err
+	}
+	if len(verification.Mismatches) == 0 {
+		verification.Status = VerificationMatch
+	} else {
+		verification.Status = VerificationMismatch
+	}
+	return verification, nil
+}
+
+// Returns why code compiled by solc at deployedVersion cannot be reproduced with solc at recordedVersion, or the empty
+// string if it can be, or it is not known which version compiled the deployed code
+func settingsMismatch(deployedVersion, recordedVersion string) string {
+	deployed, err := ParseSolcVersion(deployedVersion)
+	if err != nil {
+		return ""
+	}
+	recorded, err := ParseSolcVersion(recordedVersion)
+	if err != nil {
+		return ""
+	}
+	if deployed.Major != recorded.Major || deployed.Minor != recorded.Minor {
+		return fmt.Sprintf("deployed code was compiled with solc %s but the source was recorded as compiled with "+
+			"solc %s", deployed, recorded)
+	}
+	return ""
+}
+
+// Compiles the recorded source with the recorded settings returning the hex deployed bytecode of the contract and
+// the libraries it references
+func recompile(artifact *Artifact) (string, []string, error) {
+	input, err := definitions.SolcInputFromMetadata(artifact.Metadata, artifact.Sources)
+	if err != nil {
+		return "", nil, err
+	}
+	compiler, _, err := ResolveSolc(artifact.CompilerVersion)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not find solc %s to compile %s with: %v", artifact.CompilerVersion,
+			artifact.Contract, err)
+	}
+	output, err := runSolc(compiler, input)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, solcError := range output.Errors {
+		if strings.ToLower(solcError.Severity) == "error" {
+			return "", nil, fmt.Errorf("could not compile %s: %s", artifact.Contract, solcError.Message)
+		}
+	}
+	file, name, err := artifact.Metadata.Target()
+	if err != nil {
+		return "", nil, err
+	}
+	contract, ok := output.Contracts[file][name]
+	if !ok {
+		return "", nil, fmt.Errorf("compiling %s did not produce contract %s", artifact.File(), name)
+	}
+	return contract.EVM.DeployedBytecode.Object, contract.EVM.Bytecode.Libraries(), nil
+}
+
+// Compares deployed code against hex compiled code, matching each library placeholder of the compiled code to whatever
+// address is in its place in the deployed code provided a placeholder is always given the same address
+func compareCode(deployed []byte, compiled string, linkReferences []string) (map[string]string, []CodeSegment,
+	error) {
+
+	var compiledCode []byte
+	// offset in compiledCode of the zeroed address standing for each placeholder
+	placeholders := make(map[int]string)
+	for i := 0; i < len(compiled); {
+		if strings.HasPrefix(compiled[i:], "__") && len(compiled)-i >= placeholderLength {
+			placeholders[len(compiledCode)] = compiled[i : i+placeholderLength]
+			compiledCode = append(compiledCode, make([]byte, placeholderLength/2)...)
+			i += placeholderLength
+			continue
+		}
+		if len(compiled)-i < 2 {
+			return nil, nil, fmt.Errorf("compiled code has an odd number of hex characters")
+		}
+		bs, err := hex.DecodeString(compiled[i : i+2])
+		if err != nil {
+			return nil, nil, fmt.Errorf("compiled code is not hex: %v", err)
+		}
+		compiledCode = append(compiledCode, bs[0])
+		i += 2
+	}
+
+	addresses := make(map[string]string)
+	var mismatches []CodeSegment
+	var segment *CodeSegment
+	var deployedRun, compiledRun []byte
+	flush := func() {
+		if segment != nil {
+			segment.Deployed = fmt.Sprintf("%X", deployedRun)
+			segment.Compiled = fmt.Sprintf("%X", compiledRun)
+			mismatches = append(mismatches, *segment)
+			segment, deployedRun, compiledRun = nil, nil, nil
+		}
+	}
+	length := len(deployed)
+	if len(compiledCode) > length {
+		length = len(compiledCode)
+	}
+	for offset := 0; offset < length; offset++ {
+		if placeholder, ok := placeholders[offset]; ok && offset+placeholderLength/2 <= len(deployed) {
+			address := fmt.Sprintf("%X", deployed[offset:offset+placeholderLength/2])
+			if linked, ok := addresses[placeholder]; !ok || linked == address {
+				addresses[placeholder] = address
+				flush()
+				offset += placeholderLength/2 - 1
+				continue
+			}
+		}
+		if offset < len(deployed) && offset < len(compiledCode) && deployed[offset] == compiledCode[offset] {
+			flush()
+			continue
+		}
+		if segment == nil {
+			segment = &CodeSegment{Offset: offset}
+		}
+		if offset < len(deployed) {
+			deployedRun = append(deployedRun, deployed[offset])
+		}
+		if offset < len(compiledCode) {
+			compiledRun = append(compiledRun, compiledCode[offset])
+		}
+	}
+	flush()
+
+	libraries := make(map[string]string, len(addresses))
+	for _, placeholder := range Placeholders(compiled, linkReferences) {
+		if address, ok := addresses[placeholder.Placeholder]; ok {
+			name := placeholder.Library
+			if name == "" {
+				name = placeholder.Placeholder
+			}
+			libraries[name] = address
+		}
+	}
+	return libraries, mismatches, nil
+}
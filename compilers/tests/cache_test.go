@@ -0,0 +1,94 @@
+package compilersTest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/monax/bosmarmot/compilers/perform"
+	"github.com/stretchr/testify/assert"
+)
+
+// Puts a fake solc on the PATH that records each compilation in the returned file
+func fakeSolc(t *testing.T, dir string) string {
+	compilations := filepath.Join(dir, "compilations")
+	script := fmt.Sprintf(`#!/bin/sh
+if [ "$1" = "--version" ]; then
+	echo "Version: 0.4.25+commit.59dbf8f1.Linux.g++"
+	exit 0
+fi
+echo "$@" >> %s
+echo '{"contracts":{"simpleContract.sol":{"c":{"abi":[],"evm":{"bytecode":{"object":"6060"}}}}}}'
+`, compilations)
+	if err := ioutil.WriteFile(filepath.Join(dir, "solc"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return compilations
+}
+
+func countCompilations(compilations string) int {
+	bs, _ := ioutil.ReadFile(compilations)
+	return strings.Count(string(bs), "\n")
+}
+
+func TestCompileCache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake solc is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "compilers-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	compilations := fakeSolc(t, dir)
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir)
+	defer func(path string) { perform.CachePath = path }(perform.CachePath)
+	perform.CachePath = filepath.Join(dir, "cache")
+
+	first, err := perform.RequestCompile("simpleContract.sol", false, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := perform.RequestCompile("simpleContract.sol", false, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countCompilations(compilations) != 1 {
+		t.Errorf("expected unchanged contract to be compiled once but was compiled %v times",
+			countCompilations(compilations))
+	}
+	assert.Equal(t, first, second, "cached output should be identical to compiler output")
+
+	// changing the settings means compiling again
+	if _, err = perform.RequestCompile("simpleContract.sol", true, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if countCompilations(compilations) != 2 {
+		t.Errorf("expected optimized compile to miss the cache")
+	}
+
+	perform.NoCache = true
+	_, err = perform.RequestCompile("simpleContract.sol", false, "", "")
+	perform.NoCache = false
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countCompilations(compilations) != 3 {
+		t.Errorf("expected compile without cache to compile")
+	}
+
+	if err := perform.CleanCache(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = perform.RequestCompile("simpleContract.sol", false, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if countCompilations(compilations) != 4 {
+		t.Errorf("expected compile after cleaning the cache to compile")
+	}
+}
@@ -0,0 +1,81 @@
+package compilersTest
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/monax/bosmarmot/compilers/perform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	libAddress = "00000000000000000000000000000000000000ff"
+	// placeholders for lib.sol:Search
+	legacyPlaceholder = "__lib.sol:Search________________________"
+	hashPlaceholder   = "__$8b956c50d08fb70f74112ab503dcb71311$__"
+)
+
+func TestLinkBytecode(t *testing.T) {
+	for _, placeholder := range []string{legacyPlaceholder, hashPlaceholder} {
+		bytecode := "6060" + placeholder + "6080" + placeholder
+		for _, name := range []string{"Search", "lib.sol:Search"} {
+			linked, err := perform.LinkBytecode(bytecode, map[string]string{name: "0x" + libAddress},
+				[]string{"lib.sol:Search"})
+			require.NoError(t, err)
+			assert.Equal(t, "6060"+libAddress+"6080"+libAddress, linked)
+			assert.NoError(t, perform.CheckLinked("C", linked, nil))
+		}
+	}
+
+	// the legacy placeholders of older compilers hold the library name alone
+	linked, err := perform.LinkBytecode("60__Search"+strings.Repeat("_", 32)+"60",
+		map[string]string{"Search": libAddress}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "60"+libAddress+"60", linked)
+
+	_, err = perform.LinkBytecode("60"+legacyPlaceholder, map[string]string{"Search": "0xff"}, nil)
+	assert.Error(t, err, "library address should be 20 bytes")
+}
+
+func TestCheckLinked(t *testing.T) {
+	bytecode := "6060" + legacyPlaceholder + hashPlaceholder + legacyPlaceholder
+	linked, err := perform.LinkBytecode(bytecode, map[string]string{"Other": libAddress}, []string{"lib.sol:Search"})
+	require.NoError(t, err)
+	assert.Equal(t, bytecode, linked, "unknown libraries are left unlinked")
+
+	err = perform.CheckLinked("C", linked, []string{"lib.sol:Search"})
+	require.IsType(t, perform.ErrUnresolvedLibraries{}, err)
+	assert.Equal(t, []perform.Placeholder{
+		{Placeholder: legacyPlaceholder, Library: "lib.sol:Search"},
+		{Placeholder: hashPlaceholder, Library: "lib.sol:Search"},
+	}, err.(perform.ErrUnresolvedLibraries).Placeholders)
+	assert.True(t, strings.Contains(err.Error(), "C"))
+
+	// hash placeholders of unknown libraries are still reported
+	err = perform.CheckLinked("C", "60"+hashPlaceholder, nil)
+	require.Error(t, err)
+	assert.Equal(t, []perform.Placeholder{{Placeholder: hashPlaceholder}},
+		err.(perform.ErrUnresolvedLibraries).Placeholders)
+}
+
+func TestRequestBinaryLinkage(t *testing.T) {
+	file, err := ioutil.TempFile("", "C.bin")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("6060" + legacyPlaceholder + hashPlaceholder + "\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	// binaries carry no link references so hash placeholders are only recognised by fully qualified name
+	resp, err := perform.RequestBinaryLinkage(file.Name(), "Search:"+libAddress)
+	require.NoError(t, err)
+	assert.Equal(t, "", resp.Error)
+	assert.Equal(t, "6060"+libAddress+hashPlaceholder, resp.Binary)
+
+	resp, err = perform.RequestBinaryLinkage(file.Name(), "lib.sol:Search:"+libAddress)
+	require.NoError(t, err)
+	assert.Equal(t, "6060"+libAddress+libAddress, resp.Binary)
+}
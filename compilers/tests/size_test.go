@@ -0,0 +1,99 @@
+package compilersTest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/monax/bosmarmot/compilers/perform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeSize(t *testing.T) {
+	size, err := perform.CodeSize("0x6060604052")
+	require.NoError(t, err)
+	assert.Equal(t, 5, size)
+
+	// placeholders stand for 20 byte addresses however often they appear
+	for _, placeholder := range []string{legacyPlaceholder, hashPlaceholder} {
+		size, err = perform.CodeSize("6060" + placeholder + "6080" + placeholder)
+		require.NoError(t, err)
+		assert.Equal(t, 44, size)
+	}
+
+	_, err = perform.CodeSize("6060__truncated")
+	assert.Error(t, err)
+}
+
+func TestOversizeContracts(t *testing.T) {
+	limit := strings.Repeat("00", perform.MaxDeployedCodeSize)
+	resp := &perform.Response{Objects: []perform.ResponseItem{
+		{Objectname: "AtLimit", Bytecode: limit + "00", DeployedBytecode: limit},
+		{Objectname: "Over", DeployedBytecode: limit[:len(limit)-len(legacyPlaceholder)] + legacyPlaceholder + "0000"},
+	}}
+	oversize, err := perform.OversizeContracts(resp)
+	require.NoError(t, err)
+	assert.Equal(t, []perform.OversizeContract{{Objectname: "Over", Size: perform.MaxDeployedCodeSize + 2}},
+		oversize, "only deployed bytecode should count against the limit")
+	assert.Equal(t, 2, oversize[0].Delta())
+}
+
+// Puts a fake solc on the PATH whose deployed bytecode is over the limit unless the optimizer is enabled
+func fakeOversizeSolc(t *testing.T, dir string) {
+	script := fmt.Sprintf(`#!/bin/sh
+if [ "$1" = "--version" ]; then
+	echo "Version: 0.4.25+commit.59dbf8f1.Linux.g++"
+	exit 0
+fi
+size=%d
+if grep -q '"enabled":true'; then
+	size=%d
+fi
+code=$(head -c $size /dev/zero | od -An -v -tx1 | tr -d ' \n')
+echo '{"contracts":{"simpleContract.sol":{"c":{"abi":[],"evm":{"bytecode":{"object":"6060"},"deployedBytecode":{"object":"'$code'"}}}}}}'
+`, perform.MaxDeployedCodeSize+10, perform.MaxDeployedCodeSize-10)
+	if err := ioutil.WriteFile(filepath.Join(dir, "solc"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestCompileOversize(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake solc is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "compilers-size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fakeOversizeSolc(t, dir)
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	defer func(path string) { perform.CachePath = path }(perform.CachePath)
+	perform.CachePath = filepath.Join(dir, "cache")
+
+	_, err = perform.RequestCompile("simpleContract.sol", false, "", "")
+	require.Error(t, err)
+	oversizeErr, ok := err.(perform.ErrOversizeContracts)
+	require.True(t, ok, "expected ErrOversizeContracts but got %v", err)
+	assert.Equal(t, []perform.OversizeContract{{
+		Objectname:    "c",
+		Size:          perform.MaxDeployedCodeSize + 10,
+		OptimizedSize: perform.MaxDeployedCodeSize - 10,
+	}}, oversizeErr.Contracts)
+	assert.Contains(t, err.Error(), "c is 24586 bytes, 10 over the limit")
+	assert.Contains(t, err.Error(), "with the optimizer enabled")
+
+	_, err = perform.RequestCompile("simpleContract.sol", true, "", "")
+	assert.NoError(t, err, "optimized contract is under the limit")
+
+	perform.AllowOversize = true
+	_, err = perform.RequestCompile("simpleContract.sol", false, "", "")
+	perform.AllowOversize = false
+	assert.NoError(t, err, "oversize contracts should only be warned about")
+}
@@ -0,0 +1,162 @@
+package compilersTest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/monax/bosmarmot/compilers/perform"
+	"github.com/monax/bosmarmot/monax/config"
+)
+
+func TestSolcPragma(t *testing.T) {
+	tests := []struct {
+		pragma  string
+		version string
+		allowed bool
+	}{
+		{"^0.4.24", "0.4.24", true},
+		{"^0.4.24", "0.4.26", true},
+		{"^0.4.24", "0.4.23", false},
+		{"^0.4.24", "0.5.0", false},
+		{"~0.4.24", "0.4.99", true},
+		{">=0.4.22 <0.6.0", "0.5.17", true},
+		{">=0.4.22 <0.6.0", "0.6.0", false},
+		{"0.4.24 || 0.4.25", "0.4.25", true},
+		{"0.4.24 || 0.4.25", "0.4.26", false},
+		{"0.4", "0.4.10", true},
+		{">0.4.24", "0.4.24", false},
+		{"<=0.4.24", "0.4.24", true},
+	}
+	for _, tt := range tests {
+		constraint, err := perform.ParseSolcPragma(tt.pragma)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := perform.ParseSolcVersion(tt.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if constraint.Allows(v) != tt.allowed {
+			t.Errorf("expected pragma %s allowing %s to be %v", tt.pragma, tt.version, tt.allowed)
+		}
+	}
+
+	if _, err := perform.ParseSolcPragma("latest"); err == nil {
+		t.Errorf("expected unparsable pragma to fail")
+	}
+
+	constraints, err := perform.SolcPragmas([]byte("pragma solidity ^0.4.0;\ncontract c {}"), []byte("contract d {}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(constraints) != 1 {
+		t.Errorf("expected a constraint from the one source with a pragma but got %v", len(constraints))
+	}
+}
+
+// serves a solc release list with a fake solc binary for each of the versions
+func solcReleaseServer(binary []byte, checksum string, versions ...string) *httptest.Server {
+	platform := map[string]string{"linux": "linux-amd64", "darwin": "macosx-amd64", "windows": "windows-amd64"}[runtime.GOOS]
+	mux := http.NewServeMux()
+	var builds, releases string
+	for i, version := range versions {
+		path := "solc-v" + version
+		if i > 0 {
+			builds += ","
+			releases += ","
+		}
+		builds += fmt.Sprintf(`{"path": "%s", "version": "%s", "sha256": "0x%s"}`, path, version, checksum)
+		releases += fmt.Sprintf(`"%s": "%s"`, version, path)
+		mux.HandleFunc("/"+platform+"/"+path, func(w http.ResponseWriter, r *http.Request) {
+			w.Write(binary)
+		})
+	}
+	mux.HandleFunc("/"+platform+"/list.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"builds": [%s], "releases": {%s}}`, builds, releases)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestResolveSolc(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("solc release binaries are only available for amd64")
+	}
+	dir, err := ioutil.TempDir("", "solc-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path, url string) {
+		config.SolcBinariesPath, perform.SolcReleasesURL = path, url
+	}(config.SolcBinariesPath, perform.SolcReleasesURL)
+	config.SolcBinariesPath = dir
+	// so that any solc installed locally is not used in place of a release
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", "")
+
+	binary := []byte("#!/bin/sh\n")
+	checksum := sha256.Sum256(binary)
+	server := solcReleaseServer(binary, fmt.Sprintf("%x", checksum), "0.4.23", "0.4.24", "0.4.25", "0.5.0")
+	defer server.Close()
+	perform.SolcReleasesURL = server.URL
+
+	// the pragma of the source is resolved to the highest release satisfying it
+	solc, version, err := perform.ResolveSolc("", []byte("pragma solidity >=0.4.22 <0.4.25;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "0.4.24" || solc != filepath.Join(dir, "0.4.24", filepath.Base(solc)) {
+		t.Errorf("expected downloaded solc 0.4.24 but got %s at %s", version, solc)
+	}
+	downloaded, err := ioutil.ReadFile(solc)
+	if err != nil || string(downloaded) != string(binary) {
+		t.Errorf("expected downloaded solc binary at %s: %v", solc, err)
+	}
+
+	// the override takes precedence
+	_, version, err = perform.ResolveSolc("0.4.25", []byte("pragma solidity <0.4.25;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "0.4.25" {
+		t.Errorf("expected override version 0.4.25 but got %s", version)
+	}
+
+	if _, _, err = perform.ResolveSolc("", []byte("pragma solidity ^0.6.0;")); err == nil {
+		t.Errorf("expected pragma without matching release to fail")
+	}
+}
+
+func TestResolveSolcChecksum(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("solc release binaries are only available for amd64")
+	}
+	dir, err := ioutil.TempDir("", "solc-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path, url string) {
+		config.SolcBinariesPath, perform.SolcReleasesURL = path, url
+	}(config.SolcBinariesPath, perform.SolcReleasesURL)
+	config.SolcBinariesPath = dir
+
+	checksum := sha256.Sum256([]byte("the real solc"))
+	server := solcReleaseServer([]byte("something else"), fmt.Sprintf("%x", checksum), "0.4.25")
+	defer server.Close()
+	perform.SolcReleasesURL = server.URL
+
+	if _, _, err := perform.ResolveSolc("0.4.25"); err == nil {
+		t.Fatal("expected solc with wrong checksum to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "0.4.25")); err == nil {
+		t.Errorf("solc with wrong checksum should not be kept")
+	}
+}
@@ -0,0 +1,131 @@
+package compilersTest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/monax/bosmarmot/compilers/definitions"
+	"github.com/monax/bosmarmot/compilers/perform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const simpleContractSource = "27fbf28c5dfb221f98526c587c5762cdf4025e85809c71ba871caa2ca42a9d85.sol"
+
+// Puts a fake solc on the PATH that saves its standard-json input and replies with output
+func fakeStandardJSONSolc(t *testing.T, dir string, output string) string {
+	input := filepath.Join(dir, "input.json")
+	outputFile := filepath.Join(dir, "output.json")
+	if err := ioutil.WriteFile(outputFile, []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+if [ "$1" = "--version" ]; then
+	echo "Version: 0.4.25+commit.59dbf8f1.Linux.g++"
+	exit 0
+fi
+cat > %s
+cat %s
+`, input, outputFile)
+	if err := ioutil.WriteFile(filepath.Join(dir, "solc"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return input
+}
+
+func compileStandardJSON(t *testing.T, output string) (*perform.Response, *definitions.SolcInput) {
+	dir, err := ioutil.TempDir("", "compilers-standard-json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input := fakeStandardJSONSolc(t, dir, output)
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	perform.NoCache = true
+	defer func() { perform.NoCache = false }()
+
+	resp, err := perform.RequestCompile("simpleContract.sol", true, "lib:00000000000000000000000000000000000000ff", "")
+	require.NoError(t, err)
+	bs, err := ioutil.ReadFile(input)
+	require.NoError(t, err)
+	solcInput := new(definitions.SolcInput)
+	require.NoError(t, json.Unmarshal(bs, solcInput))
+	return resp, solcInput
+}
+
+func TestStandardJSONInput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake solc is a shell script")
+	}
+	defer func() { perform.Remappings = nil }()
+	perform.Remappings = []string{"zeppelin/=lib/zeppelin/"}
+
+	_, input := compileStandardJSON(t, `{"contracts":{}}`)
+	assert.Equal(t, "Solidity", input.Language)
+	require.Contains(t, input.Sources, simpleContractSource)
+	code, err := ioutil.ReadFile("simpleContract.sol")
+	require.NoError(t, err)
+	assert.Equal(t, string(code), input.Sources[simpleContractSource].Content)
+	assert.Equal(t, definitions.SolcOptimizer{Enabled: true, Runs: definitions.DefaultOptimizerRuns},
+		input.Settings.Optimizer)
+	assert.Equal(t, []string{"zeppelin/=lib/zeppelin/"}, input.Settings.Remappings)
+	assert.Equal(t, definitions.SolcOutputs, input.Settings.OutputSelection["*"]["*"])
+}
+
+func TestStandardJSONDiagnostics(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake solc is a shell script")
+	}
+	code, err := ioutil.ReadFile("simpleContract.sol")
+	require.NoError(t, err)
+	start := strings.Index(string(code), "function")
+
+	// warnings are not fatal
+	resp, _ := compileStandardJSON(t, fmt.Sprintf(`{
+"errors":[{"sourceLocation":{"file":"%[1]s","start":%[2]d,"end":%[3]d},"type":"Warning","severity":"warning",
+  "message":"No visibility specified.","formattedMessage":"%[1]s:4:5: Warning: No visibility specified.\n    function f() {\n    ^\n"}],
+"contracts":{"%[1]s":{"c":{"abi":[{"type":"function", "name":"f"}],"metadata":"{\"compiler\":{\"version\":\"0.4.25+commit.59dbf8f1\"}}",
+  "evm":{"bytecode":{"object":"6060"},"deployedBytecode":{"object":"6080"}}}}}}`, simpleContractSource, start, start+8))
+	assert.Equal(t, "", resp.Error)
+	require.Len(t, resp.Diagnostics, 1)
+	assert.Equal(t, &perform.Diagnostic{
+		File:             "simpleContract.sol",
+		Line:             4,
+		Severity:         "warning",
+		Message:          "No visibility specified.",
+		FormattedMessage: "simpleContract.sol:4:5: Warning: No visibility specified.\n    function f() {\n    ^",
+	}, resp.Diagnostics[0])
+	assert.Equal(t, resp.Diagnostics[0].FormattedMessage, resp.Warning)
+	assert.Equal(t, []perform.ResponseItem{{
+		Objectname:       "c",
+		Bytecode:         "6060",
+		DeployedBytecode: "6080",
+		ABI:              `[{"type":"function","name":"f"}]`,
+		Metadata:         `{"compiler":{"version":"0.4.25+commit.59dbf8f1"}}`,
+		Version:          "0.4.25",
+	}}, resp.Objects)
+
+	// libraries are linked after compiling
+	resp, _ = compileStandardJSON(t, `{"contracts":{"a.sol":{"c":{"abi":[],
+  "evm":{"bytecode":{"object":"6060__$9818331177476d7eb23f64d80afec7b49a$__","linkReferences":{"lib.sol":{"lib":[{"start":2,"length":20}]}}}}}}}}`)
+	require.Len(t, resp.Objects, 1)
+	assert.Equal(t, []string{"lib.sol:lib"}, resp.Objects[0].LinkReferences)
+	assert.Equal(t, "606000000000000000000000000000000000000000ff", resp.Objects[0].Bytecode)
+
+	// errors abort with the message and source excerpt
+	resp, _ = compileStandardJSON(t, fmt.Sprintf(`{
+"errors":[{"sourceLocation":{"file":"%[1]s","start":%[2]d,"end":%[3]d},"type":"TypeError","severity":"error",
+  "message":"Undeclared identifier.","formattedMessage":"%[1]s:4:5: TypeError: Undeclared identifier.\n    function f() {\n    ^\n"}]}`,
+		simpleContractSource, start, start+8))
+	assert.Equal(t, "simpleContract.sol:4:5: TypeError: Undeclared identifier.\n    function f() {\n    ^", resp.Error)
+	require.Len(t, resp.Diagnostics, 1)
+	assert.Equal(t, "error", resp.Diagnostics[0].Severity)
+	assert.Equal(t, 4, resp.Diagnostics[0].Line)
+}
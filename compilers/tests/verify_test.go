@@ -0,0 +1,125 @@
+package compilersTest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/monax/bosmarmot/compilers/definitions"
+	"github.com/monax/bosmarmot/compilers/perform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The metadata solc 0.4 appends to bytecode: {"bzzr0": hash}
+func bzzr0Metadata(hash []byte) []byte {
+	metadata := append([]byte{0xa1, 0x65}, "bzzr0"...)
+	metadata = append(metadata, 0x58, 0x20)
+	metadata = append(metadata, hash...)
+	return append(metadata, 0x00, byte(len(metadata)))
+}
+
+// The metadata solc 0.5.9 onwards appends to bytecode: {"ipfs": hash, "solc": version}
+func ipfsMetadata(hash []byte, major, minor, patch byte) []byte {
+	metadata := append([]byte{0xa2, 0x64}, "ipfs"...)
+	metadata = append(metadata, 0x58, byte(len(hash)))
+	metadata = append(metadata, hash...)
+	metadata = append(metadata, 0x64)
+	metadata = append(metadata, "solc"...)
+	metadata = append(metadata, 0x43, major, minor, patch)
+	return append(metadata, 0x00, byte(len(metadata)))
+}
+
+func TestSplitMetadata(t *testing.T) {
+	code := []byte{0x60, 0x80, 0x60, 0x40, 0x52, 0x00}
+	hash := bytes.Repeat([]byte{0xab}, 32)
+
+	runtime, metadata := perform.SplitMetadata(append(code, bzzr0Metadata(hash)...))
+	require.NotNil(t, metadata)
+	assert.Equal(t, code, runtime)
+	assert.Equal(t, hex.EncodeToString(hash), metadata.Hash)
+	assert.Equal(t, "", metadata.SolcVersion)
+
+	ipfsHash := append([]byte{0x12, 0x20}, hash...)
+	runtime, metadata = perform.SplitMetadata(append(code, ipfsMetadata(ipfsHash, 0, 5, 9)...))
+	require.NotNil(t, metadata)
+	assert.Equal(t, code, runtime)
+	assert.Equal(t, hex.EncodeToString(ipfsHash), metadata.Hash)
+	assert.Equal(t, "0.5.9", metadata.SolcVersion)
+
+	// code that happens to end in something that could be a length but is not followed by metadata
+	runtime, metadata = perform.SplitMetadata(append(code, 0x00, 0x03))
+	assert.Nil(t, metadata)
+	assert.Equal(t, append(code, 0x00, 0x03), runtime)
+}
+
+// Saves an artifact as compiling would returning the deployed code it is for
+func saveTestArtifact(t *testing.T, solcVersion string) []byte {
+	hash := bytes.Repeat([]byte{0xcd}, 32)
+	code := append([]byte{0x60, 0x80, 0x60, 0x40}, bzzr0Metadata(hash)...)
+	source := []byte("pragma solidity ^0.4.24;\ncontract Foo {}\n")
+	req := &definitions.Request{
+		Language:        definitions.SOLIDITY,
+		Includes:        map[string]*definitions.IncludedFiles{"abc.sol": {Script: source}},
+		FileReplacement: map[string]string{"abc.sol": "contracts/Foo.sol"},
+	}
+	resp := &perform.Response{Objects: []perform.ResponseItem{{
+		Objectname:       "Foo",
+		DeployedBytecode: hex.EncodeToString(code),
+		Metadata: `{"language":"Solidity","compiler":{"version":"` + solcVersion + `+commit.e67f0147"},` +
+			`"sources":{"abc.sol":{"keccak256":"0x01"}},` +
+			`"settings":{"compilationTarget":{"abc.sol":"Foo"},"optimizer":{"enabled":true,"runs":200}}}`,
+	}, {
+		// abstract contracts have no code to save metadata for
+		Objectname: "Abstract",
+		Metadata:   `{"language":"Solidity"}`,
+	}}}
+	require.NoError(t, perform.SaveArtifacts(req, resp))
+	return code
+}
+
+func TestSaveArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	perform.ArtifactsPath = dir
+
+	saveTestArtifact(t, "0.4.24")
+	artifact, err := perform.ReadArtifact(hex.EncodeToString(bytes.Repeat([]byte{0xcd}, 32)))
+	require.NoError(t, err)
+	assert.Equal(t, "Foo", artifact.Contract)
+	assert.Equal(t, "0.4.24", artifact.CompilerVersion)
+	assert.Equal(t, "contracts/Foo.sol", artifact.File())
+	assert.Equal(t, "pragma solidity ^0.4.24;\ncontract Foo {}\n", string(artifact.Sources["abc.sol"]))
+	assert.True(t, artifact.Metadata.Settings.Optimizer.Enabled)
+
+	input, err := definitions.SolcInputFromMetadata(artifact.Metadata, artifact.Sources)
+	require.NoError(t, err)
+	assert.Equal(t, 200, input.Settings.Optimizer.Runs)
+	assert.Contains(t, input.Settings.OutputSelection, "abc.sol")
+
+	_, err = perform.ReadArtifact("00")
+	assert.Error(t, err)
+}
+
+func TestVerifySettingsMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	perform.ArtifactsPath = dir
+
+	saveTestArtifact(t, "0.4.24")
+	// deployed by solc 0.5.9 but claiming the source recorded as compiled with 0.4.24
+	hash := hex.EncodeToString(bytes.Repeat([]byte{0xcd}, 32))
+	deployed := append([]byte{0x60, 0x80}, ipfsMetadata(bytes.Repeat([]byte{0xee}, 34), 0, 5, 9)...)
+	verification, err := perform.VerifyCode(deployed, hash)
+	require.NoError(t, err)
+	assert.Equal(t, perform.VerificationSettingsMismatch, verification.Status)
+	assert.Equal(t, "0.5.9", verification.DeployedCompilerVersion)
+	assert.Equal(t, "Foo", verification.Contract)
+
+	_, err = perform.VerifyCode([]byte{0x60, 0x80}, "")
+	assert.Error(t, err, "code without metadata cannot be matched to a source")
+}
@@ -50,4 +50,97 @@ func BenchmarkSha3(b *testing.B) {
 	fmt.Println(amount, ":", time.Since(start))
 }
 
-// TODO: we should add sig tests too
+// Keys made from fixed private keys, signed with in both the round trip tests and the malformed input tests so that
+// verification and recovery are checked against signatures from the signing path
+var signatureVectors = []struct {
+	typ  KeyType
+	priv string
+}{
+	{KeyType{CurveTypeSecp256k1, AddrTypeSha3}, "c87509a1c067bbde78beb793e6fa76530b6382a4c0241e5e4a9ec0a0f44dc0d3"},
+	{KeyType{CurveTypeSecp256k1, AddrTypeRipemd160Sha256}, "0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f0"},
+	{KeyType{CurveTypeEd25519, AddrTypeRipemd160}, "8a5c3b1d0e2f4a6b8c9d7e5f3a1b2c4d6e8f0a1b3c5d7e9f2a4b6c8d0e1f3a5b"},
+}
+
+func signVector(t *testing.T, i int) (*Key, []byte, []byte, []byte) {
+	vector := signatureVectors[i]
+	priv, _ := hex.DecodeString(vector.priv)
+	key, err := NewKeyFromPriv(vector.typ, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := key.Pubkey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := Sha3([]byte(fmt.Sprintf("signature vector %d", i)))
+	sig, err := key.Sign(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pub, hash, sig
+}
+
+func TestSignVerifyRecover(t *testing.T) {
+	for i, vector := range signatureVectors {
+		key, pub, hash, sig := signVector(t, i)
+		ok, err := Verify(vector.typ.CurveType, hash, sig, pub)
+		if err != nil || !ok {
+			t.Errorf("signature of %s key failed to verify: %v", vector.typ, err)
+		}
+		ok, err = Verify(vector.typ.CurveType, Sha3(hash), sig, pub)
+		if err != nil || ok {
+			t.Errorf("signature of %s key verified against another hash: %v", vector.typ, err)
+		}
+
+		recovered, err := Recover(vector.typ, hash, sig)
+		if vector.typ.CurveType != CurveTypeSecp256k1 {
+			if err == nil {
+				t.Errorf("expected recovery from %s signature to fail", vector.typ)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, recoveredKey := range recovered {
+			if bytes.Equal(recoveredKey.PubKey, pub) {
+				found = bytes.Equal(recoveredKey.Address, key.Address)
+			}
+		}
+		if !found {
+			t.Errorf("expected key %X with address %X of type %s among those recovered, got %v", pub,
+				key.Address, vector.typ, recovered)
+		}
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	for i, vector := range signatureVectors {
+		_, pub, hash, sig := signVector(t, i)
+		curve := vector.typ.CurveType
+		for _, malformed := range [][]byte{nil, sig[:len(sig)-1], append(append([]byte{}, sig...), 0)} {
+			if _, err := Verify(curve, hash, malformed, pub); err == nil {
+				t.Errorf("expected malformed %s signature %X to be an error", vector.typ, malformed)
+			} else if _, ok := err.(InvalidSignatureErr); !ok {
+				t.Errorf("expected InvalidSignatureErr for %s signature %X but got %v", vector.typ, malformed, err)
+			}
+		}
+		for _, malformed := range [][]byte{nil, pub[1:], make([]byte, len(pub))} {
+			if curve == CurveTypeEd25519 && len(malformed) == len(pub) {
+				// All 32 byte strings are read as ed25519 keys
+				continue
+			}
+			if _, err := Verify(curve, hash, sig, malformed); err == nil {
+				t.Errorf("expected malformed %s public key %X to be an error", vector.typ, malformed)
+			} else if _, ok := err.(InvalidPubKeyErr); !ok {
+				t.Errorf("expected InvalidPubKeyErr for %s public key %X but got %v", vector.typ, malformed, err)
+			}
+		}
+		if curve == CurveTypeSecp256k1 {
+			if _, err := Recover(vector.typ, hash, sig[:len(sig)-1]); err == nil {
+				t.Errorf("expected recovery from malformed %s signature to fail", vector.typ)
+			}
+		}
+	}
+}
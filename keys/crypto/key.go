@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/monax/bosmarmot/keys/crypto/helpers"
 	"github.com/monax/bosmarmot/keys/crypto/randentropy"
 	"github.com/tendermint/ed25519"
@@ -46,6 +47,19 @@ func (err NoPrivateKeyErr) Error() string {
 	return fmt.Sprintf("Private key is not available or is encrypted")
 }
 
+// Returned for a signature that cannot be read for the curve, as opposed to one that is read but does not verify
+type InvalidSignatureErr string
+
+func (err InvalidSignatureErr) Error() string {
+	return fmt.Sprintf("invalid signature: %s", string(err))
+}
+
+type InvalidPubKeyErr string
+
+func (err InvalidPubKeyErr) Error() string {
+	return fmt.Sprintf("invalid public key: %s", string(err))
+}
+
 type KeyType struct {
 	CurveType CurveType
 	AddrType  AddrType
@@ -217,6 +231,22 @@ func Verify(curveType CurveType, hash, sig, pub []byte) (bool, error) {
 	return false, InvalidCurveErr(curveType)
 }
 
+// A public key recovered from a signature and the address it has for the key type
+type RecoveredKey struct {
+	PubKey  []byte
+	Address []byte
+}
+
+// Recovers the public keys that could have made sig over hash, which is only possible for secp256k1. The DER
+// signatures made by Sign carry no recovery id so there may be two keys that verify, the one that signed being
+// picked out by the caller from its address.
+func Recover(typ KeyType, hash, sig []byte) ([]RecoveredKey, error) {
+	if typ.CurveType != CurveTypeSecp256k1 {
+		return nil, fmt.Errorf("cannot recover a public key from a signature for curve type %v", typ.CurveType)
+	}
+	return recoverSecp256k1(typ.AddrType, hash, sig)
+}
+
 //-----------------------------------------------------------------------------
 // json encodings
 
@@ -233,6 +263,16 @@ type cipherJSON struct {
 	Salt       []byte
 	Nonce      []byte
 	CipherText []byte
+	// Empty for keys encrypted before the kdf was recorded, which all used scrypt
+	KDF       string         `json:",omitempty"`
+	KDFParams *kdfParamsJSON `json:",omitempty"`
+}
+
+// Parameters of the argon2id kdf
+type kdfParamsJSON struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
 }
 
 type encryptedKeyJSON struct {
@@ -297,6 +337,28 @@ func IsValidKeyJson(j []byte) []byte {
 	return nil
 }
 
+// Returns the address and type recorded in a plain or encrypted key file and whether it is encrypted, without
+// decoding any private key material
+func KeyFileInfo(j []byte) (address []byte, typ KeyType, encrypted bool, err error) {
+	keyJSON := new(encryptedKeyJSON)
+	if err = json.Unmarshal(j, keyJSON); err != nil {
+		return
+	}
+	if keyJSON.Address == "" {
+		err = fmt.Errorf("key file has no address")
+		return
+	}
+	if address, err = hex.DecodeString(keyJSON.Address); err != nil {
+		err = fmt.Errorf("key file address %s is not valid hex: %v", keyJSON.Address, err)
+		return
+	}
+	if typ, err = KeyTypeFromString(keyJSON.Type); err != nil {
+		return
+	}
+	encrypted = len(keyJSON.Crypto.CipherText) > 0
+	return
+}
+
 //-----------------------------------------------------------------------------
 // main utility functions for each key type (new, pub, sign, verify)
 // TODO: run all sorts of length and validity checks
@@ -311,20 +373,9 @@ func newKeySecp256k1(addrType AddrType, privKey crypto.PrivKeySecp256k1) (*Key,
 		return nil, fmt.Errorf("unwrapped PubKey does not appear to be secp246k1")
 	}
 
-	privKey.PubKey().Address()
-
-	var address []byte
-	switch addrType {
-	case AddrTypeRipemd160:
-		// let tendermint/binary handle because
-		// it encodes the type byte ...
-		address = pubKey.Address()
-	case AddrTypeRipemd160Sha256:
-		address = Ripemd160(Sha256(pubKey[:]))
-	case AddrTypeSha3:
-		address = Sha3(pubKey[1:])[12:]
-	default:
-		return nil, fmt.Errorf("address type %v not recognised", addrType)
+	address, err := addressSecp256k1(addrType, pubKey)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Key{
@@ -335,6 +386,20 @@ func newKeySecp256k1(addrType AddrType, privKey crypto.PrivKeySecp256k1) (*Key,
 	}, nil
 }
 
+func addressSecp256k1(addrType AddrType, pubKey crypto.PubKeySecp256k1) ([]byte, error) {
+	switch addrType {
+	case AddrTypeRipemd160:
+		// let tendermint/binary handle because
+		// it encodes the type byte ...
+		return pubKey.Address(), nil
+	case AddrTypeRipemd160Sha256:
+		return Ripemd160(Sha256(pubKey[:])), nil
+	case AddrTypeSha3:
+		return Sha3(pubKey[1:])[12:], nil
+	}
+	return nil, fmt.Errorf("address type %v not recognised", addrType)
+}
+
 func newKeyEd25519(addrType AddrType) *Key {
 	randBytes := randentropy.GetEntropyMixed(32)
 	key, _ := keyFromPrivEd25519(addrType, randBytes)
@@ -402,11 +467,85 @@ func signEd25519(k *Key, hash []byte) ([]byte, error) {
 
 func verifySigSecp256k1(hash, sig, pubOG []byte) (bool, error) {
 	var pubKey crypto.PubKeySecp256k1
+	if len(pubOG) != len(pubKey) {
+		return false, InvalidPubKeyErr(fmt.Sprintf("secp256k1 public keys are %d compressed bytes, got %d",
+			len(pubKey), len(pubOG)))
+	}
+	if _, err := btcec.ParsePubKey(pubOG, btcec.S256()); err != nil {
+		return false, InvalidPubKeyErr(err.Error())
+	}
+	if _, err := parseDERSignature(sig); err != nil {
+		return false, err
+	}
 	copy(pubKey[:], pubOG)
 	return pubKey.VerifyBytes(hash, crypto.SignatureSecp256k1(sig).Wrap()), nil
 }
 
+// Parses a DER encoded secp256k1 signature, rejecting any bytes after it which btcec ignores
+func parseDERSignature(sig []byte) (*btcec.Signature, error) {
+	if len(sig) < 2 || int(sig[1])+2 != len(sig) {
+		return nil, InvalidSignatureErr(fmt.Sprintf("%X is not a DER encoded signature", sig))
+	}
+	signature, err := btcec.ParseDERSignature(sig, btcec.S256())
+	if err != nil {
+		return nil, InvalidSignatureErr(err.Error())
+	}
+	return signature, nil
+}
+
+// Tries each recovery id against the r and s of a DER signature, keeping the distinct keys that verify
+func recoverSecp256k1(addrType AddrType, hash, sig []byte) ([]RecoveredKey, error) {
+	signature, err := parseDERSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	// go-crypto signs the sha256 of what it is given
+	digest := Sha256(hash)
+	compact := make([]byte, 65)
+	rBytes, sBytes := signature.R.Bytes(), signature.S.Bytes()
+	copy(compact[33-len(rBytes):33], rBytes)
+	copy(compact[65-len(sBytes):], sBytes)
+	var recovered []RecoveredKey
+	for id := byte(0); id < 4; id++ {
+		// 27 marks a compact signature and 4 a compressed key
+		compact[0] = 27 + 4 + id
+		pub, _, err := btcec.RecoverCompact(btcec.S256(), compact, digest)
+		if err != nil || !signature.Verify(digest, pub) {
+			continue
+		}
+		var pubKey crypto.PubKeySecp256k1
+		copy(pubKey[:], pub.SerializeCompressed())
+		if containsPubKey(recovered, pubKey[:]) {
+			continue
+		}
+		address, err := addressSecp256k1(addrType, pubKey)
+		if err != nil {
+			return nil, err
+		}
+		recovered = append(recovered, RecoveredKey{PubKey: pubKey[:], Address: address})
+	}
+	if len(recovered) == 0 {
+		return nil, InvalidSignatureErr("no public key can be recovered from signature")
+	}
+	return recovered, nil
+}
+
+func containsPubKey(recovered []RecoveredKey, pub []byte) bool {
+	for _, key := range recovered {
+		if string(key.PubKey) == string(pub) {
+			return true
+		}
+	}
+	return false
+}
+
 func verifySigEd25519(hash, sig, pub []byte) (bool, error) {
+	if len(pub) != 32 {
+		return false, InvalidPubKeyErr(fmt.Sprintf("ed25519 public keys are 32 bytes, got %d", len(pub)))
+	}
+	if len(sig) != 64 {
+		return false, InvalidSignatureErr(fmt.Sprintf("ed25519 signatures are 64 bytes, got %d", len(sig)))
+	}
 	pubKeyBytes := new([32]byte)
 	copy(pubKeyBytes[:], pub)
 	sigBytes := new([64]byte)
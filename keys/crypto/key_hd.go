@@ -0,0 +1,206 @@
+/*
+
+Deterministic derivation of keys from BIP39 mnemonics [1].
+
+1. The mnemonic is normalised to NFKD and stretched into a 64 byte seed with
+   PBKDF2-HMAC-SHA512 using the salt "mnemonic" + passphrase and 2048 rounds.
+   Only the number of words is checked, the mnemonic checksum is not verified
+   since that requires the BIP39 word list.
+2. secp256k1 keys are derived from the seed along a BIP32 path [2] such as the
+   BIP44 [3] path m/44'/60'/0'/0/0 used by Ethereum wallets.
+3. ed25519 keys are derived along a SLIP-0010 path [4], which only supports
+   hardened indices.
+
+References:
+
+1. https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki
+2. https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki
+3. https://github.com/bitcoin/bips/blob/master/bip-0044.mediawiki
+4. https://github.com/satoshilabs/slips/blob/master/slip-0010.md
+
+*/
+
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// Indices at or above this are hardened, written with a trailing ' in paths
+	HardenedKeyStart uint32 = 0x80000000
+
+	DefaultHDPathSecp256k1 = "m/44'/60'/0'/0/0"
+	DefaultHDPathEd25519   = "m/44'/60'/0'/0'/0'"
+
+	mnemonicRounds = 2048
+)
+
+var (
+	secp256k1MasterKey = []byte("Bitcoin seed")
+	ed25519MasterKey   = []byte("ed25519 seed")
+)
+
+func DefaultHDPath(curveType CurveType) string {
+	if curveType == CurveTypeEd25519 {
+		return DefaultHDPathEd25519
+	}
+	return DefaultHDPathSecp256k1
+}
+
+// NewKeyFromMnemonic derives the key of type typ at the derivation path from a BIP39 mnemonic.
+// The same mnemonic, passphrase and path always give the same key.
+func NewKeyFromMnemonic(typ KeyType, mnemonic, passphrase, path string) (*Key, error) {
+	seed, err := SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = DefaultHDPath(typ.CurveType)
+	}
+	indices, err := ParseHDPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ.CurveType {
+	case CurveTypeSecp256k1:
+		priv, err := DeriveSecp256k1(seed, indices)
+		if err != nil {
+			return nil, err
+		}
+		return keyFromRawPriv(typ, priv)
+	case CurveTypeEd25519:
+		priv, err := DeriveEd25519(seed, indices)
+		if err != nil {
+			return nil, err
+		}
+		return keyFromRawPriv(typ, priv)
+	default:
+		return nil, InvalidCurveErr(typ.CurveType)
+	}
+}
+
+// SeedFromMnemonic gives the BIP39 seed for the mnemonic and (possibly empty) passphrase
+func SeedFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
+	words := strings.Fields(norm.NFKD.String(mnemonic))
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, fmt.Errorf("mnemonic should have 12, 15, 18, 21 or 24 words but has %v", len(words))
+	}
+	password := []byte(strings.Join(words, " "))
+	salt := []byte(norm.NFKD.String("mnemonic" + passphrase))
+	return pbkdf2.Key(password, salt, mnemonicRounds, 64, sha512.New), nil
+}
+
+// ParseHDPath parses a derivation path such as m/44'/60'/0'/0/0. Hardened indices may be
+// marked with ', h or H.
+func ParseHDPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %s should start with m", path)
+	}
+	indices := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		var offset uint32
+		if trimmed := strings.TrimRight(part, "'hH"); trimmed != part {
+			if len(part)-len(trimmed) != 1 {
+				return nil, fmt.Errorf("invalid index %s in derivation path %s", part, path)
+			}
+			part, offset = trimmed, HardenedKeyStart
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid index %s in derivation path %s", part, path)
+		}
+		indices = append(indices, uint32(index)+offset)
+	}
+	return indices, nil
+}
+
+// DeriveSecp256k1 gives the BIP32 private key for the seed at the path
+func DeriveSecp256k1(seed []byte, path []uint32) ([]byte, error) {
+	curveOrder := btcec.S256().N
+	key, chainCode := hmacSHA512(secp256k1MasterKey, seed)
+	if err := checkSecp256k1Key(key); err != nil {
+		return nil, err
+	}
+	for _, index := range path {
+		var data []byte
+		if index >= HardenedKeyStart {
+			data = append([]byte{0}, key...)
+		} else {
+			_, pub := btcec.PrivKeyFromBytes(btcec.S256(), key)
+			data = pub.SerializeCompressed()
+		}
+		data = append(data, ser32(index)...)
+
+		var childKey []byte
+		childKey, chainCode = hmacSHA512(chainCode, data)
+		if err := checkSecp256k1Key(childKey); err != nil {
+			return nil, err
+		}
+		k := new(big.Int).SetBytes(childKey)
+		k.Add(k, new(big.Int).SetBytes(key))
+		k.Mod(k, curveOrder)
+		key = paddedBytes(k, 32)
+		if err := checkSecp256k1Key(key); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// DeriveEd25519 gives the SLIP-0010 private key (seed) for the seed at the path
+func DeriveEd25519(seed []byte, path []uint32) ([]byte, error) {
+	key, chainCode := hmacSHA512(ed25519MasterKey, seed)
+	for _, index := range path {
+		if index < HardenedKeyStart {
+			return nil, fmt.Errorf("ed25519 keys can only be derived at hardened indices but got %v", index)
+		}
+		data := append([]byte{0}, key...)
+		key, chainCode = hmacSHA512(chainCode, append(data, ser32(index)...))
+	}
+	return key, nil
+}
+
+func hmacSHA512(key, data []byte) ([]byte, []byte) {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	I := mac.Sum(nil)
+	return I[:32], I[32:]
+}
+
+// BIP32 gives up on the (astronomically unlikely) keys outside the curve order rather than
+// moving on to the next index, so do we
+func checkSecp256k1Key(key []byte) error {
+	k := new(big.Int).SetBytes(key)
+	if k.Sign() == 0 || k.Cmp(btcec.S256().N) >= 0 {
+		return fmt.Errorf("derived secp256k1 key is invalid, try another derivation path")
+	}
+	return nil
+}
+
+func ser32(i uint32) []byte {
+	bs := make([]byte, 4)
+	binary.BigEndian.PutUint32(bs, i)
+	return bs
+}
+
+func paddedBytes(k *big.Int, length int) []byte {
+	bs := k.Bytes()
+	padded := make([]byte, length)
+	copy(padded[length-len(bs):], bs)
+	return padded
+}
@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestSeedFromMnemonic(t *testing.T) {
+	// BIP39 test vector
+	seed, err := SeedFromMnemonic(testMnemonic, "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if hex.EncodeToString(seed) != expected {
+		t.Errorf("wrong seed %x, expected %s", seed, expected)
+	}
+
+	if _, err = SeedFromMnemonic("abandon about", ""); err == nil {
+		t.Errorf("expected mnemonic with too few words to fail")
+	}
+}
+
+func TestParseHDPath(t *testing.T) {
+	indices, err := ParseHDPath("m/44'/60h/0H/0/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint32{44 + HardenedKeyStart, 60 + HardenedKeyStart, HardenedKeyStart, 0, 1}
+	if len(indices) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, indices)
+	}
+	for i := range expected {
+		if indices[i] != expected[i] {
+			t.Errorf("expected %v but got %v", expected, indices)
+		}
+	}
+
+	for _, path := range []string{"44'/0", "m/-1", "m/2147483648", "m/1''"} {
+		if _, err := ParseHDPath(path); err == nil {
+			t.Errorf("expected invalid path %s to fail", path)
+		}
+	}
+}
+
+type hdTestVector struct {
+	path string
+	priv string
+}
+
+func TestDeriveSecp256k1(t *testing.T) {
+	// BIP32 test vector 1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	vectors := []hdTestVector{
+		{"m", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+		{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{"m/0'/1/2'", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+	}
+	for _, v := range vectors {
+		path, err := ParseHDPath(v.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, err := DeriveSecp256k1(seed, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(priv) != v.priv {
+			t.Errorf("wrong key %x at %s, expected %s", priv, v.path, v.priv)
+		}
+	}
+}
+
+func TestDeriveEd25519(t *testing.T) {
+	// SLIP-0010 ed25519 test vector 1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	vectors := []hdTestVector{
+		{"m", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7"},
+		{"m/0'", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3"},
+		{"m/0'/1'", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2"},
+	}
+	for _, v := range vectors {
+		path, err := ParseHDPath(v.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, err := DeriveEd25519(seed, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(priv) != v.priv {
+			t.Errorf("wrong key %x at %s, expected %s", priv, v.path, v.priv)
+		}
+	}
+
+	if _, err := DeriveEd25519(seed, []uint32{0}); err == nil {
+		t.Errorf("expected non-hardened ed25519 derivation to fail")
+	}
+}
+
+func TestNewKeyFromMnemonic(t *testing.T) {
+	// First account of this mnemonic in Ethereum wallets
+	key, err := NewKeyFromMnemonic(KeyType{CurveTypeSecp256k1, AddrTypeSha3}, testMnemonic, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address := keyStoreV3Address(key); hex.EncodeToString(address) != "9858effd232b4033e47d90003d41ec34ecaeda94" {
+		t.Errorf("wrong Ethereum address %x", address)
+	}
+
+	typ := KeyType{CurveTypeEd25519, AddrTypeRipemd160}
+	k1, err := NewKeyFromMnemonic(typ, testMnemonic, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := NewKeyFromMnemonic(typ, testMnemonic, "", DefaultHDPathEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(k1.Address) != hex.EncodeToString(k2.Address) {
+		t.Errorf("same mnemonic and path gave addresses %x and %x", k1.Address, k2.Address)
+	}
+}
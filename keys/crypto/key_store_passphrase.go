@@ -69,6 +69,11 @@ References:
 	provides authenticated encryption, rather than managing the
 	additional checksum ourselves. The CBC IV is replaced by a Nonce
 	that may only be used once ever per key
+
+	the encryption key of newly stored keys is derived with argon2id
+	rather than scrypt, its parameters are recorded in the key file
+	alongside the salt. Key files without a KDF are decrypted with
+	scrypt as before
 */
 
 package crypto
@@ -86,6 +91,7 @@ import (
 
 	"github.com/monax/bosmarmot/keys/crypto/randentropy"
 	uuid "github.com/wayn3h0/go-uuid"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/scrypt" // 2^18 / 8 / 1 uses 256MB memory and approx 1s CPU time on a modern CPU.
 )
 
@@ -94,6 +100,14 @@ const (
 	scryptr     = 8
 	scryptp     = 1
 	scryptdkLen = 32
+
+	kdfArgon2id = "argon2id"
+
+	// The recommendation of RFC 9106 for memory constrained environments, 64MiB and approx 0.5s CPU time
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
 )
 
 type keyStorePassphrase struct {
@@ -121,12 +135,13 @@ func (ks keyStorePassphrase) GetAllAddresses() (addresses [][]byte, err error) {
 }
 
 func (ks keyStorePassphrase) StoreKey(key *Key, auth string) (err error) {
-	authArray := []byte(auth)
 	salt := randentropy.GetEntropyMixed(32)
-	derivedKey, err := scrypt.Key(authArray, salt, scryptN, scryptr, scryptp, scryptdkLen)
-	if err != nil {
-		return err
+	params := &kdfParamsJSON{
+		Time:    argon2Time,
+		Memory:  argon2Memory,
+		Threads: argon2Threads,
 	}
+	derivedKey := argon2.IDKey([]byte(auth), salt, params.Time, params.Memory, params.Threads, argon2KeyLen)
 
 	keyBytes := key.PrivateKey
 	toEncrypt := PKCS7Pad(keyBytes)
@@ -148,9 +163,11 @@ func (ks keyStorePassphrase) StoreKey(key *Key, auth string) (err error) {
 	cipherText := gcm.Seal(nil, nonce, toEncrypt, nil)
 
 	cipherStruct := cipherJSON{
-		salt,
-		nonce,
-		cipherText,
+		Salt:       salt,
+		Nonce:      nonce,
+		CipherText: cipherText,
+		KDF:        kdfArgon2id,
+		KDFParams:  params,
 	}
 	keyStruct := encryptedKeyJSON{
 		[]byte(key.Id.String()),
@@ -216,12 +233,10 @@ func DecryptKey(ks keyStorePassphrase, keyAddr []byte, auth string) (*Key, error
 	if bytes.Compare(keyAddr, keyAddr2) != 0 {
 		return nil, fmt.Errorf("address of key and address in file do not match. Got %x, expected %x", keyAddr2, keyAddr)
 	}
-	salt := keyProtected.Crypto.Salt
 	nonce := keyProtected.Crypto.Nonce
 	cipherText := keyProtected.Crypto.CipherText
 
-	authArray := []byte(auth)
-	derivedKey, err := scrypt.Key(authArray, salt, scryptN, scryptr, scryptp, scryptdkLen)
+	derivedKey, err := deriveEncryptionKey(keyProtected.Crypto, auth)
 	if err != nil {
 		return nil, err
 	}
@@ -243,3 +258,27 @@ func DecryptKey(ks keyStorePassphrase, keyAddr []byte, auth string) (*Key, error
 		PrivateKey: plainText,
 	}, nil
 }
+
+// Derives the key a key file was encrypted with from the passphrase using the kdf it records
+func deriveEncryptionKey(cipherStruct cipherJSON, auth string) ([]byte, error) {
+	switch cipherStruct.KDF {
+	case "", kdfScrypt:
+		return scrypt.Key([]byte(auth), cipherStruct.Salt, scryptN, scryptr, scryptp, scryptdkLen)
+	case kdfArgon2id:
+		params := cipherStruct.KDFParams
+		if params == nil || params.Time < 1 || params.Threads < 1 {
+			return nil, fmt.Errorf("key file has invalid argon2id parameters")
+		}
+		// The parameters come from the key file so are bounded by those keys are stored with, otherwise a crafted
+		// key file could make unlocking it take arbitrary memory and CPU time
+		if params.Time > argon2Time || params.Memory > argon2Memory || params.Threads > argon2Threads {
+			return nil, fmt.Errorf("key file has argon2id parameters time %d, memory %d and %d threads, "+
+				"greater than the maximum of time %d, memory %d and %d threads", params.Time, params.Memory,
+				params.Threads, argon2Time, argon2Memory, argon2Threads)
+		}
+		return argon2.IDKey([]byte(auth), cipherStruct.Salt, params.Time, params.Memory, params.Threads,
+			argon2KeyLen), nil
+	default:
+		return nil, fmt.Errorf("key file is encrypted with unsupported kdf %s", cipherStruct.KDF)
+	}
+}
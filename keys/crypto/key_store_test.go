@@ -1,10 +1,20 @@
 package crypto
 
 import (
-	"github.com/monax/bosmarmot/keys/common"
-
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/monax/bosmarmot/keys/common"
+	"github.com/monax/bosmarmot/keys/crypto/randentropy"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/scrypt"
 )
 
 func TestKeyStorePlain(t *testing.T) {
@@ -96,3 +106,135 @@ func TestKeyStorePassphraseDecryptionFail(t *testing.T) {
 	f(KeyType{CurveTypeSecp256k1, AddrTypeSha3})
 	f(KeyType{CurveTypeEd25519, AddrTypeRipemd160})
 }
+
+// Keys encrypted before argon2id was used record no kdf and are decrypted with scrypt
+func TestKeyStorePassphraseScrypt(t *testing.T) {
+	ks := NewKeyStorePassphrase(common.KeysPath)
+	pass := "foo"
+	k1, err := NewKey(KeyType{CurveTypeSecp256k1, AddrTypeSha3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := randentropy.GetEntropyMixed(32)
+	derivedKey, err := scrypt.Key([]byte(pass), salt, scryptN, scryptr, scryptp, scryptdkLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := randentropy.GetEntropyMixed(gcm.NonceSize())
+	keyJSON, err := json.Marshal(encryptedKeyJSON{
+		[]byte(k1.Id.String()),
+		k1.Type.String(),
+		strings.ToUpper(hex.EncodeToString(k1.Address)),
+		cipherJSON{Salt: salt, Nonce: nonce, CipherText: gcm.Seal(nil, nonce, PKCS7Pad(k1.PrivateKey), nil)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = WriteKeyFile(k1.Address, common.KeysPath, keyJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	k2, err := ks.GetKey(k1.Address, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(k1.PrivateKey, k2.PrivateKey) {
+		t.Fatal("decrypted private key differs")
+	}
+	if _, err = ks.GetKey(k1.Address, "bar"); err == nil {
+		t.Fatal("decrypting with the wrong passphrase should fail")
+	}
+	if err = ks.DeleteKey(k1.Address, pass); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The argon2id parameters of a key file must not exceed those keys are stored with
+func TestDeriveEncryptionKeyArgon2idBounds(t *testing.T) {
+	salt := randentropy.GetEntropyMixed(32)
+	for _, params := range []kdfParamsJSON{
+		{Time: argon2Time + 1, Memory: argon2Memory, Threads: argon2Threads},
+		{Time: argon2Time, Memory: argon2Memory + 1, Threads: argon2Threads},
+		{Time: argon2Time, Memory: 1 << 31, Threads: argon2Threads},
+		{Time: argon2Time, Memory: argon2Memory, Threads: argon2Threads + 1},
+	} {
+		params := params
+		_, err := deriveEncryptionKey(cipherJSON{Salt: salt, KDF: kdfArgon2id, KDFParams: &params}, "foo")
+		if err == nil {
+			t.Errorf("deriving a key with argon2id parameters %+v should fail", params)
+		}
+	}
+	params := kdfParamsJSON{Time: argon2Time, Memory: argon2Memory, Threads: argon2Threads}
+	if _, err := deriveEncryptionKey(cipherJSON{Salt: salt, KDF: kdfArgon2id, KDFParams: &params}, "foo"); err != nil {
+		t.Error(err)
+	}
+}
+
+// Checks the vendored BLAKE2b underlying argon2id against the digests of RFC 7693 and those of several blocks
+func TestBlake2b(t *testing.T) {
+	for _, tc := range []struct {
+		size   int
+		input  []byte
+		digest string
+	}{
+		{64, nil, "786a02f742015903c6c6fd852552d272912f4740e15847618a86e217f71f5419" +
+			"d25e1031afee585313896444934eb04b903a685b1448b755d56f701afe9be2ce"},
+		{64, []byte("abc"), "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d1" +
+			"7d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+		// A single full block and several blocks with a partial last block
+		{20, seq(128), "e6992372ab022447b34f6d6032fbab707a11adef"},
+		{33, bytes.Repeat(seq(256), 5), "6c3b77bcd3c5f9ef4f340c33b8852f3bae5350a7c97d8c315782a13f66753552b0"},
+	} {
+		h, err := blake2b.New(tc.size, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Write(tc.input)
+		if digest := h.Sum(nil); hex.EncodeToString(digest) != tc.digest {
+			t.Errorf("BLAKE2b-%d of %q is %x, expected %s", 8*tc.size, tc.input, digest, tc.digest)
+		}
+	}
+}
+
+func seq(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+// Checks the vendored argon2id that keys are encrypted under against the test vectors of the reference
+// implementation. The vector of RFC 9106 also hashes a secret and associated data, which argon2.IDKey does not take
+// and the key store does not use.
+func TestArgon2id(t *testing.T) {
+	for _, tc := range []struct {
+		password, salt string
+		time, memory   uint32
+		threads        uint8
+		key            string
+	}{
+		{"password", "somesalt", 2, 1 << 16, 1, "09316115d5cf24ed5a15a31a3ba326e5cf32edc24702987c02b6566f61913cf7"},
+		{"password", "somesalt", 2, 1 << 8, 1, "9dfeb910e80bad0311fee20f9c0e2b12c17987b4cac90c2ef54d5b3021c68bfe"},
+		{"password", "somesalt", 2, 1 << 8, 2, "6d093c501fd5999645e0ea3bf620d7b8be7fd2db59c20d9fff9539da2bf57037"},
+		{"password", "somesalt", 1, 1 << 16, 1, "f6a5adc1ba723dddef9b5ac1d464e180fcd9dffc9d1cbf76cca2fed795d9ca98"},
+		{"password", "somesalt", 4, 1 << 16, 1, "9025d48e68ef7395cca9079da4c4ec3affb3c8911fe4f86d1a2520856f63172c"},
+		{"differentpassword", "somesalt", 2, 1 << 16, 1,
+			"0b84d652cf6b0c4beaef0dfe278ba6a80df6696281d7e0d2891b817d8c458fde"},
+		{"password", "diffsalt", 2, 1 << 16, 1, "bdf32b05ccc42eb15d58fd19b1f856b113da1e9a5874fdcc544308565aa8141c"},
+	} {
+		key := argon2.IDKey([]byte(tc.password), []byte(tc.salt), tc.time, tc.memory, tc.threads, 32)
+		if hex.EncodeToString(key) != tc.key {
+			t.Errorf("argon2id key of %s with salt %s, time %d, memory %d and %d threads is %x, expected %s",
+				tc.password, tc.salt, tc.time, tc.memory, tc.threads, key, tc.key)
+		}
+	}
+}
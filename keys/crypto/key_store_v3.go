@@ -0,0 +1,285 @@
+/*
+
+Import and export of keys in the version 3 keystore format used by Ethereum clients [1].
+
+Cryptography:
+
+1. Encryption key is scrypt or PBKDF2-HMAC-SHA256 derived key from user passphrase.
+   Keys are exported with scrypt using the work factors of keyStorePassphrase.
+2. Encryption algo is AES 128 CTR using the first 16 bytes of the derived key.
+3. MAC is Keccak-256 of the second 16 bytes of the derived key concatenated with
+   the ciphertext.
+
+Extension:
+
+V3 only describes secp256k1 keys with Ethereum (sha3) addresses. For any other
+key type the file carries an additional top level field:
+
+	"keytype": "ed25519,ripemd160"
+
+holding the KeyType as understood by KeyTypeFromString. A file without the field
+is a standard Ethereum key ("secp256k1,sha3"). For ed25519 keys the ciphertext is
+the 32 byte seed rather than the expanded 64 byte private key.
+
+Addresses of sha3 keys are derived from the compressed public key whereas Ethereum
+uses the uncompressed one, so standard files carry the Ethereum address of the key
+in the address field for other clients to verify.
+
+References:
+
+1. https://github.com/ethereum/wiki/wiki/Web3-Secret-Storage-Definition
+
+*/
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/monax/bosmarmot/keys/crypto/randentropy"
+	"github.com/tendermint/go-crypto"
+	uuid "github.com/wayn3h0/go-uuid"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keyStoreV3Version = 3
+	keyStoreV3Cipher  = "aes-128-ctr"
+	kdfScrypt         = "scrypt"
+	kdfPBKDF2         = "pbkdf2"
+	pbkdf2PRF         = "hmac-sha256"
+)
+
+// The key type of V3 files without the keytype extension field
+var DefaultKeyStoreV3Type = KeyType{CurveTypeSecp256k1, AddrTypeSha3}
+
+type keyStoreV3JSON struct {
+	Address string           `json:"address,omitempty"`
+	Crypto  keyStoreV3Crypto `json:"crypto"`
+	Id      string           `json:"id"`
+	Version int              `json:"version"`
+	KeyType string           `json:"keytype,omitempty"`
+}
+
+type keyStoreV3Crypto struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams keyStoreV3CipherParams `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+type keyStoreV3CipherParams struct {
+	IV string `json:"iv"`
+}
+
+// IsKeyStoreV3Json reports whether j looks like a version 3 keystore file
+func IsKeyStoreV3Json(j []byte) bool {
+	keyJSON := new(keyStoreV3JSON)
+	if err := json.Unmarshal(j, keyJSON); err != nil {
+		return false
+	}
+	return keyJSON.Version == keyStoreV3Version && keyJSON.Crypto.CipherText != ""
+}
+
+// ExportKeyV3 encrypts key with auth into a version 3 keystore file
+func ExportKeyV3(key *Key, auth string) ([]byte, error) {
+	priv := key.PrivateKey
+	if key.Type.CurveType == CurveTypeEd25519 {
+		priv = priv[:32]
+	}
+
+	salt := randentropy.GetEntropyMixed(32)
+	derivedKey, err := scrypt.Key([]byte(auth), salt, scryptN, scryptr, scryptp, scryptdkLen)
+	if err != nil {
+		return nil, err
+	}
+	iv := randentropy.GetEntropyMixed(aes.BlockSize)
+	cipherText, err := aesCTRXOR(derivedKey[:16], priv, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	keyJSON := keyStoreV3JSON{
+		Address: hex.EncodeToString(keyStoreV3Address(key)),
+		Crypto: keyStoreV3Crypto{
+			Cipher:       keyStoreV3Cipher,
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: keyStoreV3CipherParams{hex.EncodeToString(iv)},
+			KDF:          kdfScrypt,
+			KDFParams: map[string]interface{}{
+				"n":     scryptN,
+				"r":     scryptr,
+				"p":     scryptp,
+				"dklen": scryptdkLen,
+				"salt":  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(Sha3(derivedKey[16:32], cipherText)),
+		},
+		Id:      key.Id.String(),
+		Version: keyStoreV3Version,
+	}
+	if key.Type != DefaultKeyStoreV3Type {
+		keyJSON.KeyType = key.Type.String()
+	}
+	return json.Marshal(keyJSON)
+}
+
+// ImportKeyV3 decrypts a version 3 keystore file with auth. The address of the decrypted key
+// must match the address in the file when one is given.
+func ImportKeyV3(j []byte, auth string) (*Key, error) {
+	keyJSON := new(keyStoreV3JSON)
+	if err := json.Unmarshal(j, keyJSON); err != nil {
+		return nil, err
+	}
+	if keyJSON.Version != keyStoreV3Version {
+		return nil, fmt.Errorf("unsupported keystore version %v, only version %v is supported",
+			keyJSON.Version, keyStoreV3Version)
+	}
+	if keyJSON.Crypto.Cipher != keyStoreV3Cipher {
+		return nil, fmt.Errorf("unsupported keystore cipher %s, only %s is supported",
+			keyJSON.Crypto.Cipher, keyStoreV3Cipher)
+	}
+
+	keyType := DefaultKeyStoreV3Type
+	if keyJSON.KeyType != "" {
+		var err error
+		if keyType, err = KeyTypeFromString(keyJSON.KeyType); err != nil {
+			return nil, err
+		}
+	}
+
+	cipherText, err := hex.DecodeString(keyJSON.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("ciphertext is invalid hex: %v", err)
+	}
+	iv, err := hex.DecodeString(keyJSON.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, fmt.Errorf("iv is invalid hex: %v", err)
+	}
+	mac, err := hex.DecodeString(keyJSON.Crypto.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("mac is invalid hex: %v", err)
+	}
+
+	derivedKey, err := deriveKeyV3(keyJSON.Crypto, auth)
+	if err != nil {
+		return nil, err
+	}
+	if len(derivedKey) < 32 {
+		return nil, fmt.Errorf("derived key length must be at least 32 bytes but dklen is %v", len(derivedKey))
+	}
+	if !bytes.Equal(Sha3(derivedKey[16:32], cipherText), mac) {
+		return nil, fmt.Errorf("could not decrypt key with given passphrase")
+	}
+	priv, err := aesCTRXOR(derivedKey[:16], cipherText, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keyFromRawPriv(keyType, priv)
+	if err != nil {
+		return nil, err
+	}
+	if keyJSON.Address != "" {
+		address, err := hex.DecodeString(strings.TrimPrefix(keyJSON.Address, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("address is invalid hex: %v", err)
+		}
+		if !bytes.Equal(address, keyStoreV3Address(key)) {
+			return nil, fmt.Errorf("address of decrypted %s key is %X but keystore gives address %X",
+				keyType, keyStoreV3Address(key), address)
+		}
+	}
+	if id, err := uuid.Parse(keyJSON.Id); err == nil {
+		key.Id = id
+	}
+	return key, nil
+}
+
+// The address other clients expect to find in the keystore
+func keyStoreV3Address(key *Key) []byte {
+	if key.Type != DefaultKeyStoreV3Type {
+		return key.Address
+	}
+	_, pub := btcec.PrivKeyFromBytes(btcec.S256(), key.PrivateKey)
+	return Sha3(pub.SerializeUncompressed()[1:])[12:]
+}
+
+func deriveKeyV3(c keyStoreV3Crypto, auth string) ([]byte, error) {
+	salt, err := hex.DecodeString(kdfString(c.KDFParams, "salt"))
+	if err != nil {
+		return nil, fmt.Errorf("kdf salt is invalid hex: %v", err)
+	}
+	dkLen := kdfInt(c.KDFParams, "dklen")
+
+	switch c.KDF {
+	case kdfScrypt:
+		n, r, p := kdfInt(c.KDFParams, "n"), kdfInt(c.KDFParams, "r"), kdfInt(c.KDFParams, "p")
+		return scrypt.Key([]byte(auth), salt, n, r, p, dkLen)
+	case kdfPBKDF2:
+		if prf := kdfString(c.KDFParams, "prf"); prf != pbkdf2PRF {
+			return nil, fmt.Errorf("unsupported pbkdf2 prf %s, only %s is supported", prf, pbkdf2PRF)
+		}
+		c := kdfInt(c.KDFParams, "c")
+		if c < 1 {
+			return nil, fmt.Errorf("pbkdf2 iteration count must be positive")
+		}
+		return pbkdf2.Key([]byte(auth), salt, c, dkLen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("unsupported kdf %s, only %s and %s are supported", c.KDF, kdfScrypt, kdfPBKDF2)
+	}
+}
+
+// json numbers decode as float64
+func kdfInt(params map[string]interface{}, name string) int {
+	f, _ := params[name].(float64)
+	return int(f)
+}
+
+func kdfString(params map[string]interface{}, name string) string {
+	s, _ := params[name].(string)
+	return s
+}
+
+func aesCTRXOR(key, in, iv []byte) ([]byte, error) {
+	AES128Block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	stream := cipher.NewCTR(AES128Block, iv)
+	out := make([]byte, len(in))
+	stream.XORKeyStream(out, in)
+	return out, nil
+}
+
+// NewKeyFromPriv derives secp256k1 keys from a secret rather than taking the private key as is,
+// which would not give back the key that was exported
+func keyFromRawPriv(typ KeyType, priv []byte) (*Key, error) {
+	switch typ.CurveType {
+	case CurveTypeSecp256k1:
+		if len(priv) != 32 {
+			return nil, fmt.Errorf("secp256k1 private key should be 32 bytes but got %v", len(priv))
+		}
+		var privKey crypto.PrivKeySecp256k1
+		copy(privKey[:], priv)
+		return newKeySecp256k1(typ.AddrType, privKey)
+	case CurveTypeEd25519:
+		if len(priv) != 32 {
+			return nil, fmt.Errorf("ed25519 seed should be 32 bytes but got %v", len(priv))
+		}
+		return keyFromPrivEd25519(typ.AddrType, priv)
+	default:
+		return nil, InvalidCurveErr(typ.CurveType)
+	}
+}
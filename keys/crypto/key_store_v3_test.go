@@ -0,0 +1,82 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test vector from the Web3 Secret Storage Definition
+const pbkdf2TestVector = `{
+	"crypto": {
+		"cipher": "aes-128-ctr",
+		"cipherparams": {"iv": "6087dab2f9fdbbfaddc31a909735c1e6"},
+		"ciphertext": "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+		"kdf": "pbkdf2",
+		"kdfparams": {
+			"c": 262144,
+			"dklen": 32,
+			"prf": "hmac-sha256",
+			"salt": "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"
+		},
+		"mac": "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+	},
+	"address": "008aeeda4d805471df9b2a5b0f38a0c3bcba786b",
+	"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+	"version": 3
+}`
+
+func TestImportKeyV3PBKDF2(t *testing.T) {
+	key, err := ImportKeyV3([]byte(pbkdf2TestVector), "testpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(key.PrivateKey) != "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d" {
+		t.Errorf("wrong private key %x", key.PrivateKey)
+	}
+	if key.Type != DefaultKeyStoreV3Type {
+		t.Errorf("expected key without keytype to be %v but got %v", DefaultKeyStoreV3Type, key.Type)
+	}
+
+	_, err = ImportKeyV3([]byte(pbkdf2TestVector), "wrongpassword")
+	if err == nil {
+		t.Errorf("expected wrong passphrase to fail")
+	}
+}
+
+func TestExportImportKeyV3(t *testing.T) {
+	f := func(typ KeyType) {
+		k1, err := NewKey(typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		j, err := ExportKeyV3(k1, "pass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsKeyStoreV3Json(j) {
+			t.Fatalf("exported key is not recognised as a V3 keystore: %s", j)
+		}
+		hasKeyType := strings.Contains(string(j), `"keytype"`)
+		if hasKeyType != (typ != DefaultKeyStoreV3Type) {
+			t.Errorf("keytype extension should only be given for non-Ethereum keys: %s", j)
+		}
+
+		k2, err := ImportKeyV3(j, "pass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(k1, k2) {
+			t.Errorf("imported key %v does not match exported key %v", k2, k1)
+		}
+
+		// corrupt the address
+		tampered := strings.Replace(string(j), hex.EncodeToString(keyStoreV3Address(k1)), strings.Repeat("00", 20), 1)
+		if _, err = ImportKeyV3([]byte(tampered), "pass"); err == nil {
+			t.Errorf("expected mismatched address to fail")
+		}
+	}
+	f(KeyType{CurveTypeSecp256k1, AddrTypeSha3})
+	f(KeyType{CurveTypeEd25519, AddrTypeRipemd160})
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/monax/bosmarmot/keys/common"
+	"github.com/monax/bosmarmot/keys/crypto"
 
 	"github.com/monax/bosmarmot/project"
 	"github.com/spf13/cobra"
@@ -45,13 +46,26 @@ var (
 	//keygenCmd only
 	NoPassword bool
 	KeyType    string
+	Mnemonic   string
+	HDPath     string
 
 	//hashCmd only
 	HashType string
 	HexByte  bool
 
-	// lockCmd only
+	// importCmd only
+	Force bool
+
+	// keygenCmd, importCmd and unlockCmd
+	Prompt bool
+
+	// unlockCmd only
 	UnlockTime int // minutes
+	UnlockTTL  string
+
+	// lsCmd only
+	CurveType  string
+	NamePrefix string
 
 	Verbose bool
 	Debug   bool
@@ -80,10 +94,13 @@ func BuildKeysCommand() {
 	EKeys.AddCommand(signCmd)
 	EKeys.AddCommand(pubKeyCmd)
 	EKeys.AddCommand(verifyCmd)
+	EKeys.AddCommand(recoverCmd)
 	EKeys.AddCommand(hashCmd)
 	EKeys.AddCommand(serverCmd)
 	EKeys.AddCommand(importCmd)
+	EKeys.AddCommand(exportCmd)
 	EKeys.AddCommand(convertCmd)
+	EKeys.AddCommand(lsCmd)
 	EKeys.AddCommand(&cobra.Command{
 		Use:   "version",
 		Short: "Print Version",
@@ -97,21 +114,25 @@ func BuildKeysCommand() {
 var keygenCmd = &cobra.Command{
 	Use:   "gen",
 	Short: "Generate a key",
-	Long:  "Generates a key using (insert crypto pkgs used)",
-	Run:   cliKeygen,
+	Long: `Generates a key using (insert crypto pkgs used)
+
+With --mnemonic the key is derived deterministically from a BIP39 mnemonic using
+BIP32 for secp256k1 keys and SLIP-0010 for ed25519 keys, so the same mnemonic and
+--path always give the same address.`,
+	Run: cliKeygen,
 }
 
 var lockCmd = &cobra.Command{
 	Use:   "lock",
 	Short: "lock a key",
-	Long:  "lock an unlocked key by re-encrypting it",
+	Long:  "lock an unlocked key by dropping its decrypted private key from the daemon's memory, signing with it fails until it is unlocked again",
 	Run:   cliLock,
 }
 
 var unlockCmd = &cobra.Command{
 	Use:   "unlock",
 	Short: "unlock a key",
-	Long:  "unlock an unlocked key by supplying the password",
+	Long:  "unlock an encrypted key by supplying the password, the daemon keeps the decrypted key in memory for --time minutes or the --ttl duration. Unlocking an unlocked key changes how long it stays unlocked for",
 	Run:   cliUnlock,
 }
 
@@ -152,9 +173,26 @@ var pubKeyCmd = &cobra.Command{
 
 var verifyCmd = &cobra.Command{
 	Use:   "verify",
-	Short: "monax-keys verify --addr <addr> <hash> <sig>",
-	Long:  "monax-keys verify --addr <addr> <hash> <sig>",
-	Run:   cliVerify,
+	Short: "monax-keys verify <hash> <sig> <pub>",
+	Long: `monax-keys verify <hash> <sig> <pub>
+
+Verify a signature made by monax-keys sign against a public key for the curve of
+--type. The hash, signature and public key may each be given as hex, with or
+without a 0x prefix, or as base64. A signature or public key that cannot be read
+for the curve is an error rather than failing to verify.`,
+	Run: cliVerify,
+}
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "monax-keys recover <hash> <sig>",
+	Long: `monax-keys recover <hash> <sig>
+
+Recover the public key and address (for the address type of --type) of the key
+a secp256k1 signature made by monax-keys sign was made with. Signatures carry no
+recovery id so up to two keys are listed, the signer being the one whose address
+is expected. Input may be hex or base64 as for verify.`,
+	Run: cliRecover,
 }
 
 var convertCmd = &cobra.Command{
@@ -179,8 +217,33 @@ var serverCmd = &cobra.Command{
 var importCmd = &cobra.Command{
 	Use:   "import",
 	Short: "monax-keys import <priv key> | /path/to/keyfile | <key json>",
-	Long:  "monax-keys import <priv key> | /path/to/keyfile | <key json>",
-	Run:   cliImport,
+	Long: `monax-keys import <priv key> | /path/to/keyfile | <key json>
+
+Version 3 keystore files as used by Ethereum clients are recognised and decrypted
+with the keystore passphrase, which may use either the scrypt or pbkdf2 kdf. Keys
+other than secp256k1,sha3 are identified by the "keytype" extension field written
+by monax-keys export.`,
+	Run: cliImport,
+}
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "monax-keys export --addr <address>",
+	Long: `monax-keys export --addr <address>
+
+Export a key as a version 3 keystore file encrypted with a new passphrase. Keys
+other than secp256k1,sha3 carry a "keytype" field (for example "ed25519,ripemd160")
+since the format only covers Ethereum keys. An encrypted key must be unlocked first.`,
+	Run: cliExport,
+}
+
+var lsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "list keys",
+	Long: `List the address, public key, type and names of each stored key, which may be
+filtered by --curve and --prefix. Public keys are not stored so are only given for
+encrypted keys while they are unlocked. Files in the keys dir that are not keys are
+listed as skipped.`,
+	Run: cliLs,
 }
 
 func addKeysFlags() {
@@ -194,16 +257,27 @@ func addKeysFlags() {
 	EKeys.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "verbose mode")
 	keygenCmd.Flags().StringVarP(&KeyType, "type", "t", DefaultKeyType, "specify the type of key to create. Supports 'secp256k1,sha3' (ethereum),  'secp256k1,ripemd160sha2' (bitcoin), 'ed25519,ripemd160' (tendermint)")
 	keygenCmd.Flags().BoolVarP(&NoPassword, "no-pass", "", false, "don't use a password for this key")
+	keygenCmd.Flags().BoolVarP(&Prompt, "prompt", "", false, "prompt for the password even if "+PassphraseEnv+" is set")
+	keygenCmd.Flags().StringVarP(&Mnemonic, "mnemonic", "", "", "derive the key from this BIP39 mnemonic instead of generating a random one")
+	keygenCmd.Flags().StringVarP(&HDPath, "path", "", "", "derivation path of the key derived from --mnemonic. Defaults to "+crypto.DefaultHDPathSecp256k1+" for secp256k1 and "+crypto.DefaultHDPathEd25519+" for ed25519 (which only supports hardened indices)")
 
 	hashCmd.PersistentFlags().StringVarP(&HashType, "type", "t", DefaultHashType, "specify the hash function to use")
 	hashCmd.PersistentFlags().BoolVarP(&HexByte, "hex", "", false, "the input should be hex decoded to bytes first")
 
 	importCmd.PersistentFlags().StringVarP(&KeyType, "type", "t", DefaultKeyType, "import a key")
 	importCmd.Flags().BoolVarP(&NoPassword, "no-pass", "", false, "don't use a password for this key")
+	importCmd.Flags().BoolVarP(&Prompt, "prompt", "", false, "prompt for the password even if "+PassphraseEnv+" is set")
+	importCmd.Flags().BoolVarP(&Force, "force", "", false, "overwrite an existing key with the same address when importing a keystore file")
 
 	verifyCmd.PersistentFlags().StringVarP(&KeyType, "type", "t", DefaultKeyType, "key type")
+	recoverCmd.PersistentFlags().StringVarP(&KeyType, "type", "t", DefaultKeyType, "key type, which must be secp256k1 and sets the address type of the recovered keys")
+
+	lsCmd.Flags().StringVarP(&CurveType, "curve", "", "", "only list keys of this curve type, either 'secp256k1' or 'ed25519'")
+	lsCmd.Flags().StringVarP(&NamePrefix, "prefix", "", "", "only list keys with a name starting with this prefix")
 
 	unlockCmd.PersistentFlags().IntVarP(&UnlockTime, "time", "t", 10, "number of minutes to unlock key for. defaults to 10, 0 for forever")
+	unlockCmd.PersistentFlags().StringVarP(&UnlockTTL, "ttl", "", "", "how long to unlock key for as a duration such as 90s or 1h, overrides --time")
+	unlockCmd.PersistentFlags().BoolVarP(&Prompt, "prompt", "", false, "prompt for the password even if "+PassphraseEnv+" is set")
 }
 
 func checkMakeDataDir(dir string) error {
@@ -8,6 +8,7 @@ import (
 	"os"
 
 	. "github.com/monax/bosmarmot/keys/common"
+	"github.com/monax/bosmarmot/keys/crypto"
 	//"github.com/howeyc/gopass"
 	"github.com/spf13/cobra"
 )
@@ -26,7 +27,8 @@ func cliKeygen(cmd *cobra.Command, args []string) {
 		auth = hiddenAuth()
 	}
 
-	r, err := Call("gen", map[string]string{"auth": auth, "type": KeyType, "name": KeyName})
+	r, err := Call("gen", map[string]string{"auth": auth, "type": KeyType, "name": KeyName,
+		"mnemonic": Mnemonic, "path": HDPath})
 	if _, ok := err.(ErrConnectionRefused); ok {
 		ExitConnectErr(err)
 	}
@@ -53,7 +55,8 @@ func cliConvert(cmd *cobra.Command, args []string) {
 
 func cliUnlock(cmd *cobra.Command, args []string) {
 	auth := hiddenAuth()
-	r, err := Call("unlock", map[string]string{"auth": auth, "addr": KeyAddr, "name": KeyName})
+	r, err := Call("unlock", map[string]string{"auth": auth, "addr": KeyAddr, "name": KeyName,
+		"timeout": fmt.Sprintf("%d", UnlockTime), "ttl": UnlockTTL})
 	if _, ok := err.(ErrConnectionRefused); ok {
 		ExitConnectErr(err)
 	}
@@ -99,6 +102,19 @@ func cliVerify(cmd *cobra.Command, args []string) {
 	LogToChannel([]byte(r))
 }
 
+func cliRecover(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		Exit(fmt.Errorf("enter a msg/hash and a signature"))
+	}
+	msg, sig := args[0], args[1]
+	r, err := Call("recover", map[string]string{"type": KeyType, "msg": msg, "sig": sig})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	LogToChannel([]byte(r))
+}
+
 func cliHash(cmd *cobra.Command, args []string) {
 	if len(args) != 1 {
 		Exit(fmt.Errorf("enter something to hash"))
@@ -126,6 +142,11 @@ func cliImport(cmd *cobra.Command, args []string) {
 		IfExit(err)
 	}
 
+	if crypto.IsKeyStoreV3Json([]byte(key)) {
+		cliImportKeyStore(key)
+		return
+	}
+
 	var auth string
 	if !NoPassword {
 		log.Printf("Warning: Please note that this encryption will only take effect if you passed a raw private key (TODO!).")
@@ -140,6 +161,32 @@ func cliImport(cmd *cobra.Command, args []string) {
 	LogToChannel([]byte(r))
 }
 
+func cliImportKeyStore(keyStore string) {
+	passphrase := hiddenAuthPrompt("Enter Keystore Passphrase:")
+	var auth string
+	if !NoPassword {
+		auth = hiddenAuth()
+	}
+
+	r, err := Call("import/keystore", map[string]string{"auth": auth, "name": KeyName, "keystore": keyStore,
+		"passphrase": passphrase, "force": fmt.Sprintf("%v", Force)})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	LogToChannel([]byte(r))
+}
+
+func cliExport(cmd *cobra.Command, args []string) {
+	passphrase := hiddenAuthPrompt("Enter Keystore Passphrase:")
+	r, err := Call("export", map[string]string{"addr": KeyAddr, "name": KeyName, "passphrase": passphrase})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	LogToChannel([]byte(r))
+}
+
 func cliName(cmd *cobra.Command, args []string) {
 	var name, addr string
 	if len(args) > 0 {
@@ -183,3 +230,20 @@ func cliNameRm(cmd *cobra.Command, args []string) {
 	IfExit(err)
 	LogToChannel([]byte(r))
 }
+
+func cliLs(cmd *cobra.Command, args []string) {
+	r, err := Call("ls", map[string]string{"curve": CurveType, "prefix": NamePrefix})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	keyList := new(KeyList)
+	IfExit(json.Unmarshal([]byte(r), keyList))
+	for _, k := range keyList.Keys {
+		log.Printf("%s: %s %v\n", k.Address, k.KeyType, k.Names)
+	}
+	for _, s := range keyList.Skipped {
+		log.Printf("Skipped %s: %s\n", s.File, s.Reason)
+	}
+	LogToChannel([]byte(r))
+}
@@ -1,6 +1,7 @@
 package keys
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -11,7 +12,8 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"strconv"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/monax/bosmarmot/keys/crypto"
@@ -21,7 +23,7 @@ import (
 	"golang.org/x/crypto/ripemd160"
 )
 
-var ErrLocked = fmt.Errorf("account is locked")
+var ErrLocked = fmt.Errorf("key locked, unlock it with monax-keys unlock")
 
 var AccountManager *Manager
 
@@ -149,6 +151,53 @@ func coreImport(auth, keyType, theKey string) ([]byte, error) {
 	return key.Address, nil
 }
 
+// import a version 3 keystore file (as used by Ethereum clients) encrypted with passphrase,
+// storing it encrypted with auth
+func coreImportKeyStore(auth, keyStoreJson, passphrase string, force bool) ([]byte, error) {
+	log.Printf("Importing keystore. Encrypted (%v). Force (%v)\n", auth != "", force)
+
+	key, err := crypto.ImportKeyV3([]byte(keyStoreJson), passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("could not import keystore: %v", err)
+	}
+
+	dir, err := returnDataDir(KeysDir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get keys dir: %v", err)
+	}
+	if _, err := crypto.GetKeyFile(dir, key.Address); err == nil && !force {
+		return nil, fmt.Errorf("key %X already exists, use --force to overwrite it", key.Address)
+	}
+
+	var keyStore crypto.KeyStore
+	if auth == "" {
+		if keyStore, err = newKeyStore(); err != nil {
+			return nil, err
+		}
+	} else {
+		keyStore = AccountManager.KeyStore()
+	}
+	if err = keyStore.StoreKey(key, auth); err != nil {
+		return nil, err
+	}
+	return key.Address, nil
+}
+
+// export a key as a version 3 keystore file encrypted with passphrase
+func coreExportKey(addr, passphrase string) ([]byte, error) {
+	addrB, err := hex.DecodeString(addr)
+	if err != nil {
+		return nil, fmt.Errorf("addr is invalid hex: %s", err.Error())
+	}
+	key, err := GetKey(addrB)
+	if err == ErrLocked {
+		return nil, fmt.Errorf("key %X must be unlocked before it can be exported", addrB)
+	} else if err != nil {
+		return nil, err
+	}
+	return crypto.ExportKeyV3(key, passphrase)
+}
+
 func coreKeygen(auth, keyType string) ([]byte, error) {
 	var keyStore crypto.KeyStore
 	var err error
@@ -177,6 +226,38 @@ func coreKeygen(auth, keyType string) ([]byte, error) {
 	return key.Address, nil
 }
 
+// derive a key from a BIP39 mnemonic along the derivation path, defaulting to
+// the BIP44 Ethereum path for secp256k1 keys
+func coreGenerateFromMnemonic(auth, keyType, mnemonic, path string) ([]byte, error) {
+	var keyStore crypto.KeyStore
+	var err error
+
+	log.Printf("Deriving key from mnemonic. Type (%s). Path (%s). Encrypted (%v)\n", keyType, path, auth != "")
+
+	if auth == "" {
+		keyStore, err = newKeyStore()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		keyStore = AccountManager.KeyStore()
+	}
+
+	keyT, err := crypto.KeyTypeFromString(keyType)
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.NewKeyFromMnemonic(keyT, mnemonic, "", path)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key %s from mnemonic: %v", keyType, err)
+	}
+	if err = keyStore.StoreKey(key, auth); err != nil {
+		return nil, err
+	}
+	log.Printf("Derived key. Address (%x). Type (%s). Encrypted (%v)\n", key.Address, key.Type, auth != "")
+	return key.Address, nil
+}
+
 func coreSign(hash, addr string) ([]byte, error) {
 
 	hashB, err := hex.DecodeString(hash)
@@ -204,17 +285,17 @@ func coreVerify(typ, pub, hash, sig string) (result bool, err error) {
 	if err != nil {
 		return result, err
 	}
-	hashB, err := hex.DecodeString(hash)
+	hashB, err := decodeHexOrBase64("hash", hash)
 	if err != nil {
-		return result, fmt.Errorf("hash is invalid hex: %s", err.Error())
+		return result, err
 	}
-	pubB, err := hex.DecodeString(pub)
+	pubB, err := decodeHexOrBase64("pub", pub)
 	if err != nil {
-		return result, fmt.Errorf("addr is invalid hex: %s", err.Error())
+		return result, err
 	}
-	sigB, err := hex.DecodeString(sig)
+	sigB, err := decodeHexOrBase64("sig", sig)
 	if err != nil {
-		return result, fmt.Errorf("sig is invalid hex: %s", err.Error())
+		return result, err
 	}
 
 	result, err = crypto.Verify(keyT.CurveType, hashB, sigB, pubB)
@@ -225,6 +306,28 @@ func coreVerify(typ, pub, hash, sig string) (result bool, err error) {
 	return
 }
 
+// Recovers the public keys and addresses of type typ that could have signed hash, see crypto.Recover
+func coreRecover(typ, hash, sig string) ([]crypto.RecoveredKey, error) {
+	keyT, err := crypto.KeyTypeFromString(typ)
+	if err != nil {
+		return nil, err
+	}
+	hashB, err := decodeHexOrBase64("hash", hash)
+	if err != nil {
+		return nil, err
+	}
+	sigB, err := decodeHexOrBase64("sig", sig)
+	if err != nil {
+		return nil, err
+	}
+
+	recovered, err := crypto.Recover(keyT, hashB, sigB)
+	if err != nil {
+		return nil, fmt.Errorf("error recovering public key from signature %x: %v", sigB, err)
+	}
+	return recovered, nil
+}
+
 func corePub(addr string) ([]byte, error) {
 	addrB, err := hex.DecodeString(addr)
 	if err != nil {
@@ -286,29 +389,34 @@ func coreConvert(addr string) ([]byte, error) {
 	return wire.JSONBytes(privVal), nil
 }
 
-func coreUnlock(auth, addr, timeout string) error {
+// unlock an encrypted key for ttl, or until the server exits if ttl is 0, an unlocked key may be unlocked again
+// to change how long it stays unlocked for
+func coreUnlock(auth, addr string, ttl time.Duration) error {
 	addrB, err := hex.DecodeString(addr)
 	if err != nil {
 		return fmt.Errorf("addr is invalid hex: %s", err.Error())
 	}
 
-	if _, err := GetKey(addrB); err == nil {
-		return fmt.Errorf("Key is already unlocked or was never encrypted")
-	}
-
-	var timeoutD time.Duration
-	if timeout != "" {
-		t, err := strconv.ParseInt(timeout, 0, 64)
-		if err != nil {
+	if AccountManager.GetKey(addrB) == nil {
+		if _, err := GetKey(addrB); err == nil {
+			return fmt.Errorf("Key was never encrypted")
+		} else if err != ErrLocked {
 			return err
 		}
-		timeoutD = time.Duration(t)
 	}
+	if ttl < 0 {
+		return fmt.Errorf("cannot unlock key for a negative duration %v", ttl)
+	}
+	return AccountManager.TimedUnlock(addrB, auth, ttl)
+}
 
-	if err := AccountManager.TimedUnlock(addrB, auth, timeoutD*time.Minute); err != nil {
-		return err
+// lock an unlocked key straight away
+func coreLock(addr string) error {
+	addrB, err := hex.DecodeString(addr)
+	if err != nil {
+		return fmt.Errorf("addr is invalid hex: %s", err.Error())
 	}
-	return nil
+	return AccountManager.Lock(addrB)
 }
 
 func coreHash(typ, data string, hexD bool) ([]byte, error) {
@@ -407,3 +515,132 @@ func coreNameGet(name string) (string, error) {
 	}
 	return string(b), nil
 }
+
+//----------------------------------------------------------------
+// list keys
+
+// A key in the data dir described without any of its private key material
+type KeyInfo struct {
+	Address string
+	// Public keys are not stored so this is empty for an encrypted key that is locked
+	PublicKey string
+	CurveType string
+	KeyType   string
+	Names     []string
+	Encrypted bool
+	// Keys do not record when they were made so this is when the key file was last written
+	Created time.Time
+}
+
+// An entry in the data dir that could not be read as a key
+type SkippedKey struct {
+	File   string
+	Reason string
+}
+
+type KeyList struct {
+	Keys    []*KeyInfo
+	Skipped []*SkippedKey
+}
+
+// list the keys in the data dir with the given curve type and a name starting with namePrefix (either
+// may be empty to match any key), entries that are not readable keys are reported as skipped
+func coreListKeys(curveType, namePrefix string) (*KeyList, error) {
+	if curveType != "" {
+		if _, err := crypto.CurveTypeFromString(curveType); err != nil {
+			return nil, err
+		}
+	}
+	dir, err := returnDataDir(KeysDir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := coreNameList()
+	if err != nil {
+		return nil, err
+	}
+	addrNames := make(map[string][]string)
+	for name, addr := range names {
+		addr = strings.ToUpper(addr)
+		addrNames[addr] = append(addrNames[addr], name)
+	}
+	fs, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keyList := &KeyList{Keys: []*KeyInfo{}, Skipped: []*SkippedKey{}}
+	for _, f := range fs {
+		keyInfo, err := readKeyInfo(dir, f)
+		if err != nil {
+			keyList.Skipped = append(keyList.Skipped, &SkippedKey{File: f.Name(), Reason: err.Error()})
+			continue
+		}
+		if curveType != "" && keyInfo.CurveType != curveType {
+			continue
+		}
+		keyInfo.Names = addrNames[keyInfo.Address]
+		sort.Strings(keyInfo.Names)
+		if namePrefix != "" && !hasNamePrefix(keyInfo.Names, namePrefix) {
+			continue
+		}
+		keyList.Keys = append(keyList.Keys, keyInfo)
+	}
+	return keyList, nil
+}
+
+// read the key stored under the data dir entry f as written by crypto.WriteKeyFile
+func readKeyInfo(dir string, f os.FileInfo) (*KeyInfo, error) {
+	if !f.IsDir() {
+		return nil, fmt.Errorf("not a key directory")
+	}
+	dirAddr, err := hex.DecodeString(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("directory name is not a hex address")
+	}
+	keyFile := path.Join(dir, f.Name(), f.Name())
+	stat, err := os.Stat(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %v", err)
+	}
+	keyJson, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %v", err)
+	}
+	addr, keyT, encrypted, err := crypto.KeyFileInfo(keyJson)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file: %v", err)
+	}
+	if !bytes.Equal(addr, dirAddr) {
+		return nil, fmt.Errorf("key file is for address %X", addr)
+	}
+
+	keyInfo := &KeyInfo{
+		Address:   fmt.Sprintf("%X", addr),
+		CurveType: keyT.CurveType.String(),
+		KeyType:   keyT.String(),
+		Encrypted: encrypted,
+		Created:   stat.ModTime(),
+	}
+	key, err := GetKey(addr)
+	if err == ErrLocked {
+		return keyInfo, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid key file: %v", err)
+	}
+	pub, err := key.Pubkey()
+	if err != nil {
+		return nil, fmt.Errorf("could not derive public key: %v", err)
+	}
+	keyInfo.PublicKey = fmt.Sprintf("%X", pub)
+	return keyInfo, nil
+}
+
+func hasNamePrefix(names []string, prefix string) bool {
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,14 @@ package keys
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
 	"testing"
 
 	"github.com/monax/bosmarmot/keys/common"
@@ -82,6 +88,52 @@ func TestSignAndVerify(t *testing.T) {
 	}
 }
 
+func testRecoverAndEncodings(t *testing.T, typ string) {
+	addr, err := coreKeygen(AUTH, typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := corePub(toHex(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := crypto.Sha3([]byte("the hash of something else!"))
+	sig, err := coreSign(toHex(hash), toHex(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.StdEncoding.EncodeToString
+	res, err := coreVerify(typ, b64(pub), "0x"+toHex(hash), b64(sig))
+	if !assert.NoError(t, err) || !assert.True(t, res) {
+		t.Errorf("Signature (type %s) given as base64 failed to verify", typ)
+	}
+	_, err = coreVerify(typ, toHex(pub), toHex(hash), toHex(sig[:len(sig)-1]))
+	assert.Error(t, err, "truncated signature (type %s) should be an error", typ)
+	_, err = coreVerify(typ, toHex(pub), toHex(hash), "not a signature!")
+	assert.Error(t, err, "signature (type %s) that is neither hex nor base64 should be an error", typ)
+
+	recovered, err := coreRecover(typ, toHex(hash), b64(sig))
+	if strings.HasPrefix(typ, "ed25519") {
+		assert.Error(t, err, "recovery is only possible for secp256k1")
+		return
+	}
+	if !assert.NoError(t, err) {
+		return
+	}
+	found := false
+	for _, key := range recovered {
+		found = found || bytes.Equal(key.Address, addr) && bytes.Equal(key.PubKey, pub)
+	}
+	assert.True(t, found, "expected address %X (type %s) among those recovered: %v", addr, typ, recovered)
+}
+
+func TestRecoverAndEncodings(t *testing.T) {
+	for _, typ := range KEY_TYPES {
+		testRecoverAndEncodings(t, typ)
+	}
+}
+
 func testHash(t *testing.T, typ string) {
 	hData := hashData[typ]
 	data, expected := hData.data, hData.expected
@@ -112,6 +164,164 @@ func TestHash(t *testing.T) {
 	}
 }
 
+func TestGenerateFromMnemonic(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	for _, typ := range KEY_TYPES {
+		addr1, err := coreGenerateFromMnemonic(AUTH, typ, mnemonic, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr2, err := coreGenerateFromMnemonic(AUTH, typ, mnemonic, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(addr1, addr2) {
+			t.Fatalf("Same mnemonic gave different addrs %X and %X (type %s)", addr1, addr2, typ)
+		}
+
+		pub, err := corePub(toHex(addr1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkAddrFromPub(typ, pub, addr1); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testExportAndImportKeyStore(t *testing.T, typ string) {
+	addr, err := coreKeygen(AUTH, typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyStore, err := coreExportKey(toHex(addr), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := coreImportKeyStore(AUTH, string(keyStore), "passphrase", false); err == nil {
+		t.Fatalf("Import of existing key %X (type %s) should fail without force", addr, typ)
+	}
+	addr2, err := coreImportKeyStore(AUTH, string(keyStore), "passphrase", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(addr, addr2) {
+		t.Fatalf("Imported keystore addr doesn't match exported key. Got %X, expected %X", addr2, addr)
+	}
+
+	// the imported key must still sign for its address
+	pub, err := corePub(toHex(addr2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkAddrFromPub(typ, pub, addr2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportAndImportKeyStore(t *testing.T) {
+	for _, typ := range []string{"secp256k1,sha3", "ed25519,ripemd160"} {
+		testExportAndImportKeyStore(t, typ)
+	}
+}
+
+func TestListKeys(t *testing.T) {
+	dir, err := returnDataDir(KeysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// entries that are not keys must be skipped rather than failing the listing
+	junkFile, junkDir := path.Join(dir, "ls-junk"), path.Join(dir, "ABCDEF")
+	if err := ioutil.WriteFile(junkFile, []byte("junk"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(junkFile)
+	if err := crypto.WriteKeyFile([]byte{0xab, 0xcd, 0xef}, dir, []byte("{not json")); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(junkDir)
+
+	addrs := make(map[string]string)
+	for i, typ := range KEY_TYPES {
+		addr, err := coreKeygen(AUTH, typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("ls-test-%d", i)
+		if err := coreNameAdd(name, toHex(addr)); err != nil {
+			t.Fatal(err)
+		}
+		addrs[toHex(addr)] = typ
+	}
+	locked, err := coreKeygen("password", "ed25519,ripemd160")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := coreNameAdd("ls-test-locked", toHex(locked)); err != nil {
+		t.Fatal(err)
+	}
+
+	keyList, err := coreListKeys("", "ls-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keyList.Keys) != len(KEY_TYPES)+1 {
+		t.Fatalf("Expected %v keys named ls-test- but got %v", len(KEY_TYPES)+1, len(keyList.Keys))
+	}
+	for _, k := range keyList.Keys {
+		if k.Address == toHex(locked) {
+			assert.True(t, k.Encrypted)
+			assert.Equal(t, "", k.PublicKey)
+			assert.Equal(t, []string{"ls-test-locked"}, k.Names)
+			continue
+		}
+		typ, ok := addrs[k.Address]
+		if !assert.True(t, ok, "unexpected key %s", k.Address) {
+			continue
+		}
+		assert.Equal(t, typ, k.KeyType)
+		assert.False(t, k.Encrypted)
+		addr, _ := hex.DecodeString(k.Address)
+		pub, _ := hex.DecodeString(k.PublicKey)
+		if err := checkAddrFromPub(typ, pub, addr); err != nil {
+			t.Error(err)
+		}
+
+		// the private key must not appear in any encoding
+		key, err := GetKey(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := json.Marshal(keyList)
+		for _, priv := range []string{toHex(key.PrivateKey), hex.EncodeToString(key.PrivateKey),
+			base64.StdEncoding.EncodeToString(key.PrivateKey)} {
+			assert.NotContains(t, string(b), priv)
+		}
+	}
+
+	skipped := make(map[string]bool)
+	for _, s := range keyList.Skipped {
+		skipped[s.File] = true
+	}
+	assert.True(t, skipped["ls-junk"], "file ls-junk should be skipped")
+	assert.True(t, skipped["ABCDEF"], "corrupt key ABCDEF should be skipped")
+
+	keyList, err = coreListKeys("secp256k1", "ls-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keyList.Keys {
+		assert.Equal(t, "secp256k1", k.CurveType)
+	}
+	assert.Len(t, keyList.Keys, 2)
+
+	if _, err := coreListKeys("rsa", ""); err == nil {
+		t.Errorf("Listing keys of an unknown curve type should fail")
+	}
+}
+
 //--------------------------------------------------------------------------------
 
 func toHex(b []byte) string {
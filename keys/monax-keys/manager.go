@@ -21,6 +21,7 @@ package keys
 // We really only wanted the TimeoutUnlock feature
 
 import (
+	"fmt"
 	"log"
 	"sync"
 	"time"
@@ -99,6 +100,24 @@ func (am *Manager) TimedUnlock(addr []byte, keyAuth string, timeout time.Duratio
 	return nil
 }
 
+// Lock locks an unlocked account straight away rather than waiting for its
+// timeout, zeroing its private key in memory.
+func (am *Manager) Lock(addr []byte) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	u, ok := am.unlocked[string(addr)]
+	if !ok {
+		return fmt.Errorf("key %X is not unlocked", addr)
+	}
+	if u.abort != nil {
+		close(u.abort)
+	}
+	log.Printf("Locking %X\n", addr)
+	zeroKey(u.PrivateKey)
+	delete(am.unlocked, string(addr))
+	return nil
+}
+
 func (am *Manager) expire(addr []byte, u *unlocked, timeout time.Duration) {
 	t := time.NewTimer(timeout)
 	defer t.Stop()
@@ -110,6 +110,50 @@ func TestOverrideUnlock(t *testing.T) {
 	}
 }
 
+func TestLock(t *testing.T) {
+	_, ks := tmpKeyStore(t, crypto.NewKeyStorePassphrase)
+
+	AccountManager = NewManager(ks)
+	pass := "foo"
+	addr, err := coreKeygen(pass, keyType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrHex := hex.EncodeToString(addr)
+
+	if err = coreLock(addrHex); err == nil {
+		t.Fatal("Locking a key that is not unlocked should fail")
+	}
+	if err = coreUnlock("bar", addrHex, time.Minute); err == nil {
+		t.Fatal("Unlocking with the wrong passphrase should fail")
+	}
+	if err = coreUnlock(pass, addrHex, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// Unlocking again changes the ttl
+	if err = coreUnlock(pass, addrHex, time.Hour); err != nil {
+		t.Fatal("Unlocking an unlocked key should extend its ttl, got ", err)
+	}
+	if _, err = coreSign(testSigData, addrHex); err != nil {
+		t.Fatal("Signing shouldn't return an error after unlocking, got ", err)
+	}
+
+	// Signing fails straight away after locking
+	key := AccountManager.GetKey(addr)
+	if err = coreLock(addrHex); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range key.PrivateKey {
+		if b != 0 {
+			t.Fatal("Private key should be zeroed after locking")
+		}
+	}
+	_, err = coreSign(testSigData, addrHex)
+	if err != ErrLocked {
+		t.Fatal("Signing should've failed with ErrLocked after locking, got ", err)
+	}
+}
+
 // This test should fail under -race if signing races the expiration goroutine.
 func TestSignRace(t *testing.T) {
 	_, ks := tmpKeyStore(t, crypto.NewKeyStorePassphrase)
@@ -0,0 +1,113 @@
+package keys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bcm "github.com/hyperledger/burrow/blockchain"
+	"github.com/hyperledger/burrow/consensus/tendermint/query"
+	"github.com/hyperledger/burrow/event"
+	"github.com/hyperledger/burrow/execution"
+	"github.com/hyperledger/burrow/genesis"
+	burrow_keys "github.com/hyperledger/burrow/keys"
+	"github.com/hyperledger/burrow/logging/loggers"
+	"github.com/hyperledger/burrow/permission"
+	"github.com/hyperledger/burrow/rpc"
+	"github.com/hyperledger/burrow/txs"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+// Executes each tx it is given in a block of its own
+type commitTransactor struct {
+	execution.Transactor
+	chainID   string
+	committer execution.BatchCommitter
+}
+
+func (trans *commitTransactor) BroadcastTx(tx txs.Tx) (*txs.Receipt, error) {
+	if err := trans.committer.Execute(tx); err != nil {
+		return nil, err
+	}
+	if _, err := trans.committer.Commit(); err != nil {
+		return nil, err
+	}
+	receipt := txs.GenerateReceipt(trans.chainID, tx)
+	return &receipt, nil
+}
+
+// Sees no pending transactions so the service takes the next sequence of an account from state alone
+type emptyMempoolNodeView struct {
+	query.NodeView
+}
+
+func (nv *emptyMempoolNodeView) MempoolTransactions(maxTxs int) ([]txs.Tx, error) {
+	return nil, nil
+}
+
+// A transaction formulated by the rpc service and signed by the keys server verifies and commits
+func TestServerSignFormulatedTx(t *testing.T) {
+	keyClient := burrow_keys.NewKeyClient(TestAddr, loggers.NewNoopInfoTraceLogger())
+	address, err := keyClient.Generate("", burrow_keys.KeyTypeEd25519Ripemd160)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The key's account is funded at genesis but, never having transacted, state does not know its public key
+	genesisDoc, privateAccounts := genesis.NewDeterministicGenesis(1).GenesisDoc(1, false, 1000, 1, false, 1000)
+	genesisDoc.Accounts = append(genesisDoc.Accounts, genesis.Account{
+		BasicAccount: genesis.BasicAccount{Address: address, Amount: 1000},
+		Name:         "offline",
+		Permissions:  permission.DefaultAccountPermissions,
+	})
+	state, err := execution.MakeGenesisState(dbm.NewMemDB(), genesisDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactor := &commitTransactor{
+		chainID: genesisDoc.ChainID(),
+		committer: execution.NewBatchCommitter(state, genesisDoc.ChainID(), bcm.NewTip(0, time.Now(), nil, nil),
+			event.NewNoOpPublisher(), loggers.NewNoopInfoTraceLogger()),
+	}
+	service := rpc.NewService(context.Background(), state, state, nil, bcm.NewBlockchain(genesisDoc), transactor,
+		&emptyMempoolNodeView{}, loggers.NewNoopInfoTraceLogger())
+
+	to := privateAccounts[0].Address()
+	formulated, err := service.FormulateTx(context.Background(), rpc.FormulateSendTx, rpc.FormulateTxParams{
+		Input:   address,
+		Amount:  100,
+		Address: &to,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := keyClient.Sign(address, formulated.SignBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := keyClient.PublicKey(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !publicKey.VerifyBytes(formulated.SignBytes, signature) {
+		t.Fatalf("signature made by the keys server does not verify against the sign bytes")
+	}
+
+	if _, err := service.BroadcastSignedTx(context.Background(), formulated.TxBytes,
+		[]rpc.TxSignature{{PublicKey: publicKey, Signature: signature}}); err != nil {
+		t.Fatal(err)
+	}
+	account, err := state.GetAccount(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance() != 900 || account.Sequence() != 1 || account.PublicKey() != publicKey {
+		t.Errorf("expected the signed transaction to have been committed but the sender is %v", account)
+	}
+	account, err = state.GetAccount(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance() != 1100 {
+		t.Errorf("expected the recipient to have been paid but its balance is %v", account.Balance())
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rs/cors"
 )
@@ -29,11 +31,15 @@ func StartServer(host, port string) error {
 	mux.HandleFunc("/pub", pubHandler)
 	mux.HandleFunc("/sign", signHandler)
 	mux.HandleFunc("/verify", verifyHandler)
+	mux.HandleFunc("/recover", recoverHandler)
 	mux.HandleFunc("/hash", hashHandler)
 	mux.HandleFunc("/import", importHandler)
+	mux.HandleFunc("/import/keystore", importKeyStoreHandler)
+	mux.HandleFunc("/export", exportHandler)
 	mux.HandleFunc("/name", nameHandler)
 	mux.HandleFunc("/name/ls", nameLsHandler)
 	mux.HandleFunc("/name/rm", nameRmHandler)
+	mux.HandleFunc("/ls", lsHandler)
 	mux.HandleFunc("/unlock", unlockHandler)
 	mux.HandleFunc("/lock", lockHandler)
 	mux.HandleFunc("/mint", convertMintHandler)
@@ -48,6 +54,12 @@ func StartServer(host, port string) error {
 // A request is just a map of args to be json marshalled
 type HTTPRequest map[string]string
 
+// A key returned by /recover, hex encoded
+type RecoveredKey struct {
+	Address string
+	PubKey  string
+}
+
 // dead simple response struct
 type HTTPResponse struct {
 	Response string
@@ -76,8 +88,13 @@ func genHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	name := args["name"]
-	addr, err := coreKeygen(auth, typ)
+	name, mnemonic := args["name"], args["mnemonic"]
+	var addr []byte
+	if mnemonic != "" {
+		addr, err = coreGenerateFromMnemonic(auth, typ, mnemonic, args["path"])
+	} else {
+		addr, err = coreKeygen(auth, typ)
+	}
 	if err != nil {
 		WriteError(w, err)
 		return
@@ -98,19 +115,43 @@ func unlockHandler(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, err)
 		return
 	}
-	addr, name, timeout := args["addr"], args["name"], args["timeout"]
+	addr, name := args["addr"], args["name"]
 	addr, err = getNameAddr(name, addr)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
-	if err := coreUnlock(auth, addr, timeout); err != nil {
+	ttl, err := unlockTTL(args["ttl"], args["timeout"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if err := coreUnlock(auth, addr, ttl); err != nil {
 		WriteError(w, err)
 		return
 	}
 	WriteResult(w, fmt.Sprintf("%s unlocked", addr))
 }
 
+// how long to unlock a key for from either a duration such as 90s or 10m, or a timeout in minutes
+func unlockTTL(ttl, timeout string) (time.Duration, error) {
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return 0, fmt.Errorf("ttl is not a valid duration: %v", err)
+		}
+		return d, nil
+	}
+	if timeout == "" {
+		return 0, nil
+	}
+	minutes, err := strconv.ParseInt(timeout, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("timeout is not a whole number of minutes: %v", err)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
 func convertMintHandler(w http.ResponseWriter, r *http.Request) {
 	_, _, args, err := typeAuthArgs(r)
 	if err != nil {
@@ -132,7 +173,22 @@ func convertMintHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func lockHandler(w http.ResponseWriter, r *http.Request) {
-	// TODO
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	addr, name := args["addr"], args["name"]
+	addr, err = getNameAddr(name, addr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if err := coreLock(addr); err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("%s locked", addr))
 }
 
 func pubHandler(w http.ResponseWriter, r *http.Request) {
@@ -208,6 +264,43 @@ func verifyHandler(w http.ResponseWriter, r *http.Request) {
 	WriteResult(w, fmt.Sprintf("%v", res))
 }
 
+// Responds with the JSON list of keys that could have made the signature, each with its address and public key
+func recoverHandler(w http.ResponseWriter, r *http.Request) {
+	typ, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	msg, sig := args["msg"], args["sig"]
+	if msg == "" {
+		WriteError(w, fmt.Errorf("must provide a message msg with the `msg` key"))
+		return
+	}
+	if sig == "" {
+		WriteError(w, fmt.Errorf("must provide a signature with the `sig` key"))
+		return
+	}
+
+	recovered, err := coreRecover(typ, msg, sig)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	keys := make([]RecoveredKey, len(recovered))
+	for i, key := range recovered {
+		keys[i] = RecoveredKey{
+			Address: fmt.Sprintf("%X", key.Address),
+			PubKey:  fmt.Sprintf("%X", key.PubKey),
+		}
+	}
+	b, err := json.Marshal(keys)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, string(b))
+}
+
 func hashHandler(w http.ResponseWriter, r *http.Request) {
 	typ, _, args, err := typeAuthArgs(r)
 	if err != nil {
@@ -248,6 +341,53 @@ func importHandler(w http.ResponseWriter, r *http.Request) {
 	WriteResult(w, fmt.Sprintf("%X", addr))
 }
 
+func importKeyStoreHandler(w http.ResponseWriter, r *http.Request) {
+	_, auth, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	name, keyStore, passphrase := args["name"], args["keystore"], args["passphrase"]
+	if keyStore == "" {
+		WriteError(w, fmt.Errorf("must provide a keystore file with the `keystore` key"))
+		return
+	}
+
+	addr, err := coreImportKeyStore(auth, keyStore, passphrase, args["force"] == "true")
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	if name != "" {
+		if err := coreNameAdd(name, strings.ToUpper(hex.EncodeToString(addr))); err != nil {
+			WriteError(w, err)
+			return
+		}
+	}
+	WriteResult(w, fmt.Sprintf("%X", addr))
+}
+
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	addr, name := args["addr"], args["name"]
+	addr, err = getNameAddr(name, addr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	keyStore, err := coreExportKey(addr, args["passphrase"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, string(keyStore))
+}
+
 func nameHandler(w http.ResponseWriter, r *http.Request) {
 	_, _, args, err := typeAuthArgs(r)
 	if err != nil {
@@ -326,6 +466,27 @@ func nameRmHandler(w http.ResponseWriter, r *http.Request) {
 	WriteResult(w, fmt.Sprintf("Removed name (%s)", name))
 }
 
+func lsHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	keyList, err := coreListKeys(args["curve"], args["prefix"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	b, err := json.Marshal(keyList)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, string(b))
+}
+
 // convenience function
 func typeAuthArgs(r *http.Request) (typ string, auth string, args map[string]string, err error) {
 
@@ -2,10 +2,13 @@ package keys
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/howeyc/gopass"
@@ -15,8 +18,20 @@ import (
 //------------------------------------------------------------
 // auth
 
+// The environment variable a passphrase can be read from in scripts instead of prompting for it
+const PassphraseEnv = "MONAX_KEYS_PASSPHRASE"
+
+// reads the passphrase of a key from PassphraseEnv unless --prompt is given or it is unset,
+// in which case it is prompted for so it never ends up in shell history
 func hiddenAuth() string {
-	fmt.Printf("Enter Password:")
+	if passphrase := os.Getenv(PassphraseEnv); !Prompt && passphrase != "" {
+		return passphrase
+	}
+	return hiddenAuthPrompt("Enter Password:")
+}
+
+func hiddenAuthPrompt(prompt string) string {
+	fmt.Print(prompt)
 	pwd, err := gopass.GetPasswdMasked()
 	if err != nil {
 		common.IfExit(err)
@@ -51,6 +66,26 @@ func getNameAddr(name, addr string) (string, error) {
 	return strings.ToUpper(addr), nil
 }
 
+//------------------------------------------------------------
+// encodings
+
+// decodes the named argument as hex, with or without a 0x prefix, falling back to base64 (padded or not) so that
+// signatures and keys can be pasted in either form. Input that is valid as both is taken as hex.
+func decodeHexOrBase64(name, s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	hexString := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if b, err := hex.DecodeString(hexString); err == nil {
+		return b, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	if b, err := base64.RawStdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return nil, fmt.Errorf("%s is neither hex nor base64: %s", name, s)
+}
+
 //------------------------------------------------------------
 // http client
 
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+
+	compilers "github.com/monax/bosmarmot/compilers/perform"
+	"github.com/monax/bosmarmot/monax/util"
+	"github.com/spf13/cobra"
+)
+
+var Compile = &cobra.Command{
+	Use:   "compile [FILE ...]",
+	Short: "compile contracts, reusing cached compiler output for unchanged sources",
+	Long: `compile contracts, reusing cached compiler output for unchanged sources
+
+Compiler output is cached by the hash of the flattened source together with
+the compiler version, optimizer setting, EVM version and libraries. The cache
+is kept in ~/.bosmarmot/cache unless $BOSMARMOT_CACHE is set.`,
+	Run: CompileContracts,
+}
+
+var (
+	compileOptimize   bool
+	compileLibraries  string
+	compileSolc       string
+	compileCleanCache bool
+)
+
+func buildCompileCommand() {
+	addCompileFlags()
+}
+
+func addCompileFlags() {
+	Compile.Flags().BoolVarP(&compileOptimize, "optimize", "o", false, "optimize code (solidity only)")
+	Compile.Flags().StringVarP(&compileLibraries, "libs", "L", "", "libraries string (libName:Address[, or whitespace]...)")
+	Compile.Flags().StringVarP(&compileSolc, "solc", "", "", "version of solc to compile with, downloading it if needed (otherwise resolved from the version pragma)")
+	Compile.Flags().StringVarP(&compilers.EVMVersion, "evm-version", "", "", "version of the EVM solc should target; the compiler default if not given")
+	Compile.Flags().StringSliceVarP(&compilers.Remappings, "remap", "", nil, "import remapping of prefix=target for solc, may be given more than once")
+	Compile.Flags().IntVarP(&compilers.OptimizerRuns, "optimizer-runs", "", compilers.OptimizerRuns, "number of runs solc should optimize for when optimizing")
+	Compile.Flags().BoolVarP(&compilers.AllowOversize, "allow-oversize", "", false, "warn about contracts whose deployed bytecode exceeds the EVM limit of 24576 bytes rather than failing")
+	Compile.Flags().BoolVarP(&compilers.NoCache, "no-cache", "", false, "always compile, without reading or writing the compiler cache")
+	Compile.Flags().BoolVarP(&compileCleanCache, "clean-cache", "", false, "remove all cached compiler output before compiling any files given")
+}
+
+func CompileContracts(cmd *cobra.Command, args []string) {
+	if compileCleanCache {
+		util.IfExit(compilers.CleanCache())
+	} else {
+		util.IfExit(ArgCheck(1, "ge", cmd, args))
+	}
+
+	for _, file := range args {
+		resp, err := compilers.RequestCompile(file, compileOptimize, compileLibraries, compileSolc)
+		util.IfExit(err)
+		if resp.Error != "" {
+			util.IfExit(fmt.Errorf("could not compile %s: %s", file, resp.Error))
+		}
+		compilers.PrintResponse(*resp, true)
+	}
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/monax/bosmarmot/monax/pkgs/console"
+	"github.com/monax/bosmarmot/monax/util"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var Console = &cobra.Command{
+	Use:   "console",
+	Short: "call the contracts of a deployment interactively",
+	Long: `call the contracts of a deployment interactively
+
+The contracts deployed by a package are loaded from its jobs output and the
+ABIs saved in the abi directory, each named by the job that deployed it.
+Expressions such as MyContract.balanceOf($alice) are encoded against the ABI
+and run as a simulated call when the function is view or pure, or as a
+committed transaction otherwise, with the decoded return values and events
+printed. $name is replaced by the result of the job called name and tab
+completes contract, function and job names.
+
+With --exec each expression given is evaluated in turn instead, failing at
+the first that fails, and when input is not a terminal an expression is read
+from each line of it.`,
+	Run: RunConsole,
+}
+
+var (
+	consoleOutput string
+	consoleExec   []string
+	consoleAmount string
+	consoleGas    string
+	consoleFee    string
+	consoleAddr   string
+	consoleChain  string
+	consoleSigner string
+	consoleABI    string
+)
+
+func buildConsoleCommand() {
+	addConsoleFlags()
+}
+
+func addConsoleFlags() {
+	Console.Flags().StringVarP(&consoleOutput, "output", "o", "epm.output.json", "jobs output file of the deployment to load")
+	Console.Flags().StringSliceVarP(&consoleExec, "exec", "x", nil, "expression to evaluate rather than starting the console, may be given more than once")
+	Console.Flags().StringVarP(&consoleChain, "chain-url", "", "tcp://localhost:46657", "chain-url to be used in tcp://IP:PORT format")
+	Console.Flags().StringVarP(&consoleSigner, "keys", "s", defaultSigner(), "IP:PORT of keys daemon to sign transactions with")
+	Console.Flags().StringVarP(&consoleABI, "abi-path", "", "./abi", "path to the abi directory the deployment saved ABIs to")
+	Console.Flags().StringVarP(&consoleAddr, "address", "a", "", "address to call from, needed to commit transactions")
+	Console.Flags().StringVarP(&consoleGas, "gas", "g", "1111111111", "gas to give each call")
+	Console.Flags().StringVarP(&consoleFee, "fee", "n", "9999", "fee to pay for each transaction")
+	Console.Flags().StringVarP(&consoleAmount, "amount", "u", "0", "amount to send with each transaction")
+}
+
+func RunConsole(cmd *cobra.Command, args []string) {
+	util.IfExit(ArgCheck(0, "eq", cmd, args))
+	do.ChainURL = consoleChain
+	do.Signer = consoleSigner
+	do.ABIPath = consoleABI
+	do.DefaultAddr = consoleAddr
+	do.DefaultGas = consoleGas
+	do.DefaultFee = consoleFee
+	do.DefaultAmount = consoleAmount
+
+	c, err := console.NewConsole(do, consoleOutput, util.NodeClient(do))
+	util.IfExit(err)
+	if len(consoleExec) > 0 {
+		for _, expression := range consoleExec {
+			if err := c.Eval(expression, os.Stdout); err != nil {
+				util.IfExit(fmt.Errorf("%s: %v", expression, err))
+			}
+		}
+		return
+	}
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		util.IfExit(c.Script(os.Stdin, os.Stdout))
+		return
+	}
+	state, err := terminal.MakeRaw(fd)
+	util.IfExit(err)
+	err = interactiveConsole(c, struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout})
+	terminal.Restore(fd, state)
+	util.IfExit(err)
+}
+
+// Reads lines from a terminal, with tab completion of contract and function names, until exit or end of input
+func interactiveConsole(c *console.Console, rw io.ReadWriter) error {
+	term := terminal.NewTerminal(rw, console.Prompt)
+	term.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return c.Complete(line, pos)
+	}
+	for {
+		line, err := term.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !c.Command(line, term) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/monax/bosmarmot/monax/loaders"
+	"github.com/monax/bosmarmot/monax/pkgs/jobs"
+	"github.com/monax/bosmarmot/monax/util"
+	"github.com/spf13/cobra"
+)
+
+var Graph = &cobra.Command{
+	Use:   "graph",
+	Short: "print the dependencies between the jobs of a package in DOT format",
+	Long: `print the dependencies between the jobs of a package in DOT format
+
+Each job is drawn with an edge to every job that depends_on it and parallel
+groups are drawn as clusters of their sub-jobs. The graph is checked for
+unknown dependencies and cycles as it would be before running the jobs. Render
+it with Graphviz, for example: bos graph | dot -Tsvg > jobs.svg`,
+	Run: GraphJobs,
+}
+
+var graphFile string
+
+func buildGraphCommand() {
+	addGraphFlags()
+}
+
+func addGraphFlags() {
+	Graph.Flags().StringVarP(&graphFile, "file", "f", "epm.yaml", "path to package file whose jobs should be graphed")
+}
+
+func GraphJobs(cmd *cobra.Command, args []string) {
+	util.IfExit(ArgCheck(0, "eq", cmd, args))
+	pkg, err := loaders.LoadPackage(graphFile)
+	util.IfExit(err)
+	dot, err := jobs.JobGraphDOT(pkg.Jobs)
+	util.IfExit(err)
+	fmt.Print(dot)
+}
@@ -54,8 +54,16 @@ func Execute() {
 func AddCommands() {
 	buildPackagesCommand()
 	buildKeysCommand()
+	buildCompileCommand()
+	buildGraphCommand()
+	buildConsoleCommand()
+	buildVerifyCommand()
 	BosCmd.AddCommand(Packages)
 	BosCmd.AddCommand(Keys)
+	BosCmd.AddCommand(Compile)
+	BosCmd.AddCommand(Graph)
+	BosCmd.AddCommand(Console)
+	BosCmd.AddCommand(Verify)
 	BosCmd.AddCommand(&cobra.Command{
 		Use:   "version",
 		Short: "Print Version",
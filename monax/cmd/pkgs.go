@@ -3,9 +3,12 @@ package commands
 import (
 	"fmt"
 
+	compilers "github.com/monax/bosmarmot/compilers/perform"
 	"github.com/monax/bosmarmot/monax/pkgs"
+	"github.com/monax/bosmarmot/monax/pkgs/jobs"
 	"github.com/monax/bosmarmot/monax/util"
 
+	"github.com/hyperledger/burrow/client"
 	"github.com/monax/bosmarmot/monax/keys"
 	"github.com/spf13/cobra"
 )
@@ -33,13 +36,16 @@ in a package definition file`,
 	Run: PackagesDo,
 }
 
+var abortOnFirstFailure bool
+var outputs []string
+
 func addPackagesFlags() {
 	packagesDo.Flags().StringVarP(&do.ChainURL, "chain-url", "", "tcp://localhost:46657", "chain-url to be used in tcp://IP:PORT format (only necessary for cluster and remote operations)")
 	packagesDo.Flags().StringVarP(&do.Signer, "keys", "s", defaultSigner(), "IP:PORT of keys daemon which jobs should use")
 	packagesDo.Flags().StringVarP(&do.Path, "dir", "i", "", "root directory of app (will use $pwd by default)")
-	packagesDo.Flags().StringVarP(&do.DefaultOutput, "output", "o", "epm.output.json", "filename for jobs output file. by default, this name will reflect the name passed in on the optional [--file]")
+	packagesDo.Flags().StringSliceVarP(&outputs, "output", "o", []string{"epm.output.json"}, "filename for jobs output file. by default, this name will reflect the name passed in on the optional [--file]. junit=path or summary=path also write a JUnit XML report or a JSON summary of the run, may be given more than once")
 	packagesDo.Flags().StringVarP(&do.YAMLPath, "file", "f", "epm.yaml", "path to package file which jobs should use. if also using the --dir flag, give the relative path to jobs file, which should be in the same directory")
-	packagesDo.Flags().StringSliceVarP(&do.DefaultSets, "set", "e", []string{}, "default sets to use; operates the same way as the [set] jobs, only before the jobs file is ran (and after default address")
+	packagesDo.Flags().StringSliceVarP(&do.DefaultSets, "set", "e", []string{}, "default sets to use as key=value; operates the same way as the [set] jobs, only before the jobs file is ran (and after default address). overrides the variables declared in the jobs file and the $env.NAME and $file(path) variables when given as env.NAME=value or file(path)=value")
 	// the package manager does not use this flag!
 	// packagesDo.Flags().StringVarP(&do.ContractsPath, "contracts-path", "p", "./contracts", "path to the contracts jobs should use")
 	packagesDo.Flags().StringVarP(&do.BinPath, "bin-path", "", "./bin", "path to the bin directory jobs should use when saving binaries after the compile process")
@@ -49,6 +55,24 @@ func addPackagesFlags() {
 	packagesDo.Flags().StringVarP(&do.DefaultFee, "fee", "n", "9999", "default fee to use")
 	packagesDo.Flags().StringVarP(&do.DefaultAmount, "amount", "u", "9999", "default amount to use")
 	packagesDo.Flags().BoolVarP(&do.Overwrite, "overwrite", "t", true, "overwrite jobs of the same name")
+	packagesDo.Flags().BoolVarP(&do.DryRun, "dry-run", "", false, "simulate every job against current chain state without broadcasting any transactions")
+	packagesDo.Flags().StringVarP(&do.SignOnly, "sign-only", "", "", "file to write the transactions jobs make to, signed for the accounts the keys server holds keys for, rather than broadcasting them; the chain does not change so each account can make only one transaction")
+	packagesDo.Flags().StringVarP(&do.Broadcast, "broadcast", "", "", "file of transactions written with --sign-only to add the signatures the keys server can make to and broadcast in place of running the jobs; with --sign-only the transactions are written there instead")
+	packagesDo.Flags().StringVarP(&do.MaxAttempts, "max-attempts", "", "1", "default number of times to run a job that fails because the chain is briefly unavailable; can be overridden for any single job")
+	packagesDo.Flags().StringVarP(&do.RetryBackoff, "retry-backoff", "", "1s", "default time to wait before retrying a job, doubling with each retry; can be overridden for any single job")
+	packagesDo.Flags().IntVarP(&do.MaxIdleConns, "max-idle-conns", "", client.DefaultMaxIdleConns, "maximum number of idle keep-alive connections to keep open to the chain, which are shared by all jobs")
+	packagesDo.Flags().DurationVarP(&do.IdleConnTimeout, "idle-conn-timeout", "", client.DefaultIdleConnTimeout, "how long to keep an idle connection to the chain open")
+	packagesDo.Flags().DurationVarP(&do.WebsocketPingPeriod, "ws-ping-period", "", client.DefaultWebsocketPingPeriod, "how often to ping websocket connections to the chain, which are reconnected and resubscribed when several pings go unanswered")
+	packagesDo.Flags().BoolVarP(&do.Resume, "resume", "", false, "resume an interrupted run from its checkpoint, skipping the jobs it completed whose inputs are unchanged and whose transactions are confirmed on chain")
+	packagesDo.Flags().StringVarP(&do.ForceFrom, "force-from", "", "", "resume from the checkpoint but run the named job and every job after it again")
+	packagesDo.Flags().StringVarP(&do.Checkpoint, "checkpoint", "", "", "file to checkpoint the run to as jobs complete; by default named after the [--file], so epm.checkpoint.json for epm.yaml")
+	packagesDo.Flags().BoolVarP(&do.IgnoreChainMismatch, "yes-i-know", "", false, "run the jobs even though the node is not running the chain given by the chain section of the jobs file")
+	packagesDo.Flags().DurationVarP(&do.Deadline, "deadline", "", 0, "how long the whole run may take; once passed the job running is cancelled and failed and the jobs after it are not run, no limit if 0")
+	packagesDo.Flags().BoolVarP(&abortOnFirstFailure, "abort-on-first-failure", "", true, "stop at the first job that fails; if false run the remaining jobs and report all failures at the end")
+	packagesDo.Flags().BoolVarP(&compilers.NoCache, "no-cache", "", false, "always compile contracts, without reading or writing the compiler cache")
+	packagesDo.Flags().BoolVarP(&compilers.AllowOversize, "allow-oversize", "", false, "warn about contracts whose deployed bytecode exceeds the EVM limit of 24576 bytes rather than failing to compile them")
+	packagesDo.Flags().StringVarP(&compilers.EVMVersion, "evm-version", "", "", "version of the EVM solc should target; the compiler default if not given")
+	packagesDo.Flags().StringSliceVarP(&compilers.Remappings, "remap", "", nil, "import remapping of prefix=target for solc, may be given more than once")
 }
 
 func PackagesDo(cmd *cobra.Command, args []string) {
@@ -57,7 +81,14 @@ func PackagesDo(cmd *cobra.Command, args []string) {
 		util.IfExit(fmt.Errorf("please provide the address to deploy from with --address"))
 	}
 
-	util.IfExit(pkgs.RunPackage(do))
+	do.DefaultOutput = "epm.output.json"
+	util.IfExit(jobs.SetOutputs(do, outputs))
+	do.ContinueOnFailure = !abortOnFirstFailure
+	err := pkgs.RunPackage(do)
+	if _, ok := err.(jobs.ErrAssertionFailed); ok {
+		util.IfExitWithCode(err, util.ExitCodeAssertionFailed)
+	}
+	util.IfExit(err)
 }
 
 func defaultSigner() string {
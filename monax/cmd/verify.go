@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	acm "github.com/hyperledger/burrow/account"
+	compilers "github.com/monax/bosmarmot/compilers/perform"
+	"github.com/monax/bosmarmot/monax/util"
+	"github.com/spf13/cobra"
+)
+
+var Verify = &cobra.Command{
+	Use:   "verify ADDRESS",
+	Short: "verify the code deployed at an address against the source it was compiled from",
+	Long: `verify the code deployed at an address against the source it was compiled from
+
+Compiling solidity saves the metadata solc records for each contract, along
+with its source files, in ~/.bosmarmot/artifacts (or $BOSMARMOT_ARTIFACTS)
+under the hash of the metadata that solc appends to the contract's code. The
+code deployed at ADDRESS is fetched from the chain and the hash it carries
+selects the source to compile again with the recorded settings. The code is
+compared with the metadata stripped, and with whatever addresses it was
+linked against in place of library placeholders, reporting either a match
+or the offsets of the segments that differ. Code deployed from a different
+solc minor version than the one recorded is reported as a settings mismatch.`,
+	Run: VerifyContract,
+}
+
+var (
+	verifyChain    string
+	verifyMetadata string
+)
+
+func buildVerifyCommand() {
+	addVerifyFlags()
+}
+
+func addVerifyFlags() {
+	Verify.Flags().StringVarP(&verifyChain, "chain-url", "", "tcp://localhost:46657", "chain-url to be used in tcp://IP:PORT format")
+	Verify.Flags().StringVarP(&verifyMetadata, "metadata", "", "", "hash of the saved metadata to verify against rather than the one carried by the deployed code")
+}
+
+func VerifyContract(cmd *cobra.Command, args []string) {
+	util.IfExit(ArgCheck(1, "eq", cmd, args))
+	address, err := acm.AddressFromHexString(args[0])
+	util.IfExit(err)
+	do.ChainURL = verifyChain
+	code, err := util.NodeClient(do).GetCode(address)
+	util.IfExit(err)
+	if len(code.Code) == 0 {
+		util.IfExit(fmt.Errorf("there is no contract deployed at %s", address))
+	}
+	verification, err := compilers.VerifyCode(code.Code, verifyMetadata)
+	util.IfExit(err)
+	printVerification(os.Stdout, address, verification)
+	if verification.Status != compilers.VerificationMatch {
+		util.IfExit(fmt.Errorf("code at %s does not match %s: %s", address, verification.Contract,
+			verification.Status))
+	}
+}
+
+func printVerification(w io.Writer, address acm.Address, verification *compilers.Verification) {
+	fmt.Fprintf(w, "%s: %s\n", address, verification.Status)
+	fmt.Fprintf(w, "  contract:  %s (%s)\n", verification.Contract, verification.File)
+	fmt.Fprintf(w, "  metadata:  %s\n", verification.MetadataHash)
+	fmt.Fprintf(w, "  solc:      %s\n", verification.CompilerVersion)
+	if verification.DeployedCompilerVersion != "" {
+		fmt.Fprintf(w, "  deployed with solc: %s\n", verification.DeployedCompilerVersion)
+	}
+	if verification.Reason != "" {
+		fmt.Fprintf(w, "  %s\n", verification.Reason)
+	}
+	if verification.Status == compilers.VerificationMatch && !verification.MetadataMatches {
+		fmt.Fprintln(w, "  the code matches but its metadata hash does not, so the source differs in comments, "+
+			"whitespace or file names")
+	}
+	var libraries []string
+	for library := range verification.Libraries {
+		libraries = append(libraries, library)
+	}
+	sort.Strings(libraries)
+	for _, library := range libraries {
+		fmt.Fprintf(w, "  library %s linked at %s\n", library, verification.Libraries[library])
+	}
+	for _, segment := range verification.Mismatches {
+		fmt.Fprintf(w, "  at byte %d\n    deployed: %s\n    compiled: %s\n", segment.Offset, segment.Deployed,
+			segment.Compiled)
+	}
+}
@@ -38,6 +38,12 @@ var (
 	LllcScratchPath      = filepath.Join(LanguagesScratchPath, "lllc")
 	SolcScratchPath      = filepath.Join(LanguagesScratchPath, "sol")
 	SerpScratchPath      = filepath.Join(LanguagesScratchPath, "ser")
+	SolcBinariesPath     = filepath.Join(LanguagesScratchPath, "solc-bin")
+
+	// Compiler output cache, kept outside the monax root so it is shared however that is set.
+	CompilersCachePath = ResolveCompilersCachePath()
+	// Metadata and sources of compiled contracts by metadata hash, for verifying deployed code against its source
+	ArtifactsPath = ResolveArtifactsPath()
 )
 
 func HomeDir() string {
@@ -81,6 +87,7 @@ func ChangeMonaxRoot(monaxDir string) {
 	// Scratch Directories (basically monax' cache) (globally coordinated)
 	DataContainersPath = filepath.Join(ScratchPath, "data")
 	LanguagesScratchPath = filepath.Join(ScratchPath, "languages") // previously "~/.monax/languages"
+	SolcBinariesPath = filepath.Join(LanguagesScratchPath, "solc-bin")
 }
 
 func AbsolutePath(Datadir string, filename string) string {
@@ -101,6 +108,20 @@ func InitDataDir(Datadir string) error {
 	return nil
 }
 
+func ResolveCompilersCachePath() string {
+	if cache := os.Getenv("BOSMARMOT_CACHE"); cache != "" {
+		return cache
+	}
+	return filepath.Join(HomeDir(), ".bosmarmot", "cache")
+}
+
+func ResolveArtifactsPath() string {
+	if artifacts := os.Getenv("BOSMARMOT_ARTIFACTS"); artifacts != "" {
+		return artifacts
+	}
+	return filepath.Join(HomeDir(), ".bosmarmot", "artifacts")
+}
+
 // TODO: [csk] give this a default string if folks want it somewhere besides ~/.monax ...?
 func ResolveMonaxRoot() string {
 	var monax string
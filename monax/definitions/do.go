@@ -1,5 +1,10 @@
 package definitions
 
+import (
+	"context"
+	"time"
+)
+
 type Do struct {
 	Quiet         bool   `mapstructure:"," json:"," yaml:"," toml:","`
 	Verbose       bool   `mapstructure:"," json:"," yaml:"," toml:","`
@@ -26,13 +31,64 @@ type Do struct {
 	ChainURL      string   `mapstructure:"," json:"," yaml:"," toml:","`
 	DefaultOutput string   `mapstructure:"," json:"," yaml:"," toml:","`
 	DefaultSets   []string `mapstructure:"," json:"," yaml:"," toml:","`
-	Package       *Package
+	DryRun        bool     `mapstructure:"," json:"," yaml:"," toml:","`
+	// What the dry run has simulated so far, started by the jobs runner when DryRun is set. The copies of Do made for
+	// the sub-jobs of parallel groups share it with the run.
+	DryRunSimulation interface{}
+	MaxAttempts      string `mapstructure:"," json:"," yaml:"," toml:","`
+	RetryBackoff     string `mapstructure:"," json:"," yaml:"," toml:","`
+	// Where to write a JUnit XML report and a JSON summary of the run, neither is written when empty
+	JUnitOutput   string `mapstructure:"," json:"," yaml:"," toml:","`
+	SummaryOutput string `mapstructure:"," json:"," yaml:"," toml:","`
+	// File to write transactions to with the signatures that could be made rather than broadcasting them
+	SignOnly string `mapstructure:"," json:"," yaml:"," toml:","`
+	// File of transactions written by SignOnly to sign and broadcast
+	Broadcast string `mapstructure:"," json:"," yaml:"," toml:","`
+	// Idle keep-alive connections kept open to the chain and how long for, the client defaults when zero
+	MaxIdleConns    int           `mapstructure:"," json:"," yaml:"," toml:","`
+	IdleConnTimeout time.Duration `mapstructure:"," json:"," yaml:"," toml:","`
+	// How often websocket connections to the chain are pinged to detect when they have died, the client default when zero
+	WebsocketPingPeriod time.Duration `mapstructure:"," json:"," yaml:"," toml:","`
+	// Run the remaining jobs after one fails and report all failures at the end
+	ContinueOnFailure bool `mapstructure:"," json:"," yaml:"," toml:","`
+	// How long the whole run may take before the job running is cancelled and the rest are not run, no limit when zero
+	Deadline time.Duration `mapstructure:"," json:"," yaml:"," toml:","`
+	// File recording the jobs the run has completed, named after the jobs file when empty
+	Checkpoint string `mapstructure:"," json:"," yaml:"," toml:","`
+	// Skip the jobs the checkpoint records as completed with the same inputs and confirmed transactions
+	Resume bool `mapstructure:"," json:"," yaml:"," toml:","`
+	// Job to run again along with every job after it when resuming
+	ForceFrom string `mapstructure:"," json:"," yaml:"," toml:","`
+	// Run the jobs even though the node is not running the chain the jobs file is meant for
+	IgnoreChainMismatch bool `mapstructure:"," json:"," yaml:"," toml:","`
+	Package             *Package
+	// Values of the $env.NAME and $file(path) references made by the jobs, keyed by reference without the $
+	SourcedVariables map[string]string
+	// Number of transactions which have reached the node, used to avoid retrying jobs that may have executed
+	BroadcastCount uint64
+	// Hex hashes of those transactions in the order they were broadcast
+	BroadcastTxHashes []string
+	// Broadcasts that failed without the node accepting them, which may still have reached it
+	UnsettledBroadcasts []UnsettledBroadcast
+	// The chain the node was running when the run started or first broadcast, which must not change during the run
+	ConnectedChain *ChainIdentity
+	// Done once the job running has timed out or the run has passed its deadline, calls to the chain made by the
+	// job are cancelled with it
+	Context context.Context
 
 	//data import/export
 	Source      string `mapstructure:"," json:"," yaml:"," toml:","`
 	Destination string `mapstructure:"," json:"," yaml:"," toml:","`
 }
 
+// A transaction whose broadcast failed after it was sent, so whether it reached the node is not known
+type UnsettledBroadcast struct {
+	// Hex hash of the transaction
+	TxHash string
+	// The sequence number the transaction spends of each of its input accounts, by hex address
+	Sequences map[string]uint64
+}
+
 func NowDo() *Do {
 	return &Do{}
 }
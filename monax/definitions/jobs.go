@@ -20,6 +20,29 @@ type SetJob struct {
 	// It should be noted that arrays and bools must be defined using strings as such "[1,2,3]"
 	// if they are intended to be used further in a assert job.
 	Value string `mapstructure:"val" json:"val" yaml:"val" toml:"val"`
+	// (Optional) the value is secret so should be redacted from the log and the output file
+	Sensitive bool `mapstructure:"sensitive" json:"sensitive,omitempty" yaml:"sensitive" toml:"sensitive"`
+}
+
+type Parallel struct {
+	// (Optional) number of sub-jobs which may be run at the same time, defaults to 4. Each transaction
+	// sending sub-job is given its own nonce from its source account before the group starts so
+	// sub-jobs may not set a nonce themselves or send more than one transaction. A sub-job that
+	// depends_on others in the group is only started once they have finished and can use their results.
+	// Results of the sub-jobs can be used by any job after the group.
+	Workers string `mapstructure:"workers" json:"workers" yaml:"workers" toml:"workers"`
+	// (Required) the sub-jobs to run, these may not include account or parallel jobs
+	Jobs []*Job `mapstructure:"jobs" json:"jobs" yaml:"jobs" toml:"jobs"`
+}
+
+type Retry struct {
+	// (Optional) number of times the job may be run before it is considered to have failed, defaults to the
+	// global --max-attempts. Only failures from the node being unreachable, timing out, or having a full mempool
+	// are retried and never once one of the job's transactions has been broadcast.
+	MaxAttempts string `mapstructure:"max_attempts" json:"max_attempts" yaml:"max_attempts" toml:"max_attempts"`
+	// (Optional) how long to wait before the first retry as a duration such as 500ms or 2s, this doubles with
+	// each further retry. Defaults to the global --retry-backoff.
+	Backoff string `mapstructure:"backoff" json:"backoff" yaml:"backoff" toml:"backoff"`
 }
 
 // ------------------------------------------------------------------------
@@ -39,6 +62,26 @@ type Send struct {
 	Nonce string `mapstructure:"nonce" json:"nonce" yaml:"nonce" toml:"nonce"`
 }
 
+type FundAccounts struct {
+	// (Optional, if account job or global account set) address of the faucet account from which to send (the
+	// public key for the account must be available to monax-keys)
+	Source string `mapstructure:"source" json:"source" yaml:"source" toml:"source"`
+	// (Required) amount of tokens to send to each account, accounts already holding at least this many are skipped
+	// so that the job can be re-run after a partial failure
+	Amount string `mapstructure:"amount" json:"amount" yaml:"amount" toml:"amount"`
+	// (Optional, if addresses given) number of accounts to generate keys for with monax-keys. The keys are named
+	// <name>_1, <name>_2 and so on and a key that already exists is reused rather than generated again.
+	Count string `mapstructure:"count" json:"count" yaml:"count" toml:"count"`
+	// (Optional) prefix of the names of the generated keys, defaults to the job name
+	Name string `mapstructure:"name" json:"name" yaml:"name" toml:"name"`
+	// (Optional) type of the generated keys, defaults to ed25519,ripemd160
+	KeyType string `mapstructure:"key_type" json:"key_type" yaml:"key_type" toml:"key_type"`
+	// (Optional, if count given) addresses of existing accounts to fund, these are funded before any generated
+	Addresses []string `mapstructure:"addresses" json:"addresses" yaml:"addresses" toml:"addresses"`
+	// (Optional) file to write the names and addresses of the accounts and whether each was funded to as JSON
+	Manifest string `mapstructure:"manifest" json:"manifest" yaml:"manifest" toml:"manifest"`
+}
+
 type RegisterName struct {
 	// (Optional, if account job or global account set) address of the account from which to send (the
 	// public key for the account must be available to monax-keys)
@@ -51,6 +94,9 @@ type RegisterName struct {
 	DataFile string `mapstructure:"data_file" json:"data_file" yaml:"data_file" toml:"data_file"`
 	// (Optional) amount of blocks which the name entry will be reserved for the registering user
 	Amount string `mapstructure:"amount" json:"amount" yaml:"amount" toml:"amount"`
+	// (Optional, required for register-name) number of blocks to register the name for, or to extend an existing
+	// registration by, the amount sent is computed from the chain's name registration costs and overrides amount
+	Lease string `mapstructure:"lease" json:"lease" yaml:"lease" toml:"lease"`
 	// (Optional) validators' fee
 	Fee string `mapstructure:"fee" json:"fee" yaml:"fee" toml:"fee"`
 	// (Optional, advanced only) nonce to use when monax-keys signs the transaction (do not use unless you
@@ -62,16 +108,16 @@ type Permission struct {
 	// (Optional, if account job or global account set) address of the account from which to send (the
 	// public key for the account must be available to monax-keys)
 	Source string `mapstructure:"source" json:"source" yaml:"source" toml:"source"`
-	// (Required) actions must be in the set ["set_base", "unset_base", "set_global", "add_role" "rm_role"]
+	// (Required) actions must be in the set ["set_base", "unset_base", "set_global", "add_role" "remove_role"]
 	Action string `mapstructure:"action" json:"action" yaml:"action" toml:"action"`
-	// (Required, unless add_role or rm_role action selected) the name of the permission flag which is to
+	// (Required, unless add_role or remove_role action selected) the name of the permission flag which is to
 	// be updated
 	PermissionFlag string `mapstructure:"permission" json:"permission" yaml:"permission" toml:"permission"`
 	// (Required) the value of the permission or role which is to be updated
 	Value string `mapstructure:"value" json:"value" yaml:"value" toml:"value"`
 	// (Required) the target account which is to be updated
 	Target string `mapstructure:"target" json:"target" yaml:"target" toml:"target"`
-	// (Required, if add_role or rm_role action selected) the role which should be given to the account
+	// (Required, if add_role or remove_role action selected) the role which should be given to the account
 	Role string `mapstructure:"role" json:"role" yaml:"role" toml:"role"`
 	// (Optional, advanced only) nonce to use when monax-keys signs the transaction (do not use unless you
 	// know what you're doing)
@@ -117,6 +163,8 @@ type PackageDeploy struct {
 type Variable struct {
 	Name  string
 	Value string
+	// ABI type the value was decoded from, if any
+	Type string
 }
 
 type Deploy struct {
@@ -137,8 +185,13 @@ type Deploy struct {
 	// the name of the file (or the last one deployed if there are no matching names; not the "last"
 	// one deployed" strategy is non-deterministic and should not be used).
 	Instance string `mapstructure:"instance" json:"instance" yaml:"instance" toml:"instance"`
-	// (Optional) list of Name:Address separated by commas of libraries (see solc --help)
-	Libraries string `mapstructure:"libraries" json:"libraries" yaml:"libraries" toml:"libraries"`
+	// (Optional) addresses of the libraries the contract is linked against, either a map of library name
+	// to address or a list of Name:Address separated by commas (see solc --help). The address may be the
+	// result of an earlier deploy job e.g. $deployLib. Deploying fails if any library is left unlinked
+	Libraries interface{} `mapstructure:"libraries" json:"libraries" yaml:"libraries" toml:"libraries"`
+	// (Optional) exact version of solc (e.g. 0.4.25) to compile with, which takes precedence over the
+	// version pragma of the contract. The release binary is downloaded if it is not already available
+	Solc string `mapstructure:"solc" json:"solc" yaml:"solc" toml:"solc"`
 	// (Optional) TODO: additional arguments to send along with the contract code
 	Data interface{} `mapstructure:"data" json:"data" yaml:"data" toml:"data"`
 	// (Optional) amount of tokens to send to the contract which will (after deployment) reside in the
@@ -164,7 +217,9 @@ type Call struct {
 	Source string `mapstructure:"source" json:"source" yaml:"source" toml:"source"`
 	// (Required) address of the contract which should be called
 	Destination string `mapstructure:"destination" json:"destination" yaml:"destination" toml:"destination"`
-	// (Required unless testing fallback function) function inside the contract to be called
+	// (Required unless testing fallback function) function inside the contract to be called, either its name or
+	// a signature such as "transfer(address,uint256) returns (bool)" which is used in place of an ABI file when
+	// there is none and picks out an overload when there is
 	Function string `mapstructure:"function" json:"function" yaml:"function" toml:"function"`
 	// (Optional) data which should be called. will use the monax-abi tooling under the hood to formalize the
 	// transaction
@@ -191,23 +246,53 @@ type Call struct {
 	Variables []*Variable
 }
 
+type WaitEvent struct {
+	// (Required) address of the contract which will emit the event
+	Destination string `mapstructure:"destination" json:"destination" yaml:"destination" toml:"destination"`
+	// (Required) name of the event to wait for, or its full signature such as "Registered(address,uint256)"
+	// if the contract has more than one event of that name
+	Event string `mapstructure:"event" json:"event" yaml:"event" toml:"event"`
+	// (Optional) decoded event parameters the event must have, by parameter name. Integers are compared
+	// numerically and addresses and bytes ignoring case and any 0x prefix. Arrays cannot be filtered on, nor can
+	// indexed strings and bytes since only the hash of their value is logged.
+	Filters map[string]string `mapstructure:"filters" json:"filters" yaml:"filters" toml:"filters"`
+	// (Optional) how long to wait for the event as a duration such as 30s or 5m, defaults to 1m
+	Timeout string `mapstructure:"timeout" json:"timeout" yaml:"timeout" toml:"timeout"`
+	// (Optional) location of the abi file to use (can be relative path or in abi path)
+	// deployed contracts save ABI artifacts in the abi folder as *both* the name of the contract
+	// and the address where the contract was deployed to
+	ABI string `mapstructure:"abi" json:"abi" yaml:"abi" toml:"abi"`
+	// (Optional) the decoded event parameters
+	Variables []*Variable
+}
+
 // ------------------------------------------------------------------------
 // State Jobs
 // ------------------------------------------------------------------------
 
 type DumpState struct {
-	WithValidators bool   `mapstructure:"include-validators" json:"include-validators" yaml:"include-validators" toml:"include-validators"`
+	// (Optional) record the current validators in the dump, which restore-state needs to make a genesis doc
+	WithValidators bool `mapstructure:"include-validators" json:"include-validators" yaml:"include-validators" toml:"include-validators"`
+	// (Optional) dump the storage of contracts and the name registry as well as the accounts
+	IncludeStorage bool   `mapstructure:"include-storage" json:"include-storage" yaml:"include-storage" toml:"include-storage"`
 	ToIPFS         bool   `mapstructure:"to-ipfs" json:"to-ipfs" yaml:"to-ipfs" toml:"to-ipfs"`
 	ToFile         bool   `mapstructure:"to-file" json:"to-file" yaml:"to-file" toml:"to-file"`
 	IPFSHost       string `mapstructure:"ipfs-host" json:"ipfs-host" yaml:"ipfs-host" toml:"ipfs-host"`
-	FilePath       string `mapstructure:"file" json:"file" yaml:"file" toml:"file"`
+	// (Optional) file to write the dump to, defaults to state.json
+	FilePath string `mapstructure:"file" json:"file" yaml:"file" toml:"file"`
 }
 
 type RestoreState struct {
 	FromIPFS bool   `mapstructure:"from-ipfs" json:"from-ipfs" yaml:"from-ipfs" toml:"from-ipfs"`
 	FromFile bool   `mapstructure:"from-file" json:"from-file" yaml:"from-file" toml:"from-file"`
 	IPFSHost string `mapstructure:"ipfs-host" json:"ipfs-host" yaml:"ipfs-host" toml:"ipfs-host"`
+	// (Optional) file a dump-state job wrote the dump to, defaults to state.json
 	FilePath string `mapstructure:"file" json:"file" yaml:"file" toml:"file"`
+	// (Required) name of the chain the genesis doc made from the dump is for
+	ChainName string `mapstructure:"chain-name" json:"chain-name" yaml:"chain-name" toml:"chain-name"`
+	// (Optional) file to write the genesis doc to, defaults to genesis.json. The result is the chain ID of the
+	// genesis doc.
+	GenesisFile string `mapstructure:"genesis-file" json:"genesis-file" yaml:"genesis-file" toml:"genesis-file"`
 }
 
 // ------------------------------------------------------------------------
@@ -222,7 +307,8 @@ type QueryContract struct {
 	// (Required) address of the contract which should be called
 	Destination string `mapstructure:"destination" json:"destination" yaml:"destination" toml:"destination"`
 	// (Required) data which should be called. will use the monax-abi tooling under the hood to formalize the
-	// transaction. QueryContract will usually be used with "accessor" functions in contracts
+	// transaction. QueryContract will usually be used with "accessor" functions in contracts. May be a signature
+	// such as "balanceOf(address) view returns (uint256)" as for call jobs
 	Function string `mapstructure:"function" json:"function" yaml:"function" toml:"function"`
 	// (Optional) data to be used in the function arguments. Will use the monax-abi tooling under the hood to formalize the
 	// transaction.
@@ -244,10 +330,18 @@ type QueryAccount struct {
 	Field string `mapstructure:"field" json:"field" yaml:"field" toml:"field"`
 }
 
+type QueryPerms struct {
+	// (Required) address of the account whose permissions should be queried. The result lists the permissions the
+	// account holds and each permission is available as $jobName.send and so on, as "true" or "false", along
+	// with its comma separated roles as $jobName.roles
+	Account string `mapstructure:"account" json:"account" yaml:"account" toml:"account"`
+}
+
 type QueryName struct {
 	// (Required) name which should be queried
 	Name string `mapstructure:"name" json:"name" yaml:"name" toml:"name"`
-	// (Required) field which should be quiried (generally will be "data" to get the registered "name")
+	// (Optional) field which should be quiried (generally will be "data" to get the registered "name"), the name's
+	// owner, data and expires fields are always available as $jobName.owner and so on
 	Field string `mapstructure:"field" json:"field" yaml:"field" toml:"field"`
 }
 
@@ -261,14 +355,43 @@ type Assert struct {
 	// (Required) key which should be used for the assertion. This is usually known as the "expected"
 	// value in most testing suites
 	Key string `mapstructure:"key" json:"key" yaml:"key" toml:"key"`
-	// (Required) must be of the set ["eq", "ne", "ge", "gt", "le", "lt", "==", "!=", ">=", ">", "<=", "<"]
-	// establishes the relation to be tested by the assertion. If a strings key:value pair is being used
-	// only the equals or not-equals relations may be used as the key:value will try to be converted to
-	// ints for the remainder of the relations. if strings are passed to them then `monax pkgs do` will return an
-	// error
+	// (Required) must be of the set ["eq", "ne", "ge", "gt", "le", "lt", "contains", "==", "!=", ">=", ">", "<=", "<"]
+	// establishes the relation to be tested by the assertion. Values are compared according to the ABI type
+	// they were decoded from when the key is a single query result, otherwise according to what they look
+	// like: integers numerically, addresses ignoring case and any 0x prefix, bools as bools and anything else
+	// as strings. Only integers may be used with the ordering relations. contains checks for an element of an
+	// array such as "[1,2,3]" or otherwise for a substring.
 	Relation string `mapstructure:"relation" json:"relation" yaml:"relation" toml:"relation"`
 	// (Required) value which should be used for the assertion. This is usually known as the "given"
 	// value in most testing suites. Generally it will be a variable expansion from one of the query
 	// jobs.
 	Value string `mapstructure:"val" json:"val" yaml:"val" toml:"val"`
 }
+
+// Deploys a contract of tests and runs each of its public functions that take no arguments and are named test...
+// in turn, as for ds-test or truffle Solidity tests. A test fails when it reverts or returns false, and the Log and
+// Assert events it emits are decoded into the report. setUp() is called before each test when the contract has it.
+type TestContract struct {
+	// (Optional, if account job or global account set) address of the account from which to deploy the contract and
+	// send the test transactions (the public key for the account must be available to monax-keys)
+	Source string `mapstructure:"source" json:"source" yaml:"source" toml:"source"`
+	// (Required) the filepath to the contract file containing the tests, as for a deploy job
+	Contract string `mapstructure:"contract" json:"contract" yaml:"contract" toml:"contract"`
+	// (Optional) the name of the test contract when the file holds more than one, by default the one with the same
+	// name as the file. Unlike a deploy job this cannot be "all"
+	Instance string `mapstructure:"instance" json:"instance" yaml:"instance" toml:"instance"`
+	// (Optional) addresses of the libraries the contract is linked against, as for a deploy job
+	Libraries interface{} `mapstructure:"libraries" json:"libraries" yaml:"libraries" toml:"libraries"`
+	// (Optional) exact version of solc to compile with, as for a deploy job
+	Solc string `mapstructure:"solc" json:"solc" yaml:"solc" toml:"solc"`
+	// (Optional) arguments to the constructor of the test contract
+	Data interface{} `mapstructure:"data" json:"data" yaml:"data" toml:"data"`
+	// (Optional) validators' fee for each transaction sent
+	Fee string `mapstructure:"fee" json:"fee" yaml:"fee" toml:"fee"`
+	// (Optional) amount of gas which should be sent along with each transaction
+	Gas string `mapstructure:"gas" json:"gas" yaml:"gas" toml:"gas"`
+	// (Optional) run each test as a simulated call rather than as a transaction so the tests leave no state
+	// behind them. setUp is still sent as a transaction since what it sets up would otherwise be discarded before
+	// the test runs
+	Simulate bool `mapstructure:"simulate" json:"simulate" yaml:"simulate" toml:"simulate"`
+}
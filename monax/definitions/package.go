@@ -1,5 +1,10 @@
 package definitions
 
+import (
+	"fmt"
+	"time"
+)
+
 //TODO: Interface all the jobs, determine if they should remain in definitions or get their own package
 
 type Job struct {
@@ -9,19 +14,45 @@ type Job struct {
 	JobResult string
 	// For multiple values
 	JobVars []*Variable
+	// Number of times the job was run, more than one if it was retried
+	JobAttempts int
+	// Hex hashes of the transactions the job broadcast
+	JobTxHashes []string `mapstructure:"-" json:"-" yaml:"-" toml:"-"`
+	// The amount the job was given as entered and the integer it resolved to
+	JobAmount *Amount `mapstructure:"-" json:"-" yaml:"-" toml:"-"`
+	// The outcome of each test a test-contract job ran
+	JobTests []*ContractTest `mapstructure:"-" json:"-" yaml:"-" toml:"-"`
+	// Where the job was defined, set when the jobs file is loaded
+	Source *Source `mapstructure:"-" json:"-" yaml:"-" toml:"-"`
+	// Overrides the global retry policy for this job
+	Retry *Retry `mapstructure:"retry" json:"retry" yaml:"retry" toml:"retry"`
+	// (Optional) how long the job may take across all of its attempts as a duration such as 30s, after which any call
+	// it is making to the chain is cancelled and the job fails. No limit when empty.
+	Timeout string `mapstructure:"timeout" json:"timeout" yaml:"timeout" toml:"timeout"`
+	// Names of jobs that must have run before this one, wherever they appear in the jobs file
+	DependsOn []string `mapstructure:"depends_on" json:"depends_on" yaml:"depends_on" toml:"depends_on"`
 	// Sets/Resets the primary account to use
 	Account *Account `mapstructure:"account" json:"account" yaml:"account" toml:"account"`
 	// Set an arbitrary value
 	Set *SetJob `mapstructure:"set" json:"set" yaml:"set" toml:"set"`
+	// Run a group of independent jobs concurrently
+	Parallel *Parallel `mapstructure:"parallel" json:"parallel" yaml:"parallel" toml:"parallel"`
 	// Contract compile and send to the chain functions
 	Deploy *Deploy `mapstructure:"deploy" json:"deploy" yaml:"deploy" toml:"deploy"`
 	// Send tokens from one account to another
 	Send *Send `mapstructure:"send" json:"send" yaml:"send" toml:"send"`
+	// Send tokens from a faucet account to each of a number of accounts, generating keys for them if necessary
+	FundAccounts *FundAccounts `mapstructure:"fund-accounts" json:"fund-accounts" yaml:"fund-accounts" toml:"fund-accounts"`
 	// Utilize monax:db's native name registry to register a name
 	RegisterName *RegisterName `mapstructure:"register" json:"register" yaml:"register" toml:"register"`
+	// Register a name, or extend a registration you own, for a lease of some number of blocks paying the fee
+	// computed from the chain's name registration costs
+	RegisterNameLease *RegisterName `mapstructure:"register-name" json:"register-name" yaml:"register-name" toml:"register-name"`
 	// Sends a transaction which will update the permissions of an account. Must be sent from an account which
 	// has root permissions on the blockchain (as set by either the genesis.json or in a subsequence transaction)
 	Permission *Permission `mapstructure:"permission" json:"permission" yaml:"permission" toml:"permission"`
+	// The permission job under its shorter name
+	Perms *Permission `mapstructure:"perms" json:"perms" yaml:"perms" toml:"perms"`
 	// Sends a bond transaction
 	Bond *Bond `mapstructure:"bond" json:"bond" yaml:"bond" toml:"bond"`
 	// Sends an unbond transaction
@@ -30,9 +61,11 @@ type Job struct {
 	Rebond *Rebond `mapstructure:"rebond" json:"rebond" yaml:"rebond" toml:"rebond"`
 	// Sends a transaction to a contract. Will utilize monax-abi under the hood to perform all of the heavy lifting
 	Call *Call `mapstructure:"call" json:"call" yaml:"call" toml:"call"`
-	// Wrapper for mintdump dump. WIP
+	// Waits for a contract to emit an event. Will utilize the contract's ABI to decode the event parameters
+	WaitEvent *WaitEvent `mapstructure:"wait-event" json:"wait-event" yaml:"wait-event" toml:"wait-event"`
+	// Dumps the accounts of the chain, and optionally their storage and the name registry, to a file
 	DumpState *DumpState `mapstructure:"dump-state" json:"dump-state" yaml:"dump-state" toml:"dump-state"`
-	// Wrapper for mintdum restore. WIP
+	// Makes a genesis doc for a new chain from a file written by dump-state
 	RestoreState *RestoreState `mapstructure:"restore-state" json:"restore-state" yaml:"restore-state" toml:"restore-state"`
 	// Sends a "simulated call" to a contract. Predominantly used for accessor functions ("Getters" within contracts)
 	QueryContract *QueryContract `mapstructure:"query-contract" json:"query-contract" yaml:"query-contract" toml:"query-contract"`
@@ -40,10 +73,14 @@ type Job struct {
 	QueryAccount *QueryAccount `mapstructure:"query-account" json:"query-account" yaml:"query-account" toml:"query-account"`
 	// Queries information about a name registered with monax:db's native name registry
 	QueryName *QueryName `mapstructure:"query-name" json:"query-name" yaml:"query-name" toml:"query-name"`
+	// Queries the permissions of an account, taking those it has not set from the global permissions
+	QueryPerms *QueryPerms `mapstructure:"query-perms" json:"query-perms" yaml:"query-perms" toml:"query-perms"`
 	// Queries information about the validator set
 	QueryVals *QueryVals `mapstructure:"query-vals" json:"query-vals" yaml:"query-vals" toml:"query-vals"`
 	// Makes and assertion (useful for testing purposes)
 	Assert *Assert `mapstructure:"assert" json:"assert" yaml:"assert" toml:"assert"`
+	// Deploys a contract of Solidity tests and runs each of them, reporting each test as a job of its own
+	TestContract *TestContract `mapstructure:"test-contract" json:"test-contract" yaml:"test-contract" toml:"test-contract"`
 }
 
 type Package struct {
@@ -51,6 +88,114 @@ type Package struct {
 	Account   string
 	Jobs      []*Job
 	Libraries map[string]string
+	// Variables given values before any job is run, each can be used by the jobs as $name
+	Variables []*PackageVariable
+	// Other jobs files whose jobs are run before these ones
+	Include []*Include
+	// The chain the jobs are meant to be run against, checked before any job is run
+	Chain *ChainIdentity
+}
+
+// Identifies a chain so that jobs meant for one chain are not run against another that happens to be at the chain URL
+// given. Only the fields given are checked, each of which may use $env.NAME and $file(path).
+type ChainIdentity struct {
+	// (Optional) chain name from the genesis doc
+	Name string `mapstructure:"name" json:"name" yaml:"name" toml:"name"`
+	// (Optional) chain ID
+	ID string `mapstructure:"id" json:"id" yaml:"id" toml:"id"`
+	// (Optional) hex hash of the genesis doc
+	GenesisHash string `mapstructure:"genesisHash" json:"genesisHash" yaml:"genesisHash" toml:"genesisHash"`
+	// (Optional) names of units the amounts of jobs may be given in on this chain, each to the amount it stands for,
+	// so that with token: 1e6 an amount of 2.5 token is 2500000. These are not checked against the chain.
+	Denominations map[string]string `mapstructure:"denominations" json:"denominations" yaml:"denominations" toml:"denominations"`
+}
+
+func (chain *ChainIdentity) String() string {
+	return fmt.Sprintf("%s (ID %s, genesis hash %s)", chain.Name, chain.ID, chain.GenesisHash)
+}
+
+// Pulls the jobs of other jobs files into a jobs file. The jobs of each included file are run in the order they are
+// listed before the jobs of the including file, which may use their results. Variables, libraries and the account
+// of the including file take precedence over those of the files it includes, so an included file's values act as
+// defaults.
+type Include struct {
+	// (Required) path to the jobs file relative to the including file, or a glob pattern matching several which are
+	// included in lexical order
+	File string `mapstructure:"file" json:"file" yaml:"file" toml:"file"`
+	// (Optional) prefixed to the names of the included jobs, joined by an underscore, so that files whose job names
+	// would collide can be included together. References to the jobs within the included file are renamed to match,
+	// so if a file containing the job deploy is included with prefix token, it is referred to as $token_deploy.
+	Prefix string `mapstructure:"prefix" json:"prefix" yaml:"prefix" toml:"prefix"`
+}
+
+// A line of a jobs file
+type Source struct {
+	File string
+	// Counting from 1, 0 if the line could not be found
+	Line int
+}
+
+func (source *Source) String() string {
+	if source.Line == 0 {
+		return source.File
+	}
+	return fmt.Sprintf("%s:%d", source.File, source.Line)
+}
+
+// An amount of a job as it appears in the jobs file and the integer it came to once its variables, units and
+// arithmetic were resolved
+type Amount struct {
+	Entered  string `json:"entered"`
+	Resolved string `json:"resolved"`
+}
+
+// The outcome of one test function of a test-contract job
+type ContractTest struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	// Why the test failed
+	Message string `json:"message,omitempty"`
+	// The Log and Assert events the test emitted, decoded
+	Logs     []string      `json:"logs,omitempty"`
+	Duration time.Duration `json:"-"`
+}
+
+// Names the job along with where it was defined if that is known, for use in error messages
+func (job *Job) Describe() string {
+	if job.Source == nil {
+		return job.JobName
+	}
+	return fmt.Sprintf("%s (%s)", job.JobName, job.Source)
+}
+
+// A variable that takes its value from outside the jobs file, such as a funded account address in CI
+type PackageVariable struct {
+	// (Required) name the jobs use to refer to the variable
+	Name string `mapstructure:"name" json:"name" yaml:"name" toml:"name"`
+	// (Optional) environment variable to read the value from
+	Env string `mapstructure:"env" json:"env" yaml:"env" toml:"env"`
+	// (Optional) file to read the value from, relative to the jobs file. Surrounding whitespace is trimmed.
+	File string `mapstructure:"file" json:"file" yaml:"file" toml:"file"`
+	// (Optional) value to use when none is given on the command line, in the environment or in the file
+	Default string `mapstructure:"default" json:"default" yaml:"default" toml:"default"`
+	// (Optional) values read from the environment or a file are redacted from the log and the output file
+	Sensitive bool `mapstructure:"sensitive" json:"sensitive" yaml:"sensitive" toml:"sensitive"`
+}
+
+// Returns the jobs of the package in order with the sub-jobs of each parallel group following the group
+func (pkg *Package) AllJobs() []*Job {
+	return flattenJobs(pkg.Jobs)
+}
+
+func flattenJobs(jobs []*Job) []*Job {
+	var all []*Job
+	for _, job := range jobs {
+		all = append(all, job)
+		if job.Parallel != nil {
+			all = append(all, flattenJobs(job.Parallel.Jobs)...)
+		}
+	}
+	return all
 }
 
 func BlankPackage() *Package {
@@ -0,0 +1,253 @@
+package loaders
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+type packageLoader struct {
+	// Directory of the top-level jobs file, sources are given relative to it
+	root string
+}
+
+// Loads the jobs file at abs and, in its place, the jobs of the files it includes. including holds the files that
+// include it, outermost first, so that files including one another in a cycle can be caught.
+func (loader *packageLoader) load(abs string, including []string) (*definitions.Package, error) {
+	for i, includer := range including {
+		if includer == abs {
+			return nil, fmt.Errorf("jobs files include one another in a cycle: %s",
+				loader.describeCycle(append(including[i:], abs)))
+		}
+	}
+	pkg, err := readPackage(abs)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(content), "\n")
+	file := loader.relative(abs)
+	setSources(pkg.AllJobs(), file, lines)
+
+	var jobs []*definitions.Job
+	for _, include := range pkg.Include {
+		source := &definitions.Source{File: file, Line: includeLine(lines, include.File)}
+		if include.File == "" {
+			return nil, fmt.Errorf("include at %s has no file", source)
+		}
+		pattern := include.File
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(abs), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("include at %s has invalid pattern %s: %v", source, include.File, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("include at %s matches no files: %s", source, include.File)
+		}
+		for _, match := range matches {
+			included, err := loader.load(match, append(including, abs))
+			if err != nil {
+				return nil, err
+			}
+			if include.Prefix != "" {
+				prefixJobs(included, include.Prefix)
+			}
+			jobs = append(jobs, included.Jobs...)
+			mergeDefaults(pkg, included)
+		}
+	}
+	pkg.Jobs = append(jobs, pkg.Jobs...)
+	pkg.Include = nil
+	return pkg, nil
+}
+
+func (loader *packageLoader) relative(abs string) string {
+	if rel, err := filepath.Rel(loader.root, abs); err == nil {
+		return rel
+	}
+	return abs
+}
+
+func (loader *packageLoader) describeCycle(cycle []string) string {
+	files := make([]string, len(cycle))
+	for i, abs := range cycle {
+		files[i] = loader.relative(abs)
+	}
+	return strings.Join(files, " -> ")
+}
+
+// Values of the including package are kept over those of the included one
+func mergeDefaults(pkg, included *definitions.Package) {
+	if pkg.Account == "" {
+		pkg.Account = included.Account
+	}
+	if pkg.Chain == nil {
+		pkg.Chain = included.Chain
+	}
+	for name, address := range included.Libraries {
+		if pkg.Libraries == nil {
+			pkg.Libraries = make(map[string]string)
+		}
+		if _, ok := pkg.Libraries[name]; !ok {
+			pkg.Libraries[name] = address
+		}
+	}
+	names := make(map[string]bool)
+	for _, variable := range pkg.Variables {
+		names[variable.Name] = true
+	}
+	for _, variable := range included.Variables {
+		if !names[variable.Name] {
+			pkg.Variables = append(pkg.Variables, variable)
+		}
+	}
+}
+
+var jobsKeyRegex = regexp.MustCompile(`^jobs\s*:`)
+
+// Records the line each job's name is given on. The lines are not known once viper has merged the document
+// so are found by looking for the first name key with the job's name after the jobs key that no earlier job has
+// claimed.
+func setSources(jobs []*definitions.Job, file string, lines []string) {
+	start := 0
+	for i, line := range lines {
+		if jobsKeyRegex.MatchString(line) {
+			start = i
+			break
+		}
+	}
+	claimed := make(map[int]bool)
+	for _, job := range jobs {
+		job.Source = &definitions.Source{File: file}
+		nameRegex := keyLineRegex("name", job.JobName)
+		for i := start; i < len(lines); i++ {
+			if !claimed[i] && nameRegex.MatchString(lines[i]) {
+				claimed[i] = true
+				job.Source.Line = i + 1
+				break
+			}
+		}
+	}
+}
+
+func includeLine(lines []string, file string) int {
+	fileRegex := keyLineRegex("file", file)
+	for i, line := range lines {
+		if fileRegex.MatchString(line) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Matches a line of a YAML list giving key the value value, whether or not it starts a list item or is quoted
+func keyLineRegex(key, value string) *regexp.Regexp {
+	return regexp.MustCompile(`^\s*(-\s+)?` + key + `\s*:\s*["']?` + regexp.QuoteMeta(value) + `["']?\s*(#.*)?$`)
+}
+
+var jobReferenceRegex = regexp.MustCompile(`\$([a-zA-Z0-9_]+)`)
+
+// Prefixes the names of the jobs of pkg and renames the references to them from its jobs to match
+func prefixJobs(pkg *definitions.Package, prefix string) {
+	jobs := pkg.AllJobs()
+	names := make(map[string]string)
+	for _, job := range jobs {
+		names[job.JobName] = prefix + "_" + job.JobName
+	}
+	rename := func(str string) string {
+		return jobReferenceRegex.ReplaceAllStringFunc(str, func(reference string) string {
+			if name, ok := names[reference[1:]]; ok {
+				return "$" + name
+			}
+			return reference
+		})
+	}
+	for _, job := range jobs {
+		job.JobName = names[job.JobName]
+		for i, dependency := range job.DependsOn {
+			if name, ok := names[dependency]; ok {
+				job.DependsOn[i] = name
+			}
+		}
+		// Sub-jobs are renamed in turn
+		parallel := job.Parallel
+		job.Parallel = nil
+		renameReferences(reflect.ValueOf(job).Elem(), rename)
+		job.Parallel = parallel
+		if parallel != nil {
+			parallel.Workers = rename(parallel.Workers)
+		}
+	}
+}
+
+// Applies rename to every string reachable from v
+func renameReferences(v reflect.Value, rename func(string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(rename(v.String()))
+		}
+	case reflect.Ptr:
+		if !v.IsNil() {
+			renameReferences(v.Elem(), rename)
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		if elem := v.Elem(); elem.Kind() == reflect.String {
+			if v.CanSet() {
+				v.Set(reflect.ValueOf(rename(elem.String())).Convert(elem.Type()))
+			}
+		} else {
+			renameReferences(elem, rename)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			renameReferences(v.Field(i), rename)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			renameReferences(v.Index(i), rename)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			elem := v.MapIndex(key)
+			if elem.Kind() == reflect.Interface && !elem.IsNil() {
+				elem = elem.Elem()
+			}
+			if elem.Kind() == reflect.String {
+				v.SetMapIndex(key, reflect.ValueOf(rename(elem.String())).Convert(elem.Type()))
+			} else {
+				renameReferences(elem, rename)
+			}
+		}
+	}
+}
+
+// Jobs of the same name from different files cannot be told apart, so must be included with a prefix
+func validateIncludedNames(pkg *definitions.Package) error {
+	defined := make(map[string]*definitions.Job)
+	for _, job := range pkg.AllJobs() {
+		other, ok := defined[job.JobName]
+		if !ok {
+			defined[job.JobName] = job
+			continue
+		}
+		if job.Source != nil && other.Source != nil && job.Source.File != other.Source.File {
+			return fmt.Errorf("job %s is defined at both %s and %s, include one of the files with a prefix",
+				job.JobName, other.Source, job.Source)
+		}
+	}
+	return nil
+}
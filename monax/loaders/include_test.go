@@ -0,0 +1,138 @@
+package loaders
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeJobsFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadPackageInclude(t *testing.T) {
+	dir := writeJobsFiles(t, map[string]string{
+		"deploy.yaml": `
+variables:
+  - name: supply
+    default: "100"
+include:
+  - file: lib/token.yaml
+    prefix: token
+  - file: envs/*.yaml
+jobs:
+  - name: check
+    assert:
+      key: $token_deploy
+      relation: ne
+      val: ""
+`,
+		"lib/token.yaml": `
+variables:
+  - name: supply
+    default: "5"
+  - name: owner
+    default: ABCD
+jobs:
+  - name: deploy
+    deploy:
+      contract: token.sol
+      data: [$supply, $owner]
+  - name: mint
+    depends_on: [deploy]
+    call:
+      destination: $deploy
+      function: mint
+`,
+		"envs/a.yaml": `
+jobs:
+  - name: a
+    set:
+      val: a
+`,
+		"envs/b.yaml": `
+jobs:
+  - name: b
+    set:
+      val: $a
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	pkg, err := LoadPackage(filepath.Join(dir, "deploy.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, job := range pkg.Jobs {
+		names = append(names, job.JobName)
+	}
+	if strings.Join(names, ",") != "token_deploy,token_mint,a,b,check" {
+		t.Fatalf("unexpected jobs: %v", names)
+	}
+	if data := pkg.Jobs[0].Deploy.Data.([]interface{}); data[0] != "$supply" || data[1] != "$owner" {
+		t.Errorf("variables should not be prefixed but got data %v", data)
+	}
+	if mint := pkg.Jobs[1]; mint.Call.Destination != "$token_deploy" || mint.DependsOn[0] != "token_deploy" {
+		t.Errorf("references to included jobs should be prefixed but got %s, %v", mint.Call.Destination,
+			mint.DependsOn)
+	}
+	if source := pkg.Jobs[1].Source.String(); source != filepath.Join("lib", "token.yaml")+":12" {
+		t.Errorf("unexpected source %s", source)
+	}
+	if source := pkg.Jobs[4].Source.String(); source != "deploy.yaml:10" {
+		t.Errorf("unexpected source %s", source)
+	}
+	if len(pkg.Variables) != 2 || pkg.Variables[0].Default != "100" || pkg.Variables[1].Name != "owner" {
+		t.Errorf("including file's variables should override those of included files but got %v, %v",
+			pkg.Variables[0], pkg.Variables[1])
+	}
+}
+
+func TestLoadPackageIncludeErrors(t *testing.T) {
+	for _, tt := range []struct {
+		files    map[string]string
+		contains string
+	}{
+		{map[string]string{
+			"deploy.yaml": "include:\n  - file: a.yaml\njobs: []\n",
+			"a.yaml":      "include:\n  - file: b.yaml\njobs: []\n",
+			"b.yaml":      "include:\n  - file: a.yaml\njobs: []\n",
+		}, "cycle: a.yaml -> b.yaml -> a.yaml"},
+		{map[string]string{
+			"deploy.yaml": "jobs: []\ninclude:\n  - file: missing/*.yaml\n",
+		}, "include at deploy.yaml:3 matches no files: missing/*.yaml"},
+		{map[string]string{
+			"deploy.yaml": "include:\n  - file: a.yaml\njobs:\n  - name: set\n    set:\n      val: 1\n",
+			"a.yaml":      "jobs:\n  - name: set\n    set:\n      val: 2\n",
+		}, "job set is defined at both a.yaml:2 and deploy.yaml:4"},
+		{map[string]string{
+			"deploy.yaml": "include:\n  - file: a.yaml\njobs: []\n",
+			"a.yaml":      "jobs:\n  - name: other\n    set:\n      val: 1\n  - name: perm\n    permission:\n      action: set_bass\n",
+		}, "job perm (a.yaml:5) has invalid permission action"},
+	} {
+		dir := writeJobsFiles(t, tt.files)
+		_, err := LoadPackage(filepath.Join(dir, "deploy.yaml"))
+		if err == nil {
+			t.Errorf("expected loading %v to fail", tt.files)
+		} else if !strings.Contains(err.Error(), tt.contains) {
+			t.Errorf("expected error to contain %q but got: %v", tt.contains, err)
+		}
+		os.RemoveAll(dir)
+	}
+}
@@ -3,7 +3,10 @@ package loaders
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
+	"github.com/hyperledger/burrow/permission"
+	ptypes "github.com/hyperledger/burrow/permission/types"
 	"github.com/monax/bosmarmot/monax/definitions"
 	"github.com/monax/bosmarmot/monax/log"
 	"github.com/spf13/viper"
@@ -11,8 +14,6 @@ import (
 
 func LoadPackage(fileName string) (*definitions.Package, error) {
 	log.Info("Loading monax Jobs Definition File.")
-	var pkg = definitions.BlankPackage()
-	var epmJobs = viper.New()
 
 	// setup file
 	abs, err := filepath.Abs(fileName)
@@ -20,6 +21,28 @@ func LoadPackage(fileName string) (*definitions.Package, error) {
 		return nil, fmt.Errorf("Sorry, the marmots were unable to find the absolute path to the monax jobs file.")
 	}
 
+	loader := &packageLoader{root: filepath.Dir(abs)}
+	pkg, err := loader.load(abs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// TODO more file sanity check (fail before running)
+	if err := validateIncludedNames(pkg); err != nil {
+		return nil, err
+	}
+	if err := validatePermissions(pkg); err != nil {
+		return nil, err
+	}
+
+	return pkg, nil
+}
+
+// Reads a single jobs file without following its includes
+func readPackage(abs string) (*definitions.Package, error) {
+	var pkg = definitions.BlankPackage()
+	var epmJobs = viper.New()
+
 	path := filepath.Dir(abs)
 	file := filepath.Base(abs)
 	extName := filepath.Ext(file)
@@ -35,16 +58,61 @@ func LoadPackage(fileName string) (*definitions.Package, error) {
 
 	// load file
 	if err := epmJobs.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("Sorry, the marmots were unable to load the monax jobs file. Please check your path: %v", err)
+		return nil, fmt.Errorf("Sorry, the marmots were unable to load the monax jobs file %s. Please check your path: %v",
+			abs, err)
 	}
 
 	// marshall file
 	if err := epmJobs.Unmarshal(pkg); err != nil {
-		return nil, fmt.Errorf(`Sorry, the marmots could not figure that monax jobs file out. 
-			Please check that your epm.yaml is properly formatted: %v`, err)
+		return nil, fmt.Errorf(`Sorry, the marmots could not figure that monax jobs file %s out. 
+			Please check that your epm.yaml is properly formatted: %v`, abs, err)
 	}
 
-	// TODO more file sanity check (fail before running)
-
 	return pkg, nil
 }
+
+// Checks the action and permission names of every permission job so that a misspelt name fails before any
+// job is run. Values which are variables can only be checked when the job runs.
+func validatePermissions(pkg *definitions.Package) error {
+	for _, job := range pkg.AllJobs() {
+		perm := job.Permission
+		if perm == nil {
+			perm = job.Perms
+		}
+		if perm == nil {
+			continue
+		}
+		if !strings.Contains(perm.Action, "$") {
+			action, err := permission.PermStringToFlag(perm.Action)
+			if err != nil || !isPermissionAction(action) {
+				return fmt.Errorf("job %s has invalid permission action %q, valid actions are: %s", job.Describe(),
+					perm.Action, strings.Join(permissionActions, ", "))
+			}
+		}
+		if perm.PermissionFlag != "" && !strings.Contains(perm.PermissionFlag, "$") {
+			if _, err := permission.PermStringToFlag(perm.PermissionFlag); err != nil {
+				return fmt.Errorf("job %s has invalid permission %q, valid permissions are: %s", job.Describe(),
+					perm.PermissionFlag, strings.Join(permissionNames(), ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func isPermissionAction(action ptypes.PermFlag) bool {
+	switch action {
+	case permission.SetBase, permission.UnsetBase, permission.SetGlobal, permission.AddRole, permission.RemoveRole:
+		return true
+	}
+	return false
+}
+
+var permissionActions = []string{"set_base", "unset_base", "set_global", "add_role", "remove_role"}
+
+func permissionNames() []string {
+	var names []string
+	for i := uint(0); i < permission.NumPermissions; i++ {
+		names = append(names, permission.PermFlagToString(1<<i))
+	}
+	return append(names, permission.AllString)
+}
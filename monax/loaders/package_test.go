@@ -0,0 +1,44 @@
+package loaders
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+func TestValidatePermissions(t *testing.T) {
+	valid := []*definitions.Permission{
+		{Action: "set_base", PermissionFlag: "create_contract", Value: "true"},
+		{Action: "setBase", PermissionFlag: "all", Value: "true"},
+		{Action: "remove_role", Role: "marmot"},
+		{Action: "rm_role", Role: "marmot"},
+		{Action: "$action", PermissionFlag: "$perm"},
+	}
+	for _, perm := range valid {
+		pkg := &definitions.Package{Jobs: []*definitions.Job{{JobName: "perm", Perms: perm}}}
+		if err := validatePermissions(pkg); err != nil {
+			t.Errorf("expected %v to be valid: %v", perm, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		perm     *definitions.Permission
+		contains string
+	}{
+		{&definitions.Permission{Action: "set_bass", PermissionFlag: "send"}, "remove_role"},
+		{&definitions.Permission{Action: "has_base", PermissionFlag: "send"}, "set_global"},
+		{&definitions.Permission{Action: "set_base", PermissionFlag: "sned"}, "createContract"},
+	} {
+		pkg := &definitions.Package{Jobs: []*definitions.Job{{
+			JobName:  "group",
+			Parallel: &definitions.Parallel{Jobs: []*definitions.Job{{JobName: "perm", Permission: tt.perm}}},
+		}}}
+		err := validatePermissions(pkg)
+		if err == nil {
+			t.Errorf("expected %v to be invalid", tt.perm)
+		} else if !strings.Contains(err.Error(), tt.contains) {
+			t.Errorf("expected error to list the valid names but got: %v", err)
+		}
+	}
+}
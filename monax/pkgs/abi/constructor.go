@@ -0,0 +1,138 @@
+package abi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Packs the constructor arguments of a deploy job for appending to the creation bytecode of contractName, whose ABI
+// is abiData. Each argument is checked against the type the constructor declares for it before packing, so an
+// argument of the wrong type fails with the constructor's signature rather than deploying garbage. Integers may be
+// decimal or 0x prefixed hex, booleans true or false, and addresses 40 hex digits with or without 0x.
+func PackConstructorArgs(abiData, contractName string, args []string) ([]byte, error) {
+	constructor, err := findFunction(abiData, "")
+	if err != nil {
+		return nil, err
+	}
+	types, err := parseTupleABITypes(constructor.Inputs)
+	if err != nil {
+		return nil, err
+	}
+	signature := constructorSignature(contractName, constructor.Inputs, types)
+	if len(args) != len(types) {
+		return nil, fmt.Errorf("constructor %s takes %v arguments but %v were given", signature, len(types),
+			len(args))
+	}
+	coerced := make([]string, len(args))
+	for i, arg := range args {
+		coerced[i], err = coerceArg(types[i], arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %v to constructor %s: %v", i+1, signature, err)
+		}
+	}
+	packed, err := Packer(abiData, "", coerced...)
+	if err != nil {
+		return nil, fmt.Errorf("could not pack arguments to constructor %s: %v", signature, err)
+	}
+	return packed, nil
+}
+
+func constructorSignature(contractName string, inputs []argumentSpec, types []*tupleABIType) string {
+	params := make([]string, len(types))
+	for i, t := range types {
+		params[i] = t.String()
+		if inputs[i].Name != "" {
+			params[i] += " " + inputs[i].Name
+		}
+	}
+	return fmt.Sprintf("%s(%s)", contractName, strings.Join(params, ", "))
+}
+
+// Returns arg in the form the packers read values of type t, or an error if it cannot be read as one. Only
+// elementary types and lists of them are checked, tuples are checked as they are encoded.
+func coerceArg(t *tupleABIType, arg string) (string, error) {
+	switch t.kind {
+	case elementaryKind:
+		return coerceElementary(t, strings.TrimSpace(arg))
+	case arrayKind:
+		if t.elem.kind != elementaryKind || t.elem.base == "string" || t.elem.base == "bytes" {
+			return arg, nil
+		}
+		elements := parseTupleInput(t, arg)
+		list, ok := elements.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("expected a list for %s but got %s", t, arg)
+		}
+		if t.length > 0 && len(list) != t.length {
+			return "", fmt.Errorf("expected %v elements for %s but got %v", t.length, t, len(list))
+		}
+		coerced := make([]string, len(list))
+		for i, element := range list {
+			var err error
+			coerced[i], err = coerceElementary(t.elem, strings.TrimSpace(fmt.Sprint(element)))
+			if err != nil {
+				return "", fmt.Errorf("element %v: %v", i+1, err)
+			}
+		}
+		return "[" + strings.Join(coerced, ",") + "]", nil
+	default:
+		return arg, nil
+	}
+}
+
+func coerceElementary(t *tupleABIType, arg string) (string, error) {
+	switch t.base {
+	case "uint", "int":
+		n, err := parseInteger(arg)
+		if err != nil {
+			return "", fmt.Errorf("expected %s but got %s", t, err)
+		}
+		if t.base == "uint" && (n.Sign() < 0 || n.BitLen() > t.size) {
+			return "", fmt.Errorf("%s does not fit in %s", arg, t)
+		}
+		if t.base == "int" {
+			limit := new(big.Int).Lsh(big.NewInt(1), uint(t.size-1))
+			if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+				return "", fmt.Errorf("%s does not fit in %s", arg, t)
+			}
+		}
+		return n.String(), nil
+	case "bool":
+		switch strings.ToLower(arg) {
+		case "true", "false":
+			return strings.ToLower(arg), nil
+		}
+		return "", fmt.Errorf("expected true or false for bool but got '%s'", arg)
+	case "address":
+		address := strings.TrimPrefix(strings.TrimPrefix(arg, "0x"), "0X")
+		if bs, err := hex.DecodeString(address); err != nil || len(bs) != 20 {
+			return "", fmt.Errorf("expected an address of 40 hex digits but got '%s' "+
+				"(quote addresses YAML would otherwise read as a number)", arg)
+		}
+		return address, nil
+	default:
+		return arg, nil
+	}
+}
+
+// Reads a decimal or 0x prefixed hex integer
+func parseInteger(arg string) (*big.Int, error) {
+	digits, negative := arg, false
+	if strings.HasPrefix(digits, "-") {
+		digits, negative = digits[1:], true
+	}
+	base := 10
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits, base = digits[2:], 16
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok || strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		return nil, fmt.Errorf("'%s', which is not a decimal or hex integer", arg)
+	}
+	if negative {
+		n.Neg(n)
+	}
+	return n, nil
+}
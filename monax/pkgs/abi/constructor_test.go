@@ -0,0 +1,61 @@
+package abi
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const constructorABI = `[{"inputs":[{"name":"supply","type":"uint64"},{"name":"owner","type":"address"},` +
+	`{"name":"open","type":"bool"},{"name":"limits","type":"int8[2]"}],"payable":false,"type":"constructor"}]`
+
+func TestPackConstructorArgs(t *testing.T) {
+	owner := "1040E6521541DAB4E7EE57F21226DD17CE9F0FB7"
+	expected, err := Packer(constructorABI, "", "4096", owner, "true", "[1,-2]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"4096", owner, "true", "[1,-2]"},
+		{"0x1000", "0x" + owner, "TRUE", "[0x1, -0x2]"},
+		{" 4096", strings.ToLower(owner), "true", "[1,-2]"},
+	} {
+		packed, err := PackConstructorArgs(constructorABI, "Coin", args)
+		if err != nil {
+			t.Errorf("could not pack %v: %v", args, err)
+		} else if !bytes.Equal(packed, expected) {
+			t.Errorf("expected %v to pack as %X but got %X", args, expected, packed)
+		}
+	}
+
+	// A contract without a constructor takes no arguments
+	packed, err := PackConstructorArgs(`[]`, "Plain", nil)
+	if err != nil || len(packed) != 0 {
+		t.Errorf("expected no arguments to pack as nothing but got %X, %v", packed, err)
+	}
+}
+
+func TestPackConstructorArgsMismatch(t *testing.T) {
+	owner := "1040E6521541DAB4E7EE57F21226DD17CE9F0FB7"
+	for _, args := range [][]string{
+		nil,
+		{"4096", owner, "true"},
+		{owner, "4096", "true", "[1,-2]"},
+		{"-1", owner, "true", "[1,-2]"},
+		{"18446744073709551616", owner, "true", "[1,-2]"},
+		{"4096", "42", "true", "[1,-2]"},
+		{"4096", owner, "yes", "[1,-2]"},
+		{"4096", owner, "true", "[1,-2,3]"},
+		{"4096", owner, "true", "[1,128]"},
+	} {
+		_, err := PackConstructorArgs(constructorABI, "Coin", args)
+		if err == nil {
+			t.Errorf("expected packing %v to fail", args)
+		} else if !strings.Contains(err.Error(), "Coin(uint64 supply, address owner, bool open, int8[2] limits)") {
+			t.Errorf("expected error to give the constructor signature but got: %v", err)
+		}
+	}
+	if _, err := PackConstructorArgs(`[]`, "Plain", []string{"1"}); err == nil {
+		t.Error("expected passing an argument to a contract without a constructor to fail")
+	}
+}
@@ -18,10 +18,13 @@ import (
 )
 
 func ReadAbiFormulateCall(abiLocation string, funcName string, args []string, do *definitions.Do) ([]byte, error) {
-	abiSpecBytes, err := util.ReadAbi(do.ABIPath, abiLocation)
+	abiSpecBytes, funcName, warnings, err := readFunctionAbi(abiLocation, funcName, do)
 	if err != nil {
 		return []byte{}, err
 	}
+	for _, warning := range warnings {
+		log.Warn(warning)
+	}
 	log.WithField("=>", string(abiSpecBytes)).Debug("ABI Specification (Formulate)")
 	log.WithFields(log.Fields{
 		"function":  funcName,
@@ -32,7 +35,8 @@ func ReadAbiFormulateCall(abiLocation string, funcName string, args []string, do
 }
 
 func ReadAndDecodeContractReturn(abiLocation, funcName string, resultRaw []byte, do *definitions.Do) ([]*definitions.Variable, error) {
-	abiSpecBytes, err := util.ReadAbi(do.ABIPath, abiLocation)
+	// Any warnings about the signature were given when the call was formulated
+	abiSpecBytes, funcName, _, err := readFunctionAbi(abiLocation, funcName, do)
 	if err != nil {
 		return nil, err
 	}
@@ -42,6 +46,20 @@ func ReadAndDecodeContractReturn(abiLocation, funcName string, resultRaw []byte,
 	return Unpacker(abiSpecBytes, funcName, resultRaw)
 }
 
+// Reads the ABI saved at abiLocation, or when function is a signature such as "balanceOf(address) returns (uint256)"
+// the ABI of just that function as SignatureABI gives, for which no ABI need be saved
+func readFunctionAbi(abiLocation, function string, do *definitions.Do) (string, string, []string, error) {
+	abiData, err := util.ReadAbi(do.ABIPath, abiLocation)
+	if !IsSignature(function) {
+		return abiData, function, nil, err
+	}
+	if err != nil {
+		log.WithField("abi", abiLocation).Debug("No ABI saved, using the function signature alone")
+		abiData = ""
+	}
+	return SignatureABI(abiData, function)
+}
+
 func MakeAbi(abiData string) (ethAbi.ABI, error) {
 	if len(abiData) == 0 {
 		return ethAbi.ABI{}, nil
@@ -57,6 +75,9 @@ func MakeAbi(abiData string) (ethAbi.ABI, error) {
 
 //Convenience Packing Functions
 func Packer(abiData, funcName string, args ...string) ([]byte, error) {
+	if hasTuples(abiData) {
+		return packTuples(abiData, funcName, args...)
+	}
 	abiSpec, err := MakeAbi(abiData)
 	if err != nil {
 		return nil, err
@@ -294,6 +315,9 @@ func packInterfaceValue(typ ethAbi.Type, val string) (interface{}, error) {
 }
 
 func Unpacker(abiData, name string, data []byte) ([]*definitions.Variable, error) {
+	if hasTuples(abiData) {
+		return unpackTuples(abiData, name, data)
+	}
 
 	abiSpec, err := MakeAbi(abiData)
 	if err != nil {
@@ -367,6 +391,7 @@ func formatUnpackedReturn(abiSpec ethAbi.ABI, methodName string, values ...inter
 			returnVar := &definitions.Variable{
 				Name:  name,
 				Value: arg,
+				Type:  output.Type.String(),
 			}
 			returnVars = append(returnVars, returnVar)
 		}
@@ -387,6 +412,7 @@ func formatUnpackedReturn(abiSpec ethAbi.ABI, methodName string, values ...inter
 		returnVar := &definitions.Variable{
 			Name:  name,
 			Value: arg,
+			Type:  output.Type.String(),
 		}
 		returnVars = append(returnVars, returnVar)
 	}
@@ -407,7 +433,8 @@ func getStringValue(value interface{}, typ ethAbi.Type) (string, error) {
 			}
 		} else {
 			values := reflect.ValueOf(value)
-			for i := 0; i < typ.SliceSize; i++ {
+			// SliceSize is -1 for dynamic arrays so take the length from the unpacked value
+			for i := 0; i < values.Len(); i++ {
 				underlyingValue, err := getStringValue(values.Index(i).Interface(), *typ.Elem)
 				if err != nil {
 					return "", err
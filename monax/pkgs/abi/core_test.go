@@ -314,6 +314,30 @@ func TestUnpacker(t *testing.T) {
 				},
 			},
 		},
+		{
+			`[{"constant":true,"inputs":[],"name":"multiReturnDynamic","outputs":[{"name":"total","type":"uint256"},{"name":"holders","type":"address[]"},{"name":"","type":"bool"}],"payable":false,"type":"function"}]`,
+			common.Hex2Bytes("0000000000000000000000000000000000000000000000000000000000000007" +
+				"0000000000000000000000000000000000000000000000000000000000000060" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000001040E6521541DAB4E7EE57F21226DD17CE9F0FB7" +
+				"00000000000000000000000000000000000000000000000000000000000000FF"),
+			"multiReturnDynamic",
+			[]pm.Variable{
+				{
+					Name:  "total",
+					Value: "7",
+				},
+				{
+					Name:  "holders",
+					Value: "[1040E6521541DAB4E7EE57F21226DD17CE9F0FB7,00000000000000000000000000000000000000FF]",
+				},
+				{
+					Name:  "2",
+					Value: "true",
+				},
+			},
+		},
 	} {
 		//t.Log(test.name)
 		t.Log(test.packed)
@@ -334,3 +358,18 @@ func TestUnpacker(t *testing.T) {
 		}
 	}
 }
+
+func TestUnpackerMismatch(t *testing.T) {
+	abi := `[{"constant":true,"inputs":[],"name":"holders","outputs":[{"name":"","type":"uint256"},{"name":"","type":"address[]"}],"payable":false,"type":"function"}]`
+	// a single word cannot hold the offset of the array
+	_, err := Unpacker(abi, "holders", common.Hex2Bytes("0000000000000000000000000000000000000000000000000000000000000001"))
+	if err == nil {
+		t.Errorf("Unpacker should fail when the return data does not match the ABI")
+	}
+	// the offset of the array is beyond the end of the data
+	_, err = Unpacker(abi, "holders", common.Hex2Bytes("0000000000000000000000000000000000000000000000000000000000000001"+
+		"00000000000000000000000000000000000000000000000000000000000000a0"))
+	if err == nil {
+		t.Errorf("Unpacker should fail when the return data does not match the ABI")
+	}
+}
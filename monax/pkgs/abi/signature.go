@@ -0,0 +1,304 @@
+package abi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Jobs may name the function to call with a human-readable signature rather than a bare name, in the form ethers
+// accepts: "transfer(address,uint256)" or "function balanceOf(address owner) view returns (uint256)". A signature is
+// enough to encode the call and decode its return without an ABI file, and when there is one it picks out which of
+// several overloads of a function is meant.
+
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*$`)
+
+// Words that may follow a parameter's type other than its name
+var parameterModifiers = map[string]bool{
+	"indexed":  true,
+	"memory":   true,
+	"calldata": true,
+	"storage":  true,
+}
+
+// Whether function is a signature such as "transfer(address,uint256)" rather than the name of a function. The
+// fallback function "()" is not a signature.
+func IsSignature(function string) bool {
+	i := strings.Index(function, "(")
+	return i > 0 && strings.TrimSpace(function[:i]) != ""
+}
+
+// Parses a human-readable function signature into the spec of a function of a JSON ABI
+func parseSignature(signature string) (*functionSpec, error) {
+	rest := strings.TrimSpace(signature)
+	if strings.HasPrefix(rest, "function ") {
+		rest = strings.TrimSpace(rest[len("function "):])
+	}
+	open := strings.Index(rest, "(")
+	if open < 0 {
+		return nil, fmt.Errorf("signature %s has no parameter list", signature)
+	}
+	spec := &functionSpec{
+		Type: "function",
+		Name: strings.TrimSpace(rest[:open]),
+	}
+	if !identifierRegexp.MatchString(spec.Name) {
+		return nil, fmt.Errorf("signature %s does not start with a function name", signature)
+	}
+	params, rest, err := parenthesised(rest[open:])
+	if err != nil {
+		return nil, fmt.Errorf("signature %s: %v", signature, err)
+	}
+	if spec.Inputs, err = parseParameters(params); err != nil {
+		return nil, fmt.Errorf("signature %s: %v", signature, err)
+	}
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		word := rest
+		if i := strings.IndexAny(rest, " \t("); i >= 0 {
+			word = rest[:i]
+		}
+		rest = rest[len(word):]
+		switch word {
+		case "view", "pure":
+			spec.Constant = true
+			spec.StateMutability = word
+		case "constant":
+			spec.Constant = true
+		case "payable", "nonpayable":
+			spec.StateMutability = word
+		case "external", "public":
+		case "returns":
+			params, rest, err = parenthesised(strings.TrimSpace(rest))
+			if err != nil {
+				return nil, fmt.Errorf("signature %s: returns %v", signature, err)
+			}
+			if spec.Outputs, err = parseParameters(params); err != nil {
+				return nil, fmt.Errorf("signature %s: %v", signature, err)
+			}
+			if strings.TrimSpace(rest) != "" {
+				return nil, fmt.Errorf("signature %s has '%s' after its returns", signature, strings.TrimSpace(rest))
+			}
+		default:
+			return nil, fmt.Errorf("signature %s has unknown modifier '%s'", signature, word)
+		}
+	}
+	return spec, nil
+}
+
+// Splits s, which must start with an opening parenthesis, into what is inside it and its matching closing
+// parenthesis and what follows
+func parenthesised(s string) (string, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return "", "", fmt.Errorf("expected a parenthesised parameter list")
+	}
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s[1:i], s[i+1:], nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("unbalanced parentheses in '%s'", s)
+}
+
+func parseParameters(params string) ([]argumentSpec, error) {
+	var args []argumentSpec
+	if strings.TrimSpace(params) == "" {
+		return args, nil
+	}
+	depth, start := 0, 0
+	for i := 0; i <= len(params); i++ {
+		if i < len(params) {
+			switch params[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if params[i] != ',' || depth > 0 {
+				continue
+			}
+		}
+		arg, err := parseParameter(strings.TrimSpace(params[start:i]))
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		start = i + 1
+	}
+	return args, nil
+}
+
+// Parses a type followed by optional modifiers and a name, such as "uint256[] memory amounts" or
+// "tuple(address to, uint256 amount) transfer"
+func parseParameter(param string) (argumentSpec, error) {
+	var arg argumentSpec
+	if param == "" {
+		return arg, fmt.Errorf("empty parameter")
+	}
+	var rest string
+	if strings.HasPrefix(param, "(") || strings.HasPrefix(param, "tuple(") {
+		components, after, err := parenthesised(strings.TrimPrefix(param, "tuple"))
+		if err != nil {
+			return arg, err
+		}
+		if arg.Components, err = parseParameters(components); err != nil {
+			return arg, err
+		}
+		// Array dimensions follow the closing parenthesis
+		dimensions := after
+		if i := strings.IndexAny(after, " \t"); i >= 0 {
+			dimensions = after[:i]
+		}
+		arg.Type = "tuple" + dimensions
+		rest = after[len(dimensions):]
+	} else {
+		fields := strings.Fields(param)
+		arg.Type = canonicalType(fields[0])
+		rest = strings.Join(fields[1:], " ")
+	}
+	for _, word := range strings.Fields(rest) {
+		if parameterModifiers[word] {
+			continue
+		}
+		if arg.Name != "" || !identifierRegexp.MatchString(word) {
+			return arg, fmt.Errorf("could not read parameter '%s'", param)
+		}
+		arg.Name = word
+	}
+	if _, err := parseTupleABIType(arg); err != nil {
+		return arg, err
+	}
+	return arg, nil
+}
+
+// Expands the aliases solidity allows for elementary types to the names used in ABIs
+func canonicalType(typ string) string {
+	elementary, dimensions := typ, ""
+	if i := strings.Index(typ, "["); i >= 0 {
+		elementary, dimensions = typ[:i], typ[i:]
+	}
+	switch elementary {
+	case "uint", "int":
+		elementary += "256"
+	case "byte":
+		elementary = "bytes1"
+	}
+	return elementary + dimensions
+}
+
+// The canonical signature of spec from which its selector is taken, such as "transfer(address,uint256)"
+func canonicalSignature(spec *functionSpec) (string, error) {
+	inputs, err := canonicalTypes(spec.Inputs)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s(%s)", spec.Name, inputs), nil
+}
+
+func canonicalTypes(args []argumentSpec) (string, error) {
+	types, err := parseTupleABITypes(args)
+	if err != nil {
+		return "", err
+	}
+	params := make([]string, len(types))
+	for i, t := range types {
+		params[i] = t.String()
+	}
+	return strings.Join(params, ","), nil
+}
+
+// Returns an ABI holding only the function signature names, for packing and unpacking by the name returned, along
+// with warnings of any way the signature disagrees with abiData. When abiData has a function matching the
+// signature's name and parameter types that function is used, with its parameter names and whichever of its
+// overloads matches, otherwise abiData, which may be empty, is ignored and the function is built from the signature.
+func SignatureABI(abiData, signature string) (string, string, []string, error) {
+	spec, err := parseSignature(signature)
+	if err != nil {
+		return "", "", nil, err
+	}
+	canonical, err := canonicalSignature(spec)
+	if err != nil {
+		return "", "", nil, err
+	}
+	var warnings []string
+	if abiData != "" {
+		entries, err := functionEntries(abiData, spec.Name)
+		if err != nil {
+			return "", "", nil, err
+		}
+		for _, entry := range entries {
+			entrySignature, err := canonicalSignature(entry.spec)
+			if err != nil || entrySignature != canonical {
+				continue
+			}
+			warnings = append(warnings, signatureMismatches(signature, spec, entry.spec)...)
+			return "[" + string(entry.raw) + "]", spec.Name, warnings, nil
+		}
+		if len(entries) > 0 {
+			var overloads []string
+			for _, entry := range entries {
+				entrySignature, _ := canonicalSignature(entry.spec)
+				overloads = append(overloads, entrySignature)
+			}
+			warnings = append(warnings, fmt.Sprintf("signature %s matches none of %s in the ABI, using the "+
+				"signature", canonical, strings.Join(overloads, ", ")))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("the ABI has no function %s, using the signature", spec.Name))
+		}
+	}
+	bs, err := json.Marshal([]*functionSpec{spec})
+	if err != nil {
+		return "", "", nil, err
+	}
+	return string(bs), spec.Name, warnings, nil
+}
+
+type functionEntry struct {
+	raw  json.RawMessage
+	spec *functionSpec
+}
+
+// The functions of abiData called name, each with the JSON it was read from
+func functionEntries(abiData, name string) ([]functionEntry, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal([]byte(abiData), &raws); err != nil {
+		return nil, fmt.Errorf("could not read ABI: %v", err)
+	}
+	var entries []functionEntry
+	for _, raw := range raws {
+		spec := new(functionSpec)
+		if err := json.Unmarshal(raw, spec); err != nil {
+			return nil, fmt.Errorf("could not read ABI: %v", err)
+		}
+		if spec.Name == name && (spec.Type == "function" || spec.Type == "") {
+			entries = append(entries, functionEntry{raw: raw, spec: spec})
+		}
+	}
+	return entries, nil
+}
+
+// Describes where what the signature says a function returns or whether it is view differs from the ABI,
+// comparing only what the signature gives
+func signatureMismatches(signature string, spec, abiSpec *functionSpec) []string {
+	var warnings []string
+	if len(spec.Outputs) > 0 {
+		outputs, _ := canonicalTypes(spec.Outputs)
+		abiOutputs, _ := canonicalTypes(abiSpec.Outputs)
+		if outputs != abiOutputs {
+			warnings = append(warnings, fmt.Sprintf("signature %s returns (%s) but the ABI has %s returning "+
+				"(%s), decoding against the ABI", signature, outputs, abiSpec.Name, abiOutputs))
+		}
+	}
+	if spec.Constant && !abiSpec.Constant && abiSpec.StateMutability != "view" && abiSpec.StateMutability != "pure" {
+		warnings = append(warnings, fmt.Sprintf("signature %s is view but the ABI does not mark %s as view",
+			signature, abiSpec.Name))
+	}
+	return warnings
+}
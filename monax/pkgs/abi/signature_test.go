@@ -0,0 +1,124 @@
+package abi
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const overloadedABI = `[
+{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],
+ "outputs":[{"name":"ok","type":"bool"}]},
+{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"},{"name":"memo","type":"bytes"}],
+ "outputs":[{"name":"ok","type":"bool"}]},
+{"type":"function","name":"balanceOf","constant":true,"inputs":[{"name":"owner","type":"address"}],
+ "outputs":[{"name":"balance","type":"uint256"}]}
+]`
+
+func TestParseSignature(t *testing.T) {
+	spec, err := parseSignature("function balanceOf(address owner) view returns (uint)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Name != "balanceOf" || !spec.Constant || spec.StateMutability != "view" {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+	if len(spec.Inputs) != 1 || spec.Inputs[0].Name != "owner" || spec.Inputs[0].Type != "address" {
+		t.Errorf("unexpected inputs %+v", spec.Inputs)
+	}
+	if len(spec.Outputs) != 1 || spec.Outputs[0].Type != "uint256" {
+		t.Errorf("expected uint to be read as uint256 but got %+v", spec.Outputs)
+	}
+
+	spec, err = parseSignature("set((uint256 x, int8[] ys)[2] points, bytes calldata data) external payable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := canonicalSignature(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature != "set((uint256,int8[])[2],bytes)" || spec.StateMutability != "payable" {
+		t.Errorf("unexpected signature %s of %+v", signature, spec)
+	}
+	if spec.Inputs[0].Name != "points" || spec.Inputs[0].Components[1].Name != "ys" {
+		t.Errorf("expected parameter names to be kept but got %+v", spec.Inputs)
+	}
+
+	for _, bad := range []string{"transfer", "(address)", "transfer(address", "transfer(address,)",
+		"transfer(adress)", "transfer(address) mutable", "f() returns (bool) view", "f(uint256 a b)"} {
+		if _, err := parseSignature(bad); err == nil {
+			t.Errorf("expected %s not to parse", bad)
+		}
+	}
+	if IsSignature("()") || IsSignature("transfer") || !IsSignature("transfer(address,uint256)") {
+		t.Error("expected only a name followed by parameters to be a signature")
+	}
+}
+
+func TestSignatureABI(t *testing.T) {
+	owner := "1040E6521541DAB4E7EE57F21226DD17CE9F0FB7"
+
+	// Without an ABI the signature alone encodes and decodes
+	abiData, name, warnings, err := SignatureABI("", "balanceOf(address) view returns (uint256)")
+	if err != nil || len(warnings) > 0 {
+		t.Fatalf("unexpected %v, %v", warnings, err)
+	}
+	packed, err := Packer(abiData, name, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packed, append(selector("balanceOf(address)"), words(owner)...)) {
+		t.Errorf("unexpected encoding %X", packed)
+	}
+	vars, err := Unpacker(abiData, name, words("2A"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 1 || vars[0].Value != "42" || vars[0].Name != "0" {
+		t.Errorf("unexpected decoding %+v", vars)
+	}
+
+	// The overload the signature names is picked out of the ABI, with its parameter names
+	abiData, name, warnings, err = SignatureABI(overloadedABI, "transfer(address,uint256,bytes)")
+	if err != nil || len(warnings) > 0 {
+		t.Fatalf("unexpected %v, %v", warnings, err)
+	}
+	packed, err = Packer(abiData, name, owner, "1", "CAFE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packed[:4], selector("transfer(address,uint256,bytes)")) {
+		t.Errorf("expected the three argument overload to be called but got selector %X", packed[:4])
+	}
+	vars, err = Unpacker(abiData, name, words("1"))
+	if err != nil || len(vars) != 1 || vars[0].Name != "ok" {
+		t.Errorf("expected the return to be decoded with its name from the ABI but got %+v, %v", vars, err)
+	}
+	abiData, name, _, err = SignatureABI(overloadedABI, "transfer(address,uint256)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed, err = Packer(abiData, name, owner, "1")
+	if err != nil || !bytes.Equal(packed[:4], selector("transfer(address,uint256)")) {
+		t.Errorf("expected the two argument overload to be called but got %X, %v", packed, err)
+	}
+
+	// Disagreeing with the ABI is only a warning
+	for signature, mismatch := range map[string]string{
+		"balanceOf(address) returns (uint8)":   "returns (uint8)",
+		"transfer(address) returns (bool)":     "matches none of",
+		"approve(address,uint256)":             "no function approve",
+		"transfer(address,uint256) view":       "view",
+		"balanceOf(address) returns (uint256)": "",
+	} {
+		_, _, warnings, err = SignatureABI(overloadedABI, signature)
+		if err != nil {
+			t.Errorf("expected %s to be usable but got %v", signature, err)
+		} else if mismatch == "" && len(warnings) > 0 {
+			t.Errorf("expected no warnings for %s but got %v", signature, warnings)
+		} else if mismatch != "" && (len(warnings) != 1 || !strings.Contains(warnings[0], mismatch)) {
+			t.Errorf("expected %s to warn of %s but got %v", signature, mismatch, warnings)
+		}
+	}
+}
@@ -0,0 +1,642 @@
+package abi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The go-ethereum ABI we vendor cannot parse tuple (struct) types, so ABIs using them are packed
+// and unpacked here. Job inputs for a tuple are given as a list of its components in order or as
+// a map of component name to value, either of which may nest. Decoded tuples are returned as JSON
+// with the components keyed by name, or as a list when any component is unnamed.
+
+type argumentSpec struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Components []argumentSpec `json:"components"`
+}
+
+type functionSpec struct {
+	Type            string         `json:"type"`
+	Name            string         `json:"name"`
+	Constant        bool           `json:"constant,omitempty"`
+	StateMutability string         `json:"stateMutability,omitempty"`
+	Inputs          []argumentSpec `json:"inputs"`
+	Outputs         []argumentSpec `json:"outputs"`
+}
+
+const (
+	elementaryKind = iota
+	arrayKind
+	tupleKind
+)
+
+type tupleABIType struct {
+	kind int
+	// elementary types
+	base string
+	size int
+	// arrays, length 0 is a dynamic array
+	elem   *tupleABIType
+	length int
+	// tuples
+	components []*tupleABIType
+	names      []string
+}
+
+// Whether any function in the ABI takes or returns a tuple
+func hasTuples(abiData string) bool {
+	var specs []functionSpec
+	if err := json.Unmarshal([]byte(abiData), &specs); err != nil {
+		return false
+	}
+	for _, spec := range specs {
+		for _, args := range [][]argumentSpec{spec.Inputs, spec.Outputs} {
+			for _, arg := range args {
+				if strings.HasPrefix(arg.Type, "tuple") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func findFunction(abiData, funcName string) (*functionSpec, error) {
+	var specs []functionSpec
+	if err := json.Unmarshal([]byte(abiData), &specs); err != nil {
+		return nil, err
+	}
+	for _, spec := range specs {
+		if funcName == "" && spec.Type == "constructor" {
+			return &spec, nil
+		}
+		if funcName != "" && spec.Name == funcName && (spec.Type == "function" || spec.Type == "") {
+			return &spec, nil
+		}
+	}
+	if funcName == "" {
+		// a contract without a constructor takes no arguments
+		return &functionSpec{Type: "constructor"}, nil
+	}
+	return nil, fmt.Errorf("method '%s' not found", funcName)
+}
+
+func parseTupleABIType(arg argumentSpec) (*tupleABIType, error) {
+	typ := arg.Type
+	if strings.HasSuffix(typ, "]") {
+		i := strings.LastIndex(typ, "[")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid ABI type %s", arg.Type)
+		}
+		elem, err := parseTupleABIType(argumentSpec{Type: typ[:i], Components: arg.Components})
+		if err != nil {
+			return nil, err
+		}
+		t := &tupleABIType{kind: arrayKind, elem: elem}
+		if length := typ[i+1 : len(typ)-1]; length != "" {
+			if t.length, err = strconv.Atoi(length); err != nil || t.length < 1 {
+				return nil, fmt.Errorf("invalid array length in ABI type %s", arg.Type)
+			}
+		}
+		return t, nil
+	}
+
+	if typ == "tuple" {
+		if len(arg.Components) == 0 {
+			return nil, fmt.Errorf("tuple %s has no components", arg.Name)
+		}
+		t := &tupleABIType{kind: tupleKind}
+		for _, component := range arg.Components {
+			c, err := parseTupleABIType(component)
+			if err != nil {
+				return nil, err
+			}
+			t.components = append(t.components, c)
+			t.names = append(t.names, component.Name)
+		}
+		return t, nil
+	}
+
+	t := &tupleABIType{kind: elementaryKind}
+	switch {
+	case typ == "address" || typ == "bool" || typ == "string" || typ == "bytes":
+		t.base = typ
+	case strings.HasPrefix(typ, "uint") || strings.HasPrefix(typ, "int"):
+		t.base = strings.TrimRight(typ, "0123456789")
+		t.size = 256
+		if size := typ[len(t.base):]; size != "" {
+			var err error
+			if t.size, err = strconv.Atoi(size); err != nil || t.size < 8 || t.size > 256 || t.size%8 != 0 {
+				return nil, fmt.Errorf("invalid integer size in ABI type %s", typ)
+			}
+		}
+	case strings.HasPrefix(typ, "bytes"):
+		t.base = "bytes"
+		var err error
+		if t.size, err = strconv.Atoi(typ[len("bytes"):]); err != nil || t.size < 1 || t.size > 32 {
+			return nil, fmt.Errorf("invalid size in ABI type %s", typ)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported ABI type %s", typ)
+	}
+	return t, nil
+}
+
+func parseTupleABITypes(args []argumentSpec) ([]*tupleABIType, error) {
+	var types []*tupleABIType
+	for _, arg := range args {
+		t, err := parseTupleABIType(arg)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// The canonical type as used in function signatures
+func (t *tupleABIType) String() string {
+	switch t.kind {
+	case arrayKind:
+		if t.length == 0 {
+			return t.elem.String() + "[]"
+		}
+		return fmt.Sprintf("%s[%d]", t.elem, t.length)
+	case tupleKind:
+		var components []string
+		for _, c := range t.components {
+			components = append(components, c.String())
+		}
+		return "(" + strings.Join(components, ",") + ")"
+	default:
+		if t.base == "uint" || t.base == "int" || (t.base == "bytes" && t.size > 0) {
+			return fmt.Sprintf("%s%d", t.base, t.size)
+		}
+		return t.base
+	}
+}
+
+func (t *tupleABIType) dynamic() bool {
+	switch t.kind {
+	case arrayKind:
+		return t.length == 0 || t.elem.dynamic()
+	case tupleKind:
+		for _, c := range t.components {
+			if c.dynamic() {
+				return true
+			}
+		}
+		return false
+	default:
+		return t.base == "string" || (t.base == "bytes" && t.size == 0)
+	}
+}
+
+// Size of the encoding of a static type or of the offset to a dynamic one
+func (t *tupleABIType) headSize() int {
+	if t.dynamic() {
+		return 32
+	}
+	switch t.kind {
+	case arrayKind:
+		return t.length * t.elem.headSize()
+	case tupleKind:
+		size := 0
+		for _, c := range t.components {
+			size += c.headSize()
+		}
+		return size
+	default:
+		return 32
+	}
+}
+
+func (t *tupleABIType) containsTuple() bool {
+	switch t.kind {
+	case arrayKind:
+		return t.elem.containsTuple()
+	case tupleKind:
+		return true
+	default:
+		return false
+	}
+}
+
+func packTuples(abiData, funcName string, args ...string) ([]byte, error) {
+	function, err := findFunction(abiData, funcName)
+	if err != nil {
+		return nil, err
+	}
+	types, err := parseTupleABITypes(function.Inputs)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != len(types) {
+		return nil, fmt.Errorf("Invalid number of arguments asked to be packed, expected %v, got %v", len(types), len(args))
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = parseTupleInput(types[i], arg)
+	}
+	packed, err := encodeSequence(types, values)
+	if err != nil {
+		return nil, err
+	}
+	if funcName == "" {
+		return packed, nil
+	}
+	var signature []string
+	for _, t := range types {
+		signature = append(signature, t.String())
+	}
+	selector := crypto.Keccak256([]byte(fmt.Sprintf("%s(%s)", funcName, strings.Join(signature, ","))))[:4]
+	return append(selector, packed...), nil
+}
+
+// Job inputs arrive as strings, those for arrays and tuples being JSON or the [a,b] form that
+// non-nested lists are given in
+func parseTupleInput(t *tupleABIType, arg string) interface{} {
+	if t.kind == elementaryKind {
+		return arg
+	}
+	decoder := json.NewDecoder(strings.NewReader(arg))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err == nil {
+		return value
+	}
+	var elements []interface{}
+	for _, element := range strings.Split(strings.Trim(arg, "[]"), ",") {
+		elements = append(elements, strings.TrimSpace(element))
+	}
+	return elements
+}
+
+func encodeSequence(types []*tupleABIType, values []interface{}) ([]byte, error) {
+	if len(types) != len(values) {
+		return nil, fmt.Errorf("expected %v values but got %v", len(types), len(values))
+	}
+	headSize := 0
+	for _, t := range types {
+		headSize += t.headSize()
+	}
+	var head, tail []byte
+	for i, t := range types {
+		encoded, err := encodeValue(t, values[i])
+		if err != nil {
+			return nil, err
+		}
+		if t.dynamic() {
+			head = append(head, encodeUint(uint64(headSize+len(tail)))...)
+			tail = append(tail, encoded...)
+		} else {
+			head = append(head, encoded...)
+		}
+	}
+	return append(head, tail...), nil
+}
+
+func encodeValue(t *tupleABIType, value interface{}) ([]byte, error) {
+	switch t.kind {
+	case arrayKind:
+		elements, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a list for %s but got %v", t, value)
+		}
+		if t.length > 0 && len(elements) != t.length {
+			return nil, fmt.Errorf("expected %v elements for %s but got %v", t.length, t, len(elements))
+		}
+		types := make([]*tupleABIType, len(elements))
+		for i := range types {
+			types[i] = t.elem
+		}
+		encoded, err := encodeSequence(types, elements)
+		if err != nil {
+			return nil, err
+		}
+		if t.length == 0 {
+			return append(encodeUint(uint64(len(elements))), encoded...), nil
+		}
+		return encoded, nil
+	case tupleKind:
+		components, err := tupleComponents(t, value)
+		if err != nil {
+			return nil, err
+		}
+		return encodeSequence(t.components, components)
+	default:
+		return encodeElementary(t, value)
+	}
+}
+
+// The values of a tuple's components in order, from a list or from a map keyed by component name
+func tupleComponents(t *tupleABIType, value interface{}) ([]interface{}, error) {
+	switch value := value.(type) {
+	case []interface{}:
+		if len(value) != len(t.components) {
+			return nil, fmt.Errorf("expected %v components for %s but got %v", len(t.components), t, len(value))
+		}
+		return value, nil
+	case map[string]interface{}:
+		components := make([]interface{}, len(t.names))
+		for i, name := range t.names {
+			component, ok := value[name]
+			if !ok {
+				return nil, fmt.Errorf("missing component '%s' of %s", name, t)
+			}
+			components[i] = component
+		}
+		if len(value) != len(t.names) {
+			return nil, fmt.Errorf("unknown components given for %s, expected %s", t, strings.Join(t.names, ", "))
+		}
+		return components, nil
+	default:
+		return nil, fmt.Errorf("expected a list or map for %s but got %v", t, value)
+	}
+}
+
+func encodeElementary(t *tupleABIType, value interface{}) ([]byte, error) {
+	var val string
+	switch value := value.(type) {
+	case string:
+		val = value
+	case json.Number:
+		val = value.String()
+	case bool:
+		val = strconv.FormatBool(value)
+	default:
+		return nil, fmt.Errorf("expected a value for %s but got %v", t, value)
+	}
+
+	switch t.base {
+	case "uint", "int":
+		n, ok := new(big.Int).SetString(val, 10)
+		if !ok {
+			return nil, fmt.Errorf("could not parse %s as %s", val, t)
+		}
+		if t.base == "uint" && (n.Sign() < 0 || n.BitLen() > t.size) {
+			return nil, fmt.Errorf("%s does not fit in %s", val, t)
+		}
+		if t.base == "int" {
+			limit := new(big.Int).Lsh(big.NewInt(1), uint(t.size-1))
+			if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+				return nil, fmt.Errorf("%s does not fit in %s", val, t)
+			}
+		}
+		return math.PaddedBigBytes(math.U256(n), 32), nil
+	case "bool":
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return encodeUint(1), nil
+		}
+		return encodeUint(0), nil
+	case "address":
+		return common.LeftPadBytes(common.HexToAddress(val).Bytes(), 32), nil
+	case "string":
+		return encodeBytes([]byte(val)), nil
+	default:
+		if t.size == 0 {
+			return encodeBytes([]byte(val)), nil
+		}
+		if len(val) > t.size {
+			return nil, fmt.Errorf("%s is longer than %s", val, t)
+		}
+		return common.RightPadBytes([]byte(val), 32), nil
+	}
+}
+
+func encodeUint(n uint64) []byte {
+	return common.LeftPadBytes(new(big.Int).SetUint64(n).Bytes(), 32)
+}
+
+func encodeBytes(bs []byte) []byte {
+	padded := (len(bs) + 31) / 32 * 32
+	return append(encodeUint(uint64(len(bs))), common.RightPadBytes(bs, padded)...)
+}
+
+func unpackTuples(abiData, funcName string, data []byte) ([]*definitions.Variable, error) {
+	if funcName == "()" {
+		return nil, nil
+	}
+	function, err := findFunction(abiData, funcName)
+	if err != nil {
+		return nil, err
+	}
+	types, err := parseTupleABITypes(function.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	if len(types) == 0 {
+		return nil, nil
+	}
+	values, err := decodeSequence(types, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var returnVars []*definitions.Variable
+	for i, t := range types {
+		name := function.Outputs[i].Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		value, err := formatTupleOutput(t, values[i])
+		if err != nil {
+			return nil, err
+		}
+		returnVars = append(returnVars, &definitions.Variable{
+			Name:  name,
+			Value: value,
+			Type:  t.String(),
+		})
+	}
+	return returnVars, nil
+}
+
+func decodeSequence(types []*tupleABIType, data []byte) ([]interface{}, error) {
+	values := make([]interface{}, len(types))
+	head := 0
+	for i, t := range types {
+		if head+t.headSize() > len(data) {
+			return nil, fmt.Errorf("return data too short to decode %s", t)
+		}
+		var err error
+		if t.dynamic() {
+			var offset int
+			if offset, err = decodeLength(data[head:]); err != nil {
+				return nil, err
+			}
+			values[i], err = decodeValue(t, data[offset:])
+		} else {
+			values[i], err = decodeValue(t, data[head:])
+		}
+		if err != nil {
+			return nil, err
+		}
+		head += t.headSize()
+	}
+	return values, nil
+}
+
+func decodeValue(t *tupleABIType, data []byte) (interface{}, error) {
+	switch t.kind {
+	case arrayKind:
+		length := t.length
+		if length == 0 {
+			var err error
+			if length, err = decodeLength(data); err != nil {
+				return nil, err
+			}
+			data = data[32:]
+		}
+		if length > len(data) {
+			return nil, fmt.Errorf("length of %s is beyond the end of the return data", t)
+		}
+		types := make([]*tupleABIType, length)
+		for i := range types {
+			types[i] = t.elem
+		}
+		return decodeSequence(types, data)
+	case tupleKind:
+		return decodeSequence(t.components, data)
+	default:
+		return decodeElementary(t, data)
+	}
+}
+
+func decodeElementary(t *tupleABIType, data []byte) (interface{}, error) {
+	if len(data) < 32 {
+		return nil, fmt.Errorf("return data too short to decode %s", t)
+	}
+	word := data[:32]
+	switch t.base {
+	case "uint":
+		return new(big.Int).SetBytes(word).String(), nil
+	case "int":
+		return math.S256(new(big.Int).SetBytes(word)).String(), nil
+	case "bool":
+		return strconv.FormatBool(word[31] == 1), nil
+	case "address":
+		return strings.ToUpper(common.Bytes2Hex(word[12:])), nil
+	case "string", "bytes":
+		if t.size > 0 {
+			return string(bytes.Trim(word[:t.size], "\x00")), nil
+		}
+		length, err := decodeLength(data)
+		if err != nil {
+			return nil, err
+		}
+		if 32+length > len(data) {
+			return nil, fmt.Errorf("length of %s is beyond the end of the return data", t)
+		}
+		return string(data[32 : 32+length]), nil
+	default:
+		return nil, fmt.Errorf("Could not unpack value of type %s", t)
+	}
+}
+
+func decodeLength(data []byte) (int, error) {
+	if len(data) < 32 {
+		return 0, fmt.Errorf("return data too short to decode length")
+	}
+	n := new(big.Int).SetBytes(data[:32])
+	if !n.IsInt64() || n.Int64() > int64(len(data)) {
+		return 0, fmt.Errorf("length or offset %v is beyond the end of the return data", n)
+	}
+	return int(n.Int64()), nil
+}
+
+// Values containing tuples are given as JSON, anything else as the other unpacked values are
+func formatTupleOutput(t *tupleABIType, value interface{}) (string, error) {
+	if !t.containsTuple() {
+		if t.kind == arrayKind {
+			var elements []string
+			for _, element := range value.([]interface{}) {
+				s, err := formatTupleOutput(t.elem, element)
+				if err != nil {
+					return "", err
+				}
+				elements = append(elements, s)
+			}
+			return "[" + strings.Join(elements, ",") + "]", nil
+		}
+		return value.(string), nil
+	}
+	bs, err := json.Marshal(structuredOutput(t, value))
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func structuredOutput(t *tupleABIType, value interface{}) interface{} {
+	switch t.kind {
+	case arrayKind:
+		var elements []interface{}
+		for _, element := range value.([]interface{}) {
+			elements = append(elements, structuredOutput(t.elem, element))
+		}
+		return elements
+	case tupleKind:
+		components := value.([]interface{})
+		named := orderedFields{}
+		for i, name := range t.names {
+			if name == "" {
+				// cannot key by name so give the components in order
+				var list []interface{}
+				for j, c := range components {
+					list = append(list, structuredOutput(t.components[j], c))
+				}
+				return list
+			}
+			named.names = append(named.names, name)
+			named.values = append(named.values, structuredOutput(t.components[i], components[i]))
+		}
+		return named
+	default:
+		return value
+	}
+}
+
+// A JSON object that keeps its fields in the order of the tuple components
+type orderedFields struct {
+	names  []string
+	values []interface{}
+}
+
+func (fields orderedFields) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, name := range fields.names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(fields.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,210 @@
+package abi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Builds ABI encodings from their 32 byte words, hex words shorter than 64 characters being left padded
+// and words prefixed by "s:" holding right padded text
+func words(ws ...string) []byte {
+	var bs []byte
+	for _, w := range ws {
+		if strings.HasPrefix(w, "s:") {
+			word := make([]byte, 32)
+			copy(word, w[2:])
+			bs = append(bs, word...)
+			continue
+		}
+		word, err := hex.DecodeString(strings.Repeat("0", 64-len(w)) + w)
+		if err != nil {
+			panic(err)
+		}
+		bs = append(bs, word...)
+	}
+	return bs
+}
+
+func selector(signature string) []byte {
+	return crypto.Keccak256([]byte(signature))[:4]
+}
+
+const tupleABI = `[
+{"type":"function","name":"setPoint","inputs":[{"name":"p","type":"tuple","components":[{"name":"x","type":"uint256"},{"name":"y","type":"int256"},{"name":"ok","type":"bool"}]}],
+ "outputs":[{"name":"p","type":"tuple","components":[{"name":"x","type":"uint256"},{"name":"y","type":"int256"},{"name":"ok","type":"bool"}]}]},
+{"type":"function","name":"setRecord","inputs":[{"name":"r","type":"tuple","components":[{"name":"name","type":"string"},{"name":"ids","type":"uint256[]"}]}],
+ "outputs":[{"name":"r","type":"tuple","components":[{"name":"name","type":"string"},{"name":"ids","type":"uint256[]"}]}]},
+{"type":"function","name":"setEntries","inputs":[{"name":"es","type":"tuple[]","components":[{"name":"id","type":"uint256"},{"name":"label","type":"string"}]}],
+ "outputs":[{"name":"es","type":"tuple[]","components":[{"name":"id","type":"uint256"},{"name":"label","type":"string"}]}]},
+{"type":"function","name":"setNested","inputs":[{"name":"n","type":"tuple","components":[{"name":"kind","type":"uint8"},{"name":"inner","type":"tuple","components":[{"name":"owner","type":"address"},{"name":"tag","type":"bytes32"}]}]},{"name":"count","type":"uint256"}],
+ "outputs":[{"name":"n","type":"tuple","components":[{"name":"kind","type":"uint8"},{"name":"inner","type":"tuple","components":[{"name":"owner","type":"address"},{"name":"tag","type":"bytes32"}]}]},{"name":"count","type":"uint256"}]},
+{"type":"function","name":"pair","inputs":[],"outputs":[{"name":"","type":"tuple","components":[{"name":"","type":"uint256"},{"name":"","type":"bool"}]}]}
+]`
+
+func TestPackTuples(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		args     []string
+		expected []byte
+	}{
+		{
+			// static tuple
+			"setPoint",
+			[]string{`{"x": 1, "y": -1, "ok": true}`},
+			append(selector("setPoint((uint256,int256,bool))"), words(
+				"1",
+				strings.Repeat("f", 64),
+				"1",
+			)...),
+		},
+		{
+			// static tuple given as a list
+			"setPoint",
+			[]string{`[1,-1,true]`},
+			append(selector("setPoint((uint256,int256,bool))"), words(
+				"1",
+				strings.Repeat("f", 64),
+				"1",
+			)...),
+		},
+		{
+			// dynamic tuple
+			"setRecord",
+			[]string{`{"name": "marmots", "ids": [1, 2]}`},
+			append(selector("setRecord((string,uint256[]))"), words(
+				"20",
+				"40",
+				"80",
+				"7",
+				"s:marmots",
+				"2",
+				"1",
+				"2",
+			)...),
+		},
+		{
+			// array of dynamic tuples
+			"setEntries",
+			[]string{`[[1, "a"], {"id": 2, "label": "b"}]`},
+			append(selector("setEntries((uint256,string)[])"), words(
+				"20",
+				"2",
+				"40",
+				"c0",
+				"1",
+				"40",
+				"1",
+				"s:a",
+				"2",
+				"40",
+				"1",
+				"s:b",
+			)...),
+		},
+		{
+			// nested static tuple followed by another argument
+			"setNested",
+			[]string{`{"kind": 7, "inner": {"owner": "0x00000000000000000000000000000000000000ff", "tag": "marmot"}}`, "3"},
+			append(selector("setNested((uint8,(address,bytes32)),uint256)"), words(
+				"7",
+				"ff",
+				"s:marmot",
+				"3",
+			)...),
+		},
+	} {
+		packed, err := Packer(tupleABI, test.name, test.args...)
+		if err != nil {
+			t.Fatalf("could not pack %s(%v): %v", test.name, test.args, err)
+		}
+		if !bytes.Equal(packed, test.expected) {
+			t.Errorf("packing %s(%v) gave\n%x\nbut expected\n%x", test.name, test.args, packed, test.expected)
+		}
+	}
+}
+
+func TestPackTuplesErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{`{"x": 1, "y": -1}`},
+		{`{"x": 1, "y": -1, "ok": true, "z": 2}`},
+		{`[1, 2]`},
+		{`{"x": -1, "y": -1, "ok": true}`},
+		{`{"x": "marmot", "y": -1, "ok": true}`},
+	} {
+		if _, err := Packer(tupleABI, "setPoint", args...); err == nil {
+			t.Errorf("expected packing setPoint(%v) to fail", args)
+		}
+	}
+}
+
+func TestUnpackTuples(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		args     []string
+		expected []string
+		types    []string
+	}{
+		{
+			"setPoint",
+			[]string{`{"x": 1, "y": -1, "ok": true}`},
+			[]string{`{"x":"1","y":"-1","ok":"true"}`},
+			[]string{"(uint256,int256,bool)"},
+		},
+		{
+			"setRecord",
+			[]string{`{"name": "marmots", "ids": [1, 2]}`},
+			[]string{`{"name":"marmots","ids":["1","2"]}`},
+			[]string{"(string,uint256[])"},
+		},
+		{
+			"setEntries",
+			[]string{`[[1, "a"], [2, "b"]]`},
+			[]string{`[{"id":"1","label":"a"},{"id":"2","label":"b"}]`},
+			[]string{"(uint256,string)[]"},
+		},
+		{
+			"setNested",
+			[]string{`[7, ["0x00000000000000000000000000000000000000ff", "marmot"]]`, "3"},
+			[]string{`{"kind":"7","inner":{"owner":"00000000000000000000000000000000000000FF","tag":"marmot"}}`, "3"},
+			[]string{"(uint8,(address,bytes32))", "uint256"},
+		},
+	} {
+		// the inputs and outputs of each function are the same so we can decode what we encode
+		packed, err := Packer(tupleABI, test.name, test.args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vars, err := Unpacker(tupleABI, test.name, packed[4:])
+		if err != nil {
+			t.Fatalf("could not unpack %s: %v", test.name, err)
+		}
+		if len(vars) != len(test.expected) {
+			t.Fatalf("expected %v return values from %s but got %v", len(test.expected), test.name, len(vars))
+		}
+		for i, v := range vars {
+			if v.Value != test.expected[i] {
+				t.Errorf("unpacking %s gave %s but expected %s", test.name, v.Value, test.expected[i])
+			}
+			if v.Type != test.types[i] {
+				t.Errorf("unpacking %s gave type %s but expected %s", test.name, v.Type, test.types[i])
+			}
+		}
+	}
+
+	// components without names are returned in order
+	vars, err := Unpacker(tupleABI, "pair", words("2a", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 1 || vars[0].Name != "0" || vars[0].Value != `["42","true"]` {
+		t.Errorf("unexpected unpacking of unnamed tuple: %v", vars[0])
+	}
+
+	if _, err := Unpacker(tupleABI, "setRecord", words("20", "40")); err == nil {
+		t.Errorf("expected unpacking truncated return data to fail")
+	}
+}
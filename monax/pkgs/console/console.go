@@ -0,0 +1,332 @@
+package console
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/client/rpc"
+	"github.com/hyperledger/burrow/execution/evm/abi"
+	"github.com/hyperledger/burrow/keys"
+	"github.com/hyperledger/burrow/logging/loggers"
+	"github.com/monax/bosmarmot/monax/definitions"
+	monaxAbi "github.com/monax/bosmarmot/monax/pkgs/abi"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+// A deployed contract the console can call, named by the deploy job that created it
+type Contract struct {
+	Name    string
+	Address acm.Address
+	ABI     string
+	// Functions of the ABI by name
+	Functions map[string]*Function
+}
+
+// A function from a contract's JSON ABI
+type Function struct {
+	Name   string
+	Inputs []string
+	// Whether the function cannot change state, so calls to it are simulated rather than committed
+	View bool
+}
+
+type abiEntry struct {
+	Type            string `json:"type"`
+	Name            string `json:"name"`
+	Constant        bool   `json:"constant"`
+	StateMutability string `json:"stateMutability"`
+	Inputs          []struct {
+		Type string `json:"type"`
+	} `json:"inputs"`
+}
+
+// Console evaluates calls to the contracts of a deployment
+type Console struct {
+	do         *definitions.Do
+	nodeClient client.NodeClient
+	keyClient  keys.KeyClient
+	// Deployed contracts by job name
+	contracts map[string]*Contract
+	// Results of the deployment's jobs by job name, referenced as $name
+	variables map[string]string
+	// Events of every loaded contract for decoding the logs of committed calls
+	events *abi.EventRegistry
+}
+
+// Loads the results of a deployment from the jobs output file, taking each job whose result is the address of a
+// contract with an ABI in do.ABIPath to be a contract that can be called
+func NewConsole(do *definitions.Do, outputFile string, nodeClient client.NodeClient) (*Console, error) {
+	bs, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read jobs output: %v", err)
+	}
+	// Annotations of the run are not strings so are skipped
+	var output map[string]interface{}
+	if err := json.Unmarshal(bs, &output); err != nil {
+		return nil, fmt.Errorf("could not parse jobs output %s: %v", outputFile, err)
+	}
+	console := &Console{
+		do:         do,
+		nodeClient: nodeClient,
+		keyClient:  keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger()),
+		contracts:  make(map[string]*Contract),
+		variables:  make(map[string]string),
+		events:     abi.NewEventRegistry(),
+	}
+	for name, result := range output {
+		value, ok := result.(string)
+		if !ok {
+			continue
+		}
+		console.variables[name] = value
+		address, err := acm.AddressFromHexString(value)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(do.ABIPath, address.String())); err != nil {
+			continue
+		}
+		contract, err := loadContract(do.ABIPath, name, address)
+		if err != nil {
+			return nil, err
+		}
+		if err := console.events.AddABI([]byte(contract.ABI)); err != nil {
+			return nil, fmt.Errorf("could not read events of contract %s: %v", name, err)
+		}
+		console.contracts[name] = contract
+	}
+	return console, nil
+}
+
+func loadContract(abiPath, name string, address acm.Address) (*Contract, error) {
+	abiSpec, err := util.ReadAbi(abiPath, address.String())
+	if err != nil {
+		return nil, err
+	}
+	var entries []abiEntry
+	if err := json.Unmarshal([]byte(abiSpec), &entries); err != nil {
+		return nil, fmt.Errorf("could not parse ABI of contract %s: %v", name, err)
+	}
+	contract := &Contract{
+		Name:      name,
+		Address:   address,
+		ABI:       abiSpec,
+		Functions: make(map[string]*Function),
+	}
+	for _, entry := range entries {
+		if entry.Type != "function" {
+			continue
+		}
+		function := &Function{
+			Name: entry.Name,
+			View: entry.Constant || entry.StateMutability == "view" || entry.StateMutability == "pure",
+		}
+		for _, input := range entry.Inputs {
+			function.Inputs = append(function.Inputs, input.Type)
+		}
+		contract.Functions[entry.Name] = function
+	}
+	return contract, nil
+}
+
+// Names of the loaded contracts in order
+func (c *Console) ContractNames() []string {
+	names := make([]string, 0, len(c.contracts))
+	for name := range c.contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Evaluates a single expression, either a call such as MyContract.balanceOf($alice) or a $variable or contract name
+// to print, writing the outcome to out
+func (c *Console) Eval(expression string, out io.Writer) error {
+	expr, err := parseExpression(expression)
+	if err != nil {
+		return err
+	}
+	if expr.function == "" {
+		return c.print(expr.target, out)
+	}
+	contract, ok := c.contracts[expr.target]
+	if !ok {
+		return fmt.Errorf("no contract called %s was deployed, have %s", expr.target,
+			strings.Join(c.ContractNames(), ", "))
+	}
+	function, ok := contract.Functions[expr.function]
+	if !ok {
+		return fmt.Errorf("contract %s has no function %s", contract.Name, expr.function)
+	}
+	args := make([]string, len(expr.args))
+	for i, arg := range expr.args {
+		if arg.quoted {
+			args[i] = arg.text
+			continue
+		}
+		args[i], err = c.substitute(arg.text)
+		if err != nil {
+			return err
+		}
+	}
+	if len(args) != len(function.Inputs) {
+		return fmt.Errorf("%s.%s takes %d arguments (%s) but was given %d", contract.Name, function.Name,
+			len(function.Inputs), strings.Join(function.Inputs, ","), len(args))
+	}
+	data, err := monaxAbi.Packer(contract.ABI, function.Name, args...)
+	if err != nil {
+		return fmt.Errorf("could not encode call to %s.%s: %v", contract.Name, function.Name, err)
+	}
+	if function.View {
+		return c.simulate(contract, function, data, out)
+	}
+	return c.commit(contract, function, data, out)
+}
+
+func (c *Console) print(target string, out io.Writer) error {
+	if strings.HasPrefix(target, "$") {
+		value, err := c.substitute(target)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, value)
+		return nil
+	}
+	contract, ok := c.contracts[target]
+	if !ok {
+		return fmt.Errorf("no contract called %s was deployed", target)
+	}
+	fmt.Fprintf(out, "%s at %s\n", contract.Name, contract.Address)
+	names := make([]string, 0, len(contract.Functions))
+	for name := range contract.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		function := contract.Functions[name]
+		mutability := "transaction"
+		if function.View {
+			mutability = "view"
+		}
+		fmt.Fprintf(out, "  %s(%s) %s\n", name, strings.Join(function.Inputs, ","), mutability)
+	}
+	return nil
+}
+
+// Replaces the $name references in arg with the results of the deployment's jobs
+func (c *Console) substitute(arg string) (string, error) {
+	var err error
+	substituted := variableRegexp.ReplaceAllStringFunc(arg, func(reference string) string {
+		value, ok := c.variables[reference[1:]]
+		if !ok {
+			err = fmt.Errorf("no job called %s in the jobs output", reference[1:])
+		}
+		return value
+	})
+	return substituted, err
+}
+
+func (c *Console) caller() acm.Address {
+	address, err := acm.AddressFromHexString(c.do.DefaultAddr)
+	if err != nil {
+		return acm.ZeroAddress
+	}
+	return address
+}
+
+func (c *Console) simulate(contract *Contract, function *Function, data []byte, out io.Writer) error {
+	ret, gasUsed, err := c.nodeClient.QueryContract(c.caller(), contract.Address, data)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "simulated, gas used %d\n", gasUsed)
+	return printReturn(contract, function, ret, out)
+}
+
+func (c *Console) commit(contract *Contract, function *Function, data []byte, out io.Writer) error {
+	if c.do.DefaultAddr == "" {
+		return fmt.Errorf("%s.%s changes state so needs the address to call from given with --address",
+			contract.Name, function.Name)
+	}
+	tx, err := rpc.Call(c.nodeClient, c.keyClient, c.do.PublicKey, c.do.DefaultAddr, contract.Address.String(),
+		c.do.DefaultAmount, "", c.do.DefaultGas, c.do.DefaultFee, hex.EncodeToString(data))
+	if err != nil {
+		return err
+	}
+	_, chainID, _, err := c.nodeClient.ChainId()
+	if err != nil {
+		return err
+	}
+	res, err := rpc.SignAndBroadcast(chainID, c.nodeClient, c.keyClient, tx, true, true, true)
+	if err != nil {
+		if res != nil && res.RevertReason != "" {
+			return fmt.Errorf("%v: reverted with %s", err, res.RevertReason)
+		}
+		return err
+	}
+	fmt.Fprintf(out, "committed tx %X\n", res.Hash)
+	if err := printReturn(contract, function, res.Return, out); err != nil {
+		return err
+	}
+	return c.printEvents(res.Hash, out)
+}
+
+func printReturn(contract *Contract, function *Function, ret []byte, out io.Writer) error {
+	if len(ret) == 0 {
+		return nil
+	}
+	vars, err := monaxAbi.Unpacker(contract.ABI, function.Name, ret)
+	if err != nil {
+		return fmt.Errorf("could not decode return of %s.%s: %v", contract.Name, function.Name, err)
+	}
+	for _, v := range vars {
+		fmt.Fprintf(out, "  %s %s = %s\n", v.Type, v.Name, v.Value)
+	}
+	return nil
+}
+
+// Prints the logs of the committed transaction decoded against the events of the loaded contracts, which is only
+// possible when the node records transaction receipts
+func (c *Console) printEvents(txHash []byte, out io.Writer) error {
+	receipt, err := c.nodeClient.TxReceipt(txHash)
+	if err != nil {
+		fmt.Fprintf(out, "  events unavailable: %v\n", err)
+		return nil
+	}
+	if receipt == nil {
+		return nil
+	}
+	for _, eventDataLog := range receipt.Logs {
+		decoded, known, err := c.events.DecodeLog(eventDataLog.Topics, eventDataLog.Data)
+		if err != nil {
+			return fmt.Errorf("could not decode event emitted by %s: %v", eventDataLog.Address, err)
+		}
+		if !known {
+			fmt.Fprintf(out, "  unknown event from %s\n", eventDataLog.Address)
+			continue
+		}
+		fmt.Fprintf(out, "  event %s\n", formatEvent(c.events.Event(eventDataLog.Topics[0]), decoded))
+	}
+	return nil
+}
+
+func formatEvent(spec *abi.EventSpec, decoded *abi.DecodedLog) string {
+	fields := make([]string, len(spec.Inputs))
+	for i, input := range spec.Inputs {
+		name := input.Name
+		if name == "" {
+			name = fmt.Sprint(i)
+		}
+		fields[i] = fmt.Sprintf("%s=%v", name, decoded.Fields[name].Value)
+	}
+	return fmt.Sprintf("%s(%s)", spec.Name, strings.Join(fields, ", "))
+}
@@ -0,0 +1,141 @@
+package console
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+const testABI = `[
+  {"type":"function","name":"balanceOf","constant":true,"inputs":[{"name":"owner","type":"address"}],
+   "outputs":[{"name":"balance","type":"uint256"}]},
+  {"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},
+   {"name":"amount","type":"uint256"}],"outputs":[]},
+  {"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],
+   "outputs":[{"name":"","type":"uint256"}]},
+  {"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},
+   {"name":"to","type":"address","indexed":true},{"name":"amount","type":"uint256","indexed":false}]}
+]`
+
+type testQueryClient struct {
+	client.NodeClient
+	calls [][]byte
+}
+
+func (tqc *testQueryClient) QueryContract(callerAddress, calleeAddress acm.Address, data []byte) ([]byte, uint64,
+	error) {
+	tqc.calls = append(tqc.calls, data)
+	ret := make([]byte, 32)
+	ret[31] = 42
+	return ret, 21, nil
+}
+
+func newTestConsole(t *testing.T) (*Console, *testQueryClient, func()) {
+	dir, err := ioutil.TempDir("", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, alice := acm.Address{1}, acm.Address{2}
+	output := `{"token": "` + token.String() + `", "alice": "` + alice.String() + `", "failed_jobs": []}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "epm.output.json"), []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, token.String()), []byte(testABI), 0644); err != nil {
+		t.Fatal(err)
+	}
+	nodeClient := new(testQueryClient)
+	c, err := NewConsole(&definitions.Do{ABIPath: dir}, filepath.Join(dir, "epm.output.json"), nodeClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, nodeClient, func() { os.RemoveAll(dir) }
+}
+
+func TestConsoleEval(t *testing.T) {
+	c, nodeClient, cleanup := newTestConsole(t)
+	defer cleanup()
+
+	if names := c.ContractNames(); len(names) != 1 || names[0] != "token" {
+		t.Fatalf("expected only the job whose result has an ABI to be a contract but got %v", names)
+	}
+	out := new(bytes.Buffer)
+	if err := c.Eval("token.balanceOf($alice)", out); err != nil {
+		t.Fatal(err)
+	}
+	if len(nodeClient.calls) != 1 || !strings.HasSuffix(hex.EncodeToString(nodeClient.calls[0]),
+		hex.EncodeToString(acm.Address{2}.Bytes())) {
+		t.Errorf("expected a simulated call passing the address of $alice but got %X", nodeClient.calls)
+	}
+	if !strings.Contains(out.String(), "simulated") || !strings.Contains(out.String(), "balance = 42") {
+		t.Errorf("expected decoded return of simulated call but got %q", out.String())
+	}
+
+	// Functions that change state are committed which needs an address to call from
+	err := c.Eval("token.transfer($alice, 10)", out)
+	if err == nil || !strings.Contains(err.Error(), "--address") {
+		t.Errorf("expected committing without an address to fail but got %v", err)
+	}
+	if err := c.Eval("token.balanceOf($bob)", out); err == nil {
+		t.Errorf("expected reference to unknown job to fail")
+	}
+	if err := c.Eval("token.balanceOf()", out); err == nil {
+		t.Errorf("expected call with the wrong number of arguments to fail")
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	expr, err := parseExpression(` token.set("a, b", [1,2], $alice) `)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr.target != "token" || expr.function != "set" || len(expr.args) != 3 {
+		t.Fatalf("unexpected parse %+v", expr)
+	}
+	if !expr.args[0].quoted || expr.args[0].text != "a, b" || expr.args[1].text != "[1,2]" ||
+		expr.args[2].text != "$alice" {
+		t.Errorf("unexpected arguments %+v", expr.args)
+	}
+
+	expr, err = parseExpression("$alice")
+	if err != nil || expr.function != "" || expr.target != "$alice" {
+		t.Errorf("expected a variable to print but got %+v, %v", expr, err)
+	}
+	for _, bad := range []string{"token.set(1", "token.set([1,2)", `token.set("a)`, "set(1)"} {
+		if _, err := parseExpression(bad); err == nil {
+			t.Errorf("expected %s not to parse", bad)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	c, _, cleanup := newTestConsole(t)
+	defer cleanup()
+
+	for _, completion := range []struct {
+		line     string
+		expected string
+	}{
+		{"to", "token."},
+		{"token.b", "token.balanceOf("},
+		{"token.t", "token.t"},
+		{"token.to", "token.totalSupply("},
+		{"token.balanceOf($al", "token.balanceOf($alice"},
+	} {
+		line, pos, ok := c.Complete(completion.line, len(completion.line))
+		if !ok {
+			line, pos = completion.line, len(completion.line)
+		}
+		if line != completion.expected || pos != len(completion.expected) {
+			t.Errorf("expected %s to complete to %s but got %s at %d", completion.line, completion.expected,
+				line, pos)
+		}
+	}
+}
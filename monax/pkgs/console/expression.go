@@ -0,0 +1,100 @@
+package console
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	variableRegexp   = regexp.MustCompile(`\$[A-Za-z0-9_.\-]+`)
+	identifierRegexp = regexp.MustCompile(`^\$?[A-Za-z_][A-Za-z0-9_.\-]*$`)
+)
+
+// A parsed console expression, either target.function(args) or just a target to print
+type expression struct {
+	target   string
+	function string
+	args     []argument
+}
+
+type argument struct {
+	text string
+	// Quoted arguments are passed as they are without substituting $name references
+	quoted bool
+}
+
+func parseExpression(input string) (*expression, error) {
+	input = strings.TrimSpace(input)
+	open := strings.Index(input, "(")
+	if open < 0 {
+		if !identifierRegexp.MatchString(input) {
+			return nil, fmt.Errorf("expected a call such as Contract.function(arg, ...) or a name but got '%s'",
+				input)
+		}
+		return &expression{target: input}, nil
+	}
+	if !strings.HasSuffix(input, ")") {
+		return nil, fmt.Errorf("call '%s' is missing its closing parenthesis", input)
+	}
+	callee := strings.TrimSpace(input[:open])
+	dot := strings.LastIndex(callee, ".")
+	if dot < 0 || !identifierRegexp.MatchString(callee) {
+		return nil, fmt.Errorf("expected a call such as Contract.function(arg, ...) but got '%s'", input)
+	}
+	args, err := splitArguments(input[open+1 : len(input)-1])
+	if err != nil {
+		return nil, err
+	}
+	return &expression{
+		target:   callee[:dot],
+		function: callee[dot+1:],
+		args:     args,
+	}, nil
+}
+
+// Splits the arguments of a call on the commas that are not within brackets or quotes
+func splitArguments(input string) ([]argument, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+	var args []argument
+	var quote rune
+	depth := 0
+	start := 0
+	for i, r := range input {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '[' || r == '(':
+			depth++
+		case r == ']' || r == ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced brackets in arguments '%s'", input)
+			}
+		case r == ',' && depth == 0:
+			args = append(args, newArgument(input[start:i]))
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated string in arguments '%s'", input)
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced brackets in arguments '%s'", input)
+	}
+	return append(args, newArgument(input[start:])), nil
+}
+
+func newArgument(text string) argument {
+	text = strings.TrimSpace(text)
+	if len(text) >= 2 && (text[0] == '"' || text[0] == '\'') && text[len(text)-1] == text[0] {
+		return argument{text: text[1 : len(text)-1], quoted: true}
+	}
+	return argument{text: text}
+}
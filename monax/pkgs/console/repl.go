@@ -0,0 +1,111 @@
+package console
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const Prompt = "bos> "
+
+const helpText = `Contract.function(arg, ...)  call a function, simulated if the ABI marks it view or pure and committed otherwise
+Contract                     list the functions of a contract
+$job                         print the result of a job
+contracts                    list the deployed contracts
+help                         print this message
+exit                         leave the console
+`
+
+// Evaluates an expression on each line of r, such as when input is piped in, stopping at the first that fails
+func (c *Console) Script(r io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "exit" || line == "quit" {
+			return nil
+		}
+		if err := c.Eval(line, out); err != nil {
+			return fmt.Errorf("%s: %v", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Runs a line typed into the console, returning false when the console should exit
+func (c *Console) Command(line string, out io.Writer) bool {
+	switch strings.TrimSpace(line) {
+	case "":
+	case "exit", "quit":
+		return false
+	case "help":
+		fmt.Fprint(out, helpText)
+	case "contracts":
+		for _, name := range c.ContractNames() {
+			fmt.Fprintf(out, "%s at %s\n", name, c.contracts[name].Address)
+		}
+	default:
+		if err := c.Eval(line, out); err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+	}
+	return true
+}
+
+// Completes the word before pos to the longest prefix shared by the contract names, the functions of the contract
+// before a dot, or the $job names it could be
+func (c *Console) Complete(line string, pos int) (string, int, bool) {
+	start := strings.LastIndexAny(line[:pos], " (,[") + 1
+	word := line[start:pos]
+	var candidates []string
+	var suffix string
+	if dot := strings.LastIndex(word, "."); dot >= 0 && !strings.HasPrefix(word, "$") {
+		contract, ok := c.contracts[word[:dot]]
+		if !ok {
+			return "", 0, false
+		}
+		for name := range contract.Functions {
+			candidates = append(candidates, word[:dot+1]+name)
+		}
+		suffix = "("
+	} else if strings.HasPrefix(word, "$") {
+		for name := range c.variables {
+			candidates = append(candidates, "$"+name)
+		}
+	} else {
+		candidates = c.ContractNames()
+		suffix = "."
+	}
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	sort.Strings(matches)
+	completion := commonPrefix(matches)
+	if len(matches) == 1 {
+		completion += suffix
+	}
+	if completion == word {
+		return "", 0, false
+	}
+	return line[:start] + completion + line[pos:], start + len(completion), true
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
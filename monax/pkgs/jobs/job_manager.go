@@ -3,6 +3,7 @@ package jobs
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/monax/bosmarmot/monax/definitions"
 	"github.com/monax/bosmarmot/monax/log"
@@ -12,17 +13,63 @@ import (
 func RunJobs(do *definitions.Do) error {
 	var err error
 	var dup bool = false
+	// Job name -> error for failed jobs when continuing on failure
+	failures := make(map[string]string)
+	assertionFailures := 0
+	// Every call the run makes to the chain is cancelled once it passes its deadline
+	ctx, cancel := runContext(do.Deadline)
+	defer cancel()
+	parent := do.Context
+	do.Context = ctx
+	defer func() {
+		do.Context = parent
+	}()
+	// Report the calls made to the chain by the run, which share the connections of one client
+	startStats := util.NodeClient(do).CallStats()
+	defer func() {
+		stats := util.NodeClient(do).CallStats().Since(startStats)
+		log.WithFields(log.Fields{
+			"calls":      stats.Calls,
+			"retries":    stats.Retries,
+			"errors":     stats.Errors,
+			"total time": stats.TotalTime,
+		}).Warn("RPC Calls")
+	}()
 	// ADD DefaultAddr and DefaultSet to jobs array....
 	// These work in reverse order and the addendums to the
 	// the ordering from the loading process is lifo
-	if len(do.DefaultSets) >= 1 {
-		defaultSetJobs(do)
+	if err = resolveVariables(do); err != nil {
+		return err
 	}
 
 	if do.DefaultAddr != "" {
 		defaultAddrJob(do)
 	}
 
+	do.Package.Jobs, err = OrderJobs(do.Package.Jobs)
+	if err != nil {
+		return err
+	}
+	if err = verifyChain(do, util.NodeClient(do)); err != nil {
+		return err
+	}
+	// Names of failed jobs and the sub-jobs of failed parallel groups
+	failed := make(map[string]bool)
+
+	checkpoint, err := startCheckpoint(do)
+	if err != nil {
+		return err
+	}
+
+	if do.DryRun {
+		log.Warn("Dry run: transactions will be simulated against current state and not broadcast")
+		do.DryRunSimulation = newSimulation()
+	}
+
+	// What happened to each job for the reports written at the end of the run
+	var reports []*JobReport
+	runStart := time.Now()
+
 	for index, job := range do.Package.Jobs {
 		for _, checkForDup := range do.Package.Jobs[0:index] {
 			if checkForDup.JobName == job.JobName {
@@ -36,89 +83,202 @@ func RunJobs(do *definitions.Do) error {
 			overwriteWarning := "You are about to overwrite a previous job name, continue?"
 
 			if util.QueryYesOrNo(overwriteWarning, []int{}...) == util.No {
+				reports = append(reports, skippedJobReport(job, "not overwriting a previous job of the same name"))
 				continue
 			}
 		}
 
-		switch {
-		// Util jobs
-		case job.Account != nil:
-			announce(job.JobName, "Account")
-			job.JobResult, err = SetAccountJob(job.Account, do)
-		case job.Set != nil:
-			announce(job.JobName, "Set")
-			job.JobResult, err = SetValJob(job.Set, do)
-
-		// Transaction jobs
-		case job.Send != nil:
-			announce(job.JobName, "Sent")
-			job.JobResult, err = SendJob(job.Send, do)
-		case job.RegisterName != nil:
-			announce(job.JobName, "RegisterName")
-			job.JobResult, err = RegisterNameJob(job.RegisterName, do)
-		case job.Permission != nil:
-			announce(job.JobName, "Permission")
-			job.JobResult, err = PermissionJob(job.Permission, do)
-		case job.Bond != nil:
-			announce(job.JobName, "Bond")
-			job.JobResult, err = BondJob(job.Bond, do)
-		case job.Unbond != nil:
-			announce(job.JobName, "Unbond")
-			job.JobResult, err = UnbondJob(job.Unbond, do)
-		case job.Rebond != nil:
-			announce(job.JobName, "Rebond")
-			job.JobResult, err = RebondJob(job.Rebond, do)
-
-		// Contracts jobs
-		case job.Deploy != nil:
-			announce(job.JobName, "Deploy")
-			job.JobResult, err = DeployJob(job.Deploy, do)
-		case job.Call != nil:
-			announce(job.JobName, "Call")
-			job.JobResult, job.JobVars, err = CallJob(job.Call, do)
-			if len(job.JobVars) != 0 {
-				for _, theJob := range job.JobVars {
-					log.WithField("=>", fmt.Sprintf("%s,%s", theJob.Name, theJob.Value)).Info("Job Vars")
-				}
+		jobStart := time.Now()
+		resumed := false
+		if dependency := failedDependency(job, failed); dependency != "" {
+			err = fmt.Errorf("job %s was not run since job %s which it depends on failed", job.JobName, dependency)
+		} else if resumed, err = checkpoint.resume(job, do); err == nil && !resumed {
+			err = runJobWithRetries(job, do)
+			if err != nil && ctx.Err() != nil {
+				err = ErrJobTimeout{JobName: job.JobName, Timeout: do.Deadline, Deadline: true}
 			}
-		// State jobs
-		case job.RestoreState != nil:
-			announce(job.JobName, "RestoreState")
-			job.JobResult, err = RestoreStateJob(job.RestoreState, do)
-		case job.DumpState != nil:
-			announce(job.JobName, "DumpState")
-			job.JobResult, err = DumpStateJob(job.DumpState, do)
-
-		// Test jobs
-		case job.QueryAccount != nil:
-			announce(job.JobName, "QueryAccount")
-			job.JobResult, err = QueryAccountJob(job.QueryAccount, do)
-		case job.QueryContract != nil:
-			announce(job.JobName, "QueryContract")
-			job.JobResult, job.JobVars, err = QueryContractJob(job.QueryContract, do)
-			if len(job.JobVars) != 0 {
-				for _, theJob := range job.JobVars {
-					log.WithField("=>", fmt.Sprintf("%s,%s", theJob.Name, theJob.Value)).Info("Job Vars")
+		}
+		if resumed {
+			reports = append(reports, skippedJobReport(job, "completed by the run being resumed"))
+			continue
+		}
+		reports = append(reports, newJobReports(job, time.Since(jobStart), err)...)
+		// The job has already run so losing its checkpoint only means it would be run again when resuming
+		if checkpointErr := checkpoint.update(job, err); checkpointErr != nil {
+			log.WithField("=>", checkpointErr).Warn("Could Not Update Checkpoint")
+		}
+		if err != nil {
+			// Nothing more can be run once the run has passed its deadline whatever the failure policy
+			if !do.ContinueOnFailure || ctx.Err() != nil {
+				reason := fmt.Sprintf("not run since job %s failed", job.JobName)
+				if ctx.Err() != nil {
+					reason = fmt.Sprintf("not run since the run passed its deadline of %v", do.Deadline)
+				}
+				for _, remaining := range do.Package.Jobs[index+1:] {
+					reports = append(reports, skippedJobReport(remaining, reason))
 				}
+				writeReports(do, reports, time.Since(runStart))
+				return err
+			}
+			for _, failedJob := range withSubJobs(job) {
+				failed[failedJob.JobName] = true
+			}
+			log.WithFields(log.Fields{
+				"job":   job.JobName,
+				"error": err,
+			}).Error("Job Failed")
+			failures[job.JobName] = err.Error()
+			if _, ok := err.(ErrAssertionFailed); ok {
+				assertionFailures++
 			}
-		case job.QueryName != nil:
-			announce(job.JobName, "QueryName")
-			job.JobResult, err = QueryNameJob(job.QueryName, do)
-		case job.QueryVals != nil:
-			announce(job.JobName, "QueryVals")
-			job.JobResult, err = QueryValsJob(job.QueryVals, do)
-		case job.Assert != nil:
-			announce(job.JobName, "Assert")
-			job.JobResult, err = AssertJob(job.Assert, do)
 		}
+	}
 
-		if err != nil {
+	postProcess(do, failures)
+	writeReports(do, reports, time.Since(runStart))
+	if len(failures) > 0 {
+		err = fmt.Errorf("%d of %d jobs failed, see %s for details", len(failures), len(do.Package.Jobs),
+			do.DefaultOutput)
+		if assertionFailures == len(failures) {
+			return ErrAssertionFailed{err.Error()}
+		}
+		return err
+	}
+	return nil
+}
+
+// Returns the name of a job which the job, or one of its sub-jobs, depends on that has failed
+func failedDependency(job *definitions.Job, failed map[string]bool) string {
+	for _, dependent := range withSubJobs(job) {
+		for _, name := range dependent.DependsOn {
+			if failed[name] {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func runJob(job *definitions.Job, do *definitions.Do) error {
+	var err error
+	if do.DryRun && job.Parallel == nil {
+		sim := simulationOf(do)
+		err = sim.checkDependencies(job)
+		if unverifiable, ok := err.(ErrUnverifiable); ok {
+			sim.markUnverifiable(job, unverifiable)
+			return nil
+		} else if err != nil {
 			return err
 		}
 	}
 
-	postProcess(do)
-	return nil
+	recordEnteredAmount(job)
+	switch {
+	// Util jobs
+	case job.Account != nil:
+		announce(job.JobName, "Account")
+		job.JobResult, err = SetAccountJob(job.Account, do)
+	case job.Set != nil:
+		announce(job.JobName, "Set")
+		job.JobResult, err = SetValJob(job.Set, do)
+	case job.Parallel != nil:
+		announce(job.JobName, "Parallel")
+		job.JobResult, err = ParallelJob(job.Parallel, do)
+
+	// Transaction jobs
+	case job.Send != nil:
+		announce(job.JobName, "Sent")
+		job.JobResult, err = SendJob(job.Send, do)
+	case job.FundAccounts != nil:
+		announce(job.JobName, "FundAccounts")
+		job.JobResult, job.JobVars, err = FundAccountsJob(job.FundAccounts, job.JobName, do)
+	case job.RegisterName != nil:
+		announce(job.JobName, "RegisterName")
+		job.JobResult, err = RegisterNameJob(job.RegisterName, do)
+	case job.RegisterNameLease != nil:
+		announce(job.JobName, "RegisterNameLease")
+		job.JobResult, err = RegisterNameLeaseJob(job.RegisterNameLease, do)
+	case job.Permission != nil:
+		announce(job.JobName, "Permission")
+		job.JobResult, err = PermissionJob(job.Permission, do)
+	case job.Perms != nil:
+		announce(job.JobName, "Perms")
+		job.JobResult, err = PermissionJob(job.Perms, do)
+	case job.Bond != nil:
+		announce(job.JobName, "Bond")
+		job.JobResult, err = BondJob(job.Bond, do)
+	case job.Unbond != nil:
+		announce(job.JobName, "Unbond")
+		job.JobResult, err = UnbondJob(job.Unbond, do)
+	case job.Rebond != nil:
+		announce(job.JobName, "Rebond")
+		job.JobResult, err = RebondJob(job.Rebond, do)
+
+	// Contracts jobs
+	case job.Deploy != nil:
+		announce(job.JobName, "Deploy")
+		job.JobResult, err = DeployJob(job.Deploy, do)
+	case job.Call != nil:
+		announce(job.JobName, "Call")
+		job.JobResult, job.JobVars, err = CallJob(job.Call, do)
+		if len(job.JobVars) != 0 {
+			for _, theJob := range job.JobVars {
+				log.WithField("=>", fmt.Sprintf("%s,%s", theJob.Name, theJob.Value)).Info("Job Vars")
+			}
+		}
+	case job.WaitEvent != nil:
+		announce(job.JobName, "WaitEvent")
+		job.JobResult, job.JobVars, err = WaitEventJob(job.WaitEvent, do)
+		if len(job.JobVars) != 0 {
+			for _, theJob := range job.JobVars {
+				log.WithField("=>", fmt.Sprintf("%s,%s", theJob.Name, theJob.Value)).Info("Job Vars")
+			}
+		}
+	// State jobs
+	case job.RestoreState != nil:
+		announce(job.JobName, "RestoreState")
+		job.JobResult, err = RestoreStateJob(job.RestoreState, do)
+	case job.DumpState != nil:
+		announce(job.JobName, "DumpState")
+		job.JobResult, err = DumpStateJob(job.DumpState, do)
+
+	// Test jobs
+	case job.QueryAccount != nil:
+		announce(job.JobName, "QueryAccount")
+		job.JobResult, err = QueryAccountJob(job.QueryAccount, do)
+	case job.QueryContract != nil:
+		announce(job.JobName, "QueryContract")
+		job.JobResult, job.JobVars, err = QueryContractJob(job.QueryContract, do)
+		if len(job.JobVars) != 0 {
+			for _, theJob := range job.JobVars {
+				log.WithField("=>", fmt.Sprintf("%s,%s", theJob.Name, theJob.Value)).Info("Job Vars")
+			}
+		}
+	case job.QueryName != nil:
+		announce(job.JobName, "QueryName")
+		job.JobResult, job.JobVars, err = QueryNameJob(job.QueryName, do)
+	case job.QueryPerms != nil:
+		announce(job.JobName, "QueryPerms")
+		job.JobResult, job.JobVars, err = QueryPermsJob(job.QueryPerms, do)
+	case job.QueryVals != nil:
+		announce(job.JobName, "QueryVals")
+		job.JobResult, err = QueryValsJob(job.QueryVals, do)
+	case job.Assert != nil:
+		announce(job.JobName, "Assert")
+		job.JobResult, err = AssertJob(job.Assert, do)
+	case job.TestContract != nil:
+		announce(job.JobName, "TestContract")
+		job.JobResult, job.JobTests, err = TestContractJob(job.TestContract, do)
+	}
+
+	if unverifiable, ok := err.(ErrUnverifiable); ok && do.DryRun {
+		simulationOf(do).markUnverifiable(job, unverifiable)
+		return nil
+	}
+	if err == nil {
+		recordResolvedAmount(job)
+	}
+	return err
 }
 
 func announce(job, typ string) {
@@ -140,27 +300,7 @@ func defaultAddrJob(do *definitions.Do) {
 	do.Package.Jobs = append([]*definitions.Job{newJob}, oldJobs...)
 }
 
-func defaultSetJobs(do *definitions.Do) {
-	oldJobs := do.Package.Jobs
-
-	newJobs := []*definitions.Job{}
-
-	for _, setr := range do.DefaultSets {
-		blowdUp := strings.Split(setr, "=")
-		if blowdUp[0] != "" {
-			newJobs = append(newJobs, &definitions.Job{
-				JobName: blowdUp[0],
-				Set: &definitions.SetJob{
-					Value: blowdUp[1],
-				},
-			})
-		}
-	}
-
-	do.Package.Jobs = append(newJobs, oldJobs...)
-}
-
-func postProcess(do *definitions.Do) error {
+func postProcess(do *definitions.Do, failures map[string]string) error {
 	// check do.YAMLPath and do.DefaultOutput
 	// get the epm.yaml
 	var yaml string
@@ -177,9 +317,45 @@ func postProcess(do *definitions.Do) error {
 	}
 
 	log.Warn(fmt.Sprintf("Writing [%s] to current directory", do.DefaultOutput))
-	results := make(map[string]string)
-	for _, job := range do.Package.Jobs {
-		results[job.JobName] = job.JobResult
+	results := make(map[string]interface{})
+	attempts := make(map[string]int)
+	for _, job := range do.Package.AllJobs() {
+		results[job.JobName] = jobResultOutput(job)
+		if job.JobAttempts > 1 {
+			attempts[job.JobName] = job.JobAttempts
+		}
+	}
+
+	annotations := make(map[string]interface{})
+	if len(attempts) > 0 {
+		annotations["attempts"] = attempts
+	}
+	if len(failures) > 0 {
+		annotations["failures"] = failures
+	}
+	if amounts := jobAmounts(do.Package.AllJobs()); len(amounts) > 0 {
+		annotations["amounts"] = amounts
+	}
+	if tests := contractTests(do.Package.AllJobs()); len(tests) > 0 {
+		annotations["tests"] = tests
+	}
+	// The order jobs were run in only differs from the jobs file when they declare dependencies
+	if hasDependencies(do.Package.Jobs) {
+		annotations["order"] = executionOrder(do.Package.Jobs)
+	}
+	if do.DryRun {
+		unverifiable := simulationOf(do).unverifiableJobs()
+		if len(unverifiable) > 0 {
+			log.WithField("=>", len(unverifiable)).Warn("Jobs that could not be verified by the dry run")
+			for name, reason := range unverifiable {
+				log.WithField(name, reason).Warn()
+			}
+		}
+		annotations["simulated"] = true
+		annotations["unverifiable"] = unverifiable
+	}
+	if len(annotations) > 0 {
+		return WriteAnnotatedJobResultJSON(results, annotations, do.DefaultOutput)
 	}
 	return WriteJobResultJSON(results, do.DefaultOutput)
 
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+// Resolves the variables of an amount field then evaluates the amount with the denominations of the chain, returning
+// the integer it comes to so that no transaction is formulated from an amount that is fractional, negative or
+// overflows. An empty amount is left empty for a default to apply.
+func resolveAmount(amount string, do *definitions.Do) (string, error) {
+	amount, err := util.PreProcess(amount, do)
+	if err != nil {
+		return "", err
+	}
+	if amount == "" {
+		return "", nil
+	}
+	var denominations map[string]string
+	if do.Package != nil && do.Package.Chain != nil {
+		denominations = do.Package.Chain.Denominations
+	}
+	value, err := util.ParseAmount(amount, denominations)
+	if err != nil {
+		return "", fmt.Errorf("invalid amount %s: %v", amount, err)
+	}
+	return strconv.FormatUint(value, 10), nil
+}
+
+// Returns the amount field of the job, or nil if it is not a kind of job that sends an amount
+func jobAmount(job *definitions.Job) *string {
+	switch {
+	case job.Send != nil:
+		return &job.Send.Amount
+	case job.FundAccounts != nil:
+		return &job.FundAccounts.Amount
+	case job.RegisterName != nil:
+		return &job.RegisterName.Amount
+	case job.RegisterNameLease != nil:
+		return &job.RegisterNameLease.Amount
+	case job.Bond != nil:
+		return &job.Bond.Amount
+	case job.Deploy != nil:
+		return &job.Deploy.Amount
+	case job.Call != nil:
+		return &job.Call.Amount
+	}
+	return nil
+}
+
+// Records the amount of the job as it was entered, before running the job resolves it in place. An amount already
+// recorded is kept since a retried job has been resolved already.
+func recordEnteredAmount(job *definitions.Job) {
+	if job.JobAmount != nil {
+		return
+	}
+	if amount := jobAmount(job); amount != nil && *amount != "" {
+		job.JobAmount = &definitions.Amount{Entered: *amount}
+	}
+}
+
+// Records the amount the job resolved its entered amount to
+func recordResolvedAmount(job *definitions.Job) {
+	if job.JobAmount != nil {
+		job.JobAmount.Resolved = *jobAmount(job)
+	}
+}
+
+// The entered and resolved amounts of the jobs that were given one, by job name
+func jobAmounts(jobs []*definitions.Job) map[string]*definitions.Amount {
+	amounts := make(map[string]*definitions.Amount)
+	for _, job := range jobs {
+		if job.JobAmount != nil && job.JobAmount.Resolved != "" {
+			amounts[job.JobName] = job.JobAmount
+		}
+	}
+	return amounts
+}
@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+func TestResolveAmount(t *testing.T) {
+	do := &definitions.Do{Package: definitions.BlankPackage()}
+	do.Package.Chain = &definitions.ChainIdentity{Denominations: map[string]string{"token": "1e6"}}
+	do.SourcedVariables = map[string]string{"env.BOS_TEST_AMOUNT": "3"}
+
+	amount, err := resolveAmount("$env.BOS_TEST_AMOUNT token + 1k", do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount != "3001000" {
+		t.Errorf("expected the amount to resolve to 3001000 but got %s", amount)
+	}
+	if amount, err := resolveAmount("", do); err != nil || amount != "" {
+		t.Errorf("an empty amount should be left for the default but got %q, %v", amount, err)
+	}
+	if _, err := resolveAmount("1 token - 2 token", do); err == nil || !strings.Contains(err.Error(), "negative") {
+		t.Errorf("expected a negative amount to be rejected but got %v", err)
+	}
+}
+
+func TestJobAmountRecorded(t *testing.T) {
+	do := &definitions.Do{Package: definitions.BlankPackage()}
+	job := &definitions.Job{JobName: "pay", Send: &definitions.Send{Amount: "1E * 20"}}
+	do.Package.Jobs = []*definitions.Job{job}
+
+	// The amount is rejected before any transaction is formulated so no chain is needed
+	if err := runJob(job, do); err == nil || !strings.Contains(err.Error(), "overflows") {
+		t.Fatalf("expected the amount to overflow but got %v", err)
+	}
+	if len(jobAmounts(do.Package.Jobs)) != 0 {
+		t.Errorf("a job that failed should have no resolved amount to record")
+	}
+
+	job.Send.Amount = "2.5k"
+	job.JobAmount = nil
+	recordEnteredAmount(job)
+	job.Send.Amount, _ = resolveAmount(job.Send.Amount, do)
+	recordResolvedAmount(job)
+	// a retry sees the amount already resolved but keeps what was entered
+	recordEnteredAmount(job)
+
+	amounts := jobAmounts(do.Package.Jobs)
+	if amounts["pay"] == nil || *amounts["pay"] != (definitions.Amount{Entered: "2.5k", Resolved: "2500"}) {
+		t.Errorf("expected the entered and resolved amounts of job pay to be recorded but got %v", amounts["pay"])
+	}
+}
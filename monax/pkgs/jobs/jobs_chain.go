@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/burrow/client"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+// Checks the node is running the chain the jobs file is meant for, if it names one, before any job is run. The chain
+// is then pinned for the rest of the run.
+func verifyChain(do *definitions.Do, nodeClient client.NodeClient) error {
+	if do.Package.Chain == nil {
+		return nil
+	}
+	expected, err := resolveChain(do.Package.Chain, do)
+	if err != nil {
+		return err
+	}
+	connected, err := connectedChain(nodeClient)
+	if err != nil {
+		return err
+	}
+	if mismatches := chainMismatches(expected, connected); len(mismatches) > 0 {
+		err = fmt.Errorf("the node at %s is running chain %s but the jobs file is meant for a chain with %s; "+
+			"pass --yes-i-know to run the jobs against it anyway", do.ChainURL, connected,
+			strings.Join(mismatches, " and "))
+		if !do.IgnoreChainMismatch {
+			return err
+		}
+		log.WithField("=>", err).Warn("Ignoring Chain Mismatch")
+	}
+	do.ConnectedChain = connected
+	return nil
+}
+
+// Called with the chain the node reports before each broadcast so that a node that starts answering for a different
+// chain part way through a run, such as when a proxy switches backends after reconnecting, is never broadcast to.
+// The first chain seen is pinned if verifyChain did not pin one.
+func checkConnectedChain(do *definitions.Do, chain *definitions.ChainIdentity) error {
+	if do.ConnectedChain == nil {
+		do.ConnectedChain = chain
+		return nil
+	}
+	if mismatches := chainMismatches(do.ConnectedChain, chain); len(mismatches) > 0 {
+		return fmt.Errorf("refusing to broadcast since the node at %s is now running chain %s rather than "+
+			"chain %s that the run started on", do.ChainURL, chain, do.ConnectedChain)
+	}
+	return nil
+}
+
+func connectedChain(nodeClient client.NodeClient) (*definitions.ChainIdentity, error) {
+	chainName, chainID, genesisHash, err := nodeClient.ChainId()
+	if err != nil {
+		return nil, err
+	}
+	return &definitions.ChainIdentity{
+		Name:        chainName,
+		ID:          chainID,
+		GenesisHash: fmt.Sprintf("%X", genesisHash),
+	}, nil
+}
+
+func resolveChain(chain *definitions.ChainIdentity, do *definitions.Do) (*definitions.ChainIdentity, error) {
+	resolved := new(definitions.ChainIdentity)
+	var err error
+	if resolved.Name, err = util.PreProcess(chain.Name, do); err != nil {
+		return nil, err
+	}
+	if resolved.ID, err = util.PreProcess(chain.ID, do); err != nil {
+		return nil, err
+	}
+	if resolved.GenesisHash, err = util.PreProcess(chain.GenesisHash, do); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// Describes each field given in expected that actual differs in, genesis hashes are compared ignoring case
+func chainMismatches(expected, actual *definitions.ChainIdentity) []string {
+	var mismatches []string
+	if expected.Name != "" && expected.Name != actual.Name {
+		mismatches = append(mismatches, fmt.Sprintf("name %s", expected.Name))
+	}
+	if expected.ID != "" && expected.ID != actual.ID {
+		mismatches = append(mismatches, fmt.Sprintf("ID %s", expected.ID))
+	}
+	if expected.GenesisHash != "" && !strings.EqualFold(expected.GenesisHash, actual.GenesisHash) {
+		mismatches = append(mismatches, fmt.Sprintf("genesis hash %s", expected.GenesisHash))
+	}
+	return mismatches
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/burrow/client"
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+type testChainClient struct {
+	client.NodeClient
+	chainName   string
+	chainID     string
+	genesisHash []byte
+}
+
+func (tcc *testChainClient) ChainId() (string, string, []byte, error) {
+	return tcc.chainName, tcc.chainID, tcc.genesisHash, nil
+}
+
+func TestVerifyChain(t *testing.T) {
+	os.Setenv("BOS_TEST_CHAIN_ID", "staging-1")
+	defer os.Unsetenv("BOS_TEST_CHAIN_ID")
+	nodeClient := &testChainClient{chainName: "staging", chainID: "staging-1", genesisHash: []byte{0xAB, 0xCD}}
+
+	for _, chain := range []*definitions.ChainIdentity{
+		{Name: "staging"},
+		{ID: "$env.BOS_TEST_CHAIN_ID", GenesisHash: "abcd"},
+	} {
+		do := &definitions.Do{Package: &definitions.Package{Chain: chain}}
+		if err := resolveVariables(do); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyChain(do, nodeClient); err != nil {
+			t.Errorf("expected chain %v to match: %v", chain, err)
+		}
+		if do.ConnectedChain == nil || do.ConnectedChain.ID != "staging-1" || do.ConnectedChain.GenesisHash != "ABCD" {
+			t.Errorf("expected the connected chain to be pinned but got %v", do.ConnectedChain)
+		}
+	}
+
+	do := &definitions.Do{Package: &definitions.Package{Chain: &definitions.ChainIdentity{Name: "dev", ID: "staging-1"}}}
+	err := verifyChain(do, nodeClient)
+	if err == nil || !strings.Contains(err.Error(), "name dev") || !strings.Contains(err.Error(), "--yes-i-know") {
+		t.Errorf("expected running against the wrong chain to be refused but got %v", err)
+	}
+	if do.ConnectedChain != nil {
+		t.Errorf("expected no chain to be pinned when refusing to run")
+	}
+	do.IgnoreChainMismatch = true
+	if err := verifyChain(do, nodeClient); err != nil || do.ConnectedChain == nil {
+		t.Errorf("expected a mismatch to be ignored with --yes-i-know but got %v", err)
+	}
+
+	// Without a chain section nothing is asked of the node until the first broadcast
+	do = &definitions.Do{Package: definitions.BlankPackage()}
+	if err := verifyChain(do, nil); err != nil || do.ConnectedChain != nil {
+		t.Errorf("expected nothing to be verified without a chain section but got %v", err)
+	}
+}
+
+func TestCheckConnectedChain(t *testing.T) {
+	do := &definitions.Do{Package: definitions.BlankPackage()}
+	first := &definitions.ChainIdentity{Name: "staging", ID: "staging-1", GenesisHash: "ABCD"}
+	if err := checkConnectedChain(do, first); err != nil || do.ConnectedChain != first {
+		t.Fatalf("expected the first chain seen to be pinned but got %v", err)
+	}
+	if err := checkConnectedChain(do, &definitions.ChainIdentity{Name: "staging", ID: "staging-1",
+		GenesisHash: "ABCD"}); err != nil {
+		t.Errorf("expected the same chain to be accepted but got %v", err)
+	}
+	err := checkConnectedChain(do, &definitions.ChainIdentity{Name: "staging", ID: "staging-1", GenesisHash: "EF01"})
+	if err == nil || !strings.Contains(err.Error(), "refusing to broadcast") {
+		t.Errorf("expected a node switching chains part way through a run to be refused but got %v", err)
+	}
+}
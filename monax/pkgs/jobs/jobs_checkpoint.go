@@ -0,0 +1,425 @@
+package jobs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/rpc"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+const checkpointSuffix = ".checkpoint.json"
+
+// Fields of a job that are not part of what it is asked to do so do not count towards its inputs
+var checkpointIgnoredFields = map[string]bool{
+	"JobResult":   true,
+	"JobVars":     true,
+	"JobAttempts": true,
+	"JobTxHashes": true,
+	"JobAmount":   true,
+	"JobTests":    true,
+	"Source":      true,
+	"Retry":       true,
+	"DependsOn":   true,
+}
+
+// The jobs of a run that broadcast transactions, recorded as each completes so that a run that is interrupted can be
+// resumed without sending them again
+type Checkpoint struct {
+	// A checkpoint is only resumed against the chain it was written for
+	ChainID string
+	Jobs    []*JobCheckpoint
+}
+
+type JobCheckpoint struct {
+	Name string
+	// Hash of the job's definition with the variables it uses resolved, empty for the sub-jobs of a parallel group
+	// which are covered by the group's
+	InputsHash string
+	Result     string
+	Vars       []*definitions.Variable `json:",omitempty"`
+	// Hex hashes of the transactions the job broadcast
+	TxHashes []string `json:",omitempty"`
+	// Contract created by a deploy job, which is confirmed by its code being on chain
+	Address string `json:",omitempty"`
+	// Records of the sub-jobs of a parallel group
+	SubJobs []*JobCheckpoint `json:",omitempty"`
+}
+
+func (cp *Checkpoint) job(name string) *JobCheckpoint {
+	for _, record := range cp.Jobs {
+		if record.Name == name {
+			return record
+		}
+	}
+	return nil
+}
+
+// Replaces any record of the same job
+func (cp *Checkpoint) record(record *JobCheckpoint) {
+	cp.remove(record.Name)
+	cp.Jobs = append(cp.Jobs, record)
+}
+
+// Returns whether there was a record to remove
+func (cp *Checkpoint) remove(name string) bool {
+	for i, record := range cp.Jobs {
+		if record.Name == name {
+			cp.Jobs = append(cp.Jobs[:i], cp.Jobs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func ReadCheckpoint(path string) (*Checkpoint, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := new(Checkpoint)
+	if err := json.Unmarshal(bs, checkpoint); err != nil {
+		return nil, fmt.Errorf("could not read checkpoint %s: %v", path, err)
+	}
+	return checkpoint, nil
+}
+
+// Writes the checkpoint to a temporary file which then replaces path so an interruption never leaves half of it
+func WriteCheckpoint(checkpoint *Checkpoint, path string) error {
+	bs, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, bs, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// The checkpoint given by --checkpoint or else one named after the jobs file, so epm.yaml is checkpointed to
+// epm.checkpoint.json in the current directory alongside the jobs output
+func checkpointPath(do *definitions.Do) string {
+	if do.Checkpoint != "" {
+		return do.Checkpoint
+	}
+	base := filepath.Base(do.YAMLPath)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + checkpointSuffix
+}
+
+func newJobCheckpoint(job *definitions.Job, inputsHash string) *JobCheckpoint {
+	record := &JobCheckpoint{
+		Name:       job.JobName,
+		InputsHash: inputsHash,
+		Result:     job.JobResult,
+		Vars:       job.JobVars,
+		TxHashes:   job.JobTxHashes,
+	}
+	if job.Deploy != nil {
+		if address, err := acm.AddressFromHexString(job.JobResult); err == nil {
+			record.Address = address.String()
+		}
+	}
+	if job.Parallel != nil {
+		for _, subJob := range job.Parallel.Jobs {
+			record.SubJobs = append(record.SubJobs, newJobCheckpoint(subJob, ""))
+		}
+	}
+	return record
+}
+
+// Whether the job or one of its sub-jobs broadcast a transaction
+func (record *JobCheckpoint) transacted() bool {
+	if len(record.TxHashes) > 0 || record.Address != "" {
+		return true
+	}
+	for _, subJob := range record.SubJobs {
+		if subJob.transacted() {
+			return true
+		}
+	}
+	return false
+}
+
+// Gives the job, and the sub-jobs of a parallel group, the results recorded so that the jobs after it can use them
+func (record *JobCheckpoint) restore(job *definitions.Job) {
+	job.JobResult = record.Result
+	job.JobVars = record.Vars
+	job.JobTxHashes = record.TxHashes
+	if job.Parallel == nil {
+		return
+	}
+	for _, subJob := range job.Parallel.Jobs {
+		for _, subRecord := range record.SubJobs {
+			if subRecord.Name == subJob.JobName {
+				subRecord.restore(subJob)
+			}
+		}
+	}
+}
+
+// Hashes the job's definition after resolving the variables it uses, so that a job is run again when it has changed
+// or when the results of the jobs it refers to have. Fields are hashed along with their paths in the definition and
+// map entries in order of their keys so the hash only depends on the definition.
+func jobInputsHash(job *definitions.Job, do *definitions.Do) (string, error) {
+	hasher := sha256.New()
+	var err error
+	var write func(path string, value reflect.Value)
+	write = func(path string, value reflect.Value) {
+		switch value.Kind() {
+		case reflect.String:
+			resolved, resolveErr := util.PreProcess(value.String(), do)
+			if resolveErr != nil && err == nil {
+				err = resolveErr
+			}
+			fmt.Fprintf(hasher, "%s=%q\n", path, resolved)
+		case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint,
+			reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+			fmt.Fprintf(hasher, "%s=%v\n", path, value.Interface())
+		case reflect.Ptr, reflect.Interface:
+			if !value.IsNil() {
+				write(path, value.Elem())
+			}
+		case reflect.Struct:
+			for i := 0; i < value.NumField(); i++ {
+				field := value.Type().Field(i)
+				if field.PkgPath != "" || checkpointIgnoredFields[field.Name] {
+					continue
+				}
+				write(path+"."+field.Name, value.Field(i))
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < value.Len(); i++ {
+				write(fmt.Sprintf("%s[%d]", path, i), value.Index(i))
+			}
+		case reflect.Map:
+			keys := value.MapKeys()
+			sort.Slice(keys, func(i, j int) bool {
+				return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+			})
+			for _, key := range keys {
+				write(fmt.Sprintf("%s[%v]", path, key.Interface()), value.MapIndex(key))
+			}
+		}
+	}
+	write("", reflect.ValueOf(*job))
+	if err != nil {
+		return "", fmt.Errorf("could not resolve the inputs of job %s: %v", job.JobName, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// Whether the transactions recorded for the job and its sub-jobs have all been committed. Jobs that broadcast
+// nothing are never confirmed so are always run again, being cheap and sometimes, as account jobs do, setting up
+// the jobs after them.
+func confirmed(nodeClient client.NodeClient, record *JobCheckpoint) (bool, error) {
+	if !record.transacted() {
+		return false, nil
+	}
+	if record.Address != "" {
+		address, err := acm.AddressFromHexString(record.Address)
+		if err != nil {
+			return false, err
+		}
+		account, err := nodeClient.GetAccount(address)
+		if err != nil {
+			return false, err
+		}
+		// Transactions too old for the node to find are confirmed by the contract they created
+		if account != nil && len(account.Code()) > 0 {
+			return true, nil
+		}
+	}
+	for _, txHash := range record.TxHashes {
+		ok, err := txConfirmed(nodeClient, txHash)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	for _, subJob := range record.SubJobs {
+		if !subJob.transacted() {
+			continue
+		}
+		ok, err := confirmed(nodeClient, subJob)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// A transaction is confirmed when the node finds it in a block or else has its receipt, which is kept for
+// transactions older than the blocks it searches when the node records receipts
+func txConfirmed(nodeClient client.NodeClient, txHash string) (bool, error) {
+	hash, err := hex.DecodeString(txHash)
+	if err != nil {
+		return false, fmt.Errorf("checkpoint has invalid transaction hash %s: %v", txHash, err)
+	}
+	result, err := nodeClient.GetTx(hash)
+	if err != nil {
+		return false, err
+	}
+	switch result.Status {
+	case rpc.TxStatusConfirmed:
+		return true, nil
+	case rpc.TxStatusPending:
+		// Running the job again could make the same change twice
+		return false, fmt.Errorf("transaction %s is still pending, wait for it to be committed before resuming",
+			txHash)
+	}
+	receipt, err := nodeClient.TxReceipt(hash)
+	return err == nil && receipt != nil, nil
+}
+
+// Checkpoints a run as it goes, and when resuming restores the jobs an earlier run completed. A nil runCheckpoint,
+// as used by dry runs and when signing only since neither changes the chain, does nothing.
+type runCheckpoint struct {
+	path       string
+	nodeClient client.NodeClient
+	checkpoint *Checkpoint
+	// Checkpoint of the run being resumed, nil when not resuming
+	previous  *Checkpoint
+	forceFrom string
+	forcing   bool
+	// Inputs hash of each job taken before it ran, by job name
+	inputs map[string]string
+}
+
+func startCheckpoint(do *definitions.Do) (*runCheckpoint, error) {
+	if do.DryRun || do.SignOnly != "" {
+		if do.Resume || do.ForceFrom != "" {
+			return nil, fmt.Errorf("a run can only be resumed when it broadcasts transactions")
+		}
+		return nil, nil
+	}
+	if do.ForceFrom != "" {
+		do.Resume = true
+		found := false
+		for _, job := range do.Package.AllJobs() {
+			found = found || job.JobName == do.ForceFrom
+		}
+		if !found {
+			return nil, fmt.Errorf("no job called %s to force the run from", do.ForceFrom)
+		}
+	}
+	rc := &runCheckpoint{
+		path:       checkpointPath(do),
+		nodeClient: util.NodeClient(do),
+		checkpoint: new(Checkpoint),
+		forceFrom:  do.ForceFrom,
+		inputs:     make(map[string]string),
+	}
+	if !do.Resume {
+		return rc, nil
+	}
+	var err error
+	rc.previous, err = ReadCheckpoint(rc.path)
+	if os.IsNotExist(err) {
+		log.WithField("=>", rc.path).Warn("No checkpoint to resume from, running every job")
+		return rc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := rc.chainID()
+	if err != nil {
+		return nil, err
+	}
+	if rc.previous.ChainID != chainID {
+		return nil, fmt.Errorf("checkpoint %s was written for chain %s so cannot be resumed on chain %s", rc.path,
+			rc.previous.ChainID, chainID)
+	}
+	return rc, nil
+}
+
+// Takes the inputs of the job before it is run and returns true if it was completed by the run being resumed, in
+// which case its results are restored in place of running it
+func (rc *runCheckpoint) resume(job *definitions.Job, do *definitions.Do) (bool, error) {
+	if rc == nil {
+		return false, nil
+	}
+	inputsHash, err := jobInputsHash(job, do)
+	if err != nil {
+		// The job is then run and not checkpointed
+		log.WithField("=>", err).Warn("Not Checkpointing Job")
+		return false, nil
+	}
+	rc.inputs[job.JobName] = inputsHash
+	for _, forced := range withSubJobs(job) {
+		rc.forcing = rc.forcing || forced.JobName == rc.forceFrom
+	}
+	if rc.previous == nil || rc.forcing {
+		return false, nil
+	}
+	record := rc.previous.job(job.JobName)
+	if record == nil || record.InputsHash != inputsHash {
+		return false, nil
+	}
+	ok, err := confirmed(rc.nodeClient, record)
+	if err != nil {
+		return false, fmt.Errorf("could not confirm the transactions job %s made in the run being resumed: %v",
+			job.JobName, err)
+	}
+	if !ok {
+		return false, nil
+	}
+	record.restore(job)
+	log.WithField("=>", job.JobName).Warn("Resumed Job From Checkpoint")
+	rc.checkpoint.record(record)
+	return true, rc.write()
+}
+
+// Records a job that succeeded, or drops any record of one that failed, given the error it was run with, so that it
+// is run again
+func (rc *runCheckpoint) update(job *definitions.Job, jobErr error) error {
+	if rc == nil {
+		return nil
+	}
+	inputsHash, ok := rc.inputs[job.JobName]
+	var record *JobCheckpoint
+	if jobErr == nil && ok {
+		record = newJobCheckpoint(job, inputsHash)
+	}
+	if record == nil || !record.transacted() {
+		if rc.checkpoint.remove(job.JobName) {
+			return rc.write()
+		}
+		return nil
+	}
+	rc.checkpoint.record(record)
+	return rc.write()
+}
+
+// The chain ID is only asked for once a run needs it so jobs that make no transactions can run without a chain
+func (rc *runCheckpoint) chainID() (string, error) {
+	if rc.checkpoint.ChainID == "" {
+		_, chainID, _, err := rc.nodeClient.ChainId()
+		if err != nil {
+			return "", err
+		}
+		rc.checkpoint.ChainID = chainID
+	}
+	return rc.checkpoint.ChainID, nil
+}
+
+func (rc *runCheckpoint) write() error {
+	if _, err := rc.chainID(); err != nil {
+		return fmt.Errorf("could not write checkpoint %s: %v", rc.path, err)
+	}
+	if err := WriteCheckpoint(rc.checkpoint, rc.path); err != nil {
+		return fmt.Errorf("could not write checkpoint %s: %v", rc.path, err)
+	}
+	return nil
+}
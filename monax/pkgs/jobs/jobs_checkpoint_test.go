@@ -0,0 +1,207 @@
+package jobs
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/execution"
+	"github.com/hyperledger/burrow/rpc"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+type testTxClient struct {
+	client.NodeClient
+	statuses map[string]rpc.TxStatus
+	receipts map[string]bool
+	code     map[acm.Address]bool
+}
+
+func (ttc *testTxClient) GetTx(txHash []byte) (*rpc.ResultGetTx, error) {
+	status, ok := ttc.statuses[fmt.Sprintf("%X", txHash)]
+	if !ok {
+		status = rpc.TxStatusNotFound
+	}
+	return &rpc.ResultGetTx{Status: status, TxHash: txHash}, nil
+}
+
+func (ttc *testTxClient) TxReceipt(txHash []byte) (*execution.TxReceipt, error) {
+	if ttc.receipts[fmt.Sprintf("%X", txHash)] {
+		return &execution.TxReceipt{}, nil
+	}
+	return nil, nil
+}
+
+func (ttc *testTxClient) GetAccount(address acm.Address) (acm.Account, error) {
+	if !ttc.code[address] {
+		return nil, nil
+	}
+	return acm.ConcreteAccount{Address: address, Code: []byte{1}}.Account(), nil
+}
+
+func checkpointDo(jobs ...*definitions.Job) *definitions.Do {
+	do := &definitions.Do{Package: definitions.BlankPackage()}
+	do.Package.Jobs = jobs
+	return do
+}
+
+func TestJobInputsHash(t *testing.T) {
+	token := &definitions.Job{JobName: "token", Deploy: &definitions.Deploy{Contract: "Token.sol"}}
+	send := &definitions.Job{JobName: "send", Send: &definitions.Send{Destination: "$token", Amount: "10"}}
+	do := checkpointDo(token, send)
+
+	token.JobResult = acm.Address{1}.String()
+	hash, err := jobInputsHash(send, do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send.JobResult, send.JobAttempts, send.JobTxHashes = "ABCD", 2, []string{"ABCD"}
+	if again, _ := jobInputsHash(send, do); again != hash {
+		t.Errorf("expected the results of a job not to change its inputs hash")
+	}
+	send.Send.Amount = "11"
+	if changed, _ := jobInputsHash(send, do); changed == hash {
+		t.Errorf("expected changing a job to change its inputs hash")
+	}
+	send.Send.Amount = "10"
+	token.JobResult = acm.Address{2}.String()
+	if changed, _ := jobInputsHash(send, do); changed == hash {
+		t.Errorf("expected a change to the result a job refers to to change its inputs hash")
+	}
+}
+
+func TestCheckpointRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "epm.checkpoint.json")
+
+	address := acm.Address{1}
+	token := &definitions.Job{JobName: "token", Deploy: &definitions.Deploy{Contract: "Token.sol"},
+		JobResult: address.String(), JobTxHashes: []string{"AA"}}
+	call := &definitions.Job{JobName: "mint", Call: &definitions.Call{Destination: "$token", Function: "mint"},
+		JobResult: "7", JobVars: []*definitions.Variable{{Name: "minted", Value: "7"}}, JobTxHashes: []string{"BB"}}
+	checkpoint := &Checkpoint{ChainID: "test-chain"}
+	checkpoint.record(newJobCheckpoint(token, "1"))
+	checkpoint.record(newJobCheckpoint(call, "2"))
+	if err := WriteCheckpoint(checkpoint, path); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.ChainID != "test-chain" || len(read.Jobs) != 2 || read.job("token").Address != address.String() {
+		t.Fatalf("checkpoint did not round trip: %+v", read)
+	}
+
+	restoredToken := &definitions.Job{JobName: "token"}
+	restoredCall := &definitions.Job{JobName: "mint"}
+	read.job("token").restore(restoredToken)
+	read.job("mint").restore(restoredCall)
+	do := checkpointDo(restoredToken, restoredCall)
+	for reference, expected := range map[string]string{"$token": address.String(), "$mint.minted": "7"} {
+		if value, err := util.PreProcess(reference, do); err != nil || value != expected {
+			t.Errorf("expected %s to resolve to %s from the checkpoint but got %s, %v", reference, expected, value,
+				err)
+		}
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := &definitions.Job{JobName: "first", Send: &definitions.Send{Destination: "AB", Amount: "1"}}
+	second := &definitions.Job{JobName: "second", Send: &definitions.Send{Destination: "AB", Amount: "2"}}
+	do := checkpointDo(first, second)
+	nodeClient := &testTxClient{statuses: map[string]rpc.TxStatus{"01": rpc.TxStatusConfirmed},
+		receipts: map[string]bool{"03": true}}
+	newRun := func(forceFrom string) *runCheckpoint {
+		return &runCheckpoint{
+			path:       filepath.Join(dir, "epm.checkpoint.json"),
+			nodeClient: nodeClient,
+			checkpoint: &Checkpoint{ChainID: "test-chain"},
+			previous:   &Checkpoint{ChainID: "test-chain"},
+			forceFrom:  forceFrom,
+			inputs:     make(map[string]string),
+		}
+	}
+	record := func(rc *runCheckpoint, job *definitions.Job, txHashes ...string) {
+		inputsHash, err := jobInputsHash(job, do)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc.previous.record(&JobCheckpoint{Name: job.JobName, InputsHash: inputsHash, Result: "done",
+			TxHashes: txHashes})
+	}
+
+	for _, resumption := range []struct {
+		txHash   string
+		resumed  bool
+		errorsOn string
+	}{
+		{txHash: "01", resumed: true},
+		{txHash: "03", resumed: true},
+		{txHash: "02"},
+		{txHash: "04", errorsOn: "pending"},
+	} {
+		nodeClient.statuses["04"] = rpc.TxStatusPending
+		rc := newRun("")
+		record(rc, first, resumption.txHash)
+		first.JobResult = ""
+		resumed, err := rc.resume(first, do)
+		if resumption.errorsOn != "" {
+			if err == nil || !strings.Contains(err.Error(), resumption.errorsOn) {
+				t.Errorf("expected resuming tx %s to fail with %s but got %v", resumption.txHash, resumption.errorsOn,
+					err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resumed != resumption.resumed {
+			t.Errorf("expected job with tx %s resumed to be %v", resumption.txHash, resumption.resumed)
+		}
+		if resumed && (first.JobResult != "done" || rc.checkpoint.job("first") == nil) {
+			t.Errorf("expected resumed job to have its result restored and be checkpointed again")
+		}
+	}
+
+	rc := newRun("")
+	record(rc, first, "01")
+	first.Send.Amount = "100"
+	if resumed, _ := rc.resume(first, do); resumed {
+		t.Errorf("expected a job whose inputs changed to be run again")
+	}
+	first.Send.Amount = "1"
+
+	rc = newRun("first")
+	record(rc, first, "01")
+	record(rc, second, "01")
+	for _, job := range []*definitions.Job{first, second} {
+		if resumed, _ := rc.resume(job, do); resumed {
+			t.Errorf("expected job %s from the forced job on to be run again", job.JobName)
+		}
+	}
+	if err := rc.update(second, fmt.Errorf("failed")); err != nil || rc.checkpoint.job(second.JobName) != nil {
+		t.Errorf("expected a failed job not to be checkpointed")
+	}
+	second.JobTxHashes = []string{hex.EncodeToString([]byte{5})}
+	if err := rc.update(second, nil); err != nil || rc.checkpoint.job(second.JobName) == nil {
+		t.Errorf("expected a completed job to be checkpointed")
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/hyperledger/burrow/client"
 	"github.com/hyperledger/burrow/client/rpc"
 	"github.com/hyperledger/burrow/keys"
 	"github.com/hyperledger/burrow/logging/loggers"
@@ -25,8 +24,11 @@ func DeployJob(deploy *definitions.Deploy, do *definitions.Do) (result string, e
 	deploy.Source, _ = util.PreProcess(deploy.Source, do)
 	deploy.Contract, _ = util.PreProcess(deploy.Contract, do)
 	deploy.Instance, _ = util.PreProcess(deploy.Instance, do)
-	deploy.Libraries, _ = util.PreProcessLibs(deploy.Libraries, do)
-	deploy.Amount, _ = util.PreProcess(deploy.Amount, do)
+	libraries, err := util.PreProcessLibs(deploy.Libraries, do)
+	if err != nil {
+		return "", err
+	}
+	deploy.Solc, _ = util.PreProcess(deploy.Solc, do)
 	deploy.Nonce, _ = util.PreProcess(deploy.Nonce, do)
 	deploy.Fee, _ = util.PreProcess(deploy.Fee, do)
 	deploy.Gas, _ = util.PreProcess(deploy.Gas, do)
@@ -37,7 +39,10 @@ func DeployJob(deploy *definitions.Deploy, do *definitions.Do) (result string, e
 	// Use defaults
 	deploy.Source = useDefault(deploy.Source, do.Package.Account)
 	deploy.Instance = useDefault(deploy.Instance, contractName)
-	deploy.Amount = useDefault(deploy.Amount, do.DefaultAmount)
+	deploy.Amount, err = resolveAmount(useDefault(deploy.Amount, do.DefaultAmount), do)
+	if err != nil {
+		return "", err
+	}
 	deploy.Fee = useDefault(deploy.Fee, do.DefaultFee)
 	deploy.Gas = useDefault(deploy.Gas, do.DefaultGas)
 
@@ -61,7 +66,7 @@ func DeployJob(deploy *definitions.Deploy, do *definitions.Do) (result string, e
 		contractPath = filepath.Join(do.BinPath, deploy.Contract)
 		log.Info("Binary file detected. Using binary deploy sequence.")
 		log.WithField("=>", contractPath).Info("Binary path")
-		binaryResponse, err := compilers.RequestBinaryLinkage(contractPath, deploy.Libraries)
+		binaryResponse, err := compilers.RequestBinaryLinkage(contractPath, libraries)
 		if err != nil {
 			return "", fmt.Errorf("Something went wrong with your binary deployment: %v", err)
 		}
@@ -69,6 +74,22 @@ func DeployJob(deploy *definitions.Deploy, do *definitions.Do) (result string, e
 			return "", fmt.Errorf("Something went wrong when you were trying to link your binaries: %v", binaryResponse.Error)
 		}
 		contractCode := binaryResponse.Binary
+		if err := compilers.CheckLinked(contractName, contractCode, nil); err != nil {
+			return "", err
+		}
+		if deploy.Data != nil {
+			// The ABI saved when the binary was compiled gives the constructor's arguments
+			abiSpec, err := util.ReadAbi(do.ABIPath, contractName)
+			if err != nil {
+				return "", fmt.Errorf("constructor arguments given for binary %s but could not read its ABI: %v",
+					contractPath, err)
+			}
+			callData, err := constructorArgs(deploy, do, contractName, string(abiSpec))
+			if err != nil {
+				return "", err
+			}
+			contractCode = contractCode + callData
+		}
 
 		tx, err := deployRaw(do, deploy, contractName, string(contractCode))
 		if err != nil {
@@ -83,7 +104,7 @@ func DeployJob(deploy *definitions.Deploy, do *definitions.Do) (result string, e
 		contractPath = deploy.Contract
 		log.WithField("=>", contractPath).Info("Contract path")
 		// normal compilation/deploy sequence
-		resp, err := compilers.RequestCompile(contractPath, false, deploy.Libraries)
+		resp, err := compilers.RequestCompile(contractPath, false, libraries, deploy.Solc)
 
 		if err != nil {
 			log.Errorln("Error compiling contracts: Compilers error:")
@@ -91,7 +112,8 @@ func DeployJob(deploy *definitions.Deploy, do *definitions.Do) (result string, e
 		} else if resp.Error != "" {
 			log.Errorln("Error compiling contracts: Language error:")
 			return "", fmt.Errorf("%v", resp.Error)
-		} else if resp.Warning != "" {
+		} else if resp.Warning != "" && len(resp.Diagnostics) == 0 {
+			// solc warnings have already been printed by the compiler
 			log.WithField("Warning", resp.Warning).Warn("Warning Generated during Contract Compilation")
 		}
 		// loop through objects returned from compiler
@@ -101,6 +123,7 @@ func DeployJob(deploy *definitions.Deploy, do *definitions.Do) (result string, e
 			response := resp.Objects[0]
 			log.WithField("=>", response.ABI).Info("Abi")
 			log.WithField("=>", response.Bytecode).Info("Bin")
+			log.WithField("=>", response.Version).Info("Compiler Version")
 			if response.Bytecode != "" {
 				result, err = deployContract(deploy, do, response)
 				if err != nil {
@@ -162,6 +185,9 @@ func matchInstanceName(objectName, deployInstance string) bool {
 func deployContract(deploy *definitions.Deploy, do *definitions.Do, compilersResponse compilers.ResponseItem) (string, error) {
 	log.WithField("=>", string(compilersResponse.ABI)).Debug("ABI Specification (From Compilers)")
 	contractCode := compilersResponse.Bytecode
+	if err := compilers.CheckLinked(compilersResponse.Objectname, contractCode, compilersResponse.LinkReferences); err != nil {
+		return "", err
+	}
 
 	// Save ABI
 	if _, err := os.Stat(do.ABIPath); os.IsNotExist(err) {
@@ -187,22 +213,11 @@ func deployContract(deploy *definitions.Deploy, do *definitions.Do, compilersRes
 		log.Debug("Objectname from compilers is blank. Not saving abi.")
 	}
 
-	// additional data may be sent along with the contract
-	// these are naively added to the end of the contract code using standard
-	// mint packing
-
-	if deploy.Data != nil {
-		_, callDataArray, err := util.PreProcessInputData(compilersResponse.Objectname, deploy.Data, do, true)
-		if err != nil {
-			return "", err
-		}
-		packedBytes, err := abi.ReadAbiFormulateCall(compilersResponse.Objectname, "", callDataArray, do)
-		if err != nil {
-			return "", err
-		}
-		callData := hex.EncodeToString(packedBytes)
-		contractCode = contractCode + callData
+	callData, err := constructorArgs(deploy, do, compilersResponse.Objectname, compilersResponse.ABI)
+	if err != nil {
+		return "", err
 	}
+	contractCode = contractCode + callData
 
 	tx, err := deployRaw(do, deploy, compilersResponse.Objectname, contractCode)
 	if err != nil {
@@ -240,6 +255,24 @@ func deployContract(deploy *definitions.Deploy, do *definitions.Do, compilersRes
 	return result, err
 }
 
+// Packs the deploy job's data as arguments to the constructor in abiSpec for appending to the creation bytecode,
+// failing before anything is broadcast if they do not match the constructor
+func constructorArgs(deploy *definitions.Deploy, do *definitions.Do, contractName, abiSpec string) (string, error) {
+	var args []string
+	if deploy.Data != nil {
+		var err error
+		_, args, err = util.PreProcessInputData(contractName, deploy.Data, do, true)
+		if err != nil {
+			return "", err
+		}
+	}
+	packed, err := abi.PackConstructorArgs(abiSpec, contractName, args)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(packed), nil
+}
+
 func deployRaw(do *definitions.Do, deploy *definitions.Deploy, contractName, contractCode string) (*txs.CallTx, error) {
 
 	// Deploy contract
@@ -253,7 +286,7 @@ func deployRaw(do *definitions.Do, deploy *definitions.Deploy, contractName, con
 		"chain-url": do.ChainURL,
 	}).Info()
 
-	monaxNodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
+	monaxNodeClient := util.NodeClient(do)
 	monaxKeyClient := keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger())
 	tx, err := rpc.Call(monaxNodeClient, monaxKeyClient, do.PublicKey, deploy.Source, "", deploy.Amount,
 		deploy.Nonce, deploy.Gas, deploy.Fee, contractCode)
@@ -277,7 +310,6 @@ func CallJob(call *definitions.Call, do *definitions.Do) (string, []*definitions
 		return "", nil, err
 	}
 	call.Function, _ = util.PreProcess(call.Function, do)
-	call.Amount, _ = util.PreProcess(call.Amount, do)
 	call.Nonce, _ = util.PreProcess(call.Nonce, do)
 	call.Fee, _ = util.PreProcess(call.Fee, do)
 	call.Gas, _ = util.PreProcess(call.Gas, do)
@@ -285,7 +317,10 @@ func CallJob(call *definitions.Call, do *definitions.Do) (string, []*definitions
 
 	// Use default
 	call.Source = useDefault(call.Source, do.Package.Account)
-	call.Amount = useDefault(call.Amount, do.DefaultAmount)
+	call.Amount, err = resolveAmount(useDefault(call.Amount, do.DefaultAmount), do)
+	if err != nil {
+		return "", nil, err
+	}
 	call.Fee = useDefault(call.Fee, do.DefaultFee)
 	call.Gas = useDefault(call.Gas, do.DefaultGas)
 
@@ -320,7 +355,7 @@ func CallJob(call *definitions.Call, do *definitions.Do) (string, []*definitions
 		"data":        callData,
 	}).Info("Calling")
 
-	nodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
+	nodeClient := util.NodeClient(do)
 	keyClient := keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger())
 	tx, err := rpc.Call(nodeClient, keyClient, do.PublicKey, call.Source, call.Destination, call.Amount, call.Nonce, call.Gas, call.Fee, callData)
 	if err != nil {
@@ -333,16 +368,10 @@ func CallJob(call *definitions.Call, do *definitions.Do) (string, []*definitions
 	}
 
 	// Sign, broadcast, display
-
-	_, chainID, _, err := nodeClient.ChainId()
+	res, err := signAndBroadcast(do, nodeClient, keyClient, tx)
 	if err != nil {
 		return "", nil, err
 	}
-	res, err := rpc.SignAndBroadcast(chainID, nodeClient, keyClient, tx, true, true, true)
-	if err != nil {
-		var str, err = util.MintChainErrorHandler(do, err)
-		return str, nil, err
-	}
 
 	txResult := res.Return
 	var result string
@@ -379,15 +408,11 @@ func CallJob(call *definitions.Call, do *definitions.Do) (string, []*definitions
 }
 
 func deployFinalize(do *definitions.Do, tx interface{}) (string, error) {
-	nodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
-	_, chainID, _, err := nodeClient.ChainId()
-	if err != nil {
-		return "", err
-	}
+	nodeClient := util.NodeClient(do)
 	keyClient := keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger())
-	res, err := rpc.SignAndBroadcast(chainID, nodeClient, keyClient, tx.(txs.Tx), true, true, true)
+	res, err := signAndBroadcast(do, nodeClient, keyClient, tx.(txs.Tx))
 	if err != nil {
-		return util.MintChainErrorHandler(do, err)
+		return "", err
 	}
 
 	if err := util.ReadTxSignAndBroadcast(res, err); err != nil {
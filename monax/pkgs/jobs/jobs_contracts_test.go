@@ -1,6 +1,12 @@
 package jobs
 
-import "testing"
+import (
+	"testing"
+
+	compilers "github.com/monax/bosmarmot/compilers/perform"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/util"
+)
 
 func Test_matchInstanceName(t *testing.T) {
 	type args struct {
@@ -69,3 +75,38 @@ func Test_matchInstanceName(t *testing.T) {
 		})
 	}
 }
+
+func TestPreProcessLibs(t *testing.T) {
+	do := definitions.NowDo()
+	do.Package = &definitions.Package{Jobs: []*definitions.Job{
+		{JobName: "deployLib", JobResult: "00000000000000000000000000000000000000FF"},
+	}}
+	for _, libs := range []interface{}{
+		"Search:$deployLib,Set:0000000000000000000000000000000000000001",
+		map[interface{}]interface{}{"Set": "0000000000000000000000000000000000000001", "Search": "$deployLib"},
+	} {
+		libraries, err := util.PreProcessLibs(libs, do)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "Search:00000000000000000000000000000000000000FF Set:0000000000000000000000000000000000000001"
+		if libraries != expected {
+			t.Errorf("expected libraries %v to give %s but got %s", libs, expected, libraries)
+		}
+	}
+	if _, err := util.PreProcessLibs([]interface{}{"Search"}, do); err == nil {
+		t.Errorf("expected a list of libraries to be rejected")
+	}
+}
+
+func TestDeployUnlinkedContract(t *testing.T) {
+	do := definitions.NowDo()
+	placeholder := "__lib.sol:Search________________________"
+	_, err := deployContract(&definitions.Deploy{Contract: "C.sol"}, do, compilers.ResponseItem{
+		Objectname: "C",
+		Bytecode:   "6060" + placeholder,
+	})
+	if _, ok := err.(compilers.ErrUnresolvedLibraries); !ok {
+		t.Fatalf("expected deploying a contract with unresolved libraries to fail but got %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/client/rpc"
+	"github.com/hyperledger/burrow/keys"
+	"github.com/hyperledger/burrow/permission"
+	ptypes "github.com/hyperledger/burrow/permission/types"
+	"github.com/hyperledger/burrow/txs"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+// ErrUnverifiable is returned by a job during a dry run when its outcome depends on the effects of an earlier
+// transaction that was only simulated
+type ErrUnverifiable struct {
+	Reason string
+}
+
+func (err ErrUnverifiable) Error() string {
+	return fmt.Sprintf("cannot be verified in a dry run since it %s", err.Reason)
+}
+
+// Nothing simulated in a dry run reaches the chain so we keep track of what would have changed in order to give
+// each transaction the sequence number it would have had and to spot jobs that read state we never wrote
+type simulation struct {
+	sync.Mutex
+	// Number of transactions simulated from each account
+	sequences map[acm.Address]uint64
+	// Amount simulated transactions would have spent from each account
+	spent map[acm.Address]uint64
+	// Accounts whose balance or permissions would have changed
+	accounts  map[acm.Address]bool
+	contracts map[acm.Address]bool
+	names     map[string]bool
+	// Job name -> reason for jobs that could not be verified
+	unverifiable map[string]string
+}
+
+// The simulation of the dry run do belongs to
+func simulationOf(do *definitions.Do) *simulation {
+	sim, _ := do.DryRunSimulation.(*simulation)
+	return sim
+}
+
+func newSimulation() *simulation {
+	return &simulation{
+		sequences:    make(map[acm.Address]uint64),
+		spent:        make(map[acm.Address]uint64),
+		accounts:     make(map[acm.Address]bool),
+		contracts:    make(map[acm.Address]bool),
+		names:        make(map[string]bool),
+		unverifiable: make(map[string]string),
+	}
+}
+
+// Signs and broadcasts tx waiting for it to be committed or, in a dry run, simulates it against current state. When
+// signing only tx is written out with the signatures that could be made instead.
+func signAndBroadcast(do *definitions.Do, nodeClient client.NodeClient, keyClient keys.KeyClient,
+	tx txs.Tx) (*rpc.TxResult, error) {
+
+	res, err := broadcastTx(do, nodeClient, keyClient, tx)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Like signAndBroadcast but also returns the result of a transaction that was committed with an exception, so the
+// caller can see why it reverted
+func broadcastTx(do *definitions.Do, nodeClient client.NodeClient, keyClient keys.KeyClient,
+	tx txs.Tx) (*rpc.TxResult, error) {
+
+	chain, err := connectedChain(nodeClient)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkConnectedChain(do, chain); err != nil {
+		return nil, err
+	}
+	chainID := chain.ID
+	if do.DryRun {
+		return simulationOf(do).simulate(chainID, nodeClient, tx)
+	}
+	if do.SignOnly != "" {
+		return signOnly(do, chainID, nodeClient, keyClient, tx)
+	}
+	// Signed before the broadcast is counted since failing to sign cannot have reached the node
+	if _, err = rpc.SignAndBroadcast(chainID, nodeClient, keyClient, tx, true, false, false); err != nil {
+		_, err = util.MintChainErrorHandler(do, err)
+		return nil, err
+	}
+	res, err := broadcastSigned(do, chainID, nodeClient, keyClient, tx)
+	if err != nil {
+		if res != nil && res.RevertReason != "" {
+			log.WithField("=>", res.RevertReason).Warn("Revert Reason")
+		}
+		_, err = util.MintChainErrorHandler(do, err)
+		if res != nil && res.Exception != "" {
+			return res, err
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// Broadcasts tx, which must already be signed, waiting for it to be committed. It is counted before it is sent since
+// a broadcast that fails may still have reached the node. A result is only returned once the node has accepted the
+// transaction even if we then fail to confirm it, without one the broadcast is recorded as unsettled.
+func broadcastSigned(do *definitions.Do, chainID string, nodeClient client.NodeClient, keyClient keys.KeyClient,
+	tx txs.Tx) (*rpc.TxResult, error) {
+
+	do.BroadcastCount++
+	res, err := rpc.SignAndBroadcast(chainID, nodeClient, keyClient, tx, false, true, true)
+	if res != nil {
+		do.BroadcastTxHashes = append(do.BroadcastTxHashes, fmt.Sprintf("%X", res.Hash))
+	} else if err != nil {
+		unsettled := definitions.UnsettledBroadcast{
+			TxHash:    fmt.Sprintf("%X", txs.TxHash(chainID, tx)),
+			Sequences: make(map[string]uint64),
+		}
+		for _, input := range txs.Inputs(tx) {
+			unsettled.Sequences[input.Address.String()] = input.Sequence
+		}
+		do.UnsettledBroadcasts = append(do.UnsettledBroadcasts, unsettled)
+	}
+	return res, err
+}
+
+func (sim *simulation) simulate(chainID string, nodeClient client.NodeClient, tx txs.Tx) (*rpc.TxResult, error) {
+	sim.Lock()
+	defer sim.Unlock()
+
+	res := new(rpc.TxResult)
+	switch tx := tx.(type) {
+	case *txs.SendTx:
+		for _, input := range tx.Inputs {
+			err := sim.spend(nodeClient, input, permission.Send)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, output := range tx.Outputs {
+			sim.accounts[output.Address] = true
+		}
+
+	case *txs.CallTx:
+		perm := permission.Call
+		if tx.Address == nil {
+			perm = permission.CreateContract
+		}
+		err := sim.spend(nodeClient, tx.Input, perm)
+		if err != nil {
+			return nil, err
+		}
+		if tx.Address == nil {
+			// Running the init code checks the constructor does not throw and gives us the code that would be stored
+			_, _, err = nodeClient.QueryContractCode(tx.Input.Address, tx.Data, nil)
+			if err != nil {
+				return nil, fmt.Errorf("simulated contract creation failed: %v", err)
+			}
+			address := acm.NewContractAddress(tx.Input.Address, tx.Input.Sequence)
+			sim.contracts[address] = true
+			res.Address = &address
+		} else {
+			if sim.contracts[*tx.Address] {
+				return nil, ErrUnverifiable{fmt.Sprintf("calls contract %s which was only deployed in this dry run",
+					*tx.Address)}
+			}
+			res.Return, _, err = nodeClient.QueryContract(tx.Input.Address, *tx.Address, tx.Data)
+			if err != nil {
+				return nil, fmt.Errorf("simulated call to %s failed: %v", *tx.Address, err)
+			}
+			if tx.Input.Amount > 0 {
+				sim.accounts[*tx.Address] = true
+			}
+		}
+
+	case *txs.NameTx:
+		err := sim.spend(nodeClient, tx.Input, permission.Name)
+		if err != nil {
+			return nil, err
+		}
+		sim.names[tx.Name] = true
+
+	case *txs.PermissionsTx:
+		err := sim.spend(nodeClient, tx.Input, permission.Root)
+		if err != nil {
+			return nil, err
+		}
+		if tx.PermArgs.Address != nil {
+			sim.accounts[*tx.PermArgs.Address] = true
+		}
+
+	default:
+		return nil, fmt.Errorf("transactions of type %T cannot be simulated in a dry run", tx)
+	}
+
+	res.Hash = txs.TxHash(chainID, tx)
+	log.WithField("=>", fmt.Sprintf("%X", res.Hash)).Info("Simulated Transaction")
+	return res, nil
+}
+
+// Checks the input account could have sent the transaction given everything simulated so far and gives the input
+// the sequence number it would have had. Must be called with lock held.
+func (sim *simulation) spend(nodeClient client.NodeClient, input *txs.TxInput, perm ptypes.PermFlag) error {
+	if sim.accounts[input.Address] {
+		return ErrUnverifiable{fmt.Sprintf("sends from account %s whose balance or permissions were changed "+
+			"earlier in this dry run", input.Address)}
+	}
+	account, err := nodeClient.GetAccount(input.Address)
+	if err != nil {
+		return err
+	}
+	global, err := nodeClient.GetAccount(permission.GlobalPermissionsAddress)
+	if err != nil {
+		return err
+	}
+	hasPerm, _ := account.Permissions().Base.Compose(global.Permissions().Base).Get(perm)
+	if !hasPerm {
+		return fmt.Errorf("account %s does not have %s permission", input.Address, permission.PermissionsString(perm))
+	}
+	spent := sim.spent[input.Address] + input.Amount
+	if spent > account.Balance() {
+		return fmt.Errorf("account %s has balance %v which is insufficient to cover the %v spent by this "+
+			"and earlier transactions in this dry run", input.Address, account.Balance(), spent)
+	}
+	sim.spent[input.Address] = spent
+	input.Sequence += sim.sequences[input.Address]
+	sim.sequences[input.Address]++
+	return nil
+}
+
+func (sim *simulation) checkAccount(address acm.Address) error {
+	sim.Lock()
+	defer sim.Unlock()
+	if sim.accounts[address] {
+		return ErrUnverifiable{fmt.Sprintf("queries account %s which was changed earlier in this dry run", address)}
+	}
+	if sim.contracts[address] {
+		return ErrUnverifiable{fmt.Sprintf("queries contract %s which was only deployed in this dry run", address)}
+	}
+	return nil
+}
+
+func (sim *simulation) checkName(name string) error {
+	sim.Lock()
+	defer sim.Unlock()
+	if sim.names[name] {
+		return ErrUnverifiable{fmt.Sprintf("queries name %s which was registered earlier in this dry run", name)}
+	}
+	return nil
+}
+
+var variableRegex = regexp.MustCompile(`\$([a-zA-Z0-9_]+)`)
+
+// Jobs that use the result of an unverifiable job cannot be verified either
+func (sim *simulation) checkDependencies(job *definitions.Job) error {
+	// Results are not part of the job definition so should not be matched
+	definition := *job
+	definition.JobResult = ""
+	definition.JobVars = nil
+	bs, err := json.Marshal(definition)
+	if err != nil {
+		return err
+	}
+	sim.Lock()
+	defer sim.Unlock()
+	for _, name := range job.DependsOn {
+		if _, ok := sim.unverifiable[name]; ok {
+			return ErrUnverifiable{fmt.Sprintf("depends on %s", name)}
+		}
+	}
+	for _, match := range variableRegex.FindAllStringSubmatch(string(bs), -1) {
+		if _, ok := sim.unverifiable[match[1]]; ok {
+			return ErrUnverifiable{fmt.Sprintf("uses the result of %s", match[1])}
+		}
+	}
+	return nil
+}
+
+func (sim *simulation) markUnverifiable(job *definitions.Job, err ErrUnverifiable) {
+	sim.Lock()
+	defer sim.Unlock()
+	sim.unverifiable[job.JobName] = err.Reason
+	log.WithField("=>", job.JobName).Warnf("Job %v", err)
+}
+
+func (sim *simulation) unverifiableJobs() map[string]string {
+	sim.Lock()
+	defer sim.Unlock()
+	jobs := make(map[string]string, len(sim.unverifiable))
+	for name, reason := range sim.unverifiable {
+		jobs[name] = reason
+	}
+	return jobs
+}
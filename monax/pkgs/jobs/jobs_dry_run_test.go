@@ -0,0 +1,179 @@
+package jobs
+
+import (
+	"sync"
+	"testing"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/permission"
+	ptypes "github.com/hyperledger/burrow/permission/types"
+	"github.com/hyperledger/burrow/txs"
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+type testNodeClient struct {
+	client.NodeClient
+	accounts map[acm.Address]acm.Account
+}
+
+func (tnc *testNodeClient) GetAccount(address acm.Address) (acm.Account, error) {
+	return tnc.accounts[address], nil
+}
+
+func (tnc *testNodeClient) QueryContract(callerAddress, calleeAddress acm.Address,
+	data []byte) ([]byte, uint64, error) {
+	return []byte{1}, 0, nil
+}
+
+func (tnc *testNodeClient) QueryContractCode(address acm.Address, code, data []byte) ([]byte, uint64, error) {
+	return code, 0, nil
+}
+
+func newTestNodeClient(balance uint64, perms ptypes.PermFlag) (*testNodeClient, acm.Address) {
+	address := acm.Address{1, 2, 3}
+	return &testNodeClient{
+		accounts: map[acm.Address]acm.Account{
+			address: acm.ConcreteAccount{
+				Address:     address,
+				Balance:     balance,
+				Permissions: ptypes.AccountPermissions{Base: ptypes.BasePermissions{Perms: perms, SetBit: perms}},
+			}.Account(),
+			permission.GlobalPermissionsAddress: acm.ConcreteAccount{
+				Address: permission.GlobalPermissionsAddress,
+			}.Account(),
+		},
+	}, address
+}
+
+func TestSimulateSequencesAndBalance(t *testing.T) {
+	nodeClient, address := newTestNodeClient(10, permission.Send)
+	sim := newSimulation()
+	destination := acm.Address{4, 5, 6}
+	send := func() (*txs.SendTx, error) {
+		tx := &txs.SendTx{
+			Inputs:  []*txs.TxInput{{Address: address, Amount: 4, Sequence: 1}},
+			Outputs: []*txs.TxOutput{{Address: destination, Amount: 4}},
+		}
+		_, err := sim.simulate("test-chain", nodeClient, tx)
+		return tx, err
+	}
+
+	tx, err := send()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Inputs[0].Sequence != 1 {
+		t.Errorf("expected first simulated sequence 1 but got %v", tx.Inputs[0].Sequence)
+	}
+	tx, err = send()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Inputs[0].Sequence != 2 {
+		t.Errorf("expected second simulated sequence 2 but got %v", tx.Inputs[0].Sequence)
+	}
+	_, err = send()
+	if err == nil {
+		t.Errorf("third send should exceed balance")
+	}
+	if _, ok := sim.checkAccount(destination).(ErrUnverifiable); !ok {
+		t.Errorf("account receiving a simulated send should be unverifiable")
+	}
+}
+
+func TestSimulateDeployAndCall(t *testing.T) {
+	nodeClient, address := newTestNodeClient(10, permission.CreateContract|permission.Call)
+	sim := newSimulation()
+
+	deploy := &txs.CallTx{Input: &txs.TxInput{Address: address, Sequence: 3}, Data: []byte{0x60}}
+	res, err := sim.simulate("test-chain", nodeClient, deploy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Address == nil || *res.Address != acm.NewContractAddress(address, 3) {
+		t.Fatalf("expected address of simulated contract but got %v", res.Address)
+	}
+
+	call := &txs.CallTx{Input: &txs.TxInput{Address: address, Sequence: 3}, Address: res.Address}
+	_, err = sim.simulate("test-chain", nodeClient, call)
+	if _, ok := err.(ErrUnverifiable); !ok {
+		t.Errorf("call to simulated contract should be unverifiable but got: %v", err)
+	}
+
+	existing := acm.Address{9}
+	call = &txs.CallTx{Input: &txs.TxInput{Address: address, Sequence: 3}, Address: &existing}
+	res, err = sim.simulate("test-chain", nodeClient, call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The unverifiable call would still have been sent so it takes up a sequence number
+	if call.Input.Sequence != 5 {
+		t.Errorf("expected call to follow simulated deploy and call with sequence 5 but got %v", call.Input.Sequence)
+	}
+	if len(res.Return) != 1 {
+		t.Errorf("expected return from simulated call")
+	}
+}
+
+func TestSimulatePermissionDenied(t *testing.T) {
+	nodeClient, address := newTestNodeClient(10, permission.Send)
+	_, err := newSimulation().simulate("test-chain", nodeClient, &txs.NameTx{
+		Input: &txs.TxInput{Address: address, Amount: 1, Sequence: 1},
+		Name:  "foo",
+	})
+	if err == nil {
+		t.Errorf("name registration without name permission should fail")
+	}
+}
+
+func TestDryRunDependencies(t *testing.T) {
+	do := definitions.NowDo()
+	do.DryRun = true
+	do.DryRunSimulation = newSimulation()
+	do.Package = &definitions.Package{}
+
+	simulationOf(do).markUnverifiable(&definitions.Job{JobName: "queryName"}, ErrUnverifiable{"queries a name"})
+	job := &definitions.Job{JobName: "assertName", Assert: &definitions.Assert{
+		Key: "$queryName", Relation: "eq", Value: "foo"}}
+	err := runJob(job, do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := simulationOf(do).unverifiableJobs()["assertName"]; !ok {
+		t.Errorf("job using result of an unverifiable job should be unverifiable")
+	}
+}
+
+// The sub-jobs of a parallel group simulate into the simulation of the run they belong to while other runs keep their
+// own
+func TestDryRunSimulationSharedBySubJobs(t *testing.T) {
+	do := definitions.NowDo()
+	do.DryRun = true
+	do.DryRunSimulation = newSimulation()
+	do.Package = &definitions.Package{}
+	other := definitions.NowDo()
+	other.DryRun = true
+	other.DryRunSimulation = newSimulation()
+
+	names := []string{"first", "second", "third"}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(subDo *definitions.Do, name string) {
+			defer wg.Done()
+			simulationOf(subDo).markUnverifiable(&definitions.Job{JobName: name}, ErrUnverifiable{"queries a name"})
+		}(subJobDo(do, nil), name)
+	}
+	wg.Wait()
+
+	unverifiable := simulationOf(do).unverifiableJobs()
+	for _, name := range names {
+		if _, ok := unverifiable[name]; !ok {
+			t.Errorf("sub-job %s should be unverifiable in the run it belongs to", name)
+		}
+	}
+	if len(simulationOf(other).unverifiableJobs()) != 0 {
+		t.Errorf("jobs of one run should not be unverifiable in another")
+	}
+}
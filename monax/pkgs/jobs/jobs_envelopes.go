@@ -0,0 +1,220 @@
+package jobs
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/client/rpc"
+	"github.com/hyperledger/burrow/execution"
+	"github.com/hyperledger/burrow/keys"
+	"github.com/hyperledger/burrow/logging/loggers"
+	"github.com/hyperledger/burrow/txs"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+// A transaction written by --sign-only with the signatures it has been given so far, for the rest of the accounts it
+// requires signatures from to sign and broadcast with --broadcast
+type Envelope struct {
+	ChainID string `json:"chain_id"`
+	// Hex of the go-wire encoding of the transaction
+	Tx      string        `json:"tx"`
+	Signed  []acm.Address `json:"signed"`
+	Missing []acm.Address `json:"missing"`
+}
+
+var envelopeCodec = txs.NewGoWireCodec()
+
+func newEnvelope(chainID string, tx txs.Tx, status *execution.SignatureStatus) (*Envelope, error) {
+	txBytes, err := envelopeCodec.EncodeTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		ChainID: chainID,
+		Tx:      hex.EncodeToString(txBytes),
+		Signed:  status.Signed,
+		Missing: status.Missing,
+	}, nil
+}
+
+func (envelope *Envelope) decodeTx() (txs.Tx, error) {
+	txBytes, err := hex.DecodeString(envelope.Tx)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode transaction hex: %v", err)
+	}
+	return envelopeCodec.DecodeTx(txBytes)
+}
+
+// ReadEnvelopes reads the transactions written to file by --sign-only, there are none if the file does not exist
+func ReadEnvelopes(file string) ([]*Envelope, error) {
+	bs, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var envelopes []*Envelope
+	if err = json.Unmarshal(bs, &envelopes); err != nil {
+		return nil, fmt.Errorf("could not read signed transactions from %s: %v", file, err)
+	}
+	return envelopes, nil
+}
+
+func WriteEnvelopes(envelopes []*Envelope, file string) error {
+	bs, err := json.MarshalIndent(envelopes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, bs, 0644)
+}
+
+// Adds the signatures the keys server holds keys for to tx and appends it to the --sign-only file rather than
+// broadcasting it. No job is run against the chain so each account can only make one transaction in a run.
+func signOnly(do *definitions.Do, chainID string, nodeClient client.NodeClient, keyClient keys.KeyClient,
+	tx txs.Tx) (*rpc.TxResult, error) {
+
+	if _, err := execution.AddSignatures(keyClient, chainID, tx); err != nil {
+		return nil, err
+	}
+	status, err := execution.VerifySignatures(nodeClient, chainID, tx)
+	if err != nil {
+		return nil, err
+	}
+	envelopes, err := ReadEnvelopes(do.SignOnly)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkSequencesUnused(chainID, tx, envelopes); err != nil {
+		return nil, fmt.Errorf("%v in %s, each account can only make one transaction when signing only", err,
+			do.SignOnly)
+	}
+	envelope, err := newEnvelope(chainID, tx, status)
+	if err != nil {
+		return nil, err
+	}
+	if err = WriteEnvelopes(append(envelopes, envelope), do.SignOnly); err != nil {
+		return nil, err
+	}
+	logSignatures(txs.TxHash(chainID, tx), status)
+
+	res := &rpc.TxResult{Hash: txs.TxHash(chainID, tx)}
+	if callTx, ok := tx.(*txs.CallTx); ok && callTx.Address == nil {
+		address := acm.NewContractAddress(callTx.Input.Address, callTx.Input.Sequence)
+		res.Address = &address
+	}
+	return res, nil
+}
+
+// Returns an error if an input of tx uses the same sequence for its account as one of the envelopes
+func checkSequencesUnused(chainID string, tx txs.Tx, envelopes []*Envelope) error {
+	for _, envelope := range envelopes {
+		if envelope.ChainID != chainID {
+			continue
+		}
+		other, err := envelope.decodeTx()
+		if err != nil {
+			return err
+		}
+		for _, input := range txInputs(tx) {
+			for _, otherInput := range txInputs(other) {
+				if input.Address == otherInput.Address && input.Sequence == otherInput.Sequence {
+					return fmt.Errorf("sequence %d of %s is already used by transaction %X", input.Sequence,
+						input.Address, txs.TxHash(chainID, other))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func txInputs(tx txs.Tx) []*txs.TxInput {
+	switch tx := tx.(type) {
+	case *txs.SendTx:
+		return tx.Inputs
+	case *txs.BondTx:
+		return tx.Inputs
+	case *txs.CallTx:
+		return []*txs.TxInput{tx.Input}
+	case *txs.NameTx:
+		return []*txs.TxInput{tx.Input}
+	case *txs.PermissionsTx:
+		return []*txs.TxInput{tx.Input}
+	}
+	return nil
+}
+
+// BroadcastEnvelopes adds the signatures the keys server holds keys for to each transaction in the --broadcast file
+// and broadcasts it, failing if it still lacks signatures. With --sign-only the transactions are instead written there
+// for their remaining signers.
+func BroadcastEnvelopes(do *definitions.Do) error {
+	envelopes, err := ReadEnvelopes(do.Broadcast)
+	if err != nil {
+		return err
+	}
+	if len(envelopes) == 0 {
+		return fmt.Errorf("no signed transactions to broadcast found in %s", do.Broadcast)
+	}
+	nodeClient := util.NodeClient(do)
+	keyClient := keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger())
+	_, chainID, _, err := nodeClient.ChainId()
+	if err != nil {
+		return err
+	}
+
+	var signed []*Envelope
+	for _, envelope := range envelopes {
+		if envelope.ChainID != chainID {
+			return fmt.Errorf("transaction in %s was made for chain %s but is being sent to chain %s",
+				do.Broadcast, envelope.ChainID, chainID)
+		}
+		tx, err := envelope.decodeTx()
+		if err != nil {
+			return err
+		}
+		txHash := txs.TxHash(chainID, tx)
+		if _, err = execution.AddSignatures(keyClient, chainID, tx); err != nil {
+			return err
+		}
+		status, err := execution.VerifySignatures(nodeClient, chainID, tx)
+		if err != nil {
+			return fmt.Errorf("transaction %X: %v", txHash, err)
+		}
+		logSignatures(txHash, status)
+		if do.SignOnly != "" {
+			envelope, err = newEnvelope(chainID, tx, status)
+			if err != nil {
+				return err
+			}
+			signed = append(signed, envelope)
+			continue
+		}
+		if !status.Complete() {
+			return fmt.Errorf("transaction %X cannot be broadcast since it is missing signatures from %v", txHash,
+				status.Missing)
+		}
+		res, err := broadcastSigned(do, chainID, nodeClient, keyClient, tx)
+		if err := util.ReadTxSignAndBroadcast(res, err); err != nil {
+			return err
+		}
+	}
+	if do.SignOnly != "" {
+		log.Warn(fmt.Sprintf("Writing signed transactions to [%s]", do.SignOnly))
+		return WriteEnvelopes(signed, do.SignOnly)
+	}
+	return nil
+}
+
+func logSignatures(txHash []byte, status *execution.SignatureStatus) {
+	log.WithFields(log.Fields{
+		"tx":      fmt.Sprintf("%X", txHash),
+		"signed":  status.Signed,
+		"missing": status.Missing,
+	}).Warn("Transaction Signatures")
+}
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/execution"
+	"github.com/hyperledger/burrow/txs"
+)
+
+func TestEnvelopes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envelopes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "signed.json")
+
+	envelopes, err := ReadEnvelopes(file)
+	if err != nil || len(envelopes) != 0 {
+		t.Fatalf("expected no envelopes before any are written but got %v, %v", envelopes, err)
+	}
+
+	signer := acm.GeneratePrivateAccountFromSecret("signer")
+	other := acm.GeneratePrivateAccountFromSecret("other")
+	tx := txs.NewSendTx()
+	tx.Inputs = append(tx.Inputs,
+		&txs.TxInput{Address: signer.Address(), Amount: 1, Sequence: 4},
+		&txs.TxInput{Address: other.Address(), Amount: 1, Sequence: 2})
+	tx.Outputs = append(tx.Outputs, &txs.TxOutput{Address: acm.ZeroAddress, Amount: 2})
+	if err = tx.SignInput("chain", 0, signer); err != nil {
+		t.Fatal(err)
+	}
+	status := &execution.SignatureStatus{Signed: []acm.Address{signer.Address()}, Missing: []acm.Address{other.Address()}}
+	envelope, err := newEnvelope("chain", tx, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = WriteEnvelopes([]*Envelope{envelope}, file); err != nil {
+		t.Fatal(err)
+	}
+
+	envelopes, err = ReadEnvelopes(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envelopes) != 1 || envelopes[0].Missing[0] != other.Address() {
+		t.Fatalf("unexpected envelopes %v", envelopes)
+	}
+	decoded, err := envelopes[0].decodeTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input := decoded.(*txs.SendTx).Inputs[0]; !input.PubKey.VerifyBytes(acm.SignBytes("chain", decoded),
+		input.Signature) {
+		t.Errorf("expected signature to survive the envelope")
+	}
+
+	reused := txs.NewSendTx()
+	reused.Inputs = append(reused.Inputs, &txs.TxInput{Address: other.Address(), Amount: 3, Sequence: 2})
+	err = checkSequencesUnused("chain", reused, envelopes)
+	if err == nil || !strings.Contains(err.Error(), "sequence 2 of "+other.Address().String()+" is already used") {
+		t.Errorf("expected sequence to be reported as used but got %v", err)
+	}
+	if err = checkSequencesUnused("other chain", reused, envelopes); err != nil {
+		t.Errorf("envelopes for other chains should be ignored but got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/execution/evm/abi"
+	evm_events "github.com/hyperledger/burrow/execution/evm/events"
+	"github.com/hyperledger/burrow/rpc"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+// How long a wait-event job waits for its event when it does not set a timeout
+const DefaultWaitEventTimeout = time.Minute
+
+// ErrEventTimeout is returned by a wait-event job when the event it is waiting for has not been emitted in time
+type ErrEventTimeout struct {
+	Event       string
+	Destination string
+	Timeout     time.Duration
+	// Latest block height seen when we gave up
+	Height uint64
+}
+
+func (err ErrEventTimeout) Error() string {
+	return fmt.Sprintf("timed out after %v waiting for event %s from %s, last block height seen was %v",
+		err.Timeout, err.Event, err.Destination, err.Height)
+}
+
+func WaitEventJob(wait *definitions.WaitEvent, do *definitions.Do) (string, []*definitions.Variable, error) {
+	// Preprocess variables
+	wait.Destination, _ = util.PreProcess(wait.Destination, do)
+	wait.Event, _ = util.PreProcess(wait.Event, do)
+	wait.Timeout, _ = util.PreProcess(wait.Timeout, do)
+	wait.ABI, _ = util.PreProcess(wait.ABI, do)
+	for name, value := range wait.Filters {
+		wait.Filters[name], _ = util.PreProcess(value, do)
+	}
+
+	address, err := acm.AddressFromHexString(wait.Destination)
+	if err != nil {
+		return "", nil, err
+	}
+	timeout := DefaultWaitEventTimeout
+	if wait.Timeout != "" {
+		timeout, err = time.ParseDuration(wait.Timeout)
+		if err != nil {
+			return "", nil, fmt.Errorf("could not parse timeout of wait-event job: %v", err)
+		}
+	}
+	abiLocation := wait.ABI
+	if abiLocation == "" {
+		abiLocation = wait.Destination
+	}
+	abiSpec, err := util.ReadAbi(do.ABIPath, abiLocation)
+	if err != nil {
+		return "", nil, err
+	}
+	spec, err := findEvent(abiSpec, wait.Event)
+	if err != nil {
+		return "", nil, err
+	}
+	if do.DryRun {
+		return "", nil, ErrUnverifiable{fmt.Sprintf("waits for event %s which nothing simulated can emit", wait.Event)}
+	}
+
+	log.WithFields(log.Fields{
+		"event":       spec.Signature(),
+		"destination": wait.Destination,
+		"timeout":     timeout,
+	}).Info("Waiting for Event")
+
+	nodeClient := util.NodeClient(do)
+	wsClient, err := nodeClient.DeriveWebsocketClient()
+	if err != nil {
+		return "", nil, err
+	}
+	defer wsClient.Close()
+
+	// Also given up on once the job itself is cancelled
+	ctx, cancel := context.WithTimeout(util.Context(do), timeout)
+	defer cancel()
+	var decoded *abi.DecodedLog
+	var lastHeight uint64
+	_, err = wsClient.WaitForEvent(ctx, evm_events.EventStringLogEvent(address),
+		func(resultEvent *rpc.ResultEvent) (bool, error) {
+			if resultEvent.Resubscribed != nil {
+				log.WithField("from height", resultEvent.Resubscribed.FromHeight).
+					Warn("Reconnected to chain, events emitted while disconnected may have been missed")
+				return false, nil
+			}
+			eventDataLog := resultEvent.EventDataLog
+			if eventDataLog == nil {
+				return false, nil
+			}
+			if eventDataLog.Height > lastHeight {
+				lastHeight = eventDataLog.Height
+			}
+			if len(eventDataLog.Topics) == 0 || eventDataLog.Topics[0] != spec.ID {
+				return false, nil
+			}
+			decodedLog, err := spec.DecodeLog(eventDataLog.Topics, eventDataLog.Data)
+			if err != nil {
+				return false, err
+			}
+			matches, err := eventMatches(decodedLog, wait.Filters)
+			if err != nil || !matches {
+				return false, err
+			}
+			decoded = decodedLog
+			return true, nil
+		})
+	if err == context.DeadlineExceeded && util.Context(do).Err() == nil {
+		if height, err := util.GetBlockHeight(do); err == nil && height > lastHeight {
+			lastHeight = height
+		}
+		return "", nil, ErrEventTimeout{
+			Event:       spec.Signature(),
+			Destination: wait.Destination,
+			Timeout:     timeout,
+			Height:      lastHeight,
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	wait.Variables = eventVariables(spec, decoded)
+	result := util.GetReturnValue(wait.Variables)
+	log.WithField("=>", result).Warn("Event Received")
+	return result, wait.Variables, nil
+}
+
+// Finds the event called name, or with signature name, in the JSON ABI
+func findEvent(abiSpec, name string) (*abi.EventSpec, error) {
+	registry := abi.NewEventRegistry()
+	err := registry.AddABI([]byte(abiSpec))
+	if err != nil {
+		return nil, err
+	}
+	signature := strings.Replace(name, " ", "", -1)
+	if i := strings.Index(signature, "("); i >= 0 {
+		for _, spec := range registry.EventsByName(signature[:i]) {
+			if spec.Signature() == signature {
+				return spec, nil
+			}
+		}
+		return nil, fmt.Errorf("could not find event with signature %s in ABI", name)
+	}
+	specs := registry.EventsByName(name)
+	switch len(specs) {
+	case 0:
+		return nil, fmt.Errorf("could not find event %s in ABI", name)
+	case 1:
+		return specs[0], nil
+	default:
+		signatures := make([]string, len(specs))
+		for i, spec := range specs {
+			signatures[i] = spec.Signature()
+		}
+		return nil, fmt.Errorf("ABI has more than one event called %s, use one of the signatures %s to choose "+
+			"between them", name, strings.Join(signatures, ", "))
+	}
+}
+
+// Checks the decoded event has the parameter values given by filters
+func eventMatches(decoded *abi.DecodedLog, filters map[string]string) (bool, error) {
+	for name, want := range filters {
+		field, ok := decoded.Fields[name]
+		if !ok {
+			return false, fmt.Errorf("cannot filter on %s since event %s has no parameter of that name",
+				name, decoded.Event)
+		}
+		if field.Hashed {
+			return false, fmt.Errorf("cannot filter on %s since it is indexed so only the hash of its value is "+
+				"available", name)
+		}
+		matches, err := eventFieldMatches(string(field.TypeName), field.Value, want)
+		if err != nil {
+			return false, fmt.Errorf("cannot filter on %s: %v", name, err)
+		}
+		if !matches {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func eventFieldMatches(typeName string, value interface{}, want string) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		b, err := strconv.ParseBool(want)
+		if err != nil {
+			return false, fmt.Errorf("%s is not a bool", want)
+		}
+		return b == v, nil
+	case string:
+		switch {
+		case strings.HasPrefix(typeName, "int") || strings.HasPrefix(typeName, "uint"):
+			n, ok := parseInteger(v)
+			if !ok {
+				return false, fmt.Errorf("could not read integer %s", v)
+			}
+			w, ok := parseInteger(want)
+			if !ok {
+				return false, fmt.Errorf("%s is not an integer", want)
+			}
+			return n.Cmp(w) == 0, nil
+		case typeName == "address" || strings.HasPrefix(typeName, "bytes"):
+			return normaliseAddress(v) == normaliseAddress(want), nil
+		}
+		return v == want, nil
+	default:
+		return false, fmt.Errorf("filtering on %s values is not supported", typeName)
+	}
+}
+
+// Returns the event parameters in the order they are declared
+func eventVariables(spec *abi.EventSpec, decoded *abi.DecodedLog) []*definitions.Variable {
+	vars := make([]*definitions.Variable, len(spec.Inputs))
+	for i, input := range spec.Inputs {
+		name := input.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		field := decoded.Fields[name]
+		vars[i] = &definitions.Variable{
+			Name:  name,
+			Value: formatEventValue(field.Value),
+			Type:  string(field.TypeName),
+		}
+	}
+	return vars
+}
+
+func formatEventValue(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		elements := make([]string, len(v))
+		for i, element := range v {
+			elements[i] = formatEventValue(element)
+		}
+		return "[" + strings.Join(elements, ",") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
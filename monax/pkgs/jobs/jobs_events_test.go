@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/burrow/binary"
+)
+
+const eventsABI = `[
+{"type":"event","name":"Registered","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"name","type":"string","indexed":true},{"name":"amount","type":"int256","indexed":false},{"name":"","type":"bool","indexed":false},{"name":"ids","type":"uint8[]","indexed":false}]},
+{"type":"event","name":"Moved","anonymous":false,"inputs":[{"name":"to","type":"address","indexed":false}]},
+{"type":"event","name":"Moved","anonymous":false,"inputs":[{"name":"to","type":"address","indexed":false},{"name":"by","type":"uint256","indexed":false}]}
+]`
+
+// Concatenates 32 byte words given in hex, left padding any shorter than 64 characters
+func hexWords(ws ...string) []byte {
+	var bs []byte
+	for _, w := range ws {
+		word, err := hex.DecodeString(strings.Repeat("0", 64-len(w)) + w)
+		if err != nil {
+			panic(err)
+		}
+		bs = append(bs, word...)
+	}
+	return bs
+}
+
+func word(hexWord string) binary.Word256 {
+	return binary.LeftPadWord256(hexWords(hexWord))
+}
+
+func TestFindEvent(t *testing.T) {
+	spec, err := findEvent(eventsABI, "Registered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Signature() != "Registered(address,string,int256,bool,uint8[])" {
+		t.Errorf("found unexpected event %s", spec.Signature())
+	}
+	if _, err := findEvent(eventsABI, "Moved"); err == nil {
+		t.Errorf("expected finding overloaded event by name to fail")
+	}
+	spec, err = findEvent(eventsABI, "Moved(address, uint256)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Inputs) != 2 {
+		t.Errorf("found the wrong overload of Moved: %s", spec.Signature())
+	}
+	if _, err := findEvent(eventsABI, "Removed"); err == nil {
+		t.Errorf("expected finding missing event to fail")
+	}
+}
+
+func TestEventMatches(t *testing.T) {
+	spec, err := findEvent(eventsABI, "Registered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	topics := []binary.Word256{spec.ID, word("ff"), word("abcd")}
+	data := hexWords(strings.Repeat("f", 64), "1", "60", "2", "1", "2")
+	decoded, err := spec.DecodeLog(topics, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		filters map[string]string
+		matches bool
+	}{
+		{nil, true},
+		{map[string]string{"owner": "0x00000000000000000000000000000000000000fF"}, true},
+		{map[string]string{"owner": "00000000000000000000000000000000000000FE"}, false},
+		{map[string]string{"amount": "-1", "3": "true"}, true},
+		{map[string]string{"owner": "0xff"}, false},
+		{map[string]string{"amount": "1"}, false},
+		{map[string]string{"3": "false"}, false},
+	} {
+		matches, err := eventMatches(decoded, test.filters)
+		if err != nil {
+			t.Errorf("could not match filters %v: %v", test.filters, err)
+		}
+		if matches != test.matches {
+			t.Errorf("expected matching filters %v to be %v", test.filters, test.matches)
+		}
+	}
+
+	for _, filters := range []map[string]string{
+		{"missing": "1"},
+		{"name": "marmot"},
+		{"ids": "[1,2]"},
+		{"amount": "marmot"},
+		{"3": "maybe"},
+	} {
+		if _, err := eventMatches(decoded, filters); err == nil {
+			t.Errorf("expected matching filters %v to fail", filters)
+		}
+	}
+
+	vars := eventVariables(spec, decoded)
+	expected := []struct{ name, value, typ string }{
+		{"owner", "00000000000000000000000000000000000000FF", "address"},
+		{"name", "000000000000000000000000000000000000000000000000000000000000ABCD", "string"},
+		{"amount", "-1", "int256"},
+		{"3", "true", "bool"},
+		{"ids", "[1,2]", "uint8[]"},
+	}
+	if len(vars) != len(expected) {
+		t.Fatalf("expected %v variables but got %v", len(expected), len(vars))
+	}
+	for i, v := range vars {
+		if v.Name != expected[i].name || v.Value != expected[i].value || v.Type != expected[i].typ {
+			t.Errorf("expected variable %v but got %s=%s (%s)", expected[i], v.Name, v.Value, v.Type)
+		}
+	}
+}
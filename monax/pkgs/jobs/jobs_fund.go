@@ -0,0 +1,233 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/client/rpc"
+	"github.com/hyperledger/burrow/keys"
+	"github.com/hyperledger/burrow/logging/loggers"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+// An account of a fund-accounts job as written to its manifest
+type FundedAccount struct {
+	// Name of the key generated for the account, empty for accounts given by address
+	Name    string      `json:"name,omitempty"`
+	Address acm.Address `json:"address"`
+	// Whether the account holds the amount, either because it was funded by this run or already did
+	Funded bool `json:"funded"`
+	// Hash of the transaction that funded the account, empty if it already held the amount
+	TxHash string `json:"tx_hash,omitempty"`
+}
+
+// FundAccountsJob sends amount from the faucet account to each account in turn, giving the transactions consecutive
+// sequence numbers fetched once from the chain. The result is the array of the addresses and the address of each
+// generated key can be used as $job.name_1 and so on.
+func FundAccountsJob(fund *definitions.FundAccounts, jobName string, do *definitions.Do) (string,
+	[]*definitions.Variable, error) {
+
+	// Process Variables
+	fund.Source, _ = util.PreProcess(fund.Source, do)
+	resolved, err := resolveAmount(fund.Amount, do)
+	if err != nil {
+		return "", nil, err
+	}
+	fund.Amount = resolved
+	fund.Count, _ = util.PreProcess(fund.Count, do)
+	fund.Name, _ = util.PreProcess(fund.Name, do)
+	fund.KeyType, _ = util.PreProcess(fund.KeyType, do)
+	fund.Manifest, _ = util.PreProcess(fund.Manifest, do)
+	for i := range fund.Addresses {
+		fund.Addresses[i], _ = util.PreProcess(fund.Addresses[i], do)
+	}
+
+	// Use Default
+	fund.Source = useDefault(fund.Source, do.Package.Account)
+	fund.Name = useDefault(fund.Name, jobName)
+	fund.KeyType = useDefault(fund.KeyType, keys.KeyTypeDefault.String())
+
+	amount, err := strconv.ParseUint(fund.Amount, 10, 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("amount for a fund-accounts job must be a positive integer but got '%s'",
+			fund.Amount)
+	}
+	count := 0
+	if fund.Count != "" {
+		count, err = strconv.Atoi(fund.Count)
+		if err != nil || count < 0 {
+			return "", nil, fmt.Errorf("count for a fund-accounts job must be a non-negative integer but got '%s'",
+				fund.Count)
+		}
+	}
+	if count == 0 && len(fund.Addresses) == 0 {
+		return "", nil, fmt.Errorf("a fund-accounts job must be given a count of accounts to generate or " +
+			"addresses to fund")
+	}
+
+	keyClient := keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger())
+	accounts, err := fundAccountsToFund(keyClient, fund.Addresses, fund.Name, keys.KeyType(fund.KeyType), count,
+		do.DryRun)
+	if err != nil {
+		return "", nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"source":   fund.Source,
+		"accounts": len(accounts),
+		"amount":   fund.Amount,
+	}).Info("Funding Accounts")
+
+	nodeClient := util.NodeClient(do)
+	err = fundAccounts(do, nodeClient, keyClient, fund.Source, amount, accounts)
+	if fund.Manifest != "" {
+		if writeErr := writeFundedAccounts(accounts, fund.Manifest); writeErr != nil {
+			log.WithError(writeErr).Warn("Could not write fund-accounts manifest")
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	addresses := make([]string, len(accounts))
+	var vars []*definitions.Variable
+	for i, account := range accounts {
+		addresses[i] = account.Address.String()
+		if account.Name != "" {
+			vars = append(vars, &definitions.Variable{Name: account.Name, Value: addresses[i]})
+		}
+	}
+	return fmt.Sprintf("[%s]", strings.Join(addresses, ",")), vars, nil
+}
+
+// Returns the accounts given by address followed by those of the keys named prefix_1 to prefix_count, generating
+// those that do not yet exist (which cannot be done in a dry run)
+func fundAccountsToFund(keyClient keys.KeyClient, addresses []string, prefix string, keyType keys.KeyType, count int,
+	dryRun bool) ([]*FundedAccount, error) {
+
+	var accounts []*FundedAccount
+	for _, addr := range addresses {
+		address, err := acm.AddressFromHexString(addr)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse address %s to fund: %v", addr, err)
+		}
+		accounts = append(accounts, &FundedAccount{Address: address})
+	}
+	for i := 1; i <= count; i++ {
+		name := fmt.Sprintf("%s_%d", prefix, i)
+		address, err := keyClient.Address(name)
+		if err != nil {
+			if dryRun {
+				return nil, ErrUnverifiable{fmt.Sprintf("generates key %s which is not made in a dry run", name)}
+			}
+			address, err = keyClient.Generate(name, keyType)
+			if err != nil {
+				return nil, fmt.Errorf("could not generate key %s: %v", name, err)
+			}
+			log.WithFields(log.Fields{
+				"name":    name,
+				"address": address,
+			}).Info("Generated Key")
+		}
+		accounts = append(accounts, &FundedAccount{Name: name, Address: address})
+	}
+	return accounts, nil
+}
+
+// Sends amount to each account that does not already hold it, marking those that then do as funded. On failure the
+// error names the accounts that were funded and those that were not.
+func fundAccounts(do *definitions.Do, nodeClient client.NodeClient, keyClient keys.KeyClient, source string,
+	amount uint64, accounts []*FundedAccount) error {
+
+	_, _, _, height, _, err := nodeClient.Status()
+	if err != nil {
+		return err
+	}
+	var unfunded []*FundedAccount
+	for _, account := range accounts {
+		existing, _, err := nodeClient.GetAccountAtHeight(account.Address, height)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.Balance() >= amount {
+			log.WithField("=>", account.Address).Info("Account Already Funded")
+			account.Funded = true
+			continue
+		}
+		unfunded = append(unfunded, account)
+	}
+	if len(unfunded) == 0 {
+		return nil
+	}
+
+	// Simulated transactions are given their sequence numbers as they run
+	var sequence uint64
+	if !do.DryRun {
+		address, err := acm.AddressFromHexString(source)
+		if err != nil {
+			return fmt.Errorf("could not parse faucet address %s: %v", source, err)
+		}
+		faucet, err := nodeClient.GetAccount(address)
+		if err != nil {
+			return err
+		}
+		sequence = faucet.Sequence()
+	}
+
+	// Don't use pubKey if account override
+	publicKey := do.PublicKey
+	if source != do.Package.Account {
+		publicKey = ""
+	}
+	amountS := strconv.FormatUint(amount, 10)
+	for i, account := range unfunded {
+		var nonce string
+		if !do.DryRun {
+			nonce = strconv.FormatUint(sequence+uint64(i)+1, 10)
+		}
+		tx, err := rpc.Send(nodeClient, keyClient, publicKey, source, account.Address.String(), amountS, nonce)
+		if err == nil {
+			var res *rpc.TxResult
+			res, err = signAndBroadcast(do, nodeClient, keyClient, tx)
+			if err == nil {
+				err = util.ReadTxSignAndBroadcast(res, err)
+			}
+			if err == nil {
+				account.Funded = true
+				account.TxHash = fmt.Sprintf("%X", res.Hash)
+				continue
+			}
+		}
+		return fundingFailure(accounts, account, err)
+	}
+	return nil
+}
+
+func fundingFailure(accounts []*FundedAccount, failed *FundedAccount, err error) error {
+	var funded, unfunded []string
+	for _, account := range accounts {
+		if account.Funded {
+			funded = append(funded, account.Address.String())
+		} else {
+			unfunded = append(unfunded, account.Address.String())
+		}
+	}
+	return fmt.Errorf("could not fund account %s: %v; funded [%s] but not [%s], re-run the job to fund the rest",
+		failed.Address, err, strings.Join(funded, ","), strings.Join(unfunded, ","))
+}
+
+func writeFundedAccounts(accounts []*FundedAccount, file string) error {
+	bs, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	log.WithField("=>", file).Warn("Writing Funded Accounts")
+	return ioutil.WriteFile(file, bs, 0644)
+}
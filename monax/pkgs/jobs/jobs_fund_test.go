@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/keys"
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+type testKeyClient struct {
+	keys.KeyClient
+	named map[string]acm.Address
+}
+
+func (tkc *testKeyClient) Address(keyName string) (acm.Address, error) {
+	address, ok := tkc.named[keyName]
+	if !ok {
+		return acm.ZeroAddress, fmt.Errorf("no key named %s", keyName)
+	}
+	return address, nil
+}
+
+func (tkc *testKeyClient) Generate(keyName string, keyType keys.KeyType) (acm.Address, error) {
+	address := acm.Address{byte(len(tkc.named) + 1)}
+	tkc.named[keyName] = address
+	return address, nil
+}
+
+type testBalanceClient struct {
+	client.NodeClient
+	balances map[acm.Address]uint64
+}
+
+func (tbc *testBalanceClient) Status() ([]byte, []byte, []byte, uint64, int64, error) {
+	return nil, nil, nil, 10, 0, nil
+}
+
+func (tbc *testBalanceClient) GetAccountAtHeight(address acm.Address, height uint64) (acm.Account, uint64, error) {
+	balance, ok := tbc.balances[address]
+	if !ok {
+		return nil, height, nil
+	}
+	return acm.ConcreteAccount{Address: address, Balance: balance}.Account(), height, nil
+}
+
+func TestFundAccountsToFund(t *testing.T) {
+	given := acm.Address{9}
+	keyClient := &testKeyClient{named: map[string]acm.Address{"faucet_1": {1}}}
+
+	if _, err := fundAccountsToFund(keyClient, nil, "faucet", keys.KeyTypeDefault, 2, true); err == nil {
+		t.Errorf("generating a key should not be verifiable in a dry run")
+	}
+	accounts, err := fundAccountsToFund(keyClient, []string{given.String()}, "faucet", keys.KeyTypeDefault, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 3 || accounts[0].Address != given || accounts[0].Name != "" {
+		t.Fatalf("expected the given address followed by the generated keys but got %v", accounts)
+	}
+	// The existing key is reused so re-running the job funds the same accounts
+	if accounts[1].Name != "faucet_1" || accounts[1].Address != (acm.Address{1}) {
+		t.Errorf("expected existing key faucet_1 to be reused but got %v", accounts[1])
+	}
+	if accounts[2].Name != "faucet_2" || keyClient.named["faucet_2"] != accounts[2].Address {
+		t.Errorf("expected key faucet_2 to be generated but got %v", accounts[2])
+	}
+}
+
+func TestFundAccountsAlreadyFunded(t *testing.T) {
+	funded, poor := acm.Address{1}, acm.Address{2}
+	nodeClient := &testBalanceClient{balances: map[acm.Address]uint64{funded: 100, poor: 99}}
+	accounts := []*FundedAccount{{Address: funded}}
+	err := fundAccounts(&definitions.Do{Package: definitions.BlankPackage()}, nodeClient, nil, "", 100, accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !accounts[0].Funded || accounts[0].TxHash != "" {
+		t.Errorf("expected account holding the amount to be funded without a transaction but got %v", accounts[0])
+	}
+
+	accounts = append(accounts, &FundedAccount{Address: poor}, &FundedAccount{Address: acm.Address{3}})
+	err = fundingFailure(accounts, accounts[1], fmt.Errorf("mempool is full"))
+	expected := fmt.Sprintf("could not fund account %s: mempool is full; funded [%s] but not [%s,%s]", poor, funded,
+		poor, acm.Address{3})
+	if err == nil || !strings.HasPrefix(err.Error(), expected) {
+		t.Errorf("expected error naming funded and unfunded accounts but got %v", err)
+	}
+}
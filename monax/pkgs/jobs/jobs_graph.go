@@ -0,0 +1,263 @@
+package jobs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+// Returns the jobs ordered so that each runs after the jobs it depends on, otherwise keeping the order of the jobs
+// file. The sub-jobs of each parallel group are ordered amongst themselves in the same way, and a group runs after
+// any job outside it that one of its sub-jobs depends on. Unknown or ambiguous dependencies and cycles are errors.
+func OrderJobs(jobs []*definitions.Job) ([]*definitions.Job, error) {
+	deps, err := groupDependencies(jobs)
+	if err != nil {
+		return nil, err
+	}
+	order, err := topologicalOrder(jobs, deps)
+	if err != nil {
+		return nil, err
+	}
+	ordered := make([]*definitions.Job, len(order))
+	for i, index := range order {
+		job := jobs[index]
+		if job.Parallel != nil {
+			job.Parallel.Jobs, err = orderSubJobs(job.Parallel.Jobs)
+			if err != nil {
+				return nil, err
+			}
+		}
+		ordered[i] = job
+	}
+	return ordered, nil
+}
+
+// Returns the sub-jobs of a parallel group ordered after the sub-jobs they depend on
+func orderSubJobs(subJobs []*definitions.Job) ([]*definitions.Job, error) {
+	deps, err := siblingDependencies(subJobs)
+	if err != nil {
+		return nil, err
+	}
+	order, err := topologicalOrder(subJobs, deps)
+	if err != nil {
+		return nil, err
+	}
+	ordered := make([]*definitions.Job, len(order))
+	for i, index := range order {
+		ordered[i] = subJobs[index]
+	}
+	return ordered, nil
+}
+
+// Returns true if any job or sub-job declares a dependency
+func hasDependencies(jobs []*definitions.Job) bool {
+	for _, job := range jobs {
+		if len(job.DependsOn) > 0 {
+			return true
+		}
+		if job.Parallel != nil && hasDependencies(job.Parallel.Jobs) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the indices of the jobs each job depends on, a sub-job's dependencies outside its group being those of
+// the group
+func groupDependencies(jobs []*definitions.Job) ([][]int, error) {
+	// Sub-jobs are run as part of their group
+	owners := make(map[string][]int)
+	for i, job := range jobs {
+		owners[job.JobName] = append(owners[job.JobName], i)
+		if job.Parallel != nil {
+			for _, subJob := range job.Parallel.Jobs {
+				owners[subJob.JobName] = append(owners[subJob.JobName], i)
+			}
+		}
+	}
+	deps := make([][]int, len(jobs))
+	for i, job := range jobs {
+		dependents := []*definitions.Job{job}
+		if job.Parallel != nil {
+			dependents = append(dependents, job.Parallel.Jobs...)
+		}
+		for _, dependent := range dependents {
+			for _, name := range dependent.DependsOn {
+				owner, err := dependency(dependent, name, owners)
+				if err != nil {
+					return nil, err
+				}
+				if owner == i {
+					if dependent == job {
+						return nil, fmt.Errorf("job %s depends on itself", job.Describe())
+					}
+					// Dependencies between sub-jobs of the same group are handled by the group
+					continue
+				}
+				deps[i] = append(deps[i], owner)
+			}
+		}
+	}
+	return deps, nil
+}
+
+// Returns the indices of the sibling sub-jobs each sub-job of a parallel group depends on
+func siblingDependencies(subJobs []*definitions.Job) ([][]int, error) {
+	siblings := make(map[string][]int)
+	for i, subJob := range subJobs {
+		siblings[subJob.JobName] = append(siblings[subJob.JobName], i)
+	}
+	deps := make([][]int, len(subJobs))
+	for i, subJob := range subJobs {
+		for _, name := range subJob.DependsOn {
+			if _, ok := siblings[name]; !ok {
+				// Ordered before the group
+				continue
+			}
+			sibling, err := dependency(subJob, name, siblings)
+			if err != nil {
+				return nil, err
+			}
+			if sibling == i {
+				return nil, fmt.Errorf("job %s depends on itself", subJob.Describe())
+			}
+			deps[i] = append(deps[i], sibling)
+		}
+	}
+	return deps, nil
+}
+
+func dependency(job *definitions.Job, name string, indices map[string][]int) (int, error) {
+	switch matches := indices[name]; len(matches) {
+	case 0:
+		return 0, fmt.Errorf("job %s depends on %s but there is no job of that name", job.Describe(), name)
+	case 1:
+		return matches[0], nil
+	default:
+		// Distinct jobs of the same name within a group and its sub-jobs are ambiguous
+		for _, match := range matches[1:] {
+			if match != matches[0] {
+				return 0, fmt.Errorf("job %s depends on %s but more than one job has that name", job.Describe(),
+					name)
+			}
+		}
+		return matches[0], nil
+	}
+}
+
+// Orders the jobs after their dependencies, running the earliest job in the jobs file whose dependencies have run
+// next (Kahn's algorithm) so that jobs without dependencies keep their order
+func topologicalOrder(jobs []*definitions.Job, deps [][]int) ([]int, error) {
+	waiting := make([]int, len(jobs))
+	dependents := make([][]int, len(jobs))
+	for i, ds := range deps {
+		waiting[i] = len(ds)
+		for _, d := range ds {
+			dependents[d] = append(dependents[d], i)
+		}
+	}
+	done := make([]bool, len(jobs))
+	order := make([]int, 0, len(jobs))
+	for len(order) < len(jobs) {
+		next := -1
+		for i := range jobs {
+			if !done[i] && waiting[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return nil, fmt.Errorf("jobs depend on one another in a cycle: %s", describeCycle(jobs, deps, done))
+		}
+		done[next] = true
+		order = append(order, next)
+		for _, dependent := range dependents[next] {
+			waiting[dependent]--
+		}
+	}
+	return order, nil
+}
+
+// Follows dependencies from a job that is part of a cycle until one repeats
+func describeCycle(jobs []*definitions.Job, deps [][]int, done []bool) string {
+	start := -1
+	for i := range jobs {
+		if !done[i] {
+			start = i
+			break
+		}
+	}
+	position := make(map[int]int)
+	var path []int
+	for current := start; ; {
+		if p, ok := position[current]; ok {
+			path = append(path[p:], current)
+			break
+		}
+		position[current] = len(path)
+		path = append(path, current)
+		for _, d := range deps[current] {
+			if !done[d] {
+				current = d
+				break
+			}
+		}
+	}
+	names := make([]string, len(path))
+	for i, index := range path {
+		names[i] = jobs[index].JobName
+	}
+	return strings.Join(names, " depends on ")
+}
+
+// Returns the job followed by its sub-jobs if it is a parallel group
+func withSubJobs(job *definitions.Job) []*definitions.Job {
+	return flattenJobs([]*definitions.Job{job})
+}
+
+func flattenJobs(jobs []*definitions.Job) []*definitions.Job {
+	return (&definitions.Package{Jobs: jobs}).AllJobs()
+}
+
+// Returns the names of the jobs in the order they are run, with the sub-jobs of each parallel group following
+// the group
+func executionOrder(jobs []*definitions.Job) []string {
+	var names []string
+	for _, job := range flattenJobs(jobs) {
+		names = append(names, job.JobName)
+	}
+	return names
+}
+
+// Returns the jobs and their dependencies as a graph in the DOT language of Graphviz with edges from each job to
+// the jobs that depend on it and parallel groups drawn as clusters of their sub-jobs
+func JobGraphDOT(jobs []*definitions.Job) (string, error) {
+	jobs, err := OrderJobs(jobs)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString("digraph jobs {\n")
+	for _, job := range jobs {
+		if job.Parallel == nil {
+			fmt.Fprintf(buf, "  %q;\n", job.JobName)
+			continue
+		}
+		fmt.Fprintf(buf, "  subgraph %q {\n", "cluster_"+job.JobName)
+		fmt.Fprintf(buf, "    label=%q;\n", job.JobName+" (parallel)")
+		fmt.Fprintf(buf, "    %q [shape=box];\n", job.JobName)
+		for _, subJob := range job.Parallel.Jobs {
+			fmt.Fprintf(buf, "    %q;\n", subJob.JobName)
+		}
+		buf.WriteString("  }\n")
+	}
+	for _, job := range flattenJobs(jobs) {
+		for _, name := range job.DependsOn {
+			fmt.Fprintf(buf, "  %q -> %q;\n", name, job.JobName)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.String(), nil
+}
@@ -0,0 +1,185 @@
+package jobs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+func setJob(name string, dependsOn ...string) *definitions.Job {
+	return &definitions.Job{JobName: name, DependsOn: dependsOn, Set: &definitions.SetJob{Value: name}}
+}
+
+func jobNames(jobs []*definitions.Job) []string {
+	names := make([]string, len(jobs))
+	for i, job := range jobs {
+		names[i] = job.JobName
+	}
+	return names
+}
+
+func TestOrderJobs(t *testing.T) {
+	for _, test := range []struct {
+		jobs     []*definitions.Job
+		expected []string
+	}{
+		{
+			// jobs without dependencies keep their order
+			[]*definitions.Job{setJob("a"), setJob("b"), setJob("c")},
+			[]string{"a", "b", "c"},
+		},
+		{
+			[]*definitions.Job{setJob("a", "c"), setJob("b"), setJob("c", "b"), setJob("d")},
+			[]string{"b", "c", "a", "d"},
+		},
+		{
+			[]*definitions.Job{setJob("a", "b", "c"), setJob("b", "c"), setJob("c")},
+			[]string{"c", "b", "a"},
+		},
+	} {
+		ordered, err := OrderJobs(test.jobs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := jobNames(ordered); !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("expected jobs to be ordered %v but got %v", test.expected, names)
+		}
+	}
+
+	// a group runs after the jobs its sub-jobs depend on and its sub-jobs after their siblings
+	parallel := &definitions.Parallel{Jobs: []*definitions.Job{setJob("y", "z"), setJob("z", "b")}}
+	ordered, err := OrderJobs([]*definitions.Job{
+		setJob("a"),
+		{JobName: "group", Parallel: parallel},
+		setJob("b"),
+		setJob("c", "y"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := executionOrder(ordered); !reflect.DeepEqual(names, []string{"a", "b", "group", "z", "y", "c"}) {
+		t.Errorf("unexpected order of parallel group %v", names)
+	}
+}
+
+func TestOrderJobsErrors(t *testing.T) {
+	for _, test := range []struct {
+		jobs  []*definitions.Job
+		error string
+	}{
+		{[]*definitions.Job{setJob("a", "missing")}, "no job of that name"},
+		{[]*definitions.Job{setJob("a", "a")}, "depends on itself"},
+		{[]*definitions.Job{setJob("a", "b"), setJob("b"), setJob("b")}, "more than one job"},
+		{[]*definitions.Job{setJob("a", "c"), setJob("b", "a"), setJob("c", "b")},
+			"cycle: a depends on c depends on b depends on a"},
+		{[]*definitions.Job{{JobName: "group", Parallel: &definitions.Parallel{
+			Jobs: []*definitions.Job{setJob("y", "z"), setJob("z", "y")}}}},
+			"cycle: y depends on z depends on y"},
+		{[]*definitions.Job{setJob("a", "y"), {JobName: "group", DependsOn: []string{"a"},
+			Parallel: &definitions.Parallel{Jobs: []*definitions.Job{setJob("y")}}}},
+			"cycle: a depends on group depends on a"},
+	} {
+		_, err := OrderJobs(test.jobs)
+		if err == nil {
+			t.Errorf("expected ordering %v to fail", jobNames(test.jobs))
+		} else if !strings.Contains(err.Error(), test.error) {
+			t.Errorf("expected error containing '%s' but got: %v", test.error, err)
+		}
+	}
+}
+
+func TestParallelJobDependencies(t *testing.T) {
+	parallel := &definitions.Parallel{
+		Workers: "4",
+		Jobs: []*definitions.Job{
+			{JobName: "last", DependsOn: []string{"middle"}, Set: &definitions.SetJob{Value: "$middle!"}},
+			{JobName: "middle", DependsOn: []string{"first"}, Set: &definitions.SetJob{Value: "$first"}},
+			{JobName: "first", Set: &definitions.SetJob{Value: "foo"}},
+		},
+	}
+	do := definitions.NowDo()
+	do.Package = &definitions.Package{Jobs: []*definitions.Job{{JobName: "group", Parallel: parallel}}}
+
+	if _, err := ParallelJob(parallel, do); err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range parallel.Jobs {
+		if job.JobName == "last" && job.JobResult != "foo!" {
+			t.Errorf("sub-jobs should see the results of the sub-jobs they depend on but got '%s'", job.JobResult)
+		}
+	}
+}
+
+func TestRunJobsDependencies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bos-graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	do := definitions.NowDo()
+	do.YAMLPath = filepath.Join(dir, "epm.yaml")
+	do.DefaultOutput = filepath.Join(dir, "epm.output.json")
+	do.Overwrite = true
+	do.ContinueOnFailure = true
+	do.Package = &definitions.Package{Jobs: []*definitions.Job{
+		setJob("uses", "fails"),
+		{JobName: "fails", Assert: &definitions.Assert{Key: "1", Relation: "eq", Value: "2"}},
+		setJob("independent"),
+	}}
+
+	if err := RunJobs(do); err == nil {
+		t.Fatal("expected run to fail")
+	}
+	bs, err := ioutil.ReadFile(do.DefaultOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := struct {
+		Order    []string
+		Failures map[string]string
+	}{}
+	if err := json.Unmarshal(bs, &output); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(output.Order, []string{"fails", "uses", "independent"}) {
+		t.Errorf("expected execution order in output but got %v", output.Order)
+	}
+	if !strings.Contains(output.Failures["uses"], "which it depends on failed") {
+		t.Errorf("expected job depending on failed job not to run but got failures %v", output.Failures)
+	}
+	if _, ok := output.Failures["independent"]; ok {
+		t.Errorf("independent job should have run")
+	}
+}
+
+func TestJobGraphDOT(t *testing.T) {
+	dot, err := JobGraphDOT([]*definitions.Job{
+		setJob("a"),
+		{JobName: "group", Parallel: &definitions.Parallel{Jobs: []*definitions.Job{setJob("y", "a")}}},
+		setJob("b", "group"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `digraph jobs {
+  "a";
+  subgraph "cluster_group" {
+    label="group (parallel)";
+    "group" [shape=box];
+    "y";
+  }
+  "b";
+  "a" -> "y";
+  "group" -> "b";
+}
+`
+	if dot != expected {
+		t.Errorf("expected DOT\n%s\nbut got\n%s", expected, dot)
+	}
+}
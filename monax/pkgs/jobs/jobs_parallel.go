@@ -0,0 +1,318 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+const DefaultParallelWorkers = 4
+
+func ParallelJob(parallel *definitions.Parallel, do *definitions.Do) (string, error) {
+	var err error
+	// Process Variables
+	parallel.Workers, _ = util.PreProcess(parallel.Workers, do)
+
+	workers := DefaultParallelWorkers
+	if parallel.Workers != "" {
+		workers, err = strconv.Atoi(parallel.Workers)
+		if err != nil || workers < 1 {
+			return "", fmt.Errorf("workers for a parallel job must be a positive integer but got '%s'",
+				parallel.Workers)
+		}
+	}
+
+	// Sub-jobs are run after the sub-jobs they depend on so are ordered after them to be given later nonces
+	parallel.Jobs, err = orderSubJobs(parallel.Jobs)
+	if err != nil {
+		return "", err
+	}
+	deps, err := siblingDependencies(parallel.Jobs)
+	if err != nil {
+		return "", err
+	}
+
+	// Give every transaction its own nonce up front since sub-jobs sending from the same account would otherwise
+	// all fetch the same sequence from the chain
+	previousFromSource, err := reserveNonces(parallel.Jobs, do)
+	if err != nil {
+		return "", err
+	}
+
+	log.WithFields(log.Fields{
+		"jobs":    len(parallel.Jobs),
+		"workers": workers,
+	}).Info("Running Parallel Jobs")
+
+	// Sub-jobs can only see the results of jobs preceding the group and of the sub-jobs they depend on since their
+	// other siblings run concurrently
+	visible := visibleJobs(precedingJobs(parallel, do.Package.Jobs), parallel.Jobs, deps)
+
+	// Nothing more is dispatched once the group has timed out, the sub-jobs running are cancelled along with it
+	failed, failure := runSubJobs(workers, sendInSequence(deps, previousFromSource), util.Context(do).Done(), func(index int) error {
+		return runJobWithRetries(parallel.Jobs[index], subJobDo(do, visible[index]))
+	})
+	if failure != nil {
+		err = fmt.Errorf("sub-job %s of parallel job failed so remaining sub-jobs were cancelled: %v",
+			parallel.Jobs[failed].JobName, failure)
+		if _, ok := failure.(ErrAssertionFailed); ok {
+			return "", ErrAssertionFailed{err.Error()}
+		}
+		return "", err
+	}
+	if err = util.Context(do).Err(); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(len(parallel.Jobs)), nil
+}
+
+// Returns deps with each sub-job also depending on the sub-job given the nonce before its own from the same account.
+// The chain only accepts the transactions of an account in sequence so those sub-jobs must be broadcast one after
+// another, only sub-jobs sending from different accounts run concurrently.
+func sendInSequence(deps [][]int, previousFromSource []int) [][]int {
+	runAfter := make([][]int, len(deps))
+	for i, ds := range deps {
+		runAfter[i] = ds
+		if previous := previousFromSource[i]; previous >= 0 {
+			runAfter[i] = append(ds[:len(ds):len(ds)], previous)
+		}
+	}
+	return runAfter
+}
+
+// Runs the sub-jobs with indices into deps on workers goroutines, dispatching each once the sub-jobs it depends on
+// have finished. Nothing more is dispatched once a sub-job fails or done is closed and the index and error of the
+// first sub-job to fail are returned once those running have finished.
+func runSubJobs(workers int, deps [][]int, done <-chan struct{}, run func(index int) error) (int, error) {
+	work := make(chan int)
+	finished := make(chan int)
+	cancel := make(chan struct{})
+	var once sync.Once
+	failed := -1
+	var failure error
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range work {
+				err := run(index)
+				if err != nil {
+					once.Do(func() {
+						failed = index
+						failure = err
+						close(cancel)
+					})
+				}
+				finished <- index
+			}
+		}()
+	}
+
+	waiting := make([]int, len(deps))
+	dependents := make([][]int, len(deps))
+	var ready []int
+	for i, ds := range deps {
+		waiting[i] = len(ds)
+		for _, d := range ds {
+			dependents[d] = append(dependents[d], i)
+		}
+		if len(ds) == 0 {
+			ready = append(ready, i)
+		}
+	}
+	running := 0
+	for {
+		select {
+		case <-cancel:
+			ready = nil
+		case <-done:
+			ready = nil
+		default:
+		}
+		if len(ready) == 0 && running == 0 {
+			break
+		}
+		var dispatch chan int
+		next := -1
+		if len(ready) > 0 {
+			dispatch = work
+			next = ready[0]
+		}
+		select {
+		case dispatch <- next:
+			ready = ready[1:]
+			running++
+		case index := <-finished:
+			running--
+			for _, dependent := range dependents[index] {
+				waiting[dependent]--
+				if waiting[dependent] == 0 {
+					ready = append(ready, dependent)
+				}
+			}
+		case <-cancel:
+			ready = nil
+		case <-done:
+			ready = nil
+		}
+	}
+	close(work)
+	wg.Wait()
+	return failed, failure
+}
+
+// Each sub-job gets its own copy of do since jobs swap out do.PublicKey when overriding the source account, and its
+// own lists of the transactions it broadcasts
+func subJobDo(do *definitions.Do, preceding []*definitions.Job) *definitions.Do {
+	subDo := *do
+	subDo.BroadcastTxHashes = nil
+	subDo.UnsettledBroadcasts = nil
+	pkg := *do.Package
+	pkg.Jobs = preceding
+	subDo.Package = &pkg
+	return &subDo
+}
+
+// Returns the jobs whose results each sub-job can use, being the preceding jobs followed by the sub-jobs it depends
+// on directly or indirectly
+func visibleJobs(preceding, subJobs []*definitions.Job, deps [][]int) [][]*definitions.Job {
+	visible := make([][]*definitions.Job, len(subJobs))
+	for i := range subJobs {
+		visible[i] = preceding[:len(preceding):len(preceding)]
+		ancestors := make(map[int]bool)
+		var visit func(int)
+		visit = func(index int) {
+			for _, d := range deps[index] {
+				if !ancestors[d] {
+					ancestors[d] = true
+					visit(d)
+				}
+			}
+		}
+		visit(i)
+		for j, subJob := range subJobs {
+			if ancestors[j] {
+				visible[i] = append(visible[i], subJob)
+			}
+		}
+	}
+	return visible
+}
+
+func precedingJobs(parallel *definitions.Parallel, jobs []*definitions.Job) []*definitions.Job {
+	for i, job := range jobs {
+		if job.Parallel == parallel {
+			return jobs[:i:i]
+		}
+	}
+	return jobs
+}
+
+// Assigns consecutive nonces to the transactions of the sub-jobs from each source account starting from the
+// account's next sequence number on chain. Returns the index of the sub-job given the previous nonce from the same
+// account for each sub-job, or -1 for those sending the first transaction of their account or none at all.
+func reserveNonces(subJobs []*definitions.Job, do *definitions.Do) ([]int, error) {
+	var sources []string
+	bySource := make(map[string][]*string)
+	lastFromSource := make(map[string]int)
+	previousFromSource := make([]int, len(subJobs))
+	for i, job := range subJobs {
+		previousFromSource[i] = -1
+		source, nonce, err := transactionFields(job)
+		if err != nil {
+			return nil, err
+		}
+		if source == nil {
+			continue
+		}
+		if *nonce != "" {
+			return nil, fmt.Errorf("sub-job %s of parallel job sets a nonce but nonces are reserved by the parallel job",
+				job.JobName)
+		}
+		*source, _ = util.PreProcess(*source, do)
+		*source = useDefault(*source, do.Package.Account)
+		if last, ok := lastFromSource[*source]; ok {
+			previousFromSource[i] = last
+		} else {
+			sources = append(sources, *source)
+		}
+		lastFromSource[*source] = i
+		bySource[*source] = append(bySource[*source], nonce)
+	}
+
+	// Simulated transactions are given their sequence numbers as they run
+	if len(sources) == 0 || do.DryRun {
+		return previousFromSource, nil
+	}
+
+	nodeClient := util.NodeClient(do)
+	for _, source := range sources {
+		address, err := acm.AddressFromHexString(source)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse source address %s for parallel job: %v", source, err)
+		}
+		account, err := nodeClient.GetAccount(address)
+		if err != nil {
+			return nil, err
+		}
+		sequence := account.Sequence()
+		for _, nonce := range bySource[source] {
+			sequence++
+			*nonce = strconv.FormatUint(sequence, 10)
+		}
+		log.WithFields(log.Fields{
+			"source": source,
+			"from":   account.Sequence() + 1,
+			"to":     sequence,
+		}).Info("Reserved Nonces")
+	}
+	return previousFromSource, nil
+}
+
+// Returns pointers to the source and nonce of a sub-job that sends exactly one transaction, or nils for sub-jobs
+// that do not transact. Sub-jobs that cannot safely run in a parallel group return an error.
+func transactionFields(job *definitions.Job) (source, nonce *string, err error) {
+	switch {
+	case job.Account != nil:
+		return nil, nil, fmt.Errorf("sub-job %s of parallel job is an account job which cannot be run in parallel "+
+			"since it changes the account used by other jobs", job.JobName)
+	case job.Parallel != nil:
+		return nil, nil, fmt.Errorf("sub-job %s of parallel job is itself a parallel job which is not supported",
+			job.JobName)
+	case job.Bond != nil || job.Unbond != nil || job.Rebond != nil:
+		return nil, nil, fmt.Errorf("sub-job %s of parallel job is a bonding job which cannot be run in parallel",
+			job.JobName)
+	case job.Send != nil:
+		return &job.Send.Source, &job.Send.Nonce, nil
+	case job.FundAccounts != nil:
+		return nil, nil, fmt.Errorf("sub-job %s of parallel job funds accounts which sends more than one "+
+			"transaction so cannot be run in parallel", job.JobName)
+	case job.RegisterName != nil:
+		if job.RegisterName.DataFile != "" {
+			return nil, nil, fmt.Errorf("sub-job %s of parallel job registers names from a data file which "+
+				"sends more than one transaction so cannot be run in parallel", job.JobName)
+		}
+		return &job.RegisterName.Source, &job.RegisterName.Nonce, nil
+	case job.RegisterNameLease != nil:
+		return &job.RegisterNameLease.Source, &job.RegisterNameLease.Nonce, nil
+	case job.Permission != nil:
+		return &job.Permission.Source, &job.Permission.Nonce, nil
+	case job.Perms != nil:
+		return &job.Perms.Source, &job.Perms.Nonce, nil
+	case job.Deploy != nil:
+		if job.Deploy.Instance == "all" {
+			return nil, nil, fmt.Errorf("sub-job %s of parallel job deploys all contracts in a file which "+
+				"sends more than one transaction so cannot be run in parallel", job.JobName)
+		}
+		return &job.Deploy.Source, &job.Deploy.Nonce, nil
+	case job.Call != nil:
+		return &job.Call.Source, &job.Call.Nonce, nil
+	}
+	return nil, nil, nil
+}
@@ -0,0 +1,140 @@
+package jobs
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+func TestParallelJob(t *testing.T) {
+	parallel := &definitions.Parallel{
+		Workers: "2",
+		Jobs: []*definitions.Job{
+			{JobName: "a", Set: &definitions.SetJob{Value: "$base"}},
+			{JobName: "b", Set: &definitions.SetJob{Value: "bar"}},
+			{JobName: "c", Set: &definitions.SetJob{Value: "baz"}},
+		},
+	}
+	do := definitions.NowDo()
+	do.Package = &definitions.Package{
+		Jobs: []*definitions.Job{
+			{JobName: "base", Set: &definitions.SetJob{Value: "foo"}},
+			{JobName: "group", Parallel: parallel},
+		},
+	}
+	do.Package.Jobs[0].JobResult = "foo"
+
+	result, err := ParallelJob(parallel, do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "3" {
+		t.Errorf("expected result 3 but got %s", result)
+	}
+	got, err := util.PreProcess("$a $b $c", do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "foo bar baz" {
+		t.Errorf("sub-job results should be visible after the group but got '%s'", got)
+	}
+}
+
+func TestParallelJobFailure(t *testing.T) {
+	parallel := &definitions.Parallel{
+		Workers: "1",
+		Jobs: []*definitions.Job{
+			{JobName: "fails", Assert: &definitions.Assert{Key: "1", Relation: "eq", Value: "2"}},
+			{JobName: "never", Set: &definitions.SetJob{Value: "ran"}},
+		},
+	}
+	do := definitions.NowDo()
+	do.Package = &definitions.Package{Jobs: []*definitions.Job{{JobName: "group", Parallel: parallel}}}
+
+	_, err := ParallelJob(parallel, do)
+	if err == nil {
+		t.Fatal("expected parallel job to fail")
+	}
+	if !strings.Contains(err.Error(), "sub-job fails") {
+		t.Errorf("error should name the failed sub-job: %v", err)
+	}
+	if parallel.Jobs[1].JobResult != "" {
+		t.Errorf("sub-jobs after a failure should be cancelled")
+	}
+}
+
+func TestParallelJobRejectsNonce(t *testing.T) {
+	parallel := &definitions.Parallel{
+		Jobs: []*definitions.Job{
+			{JobName: "send", Send: &definitions.Send{Destination: "AB", Amount: "1", Nonce: "3"}},
+		},
+	}
+	do := definitions.NowDo()
+	do.Package = &definitions.Package{Jobs: []*definitions.Job{{JobName: "group", Parallel: parallel}}}
+
+	_, err := ParallelJob(parallel, do)
+	if err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Errorf("expected error for sub-job setting a nonce but got: %v", err)
+	}
+}
+
+func TestParallelJobSendsInSequence(t *testing.T) {
+	account := "0000000000000000000000000000000000000001"
+	subJobs := []*definitions.Job{
+		{JobName: "first", Send: &definitions.Send{Source: account, Destination: "AB", Amount: "1"}},
+		{JobName: "other", Send: &definitions.Send{Source: "0000000000000000000000000000000000000002",
+			Destination: "AB", Amount: "1"}},
+		// Sends from the default account, the same as the first
+		{JobName: "second", Send: &definitions.Send{Destination: "AB", Amount: "1"}},
+	}
+	do := definitions.NowDo()
+	do.DryRun = true
+	do.Package = &definitions.Package{Account: account}
+
+	previousFromSource, err := reserveNonces(subJobs, do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(previousFromSource, []int{-1, -1, 0}) {
+		t.Fatalf("expected the second send from the account to follow the first but got %v", previousFromSource)
+	}
+	deps, err := siblingDependencies(subJobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first send is slow so would finish after the second if they were run concurrently
+	var lock sync.Mutex
+	var events []string
+	record := func(event string) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, event)
+	}
+	_, err = runSubJobs(3, sendInSequence(deps, previousFromSource), nil, func(index int) error {
+		record("start " + subJobs[index].JobName)
+		if index == 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		record("finish " + subJobs[index].JobName)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	position := make(map[string]int)
+	for i, event := range events {
+		position[event] = i
+	}
+	if position["start second"] < position["finish first"] {
+		t.Errorf("expected sends from the same account to run in nonce order but got %v", events)
+	}
+	if position["start other"] > position["finish first"] {
+		t.Errorf("expected sends from different accounts to run concurrently but got %v", events)
+	}
+}
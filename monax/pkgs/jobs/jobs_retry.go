@@ -0,0 +1,182 @@
+package jobs
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/rpc"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+const (
+	DefaultMaxAttempts  = 1
+	DefaultRetryBackoff = time.Second
+)
+
+// Fragments of errors caused by the node being briefly unavailable rather than by the job itself
+var retryableErrors = []string{
+	"connection refused",
+	"connection reset",
+	"timeout",
+	"timed out",
+	"mempool is full",
+	"tx buffer is full",
+}
+
+// Runs the job as many times as its retry policy allows while it fails with retryable errors, cancelling it once its
+// timeout expires or the context of do is done
+func runJobWithRetries(job *definitions.Job, do *definitions.Do) error {
+	maxAttempts, backoff, err := retryPolicy(job, do)
+	if err != nil {
+		return err
+	}
+	timeout, err := jobTimeout(job, do)
+	if err != nil {
+		return err
+	}
+	// Retrying a group would run sub-jobs that have already succeeded again so sub-jobs are retried individually
+	if job.Parallel != nil {
+		maxAttempts = 1
+	}
+	// The job runs with its own context, do is handed back with the context it was given
+	parent := do.Context
+	parentCtx := util.Context(do)
+	ctx, cancel := jobContext(parentCtx, timeout)
+	defer cancel()
+	do.Context = ctx
+	defer func() {
+		do.Context = parent
+	}()
+
+	broadcast := len(do.BroadcastTxHashes)
+	for job.JobAttempts = 1; ; job.JobAttempts++ {
+		if ctx.Err() != nil {
+			return jobTimeoutError(job, parentCtx, timeout)
+		}
+		broadcastCount, unsettled := do.BroadcastCount, len(do.UnsettledBroadcasts)
+		err = runJob(job, do)
+		job.JobTxHashes = append([]string(nil), do.BroadcastTxHashes[broadcast:]...)
+		if err != nil && ctx.Err() != nil {
+			// Whatever the job returned was caused by being cancelled
+			return jobTimeoutError(job, parentCtx, timeout)
+		}
+		if err == nil || job.JobAttempts >= maxAttempts || !isRetryable(err) {
+			return err
+		}
+		if do.BroadcastCount != broadcastCount && !canRebroadcast(util.NodeClient(do),
+			do.BroadcastCount-broadcastCount, do.UnsettledBroadcasts[unsettled:]) {
+			log.WithField("=>", job.JobName).Warn("Not retrying job since a transaction was already broadcast")
+			return err
+		}
+		log.WithFields(log.Fields{
+			"attempt": job.JobAttempts,
+			"backoff": backoff,
+			"error":   err,
+		}).Warn("Retrying Job")
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return jobTimeoutError(job, parentCtx, timeout)
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// Whether a job whose attempt made broadcasts can be run again, which is only when none of them were accepted by the
+// node and each can be seen not to have reached it: it is in neither the mempool nor a recent block and none of the
+// sequence numbers it spends have been used. A copy reaching the node late then spends the same sequence numbers as
+// the transaction made by the retry so at most one of them can be executed.
+func canRebroadcast(nodeClient client.NodeClient, broadcasts uint64, unsettled []definitions.UnsettledBroadcast) bool {
+	if uint64(len(unsettled)) != broadcasts {
+		return false
+	}
+	for _, broadcast := range unsettled {
+		if err := checkNotLanded(nodeClient, broadcast); err != nil {
+			log.WithFields(log.Fields{
+				"tx":    broadcast.TxHash,
+				"error": err,
+			}).Warn("Broadcast May Have Reached The Node")
+			return false
+		}
+	}
+	return true
+}
+
+// Returns an error unless the broadcast can be seen to have had no effect on the node
+func checkNotLanded(nodeClient client.NodeClient, broadcast definitions.UnsettledBroadcast) error {
+	txHash, err := hex.DecodeString(broadcast.TxHash)
+	if err != nil {
+		return err
+	}
+	tx, err := nodeClient.GetTx(txHash)
+	if err != nil {
+		return err
+	}
+	if tx.Status != rpc.TxStatusNotFound {
+		return fmt.Errorf("transaction is %s", tx.Status)
+	}
+	for address, sequence := range broadcast.Sequences {
+		input, err := acm.AddressFromHexString(address)
+		if err != nil {
+			return err
+		}
+		account, err := nodeClient.GetAccount(input)
+		if err != nil {
+			return err
+		}
+		if account != nil && account.Sequence() >= sequence {
+			return fmt.Errorf("sequence %v of %s has been used", sequence, address)
+		}
+	}
+	return nil
+}
+
+func retryPolicy(job *definitions.Job, do *definitions.Do) (maxAttempts int, backoff time.Duration, err error) {
+	attemptsString, backoffString := do.MaxAttempts, do.RetryBackoff
+	if job.Retry != nil {
+		job.Retry.MaxAttempts, _ = util.PreProcess(job.Retry.MaxAttempts, do)
+		job.Retry.Backoff, _ = util.PreProcess(job.Retry.Backoff, do)
+		attemptsString = useDefault(job.Retry.MaxAttempts, attemptsString)
+		backoffString = useDefault(job.Retry.Backoff, backoffString)
+	}
+
+	maxAttempts = DefaultMaxAttempts
+	if attemptsString != "" {
+		maxAttempts, err = strconv.Atoi(attemptsString)
+		if err != nil || maxAttempts < 1 {
+			return 0, 0, fmt.Errorf("max attempts for job %s must be a positive integer but got '%s'",
+				job.JobName, attemptsString)
+		}
+	}
+	backoff = DefaultRetryBackoff
+	if backoffString != "" {
+		backoff, err = time.ParseDuration(backoffString)
+		if err != nil {
+			return 0, 0, fmt.Errorf("retry backoff for job %s is not a valid duration: %v", job.JobName, err)
+		}
+	}
+	return maxAttempts, backoff, nil
+}
+
+func isRetryable(err error) bool {
+	switch err.(type) {
+	case ErrUnverifiable, ErrAssertionFailed, ErrJobTimeout:
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range retryableErrors {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
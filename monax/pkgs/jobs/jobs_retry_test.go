@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/rpc"
+	"github.com/hyperledger/burrow/txs"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("dial tcp 127.0.0.1:46657: connect: connection refused"), true},
+		{fmt.Errorf("Post http://localhost:46657: net/http: request canceled (Client.Timeout exceeded)"), true},
+		{fmt.Errorf("Mempool is full"), true},
+		{fmt.Errorf("encountered Exception from chain: insufficient gas"), false},
+		{ErrUnverifiable{"times out"}, false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	do := definitions.NowDo()
+	do.Package = &definitions.Package{}
+	do.MaxAttempts = "3"
+
+	maxAttempts, backoff, err := retryPolicy(&definitions.Job{JobName: "job"}, do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxAttempts != 3 || backoff != DefaultRetryBackoff {
+		t.Errorf("expected global policy but got %v attempts with backoff %v", maxAttempts, backoff)
+	}
+
+	maxAttempts, backoff, err = retryPolicy(&definitions.Job{
+		JobName: "job",
+		Retry:   &definitions.Retry{MaxAttempts: "5", Backoff: "10ms"},
+	}, do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxAttempts != 5 || backoff != 10*time.Millisecond {
+		t.Errorf("expected job policy but got %v attempts with backoff %v", maxAttempts, backoff)
+	}
+
+	_, _, err = retryPolicy(&definitions.Job{JobName: "job", Retry: &definitions.Retry{MaxAttempts: "0"}}, do)
+	if err == nil {
+		t.Errorf("expected error for non-positive max attempts")
+	}
+}
+
+func TestRunJobWithRetries(t *testing.T) {
+	do := definitions.NowDo()
+	do.Package = &definitions.Package{Account: "0000000000000000000000000000000000000001"}
+	// Nothing listens here so every attempt fails before anything is broadcast
+	do.ChainURL = "tcp://127.0.0.1:1"
+	do.Signer = "http://127.0.0.1:1"
+	job := &definitions.Job{
+		JobName: "send",
+		Send:    &definitions.Send{Destination: "0000000000000000000000000000000000000002", Amount: "1"},
+		Retry:   &definitions.Retry{MaxAttempts: "3", Backoff: "1ms"},
+	}
+
+	err := runJobWithRetries(job, do)
+	if err == nil {
+		t.Fatal("expected send to unreachable node to fail")
+	}
+	if job.JobAttempts != 3 {
+		t.Errorf("expected 3 attempts but got %v: %v", job.JobAttempts, err)
+	}
+}
+
+func TestBroadcastCountedBeforeSending(t *testing.T) {
+	do := definitions.NowDo()
+	// Nothing listens here so the broadcast fails without any result
+	do.ChainURL = "tcp://127.0.0.1:1"
+	input := acm.GeneratePrivateAccountFromSecret("input").Address()
+	tx := &txs.SendTx{Inputs: []*txs.TxInput{{Address: input, Amount: 1, Sequence: 5}}}
+
+	if _, err := broadcastSigned(do, "chain", util.NodeClient(do), nil, tx); err == nil {
+		t.Fatal("expected broadcast to unreachable node to fail")
+	}
+	if do.BroadcastCount != 1 || len(do.BroadcastTxHashes) != 0 {
+		t.Errorf("expected the failed broadcast to be counted without a hash but got %v, %v", do.BroadcastCount,
+			do.BroadcastTxHashes)
+	}
+	expected := definitions.UnsettledBroadcast{
+		TxHash:    fmt.Sprintf("%X", txs.TxHash("chain", tx)),
+		Sequences: map[string]uint64{input.String(): 5},
+	}
+	if len(do.UnsettledBroadcasts) != 1 || !reflect.DeepEqual(do.UnsettledBroadcasts[0], expected) {
+		t.Errorf("expected the broadcast to be unsettled but got %v", do.UnsettledBroadcasts)
+	}
+}
+
+// Answers whether a broadcast landed with the status of every transaction and the account of every address
+type testBroadcastClient struct {
+	client.NodeClient
+	status  rpc.TxStatus
+	account acm.Account
+}
+
+func (tbc *testBroadcastClient) GetTx(txHash []byte) (*rpc.ResultGetTx, error) {
+	return &rpc.ResultGetTx{Status: tbc.status, TxHash: txHash}, nil
+}
+
+func (tbc *testBroadcastClient) GetAccount(address acm.Address) (acm.Account, error) {
+	return tbc.account, nil
+}
+
+func TestCanRebroadcast(t *testing.T) {
+	input := acm.GeneratePrivateAccountFromSecret("input").Address()
+	unsettled := []definitions.UnsettledBroadcast{{TxHash: "AB", Sequences: map[string]uint64{input.String(): 5}}}
+	nodeClient := func(status rpc.TxStatus, sequence uint64) client.NodeClient {
+		return &testBroadcastClient{
+			status:  status,
+			account: acm.ConcreteAccount{Address: input, Sequence: sequence}.Account(),
+		}
+	}
+
+	if !canRebroadcast(nodeClient(rpc.TxStatusNotFound, 4), 1, unsettled) {
+		t.Errorf("expected a broadcast that did not reach the node to be made again")
+	}
+	if canRebroadcast(nodeClient(rpc.TxStatusPending, 4), 1, unsettled) {
+		t.Errorf("expected a broadcast in the mempool not to be made again")
+	}
+	if canRebroadcast(nodeClient(rpc.TxStatusNotFound, 5), 1, unsettled) {
+		t.Errorf("expected a broadcast whose sequence has been used not to be made again")
+	}
+	// The other broadcast was accepted by the node
+	if canRebroadcast(nodeClient(rpc.TxStatusNotFound, 4), 2, unsettled) {
+		t.Errorf("expected an attempt with an accepted broadcast not to be made again")
+	}
+}
@@ -1,17 +1,209 @@
 package jobs
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/hyperledger/burrow/genesis"
+	"github.com/hyperledger/burrow/permission"
+	"github.com/hyperledger/burrow/rpc"
 	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+	"github.com/monax/bosmarmot/monax/util"
 )
 
+const defaultStateDumpFile = "state.json"
+
+// The first value in a state dump file, it is followed by one rpc.DumpStateChunk value after another so that the
+// file can be written and read a chunk at a time
+type stateDumpHeader struct {
+	ChainID        string
+	Height         uint64
+	IncludeStorage bool
+	Validators     []genesis.Validator `json:",omitempty"`
+}
+
 func DumpStateJob(dump *definitions.DumpState, do *definitions.Do) (string, error) {
-	var result string
+	// Process variables
+	dump.FilePath, _ = util.PreProcess(dump.FilePath, do)
+
+	// Set defaults
+	dump.FilePath = useDefault(dump.FilePath, defaultStateDumpFile)
+
+	if dump.ToIPFS {
+		return "", fmt.Errorf("dumping state to IPFS is not supported, dump to a file instead")
+	}
+
+	nodeClient := util.NodeClient(do)
+	header := stateDumpHeader{IncludeStorage: dump.IncludeStorage}
+	if dump.WithValidators {
+		_, bonded, _, err := nodeClient.ListValidators()
+		if err != nil {
+			return "", err
+		}
+		for _, validator := range bonded {
+			header.Validators = append(header.Validators, genesis.Validator{
+				BasicAccount: genesis.BasicAccount{
+					Address:   validator.Address(),
+					PublicKey: validator.PublicKey(),
+					Amount:    validator.Power(),
+				},
+			})
+		}
+	}
+	log.WithFields(log.Fields{
+		"file":            dump.FilePath,
+		"include storage": dump.IncludeStorage,
+	}).Info("Dumping State")
+
+	result, err := nodeClient.DumpState(dump.IncludeStorage)
+	if err != nil {
+		return "", err
+	}
+	header.ChainID = result.ChainID
+	header.Height = result.Height
 
-	return result, nil
+	file, err := os.Create(dump.FilePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	err = writeStateDump(file, header, result.Chunks)
+	if err != nil {
+		return "", fmt.Errorf("could not write state dump to %s: %v", dump.FilePath, err)
+	}
+	log.WithFields(log.Fields{
+		"chain":  header.ChainID,
+		"height": header.Height,
+	}).Warn("Dumped State")
+	return dump.FilePath, nil
+}
+
+func writeStateDump(w io.Writer, header stateDumpHeader, chunks []*rpc.DumpStateChunk) error {
+	encoder := json.NewEncoder(w)
+	err := encoder.Encode(header)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		err = encoder.Encode(chunk)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func RestoreStateJob(restore *definitions.RestoreState, do *definitions.Do) (string, error) {
-	var result string
+	// Process variables
+	restore.FilePath, _ = util.PreProcess(restore.FilePath, do)
+	restore.ChainName, _ = util.PreProcess(restore.ChainName, do)
+	restore.GenesisFile, _ = util.PreProcess(restore.GenesisFile, do)
+
+	// Set defaults
+	restore.FilePath = useDefault(restore.FilePath, defaultStateDumpFile)
+	restore.GenesisFile = useDefault(restore.GenesisFile, "genesis.json")
+
+	if restore.FromIPFS {
+		return "", fmt.Errorf("restoring state from IPFS is not supported, restore from a file instead")
+	}
+	if restore.ChainName == "" {
+		return "", fmt.Errorf("restore-state requires the chain-name of the chain to make a genesis doc for")
+	}
+
+	file, err := os.Open(restore.FilePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	genesisDoc, err := genesisFromStateDump(file, restore.ChainName, time.Now().UTC())
+	if err != nil {
+		return "", fmt.Errorf("could not make genesis doc from state dump %s: %v", restore.FilePath, err)
+	}
+	genesisBytes, err := genesisDoc.JSONBytes()
+	if err != nil {
+		return "", err
+	}
+	err = ioutil.WriteFile(restore.GenesisFile, genesisBytes, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	chainID := genesisDoc.ChainID()
+	log.WithFields(log.Fields{
+		"file":     restore.GenesisFile,
+		"accounts": len(genesisDoc.Accounts),
+		"names":    len(genesisDoc.Names),
+	}).Warn("Wrote Genesis")
+	return chainID, nil
+}
 
-	return result, nil
+// Makes a genesis doc for a new chain with the balances, code, storage, and permissions of the accounts in the dump
+// read from r a chunk at a time. Names keep the number of blocks their lease had left when the dump was made and
+// those that had expired are dropped. The global permissions account becomes the global permissions of the genesis.
+func genesisFromStateDump(r io.Reader, chainName string, genesisTime time.Time) (*genesis.GenesisDoc, error) {
+	decoder := json.NewDecoder(r)
+	header := new(stateDumpHeader)
+	err := decoder.Decode(header)
+	if err != nil {
+		return nil, fmt.Errorf("could not read header: %v", err)
+	}
+	if len(header.Validators) == 0 {
+		return nil, fmt.Errorf("dump of chain %s has no validators, use include-validators when dumping state",
+			header.ChainID)
+	}
+	genesisDoc := &genesis.GenesisDoc{
+		GenesisTime: genesisTime,
+		ChainName:   chainName,
+		Validators:  header.Validators,
+	}
+	for {
+		chunk := new(rpc.DumpStateChunk)
+		err = decoder.Decode(chunk)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read chunk: %v", err)
+		}
+		for _, dumpAccount := range chunk.Accounts {
+			account := dumpAccount.Account
+			if account.Address == permission.GlobalPermissionsAddress {
+				genesisDoc.GlobalPermissions = account.Permissions
+				continue
+			}
+			genesisAccount := genesis.Account{
+				BasicAccount: genesis.BasicAccount{
+					Address:   account.Address,
+					PublicKey: account.PublicKey,
+					Amount:    account.Balance,
+				},
+				Permissions: account.Permissions,
+				Code:        account.Code,
+			}
+			for _, item := range dumpAccount.Storage {
+				genesisAccount.Storage = append(genesisAccount.Storage, genesis.StorageItem{
+					Key:   item.Key,
+					Value: item.Value,
+				})
+			}
+			genesisDoc.Accounts = append(genesisDoc.Accounts, genesisAccount)
+		}
+		for _, entry := range chunk.Names {
+			if entry.Expires <= header.Height {
+				continue
+			}
+			genesisDoc.Names = append(genesisDoc.Names, genesis.Name{
+				Name:    entry.Name,
+				Owner:   entry.Owner,
+				Data:    entry.Data,
+				Expires: entry.Expires - header.Height,
+			})
+		}
+	}
+	return genesisDoc, nil
 }
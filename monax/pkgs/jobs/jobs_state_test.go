@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/execution"
+	"github.com/hyperledger/burrow/genesis"
+	"github.com/hyperledger/burrow/permission"
+	ptypes "github.com/hyperledger/burrow/permission/types"
+	"github.com/hyperledger/burrow/rpc"
+)
+
+func TestGenesisFromStateDump(t *testing.T) {
+	contract := acm.Address{1, 2, 3}
+	user := acm.Address{4, 5, 6}
+	validator := genesis.Validator{
+		BasicAccount: genesis.BasicAccount{Address: acm.Address{7, 8, 9}, Amount: 1000},
+	}
+	header := stateDumpHeader{ChainID: "old-chain", Height: 10, IncludeStorage: true,
+		Validators: []genesis.Validator{validator}}
+	chunks := []*rpc.DumpStateChunk{
+		{
+			Accounts: []*rpc.DumpStateAccount{
+				{Account: &acm.ConcreteAccount{
+					Address:     permission.GlobalPermissionsAddress,
+					Permissions: ptypes.AccountPermissions{Base: ptypes.BasePermissions{Perms: permission.Send, SetBit: permission.Send}},
+				}},
+				{
+					Account: &acm.ConcreteAccount{Address: contract, Balance: 3, Code: acm.Bytecode{0x60, 0x01}},
+					Storage: []rpc.StorageItem{{Key: []byte{1}, Value: []byte{2}}},
+				},
+			},
+		},
+		{
+			Accounts: []*rpc.DumpStateAccount{{Account: &acm.ConcreteAccount{Address: user, Balance: 42}}},
+			Names: []*execution.NameRegEntry{
+				{Name: "live", Owner: user, Data: "data", Expires: 25},
+				{Name: "expired", Owner: user, Expires: 10},
+			},
+		},
+	}
+	buf := new(bytes.Buffer)
+	err := writeStateDump(buf, header, chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesisTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	genesisDoc, err := genesisFromStateDump(buf, "new-chain", genesisTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if genesisDoc.ChainName != "new-chain" || !genesisDoc.GenesisTime.Equal(genesisTime) {
+		t.Errorf("unexpected chain name or genesis time in %v", genesisDoc)
+	}
+	if genesisDoc.GlobalPermissions.Base.Perms != permission.Send {
+		t.Errorf("expected global permissions from the dump but got %v", genesisDoc.GlobalPermissions)
+	}
+	if len(genesisDoc.Validators) != 1 || genesisDoc.Validators[0].Address != validator.Address {
+		t.Errorf("expected the dumped validator but got %v", genesisDoc.Validators)
+	}
+	if len(genesisDoc.Accounts) != 2 {
+		t.Fatalf("expected the contract and user accounts but got %v", genesisDoc.Accounts)
+	}
+	contractAccount := genesisDoc.Accounts[0]
+	if contractAccount.Address != contract || contractAccount.Amount != 3 ||
+		!bytes.Equal(contractAccount.Code, []byte{0x60, 0x01}) ||
+		len(contractAccount.Storage) != 1 || !bytes.Equal(contractAccount.Storage[0].Value, []byte{2}) {
+		t.Errorf("contract account not carried over: %v", contractAccount)
+	}
+	if genesisDoc.Accounts[1].Address != user || genesisDoc.Accounts[1].Amount != 42 {
+		t.Errorf("user account not carried over: %v", genesisDoc.Accounts[1])
+	}
+	if len(genesisDoc.Names) != 1 || genesisDoc.Names[0].Name != "live" || genesisDoc.Names[0].Expires != 15 {
+		t.Errorf("expected only the live name with its remaining lease but got %v", genesisDoc.Names)
+	}
+}
+
+func TestGenesisFromStateDumpWithoutValidators(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := writeStateDump(buf, stateDumpHeader{ChainID: "old-chain"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = genesisFromStateDump(buf, "new-chain", time.Now())
+	if err == nil {
+		t.Fatal("expected an error for a dump without validators")
+	}
+}
@@ -0,0 +1,356 @@
+package jobs
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/client/rpc"
+	"github.com/hyperledger/burrow/execution/evm/abi"
+	evm_events "github.com/hyperledger/burrow/execution/evm/events"
+	"github.com/hyperledger/burrow/keys"
+	"github.com/hyperledger/burrow/logging/loggers"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+	monaxAbi "github.com/monax/bosmarmot/monax/pkgs/abi"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+// Called before each test when the test contract has it
+const testSetUpFunction = "setUp"
+
+// Events of a test contract that are decoded into the report of the test that emitted them. An Assert event with a
+// bool parameter that is false fails the test.
+const (
+	testLogEvent    = "Log"
+	testAssertEvent = "Assert"
+)
+
+// A function of the JSON ABI of a test contract
+type testContractFunction struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Inputs []struct {
+		Type string `json:"type"`
+	} `json:"inputs"`
+	Outputs []struct {
+		Type string `json:"type"`
+	} `json:"outputs"`
+}
+
+// What calling a function of a test contract did
+type testCallResult struct {
+	Return []byte
+	// Set when the call reverted
+	Exception    string
+	RevertReason string
+	Logs         []*evm_events.EventDataLog
+}
+
+// Calls a function of the deployed test contract with data, simulating the call rather than committing it as a
+// transaction when simulate is set. A call that reverts is not an error.
+type testCaller func(data []byte, simulate bool) (*testCallResult, error)
+
+func TestContractJob(test *definitions.TestContract, do *definitions.Do) (string, []*definitions.ContractTest, error) {
+	// The deploy job preprocesses the rest
+	test.Instance, _ = util.PreProcess(test.Instance, do)
+	if test.Instance == "all" {
+		return "", nil, fmt.Errorf("a test-contract job tests a single contract so its instance cannot be all")
+	}
+	if do.SignOnly != "" {
+		return "", nil, fmt.Errorf("cannot run the tests of %s when only signing since they need the contract to be "+
+			"deployed", test.Contract)
+	}
+	deploy := &definitions.Deploy{
+		Source:    test.Source,
+		Contract:  test.Contract,
+		Instance:  test.Instance,
+		Libraries: test.Libraries,
+		Solc:      test.Solc,
+		Data:      test.Data,
+		Fee:       test.Fee,
+		Gas:       test.Gas,
+	}
+	address, err := DeployJob(deploy, do)
+	if err != nil {
+		return "", nil, err
+	}
+	if do.DryRun {
+		return address, nil, ErrUnverifiable{fmt.Sprintf("runs the tests of %s which was only simulated",
+			deploy.Instance)}
+	}
+	abiSpec, err := util.ReadAbi(do.ABIPath, address)
+	if err != nil {
+		return "", nil, err
+	}
+	callerAddress, err := acm.AddressFromHexString(deploy.Source)
+	if err != nil {
+		return "", nil, err
+	}
+	contractAddress, err := acm.AddressFromHexString(address)
+	if err != nil {
+		return "", nil, err
+	}
+
+	nodeClient := util.NodeClient(do)
+	keyClient := keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger())
+	call := func(data []byte, simulate bool) (*testCallResult, error) {
+		if simulate {
+			return simulateTestCall(nodeClient, callerAddress, contractAddress, data)
+		}
+		return commitTestCall(do, nodeClient, keyClient, deploy, address, data)
+	}
+	log.WithFields(log.Fields{
+		"contract": deploy.Instance,
+		"address":  address,
+		"simulate": test.Simulate,
+	}).Warn("Running Contract Tests")
+	tests, err := runContractTests(abiSpec, call, test.Simulate)
+	if err != nil {
+		return address, tests, err
+	}
+	return address, tests, contractTestFailures(deploy.Instance, tests)
+}
+
+// Runs each test function of the contract with the ABI given in name order, calling setUp before each when the
+// contract has it. An error is only returned when a test could not be run, any run before it are returned with it.
+func runContractTests(abiSpec string, call testCaller, simulate bool) ([]*definitions.ContractTest, error) {
+	functions, setUp, err := testFunctions(abiSpec)
+	if err != nil {
+		return nil, err
+	}
+	if len(functions) == 0 {
+		return nil, fmt.Errorf("contract has no tests, which are functions taking no arguments whose names begin " +
+			"with test")
+	}
+	registry := abi.NewEventRegistry()
+	if err = registry.AddABI([]byte(abiSpec)); err != nil {
+		return nil, err
+	}
+
+	var tests []*definitions.ContractTest
+	for _, function := range functions {
+		start := time.Now()
+		test := &definitions.ContractTest{Name: function.Name}
+		tests = append(tests, test)
+		if setUp {
+			res, err := callTestFunction(call, abiSpec, testSetUpFunction, false)
+			if err != nil {
+				return tests, fmt.Errorf("could not call %s before test %s: %v", testSetUpFunction, function.Name, err)
+			}
+			logs, _ := decodeTestLogs(registry, res.Logs)
+			test.Logs = append(test.Logs, logs...)
+			if res.Exception != "" {
+				test.Message = fmt.Sprintf("%s reverted: %s", testSetUpFunction, revertReason(res))
+				test.Duration = time.Since(start)
+				logContractTest(test)
+				continue
+			}
+		}
+		res, err := callTestFunction(call, abiSpec, function.Name, simulate)
+		if err != nil {
+			return tests, fmt.Errorf("could not call test %s: %v", function.Name, err)
+		}
+		logs, failedAssertions := decodeTestLogs(registry, res.Logs)
+		test.Logs = append(test.Logs, logs...)
+		test.Passed, test.Message = testOutcome(function, res, failedAssertions)
+		test.Duration = time.Since(start)
+		logContractTest(test)
+	}
+	return tests, nil
+}
+
+// Returns the test functions of the ABI in name order and whether it has a setUp function
+func testFunctions(abiSpec string) ([]*testContractFunction, bool, error) {
+	var entries []*testContractFunction
+	if err := json.Unmarshal([]byte(abiSpec), &entries); err != nil {
+		return nil, false, fmt.Errorf("could not read ABI of test contract: %v", err)
+	}
+	var functions []*testContractFunction
+	setUp := false
+	for _, entry := range entries {
+		// Entries without a type are functions in older ABIs
+		if (entry.Type != "function" && entry.Type != "") || len(entry.Inputs) > 0 {
+			continue
+		}
+		switch {
+		case entry.Name == testSetUpFunction:
+			setUp = true
+		case strings.HasPrefix(entry.Name, "test"):
+			functions = append(functions, entry)
+		}
+	}
+	sort.Slice(functions, func(i, j int) bool { return functions[i].Name < functions[j].Name })
+	return functions, setUp, nil
+}
+
+func callTestFunction(call testCaller, abiSpec, name string, simulate bool) (*testCallResult, error) {
+	data, err := monaxAbi.Packer(abiSpec, name)
+	if err != nil {
+		return nil, err
+	}
+	return call(data, simulate)
+}
+
+// A test passes unless it reverted, emitted an Assert event that failed or returned false
+func testOutcome(function *testContractFunction, res *testCallResult, failedAssertions []string) (bool, string) {
+	if res.Exception != "" {
+		return false, fmt.Sprintf("reverted: %s", revertReason(res))
+	}
+	if len(failedAssertions) > 0 {
+		return false, fmt.Sprintf("assertion failed: %s", strings.Join(failedAssertions, "; "))
+	}
+	if len(function.Outputs) == 1 && function.Outputs[0].Type == "bool" {
+		if len(res.Return) != 32 {
+			return false, fmt.Sprintf("returned %X which is not a bool", res.Return)
+		}
+		if res.Return[31] == 0 {
+			return false, "returned false"
+		}
+	}
+	return true, ""
+}
+
+func revertReason(res *testCallResult) string {
+	if res.RevertReason != "" {
+		return res.RevertReason
+	}
+	return res.Exception
+}
+
+// Decodes the Log and Assert events of the test contract among logs, returning them along with those of the Assert
+// events that failed
+func decodeTestLogs(registry *abi.EventRegistry, logs []*evm_events.EventDataLog) ([]string, []string) {
+	var decodedLogs, failedAssertions []string
+	for _, eventLog := range logs {
+		if len(eventLog.Topics) == 0 {
+			continue
+		}
+		spec := registry.Event(eventLog.Topics[0])
+		if spec == nil || (spec.Name != testLogEvent && spec.Name != testAssertEvent) {
+			continue
+		}
+		decoded, err := spec.DecodeLog(eventLog.Topics, eventLog.Data)
+		if err != nil {
+			decodedLogs = append(decodedLogs, fmt.Sprintf("could not decode %s event: %v", spec.Name, err))
+			continue
+		}
+		vars := eventVariables(spec, decoded)
+		fields := make([]string, len(vars))
+		for i, variable := range vars {
+			fields[i] = fmt.Sprintf("%s=%s", variable.Name, variable.Value)
+		}
+		decodedLog := fmt.Sprintf("%s(%s)", spec.Name, strings.Join(fields, ", "))
+		decodedLogs = append(decodedLogs, decodedLog)
+		if spec.Name == testAssertEvent && assertionFailed(decoded) {
+			failedAssertions = append(failedAssertions, decodedLog)
+		}
+	}
+	return decodedLogs, failedAssertions
+}
+
+func assertionFailed(decoded *abi.DecodedLog) bool {
+	for _, field := range decoded.Fields {
+		if passed, ok := field.Value.(bool); ok && !passed {
+			return true
+		}
+	}
+	return false
+}
+
+func simulateTestCall(nodeClient client.NodeClient, caller, contract acm.Address,
+	data []byte) (*testCallResult, error) {
+
+	call, err := nodeClient.SimulateCall(caller, contract, data)
+	if err != nil {
+		return nil, err
+	}
+	res := &testCallResult{
+		Return:       call.Return,
+		Exception:    call.Exception,
+		RevertReason: call.RevertReason,
+	}
+	for _, event := range call.Events {
+		if event.EventDataLog != nil {
+			res.Logs = append(res.Logs, event.EventDataLog)
+		}
+	}
+	return res, nil
+}
+
+func commitTestCall(do *definitions.Do, nodeClient client.NodeClient, keyClient keys.KeyClient,
+	deploy *definitions.Deploy, address string, data []byte) (*testCallResult, error) {
+
+	// Don't use pubKey if account override
+	publicKey := do.PublicKey
+	if deploy.Source != do.Package.Account {
+		publicKey = ""
+	}
+	tx, err := rpc.Call(nodeClient, keyClient, publicKey, deploy.Source, address, "0", "", deploy.Gas,
+		deploy.Fee, hex.EncodeToString(data))
+	if err != nil {
+		return nil, err
+	}
+	res, err := broadcastTx(do, nodeClient, keyClient, tx)
+	if err != nil {
+		if res == nil {
+			return nil, err
+		}
+		// Reverted transactions emit no events
+		return &testCallResult{Return: res.Return, Exception: res.Exception, RevertReason: res.RevertReason}, nil
+	}
+	result := &testCallResult{Return: res.Return}
+	receipt, err := nodeClient.TxReceipt(res.Hash)
+	if err != nil {
+		// The events are only diagnostics so the test still counts
+		log.WithError(err).Warn("Could not get the events of the test transaction")
+	} else if receipt != nil {
+		result.Logs = receipt.Logs
+	}
+	return result, nil
+}
+
+func logContractTest(test *definitions.ContractTest) {
+	for _, decodedLog := range test.Logs {
+		log.WithField("=>", decodedLog).Info("Test Event")
+	}
+	if test.Passed {
+		log.WithField("=>", test.Name).Warn("Test Passed")
+		return
+	}
+	log.WithFields(log.Fields{
+		"test":   test.Name,
+		"reason": test.Message,
+	}).Warn("Test Failed")
+}
+
+// Returns an assertion failure naming the tests that failed, if any did
+func contractTestFailures(contract string, tests []*definitions.ContractTest) error {
+	var failed []string
+	for _, test := range tests {
+		if !test.Passed {
+			failed = append(failed, test.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return ErrAssertionFailed{fmt.Sprintf("%d of %d tests of %s failed: %s", len(failed), len(tests), contract,
+		strings.Join(failed, ", "))}
+}
+
+// The tests each test-contract job ran, by job name
+func contractTests(jobs []*definitions.Job) map[string][]*definitions.ContractTest {
+	tests := make(map[string][]*definitions.ContractTest)
+	for _, job := range jobs {
+		if len(job.JobTests) > 0 {
+			tests[job.JobName] = job.JobTests
+		}
+	}
+	return tests
+}
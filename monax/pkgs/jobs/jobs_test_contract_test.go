@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/burrow/binary"
+	evm_events "github.com/hyperledger/burrow/execution/evm/events"
+	"github.com/monax/bosmarmot/monax/definitions"
+	monaxAbi "github.com/monax/bosmarmot/monax/pkgs/abi"
+)
+
+const testContractABI = `[
+{"type":"function","name":"setUp","inputs":[],"outputs":[]},
+{"type":"function","name":"testTransfer","inputs":[],"outputs":[{"name":"","type":"bool"}]},
+{"type":"function","name":"testOverdraw","inputs":[],"outputs":[{"name":"","type":"bool"}]},
+{"type":"function","name":"testOwner","inputs":[],"outputs":[]},
+{"type":"function","name":"testBalance","inputs":[],"outputs":[]},
+{"type":"function","name":"testWithAmount","inputs":[{"name":"amount","type":"uint256"}],"outputs":[]},
+{"type":"function","name":"helper","inputs":[],"outputs":[]},
+{"type":"event","name":"Log","anonymous":false,"inputs":[{"name":"value","type":"uint256","indexed":false}]},
+{"type":"event","name":"Assert","anonymous":false,"inputs":[{"name":"passed","type":"bool","indexed":false},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+// Answers calls to the test contract by function name, recording the calls made
+type testContractCaller struct {
+	results map[string]*testCallResult
+	calls   []string
+}
+
+func (tcc *testContractCaller) call(data []byte, simulate bool) (*testCallResult, error) {
+	for name, res := range tcc.results {
+		selector, err := monaxAbi.Packer(testContractABI, name)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(selector, data) {
+			if simulate {
+				name += " simulated"
+			}
+			tcc.calls = append(tcc.calls, name)
+			return res, nil
+		}
+	}
+	return &testCallResult{}, nil
+}
+
+func testEventLog(event string, words ...string) *evm_events.EventDataLog {
+	spec, err := findEvent(testContractABI, event)
+	if err != nil {
+		panic(err)
+	}
+	return &evm_events.EventDataLog{Topics: []binary.Word256{spec.ID}, Data: hexWords(words...)}
+}
+
+func TestRunContractTests(t *testing.T) {
+	caller := &testContractCaller{results: map[string]*testCallResult{
+		"setUp":        {Logs: []*evm_events.EventDataLog{testEventLog("Log", "1")}},
+		"testTransfer": {Return: hexWords("1")},
+		"testOverdraw": {Return: hexWords("0")},
+		"testOwner":    {Exception: "execution reverted", RevertReason: "not owner"},
+		"testBalance": {Logs: []*evm_events.EventDataLog{
+			testEventLog("Log", "64"),
+			testEventLog("Assert", "0", "64"),
+		}},
+	}}
+	tests, err := runContractTests(testContractABI, caller.call, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// setUp is committed before each test, which are run in name order
+	expectedCalls := "setUp,testBalance simulated,setUp,testOverdraw simulated,setUp,testOwner simulated," +
+		"setUp,testTransfer simulated"
+	if strings.Join(caller.calls, ",") != expectedCalls {
+		t.Errorf("expected calls %s but got %s", expectedCalls, strings.Join(caller.calls, ","))
+	}
+	expected := []definitions.ContractTest{
+		{Name: "testBalance", Message: "assertion failed: Assert(passed=false, value=100)",
+			Logs: []string{"Log(value=1)", "Log(value=100)", "Assert(passed=false, value=100)"}},
+		{Name: "testOverdraw", Message: "returned false", Logs: []string{"Log(value=1)"}},
+		{Name: "testOwner", Message: "reverted: not owner", Logs: []string{"Log(value=1)"}},
+		{Name: "testTransfer", Passed: true, Logs: []string{"Log(value=1)"}},
+	}
+	if len(tests) != len(expected) {
+		t.Fatalf("expected %d tests but got %d", len(expected), len(tests))
+	}
+	for i, test := range tests {
+		if test.Name != expected[i].Name || test.Passed != expected[i].Passed || test.Message != expected[i].Message ||
+			strings.Join(test.Logs, ";") != strings.Join(expected[i].Logs, ";") {
+			t.Errorf("expected test %v but got %v", expected[i], *test)
+		}
+	}
+
+	err = contractTestFailures("Token", tests)
+	if _, ok := err.(ErrAssertionFailed); !ok || err.Error() != "3 of 4 tests of Token failed: testBalance, "+
+		"testOverdraw, testOwner" {
+		t.Errorf("expected the failed tests to be an assertion failure but got %v", err)
+	}
+}
+
+func TestRunContractTestsSetUpReverts(t *testing.T) {
+	caller := &testContractCaller{results: map[string]*testCallResult{
+		"setUp": {Exception: "execution reverted"},
+	}}
+	tests, err := runContractTests(testContractABI, caller.call, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		if test.Passed || test.Message != "setUp reverted: execution reverted" {
+			t.Errorf("expected %s to fail since setUp reverted but got %v", test.Name, *test)
+		}
+	}
+	for _, call := range caller.calls {
+		if call != "setUp" {
+			t.Errorf("expected no test to be called when setUp reverts but %s was", call)
+		}
+	}
+
+	if _, err := runContractTests(`[{"type":"function","name":"helper","inputs":[],"outputs":[]}]`, caller.call,
+		false); err == nil {
+		t.Errorf("expected a contract without tests to be an error")
+	}
+}
+
+func TestContractTestReports(t *testing.T) {
+	job := &definitions.Job{
+		JobName:      "token",
+		TestContract: &definitions.TestContract{},
+		JobTests: []*definitions.ContractTest{
+			{Name: "testTransfer", Passed: true, Duration: time.Second},
+			{Name: "testOwner", Message: "reverted: not owner", Logs: []string{"Log(value=1)"}},
+		},
+	}
+	reports := newJobReports(job, 3*time.Second, ErrAssertionFailed{"1 of 2 tests of Token failed: testOwner"})
+	if len(reports) != 2 {
+		t.Fatalf("expected a report for each test but got %d", len(reports))
+	}
+	if reports[0].Name != "token.testTransfer" || reports[0].Type != "test-contract" ||
+		reports[0].Status != JobPassed || reports[0].Duration != time.Second {
+		t.Errorf("unexpected report of passing test %v", *reports[0])
+	}
+	if reports[1].Status != JobFailed || !reports[1].Assertion ||
+		reports[1].Message != "reverted: not owner\nLog(value=1)" {
+		t.Errorf("unexpected report of failing test %v", *reports[1])
+	}
+
+	// A job that stops partway through its tests is reported as an error as well
+	reports = newJobReports(job, 3*time.Second, ErrEventTimeout{})
+	if len(reports) != 3 || reports[2].Name != "token" || reports[2].Assertion {
+		t.Errorf("expected the job to be reported as an error after its tests but got %v", reports)
+	}
+}
@@ -3,11 +3,14 @@ package jobs
 import (
 	"encoding/hex"
 	"fmt"
+	"math/big"
+	"regexp"
 	"strconv"
+	"strings"
 
 	acm "github.com/hyperledger/burrow/account"
 	"github.com/hyperledger/burrow/client"
-	"github.com/hyperledger/burrow/logging/loggers"
+	"github.com/hyperledger/burrow/permission"
 	"github.com/monax/bosmarmot/monax/definitions"
 	"github.com/monax/bosmarmot/monax/log"
 	"github.com/monax/bosmarmot/monax/pkgs/abi"
@@ -39,6 +42,12 @@ func QueryContractJob(query *definitions.QueryContract, do *definitions.Do) (str
 	if err != nil {
 		return "", nil, err
 	}
+	if do.DryRun {
+		err = simulationOf(do).checkAccount(toAddress)
+		if err != nil {
+			return "", nil, err
+		}
+	}
 
 	// Get the packed data from the ABI functions
 	var data string
@@ -60,7 +69,7 @@ func QueryContractJob(query *definitions.QueryContract, do *definitions.Do) (str
 	}
 
 	// Call the client
-	nodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
+	nodeClient := util.NodeClient(do)
 	result, _, err := nodeClient.QueryContract(fromAddress, toAddress, dataBytes)
 	if err != nil {
 		return "", nil, err
@@ -76,7 +85,8 @@ func QueryContractJob(query *definitions.QueryContract, do *definitions.Do) (str
 		query.Variables, err = abi.ReadAndDecodeContractReturn(query.ABI, query.Function, result, do)
 	}
 	if err != nil {
-		return "", nil, err
+		return "", nil, fmt.Errorf("could not decode the return of %s from contract %s against its ABI: %v",
+			query.Function, toAddress, err)
 	}
 
 	result2 := util.GetReturnValue(query.Variables)
@@ -98,6 +108,17 @@ func QueryAccountJob(query *definitions.QueryAccount, do *definitions.Do) (strin
 	arg := fmt.Sprintf("%s:%s", query.Account, query.Field)
 	log.WithField("=>", arg).Info("Querying Account")
 
+	if do.DryRun {
+		address, err := acm.AddressFromHexString(query.Account)
+		if err != nil {
+			return "", fmt.Errorf("Account Addr %s is improper hex: %v", query.Account, err)
+		}
+		err = simulationOf(do).checkAccount(address)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	result, err := util.AccountsInfo(query.Account, query.Field, do)
 	if err != nil {
 		return "", err
@@ -112,19 +133,33 @@ func QueryAccountJob(query *definitions.QueryAccount, do *definitions.Do) (strin
 	return result, nil
 }
 
-func QueryNameJob(query *definitions.QueryName, do *definitions.Do) (string, error) {
+func QueryNameJob(query *definitions.QueryName, do *definitions.Do) (string, []*definitions.Variable, error) {
 	// Preprocess variables
 	query.Name, _ = util.PreProcess(query.Name, do)
 	query.Field, _ = util.PreProcess(query.Field, do)
 
+	// Set defaults
+	query.Field = useDefault(query.Field, "data")
+
 	// Peform query
 	log.WithFields(log.Fields{
 		"name":  query.Name,
 		"field": query.Field,
 	}).Info("Querying")
-	result, err := util.NamesInfo(query.Name, query.Field, do)
+
+	if do.DryRun {
+		err := simulationOf(do).checkName(query.Name)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	vars, err := util.NameVariables(query.Name, do)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	result, err := util.NameField(query.Name, query.Field, vars)
+	if err != nil {
+		return "", nil, err
 	}
 
 	if result != "" {
@@ -132,7 +167,67 @@ func QueryNameJob(query *definitions.QueryName, do *definitions.Do) (string, err
 	} else {
 		log.Debug("No return.")
 	}
-	return result, nil
+	return result, vars, nil
+}
+
+func QueryPermsJob(query *definitions.QueryPerms, do *definitions.Do) (string, []*definitions.Variable, error) {
+	// Preprocess variables
+	query.Account, _ = util.PreProcess(query.Account, do)
+
+	// Perform query
+	log.WithField("=>", query.Account).Info("Querying Permissions")
+
+	address, err := acm.AddressFromHexString(query.Account)
+	if err != nil {
+		return "", nil, fmt.Errorf("Account Addr %s is improper hex: %v", query.Account, err)
+	}
+	if do.DryRun {
+		err = simulationOf(do).checkAccount(address)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	result, vars, err := permissionVariables(util.NodeClient(do), address)
+	if err != nil {
+		return "", nil, err
+	}
+
+	log.WithField("=>", result).Warn("Return Value")
+	return result, vars, nil
+}
+
+// Returns the comma separated names of the base permissions the account holds, taking any it has not set from the
+// global permissions, along with a "true" or "false" variable for every permission and a variable listing its roles
+func permissionVariables(nodeClient client.NodeClient, address acm.Address) (string, []*definitions.Variable, error) {
+	account, err := nodeClient.GetAccount(address)
+	if err != nil {
+		return "", nil, err
+	}
+	if account == nil {
+		return "", nil, fmt.Errorf("account %s does not exist", address)
+	}
+	global, err := nodeClient.GetAccount(permission.GlobalPermissionsAddress)
+	if err != nil {
+		return "", nil, err
+	}
+	base := account.Permissions().Base
+	if global != nil {
+		base = base.Compose(global.Permissions().Base)
+	}
+
+	var held []string
+	var vars []*definitions.Variable
+	for i := uint(0); i < permission.NumPermissions; i++ {
+		flag := permission.PermFlagToString(1 << i)
+		has, _ := base.Get(1 << i)
+		if has {
+			held = append(held, flag)
+		}
+		vars = append(vars, &definitions.Variable{Name: flag, Value: strconv.FormatBool(has)})
+	}
+	vars = append(vars, &definitions.Variable{Name: "roles", Value: strings.Join(account.Permissions().Roles, ",")})
+	return strings.Join(held, ","), vars, nil
 }
 
 func QueryValsJob(query *definitions.QueryVals, do *definitions.Do) (string, error) {
@@ -156,8 +251,58 @@ func QueryValsJob(query *definitions.QueryVals, do *definitions.Do) (string, err
 	return result, nil
 }
 
+// ErrAssertionFailed is returned when an assert job's relation does not hold as opposed to when a job cannot be run
+type ErrAssertionFailed struct {
+	Message string
+}
+
+func (err ErrAssertionFailed) Error() string {
+	return err.Message
+}
+
+// Kinds of value an assertion can compare
+const (
+	integerKind = "integer"
+	addressKind = "address"
+	boolKind    = "bool"
+	stringKind  = "string"
+)
+
+// Relation aliases -> relation
+var assertRelations = map[string]string{
+	"==":       "==",
+	"eq":       "==",
+	"!=":       "!=",
+	"ne":       "!=",
+	">":        ">",
+	"gt":       ">",
+	">=":       ">=",
+	"ge":       ">=",
+	"<":        "<",
+	"lt":       "<",
+	"<=":       "<=",
+	"le":       "<=",
+	"contains": "contains",
+}
+
+var relationDescriptions = map[string]string{
+	"==": "equal to",
+	"!=": "not equal to",
+	">":  "greater than",
+	">=": "greater than or equal to",
+	"<":  "less than",
+	"<=": "less than or equal to",
+}
+
+var (
+	variableRefRegex  = regexp.MustCompile(`^\$([a-zA-Z0-9_]+)(?:\.([a-zA-Z0-9_]+))?$`)
+	addressValueRegex = regexp.MustCompile(`^(0[xX])?[0-9a-fA-F]{40}$`)
+)
+
 func AssertJob(assertion *definitions.Assert, do *definitions.Do) (string, error) {
-	var result string
+	// Find the ABI type of the key while we can still see which job it refers to
+	keyType := variableType(assertion.Key, do)
+
 	// Preprocess variables
 	assertion.Key, _ = util.PreProcess(assertion.Key, do)
 	assertion.Relation, _ = util.PreProcess(assertion.Relation, do)
@@ -170,93 +315,192 @@ func AssertJob(assertion *definitions.Assert, do *definitions.Do) (string, error
 		"value":    assertion.Value,
 	}).Info("Assertion =>")
 
-	switch assertion.Relation {
-	case "==", "eq":
-		/*log.Debug("Compare", strings.Compare(assertion.Key, assertion.Value))
-		log.Debug("UTF8?: ", utf8.ValidString(assertion.Key))
-		log.Debug("UTF8?: ", utf8.ValidString(assertion.Value))
-		log.Debug("UTF8?: ", utf8.RuneCountInString(assertion.Key))
-		log.Debug("UTF8?: ", utf8.RuneCountInString(assertion.Value))*/
-		if assertion.Key == assertion.Value {
-			return assertPass("==", assertion.Key, assertion.Value)
-		} else {
-			return assertFail("==", assertion.Key, assertion.Value)
+	relation, ok := assertRelations[assertion.Relation]
+	if !ok {
+		return "", fmt.Errorf("Error: Bad assert relation: \"%s\" is not a valid relation. See documentation for more information.", assertion.Relation)
+	}
+
+	kind, holds, err := assertionHolds(relation, keyType, assertion.Key, assertion.Value)
+	if err != nil {
+		return "", err
+	}
+	if holds {
+		return assertPass(relation, assertion.Key, assertion.Value)
+	}
+	return assertFail(relation, kind, assertion.Key, assertion.Value)
+}
+
+// Returns the ABI type of a $job or $job.variable reference to a query result or "" if unknown
+func variableType(reference string, do *definitions.Do) string {
+	match := variableRefRegex.FindStringSubmatch(strings.TrimSpace(reference))
+	if match == nil {
+		return ""
+	}
+	for _, job := range do.Package.AllJobs() {
+		if job.JobName != match[1] {
+			continue
 		}
-	case "!=", "ne":
-		if assertion.Key != assertion.Value {
-			return assertPass("!=", assertion.Key, assertion.Value)
-		} else {
-			return assertFail("!=", assertion.Key, assertion.Value)
+		if match[2] == "" {
+			if len(job.JobVars) == 1 {
+				return job.JobVars[0].Type
+			}
+			return ""
 		}
-	case ">", "gt":
-		k, v, err := bulkConvert(assertion.Key, assertion.Value)
-		if err != nil {
-			return convFail()
+		for _, variable := range job.JobVars {
+			if variable.Name == match[2] {
+				return variable.Type
+			}
 		}
-		if k > v {
-			return assertPass(">", assertion.Key, assertion.Value)
-		} else {
-			return assertFail(">", assertion.Key, assertion.Value)
+	}
+	return ""
+}
+
+func assertionHolds(relation, keyType, key, value string) (kind string, holds bool, err error) {
+	if relation == "contains" {
+		elements, isArray := arrayElements(key)
+		if !isArray {
+			return stringKind, strings.Contains(key, value), nil
 		}
-	case ">=", "ge":
-		k, v, err := bulkConvert(assertion.Key, assertion.Value)
-		if err != nil {
-			return convFail()
+		// The element type of an ABI array type such as uint256[3] is everything before the last bracket
+		var elementType string
+		if i := strings.LastIndex(keyType, "["); i >= 0 {
+			elementType = keyType[:i]
 		}
-		if k >= v {
-			return assertPass(">=", assertion.Key, assertion.Value)
-		} else {
-			return assertFail(">=", assertion.Key, assertion.Value)
+		for _, element := range elements {
+			kind, holds, err = assertionHolds("==", elementType, element, value)
+			if err != nil || holds {
+				return kind, holds, err
+			}
 		}
-	case "<", "lt":
-		k, v, err := bulkConvert(assertion.Key, assertion.Value)
+		return kind, false, nil
+	}
+
+	kind = kindOf(keyType, key, value)
+	switch kind {
+	case integerKind:
+		k, v, err := bulkConvert(key, value)
 		if err != nil {
-			return convFail()
+			return kind, false, err
 		}
-		if k < v {
-			return assertPass("<", assertion.Key, assertion.Value)
-		} else {
-			return assertFail("<", assertion.Key, assertion.Value)
+		cmp := k.Cmp(v)
+		switch relation {
+		case "==":
+			return kind, cmp == 0, nil
+		case "!=":
+			return kind, cmp != 0, nil
+		case ">":
+			return kind, cmp > 0, nil
+		case ">=":
+			return kind, cmp >= 0, nil
+		case "<":
+			return kind, cmp < 0, nil
+		case "<=":
+			return kind, cmp <= 0, nil
 		}
-	case "<=", "le":
-		k, v, err := bulkConvert(assertion.Key, assertion.Value)
+	case addressKind:
+		key, value = normaliseAddress(key), normaliseAddress(value)
+	case boolKind:
+		k, err := strconv.ParseBool(key)
 		if err != nil {
-			return convFail()
+			return kind, false, fmt.Errorf("The key of your assertion (%s) cannot be converted into a bool", key)
 		}
-		if k <= v {
-			return assertPass("<=", assertion.Key, assertion.Value)
-		} else {
-			return assertFail("<=", assertion.Key, assertion.Value)
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return kind, false, fmt.Errorf("The value of your assertion (%s) cannot be converted into a bool", value)
 		}
+		key, value = strconv.FormatBool(k), strconv.FormatBool(v)
+	}
+
+	switch relation {
+	case "==":
+		return kind, key == value, nil
+	case "!=":
+		return kind, key != value, nil
 	default:
-		return "", fmt.Errorf("Error: Bad assert relation: \"%s\" is not a valid relation. See documentation for more information.", assertion.Relation)
+		return kind, false, fmt.Errorf("The %s relation can only be used to compare integers but %s and %s "+
+			"are compared as %s values.\nFor other values please use the eq, ne, or contains relations.",
+			relation, key, value, kind)
 	}
+}
 
-	return result, nil
+// Picks how to compare key and value, preferring the ABI type of the key when it is known
+func kindOf(keyType, key, value string) string {
+	switch {
+	case strings.HasPrefix(keyType, "int"), strings.HasPrefix(keyType, "uint"):
+		return integerKind
+	case keyType == "address":
+		return addressKind
+	case keyType == "bool":
+		return boolKind
+	case keyType != "":
+		return stringKind
+	}
+	if _, _, err := bulkConvert(key, value); err == nil {
+		return integerKind
+	}
+	if addressValueRegex.MatchString(key) && addressValueRegex.MatchString(value) {
+		return addressKind
+	}
+	if _, err := strconv.ParseBool(key); err == nil {
+		if _, err := strconv.ParseBool(value); err == nil {
+			return boolKind
+		}
+	}
+	return stringKind
 }
 
-func bulkConvert(key, value string) (int, int, error) {
-	k, err := strconv.Atoi(key)
-	if err != nil {
-		return 0, 0, err
+func arrayElements(value string) ([]string, bool) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, false
 	}
-	v, err := strconv.Atoi(value)
-	if err != nil {
-		return 0, 0, err
+	inner := strings.TrimSpace(value[1 : len(value)-1])
+	if inner == "" {
+		return []string{}, true
+	}
+	elements := strings.Split(inner, ",")
+	for i, element := range elements {
+		elements[i] = strings.TrimSpace(element)
+	}
+	return elements, true
+}
+
+func normaliseAddress(address string) string {
+	return strings.ToUpper(strings.TrimPrefix(strings.TrimPrefix(address, "0x"), "0X"))
+}
+
+func bulkConvert(key, value string) (*big.Int, *big.Int, error) {
+	k, ok := parseInteger(key)
+	if !ok {
+		return nil, nil, fmt.Errorf("The key of your assertion (%s) cannot be converted into an integer.\nFor string conversions please use the equal or not equal relations.", key)
+	}
+	v, ok := parseInteger(value)
+	if !ok {
+		return nil, nil, fmt.Errorf("The value of your assertion (%s) cannot be converted into an integer.\nFor string conversions please use the equal or not equal relations.", value)
 	}
 	return k, v, nil
 }
 
+// Parses decimal or 0x prefixed hex integers of any size
+func parseInteger(value string) (*big.Int, bool) {
+	value = strings.TrimSpace(value)
+	base := 10
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") {
+		value = value[2:]
+		base = 16
+	}
+	return new(big.Int).SetString(value, base)
+}
+
 func assertPass(typ, key, val string) (string, error) {
 	log.WithField("=>", fmt.Sprintf("%s %s %s", key, typ, val)).Warn("Assertion Succeeded")
 	return "passed", nil
 }
 
-func assertFail(typ, key, val string) (string, error) {
+func assertFail(typ, kind, key, val string) (string, error) {
 	log.WithField("=>", fmt.Sprintf("%s %s %s", key, typ, val)).Warn("Assertion Failed")
-	return "failed", fmt.Errorf("assertion failed")
-}
-
-func convFail() (string, error) {
-	return "", fmt.Errorf("The Key of your assertion cannot be converted into an integer.\nFor string conversions please use the equal or not equal relations.")
+	if typ == "contains" {
+		return "failed", ErrAssertionFailed{fmt.Sprintf("assertion failed: key %s does not contain value %s", key, val)}
+	}
+	return "failed", ErrAssertionFailed{fmt.Sprintf("assertion failed: key %s is not %s value %s (compared as %s values)",
+		key, relationDescriptions[typ], val, kind)}
 }
@@ -0,0 +1,188 @@
+package jobs
+
+import (
+	"encoding/json"
+	"testing"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/permission"
+	ptypes "github.com/hyperledger/burrow/permission/types"
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+func TestAssertJob(t *testing.T) {
+	do := definitions.NowDo()
+	do.Package = &definitions.Package{
+		Jobs: []*definitions.Job{
+			{
+				JobName:   "balance",
+				JobResult: "115792089237316195423570985008687907853269984665640564039457584007913129639935",
+				JobVars: []*definitions.Variable{{Name: "0", Type: "uint256",
+					Value: "115792089237316195423570985008687907853269984665640564039457584007913129639935"}},
+			},
+			{
+				JobName:   "owner",
+				JobResult: "6a3affb16bfb95aa547930572d71c460efbcd857",
+				JobVars: []*definitions.Variable{{Name: "owner", Type: "address",
+					Value: "6a3affb16bfb95aa547930572d71c460efbcd857"}},
+			},
+			{
+				JobName:   "ids",
+				JobResult: "[1,2,3]",
+				JobVars:   []*definitions.Variable{{Name: "0", Type: "uint8[3]", Value: "[1,2,3]"}},
+			},
+			{
+				JobName:   "name",
+				JobResult: "0011",
+				JobVars:   []*definitions.Variable{{Name: "0", Type: "string", Value: "0011"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		key      string
+		relation string
+		value    string
+		pass     bool
+	}{
+		{"$balance", "gt", "1000", true},
+		{"$balance", "le", "1000", false},
+		{"$owner", "eq", "0x6A3AFFB16BFB95AA547930572D71C460EFBCD857", true},
+		{"$owner.owner", "ne", "6A3AFFB16BFB95AA547930572D71C460EFBCD857", false},
+		{"$ids", "contains", "2", true},
+		{"$ids", "contains", "02", true},
+		{"$ids", "contains", "4", false},
+		{"$name", "eq", "11", false},
+		{"$name", "contains", "11", true},
+		{"TRUE", "eq", "true", true},
+		{"10", "==", "0x0a", true},
+		{"9", "<", "10", true},
+	}
+	for _, tt := range tests {
+		_, err := AssertJob(&definitions.Assert{Key: tt.key, Relation: tt.relation, Value: tt.value}, do)
+		if tt.pass && err != nil {
+			t.Errorf("expected %s %s %s to pass but got: %v", tt.key, tt.relation, tt.value, err)
+		}
+		if !tt.pass {
+			if _, ok := err.(ErrAssertionFailed); !ok {
+				t.Errorf("expected %s %s %s to fail the assertion but got: %v", tt.key, tt.relation, tt.value, err)
+			}
+		}
+	}
+}
+
+func TestAssertJobErrors(t *testing.T) {
+	do := definitions.NowDo()
+	do.Package = &definitions.Package{}
+
+	_, err := AssertJob(&definitions.Assert{Key: "foo", Relation: "gt", Value: "1"}, do)
+	if err == nil {
+		t.Errorf("ordering strings should be an error")
+	}
+	if _, ok := err.(ErrAssertionFailed); ok {
+		t.Errorf("a relation that cannot be evaluated is not a failed assertion")
+	}
+	_, err = AssertJob(&definitions.Assert{Key: "1", Relation: "approx", Value: "1"}, do)
+	if err == nil {
+		t.Errorf("unknown relation should be an error")
+	}
+}
+
+func TestQueryContractReturnValues(t *testing.T) {
+	do := definitions.NowDo()
+	do.Package = &definitions.Package{
+		Jobs: []*definitions.Job{
+			{
+				JobName:   "holdings",
+				JobResult: "(7, [1040E6521541DAB4E7EE57F21226DD17CE9F0FB7,00000000000000000000000000000000000000FF], true)",
+				JobVars: []*definitions.Variable{
+					{Name: "total", Type: "uint256", Value: "7"},
+					{Name: "holders", Type: "address[]",
+						Value: "[1040E6521541DAB4E7EE57F21226DD17CE9F0FB7,00000000000000000000000000000000000000FF]"},
+					{Name: "2", Type: "bool", Value: "true"},
+				},
+			},
+			{
+				JobName:   "grid",
+				JobResult: "[[1,2],[3,4]]",
+				JobVars:   []*definitions.Variable{{Name: "0", Type: "uint8[2][2]", Value: "[[1,2],[3,4]]"}},
+			},
+		},
+	}
+
+	for _, tt := range []struct {
+		variable string
+		value    string
+	}{
+		{"$holdings.total", "7"},
+		{"$holdings.0", "7"},
+		{"$holdings.holders[1]", "00000000000000000000000000000000000000FF"},
+		{"$holdings.1[0]", "1040E6521541DAB4E7EE57F21226DD17CE9F0FB7"},
+		{"$holdings.2", "true"},
+		{"$grid[1]", "[3,4]"},
+		{"$grid.0[1][0]", "3"},
+	} {
+		value, err := util.PreProcess(tt.variable, do)
+		if err != nil {
+			t.Errorf("could not resolve %s: %v", tt.variable, err)
+		} else if value != tt.value {
+			t.Errorf("expected %s to resolve to %s but got %s", tt.variable, tt.value, value)
+		}
+	}
+	if _, err := util.PreProcess("$holdings.holders[2]", do); err == nil {
+		t.Errorf("indexing beyond the end of an array should be an error")
+	}
+
+	bs, err := json.Marshal(jobResultOutput(do.Package.Jobs[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `["7",["1040E6521541DAB4E7EE57F21226DD17CE9F0FB7","00000000000000000000000000000000000000FF"],"true"]`
+	if string(bs) != expected {
+		t.Errorf("expected output %s but got %s", expected, bs)
+	}
+}
+
+func TestPermissionVariables(t *testing.T) {
+	nodeClient, address := newTestNodeClient(0, permission.Send)
+	nodeClient.accounts[address] = acm.ConcreteAccount{
+		Address: address,
+		Permissions: ptypes.AccountPermissions{
+			Base:  ptypes.BasePermissions{Perms: permission.Send, SetBit: permission.Send | permission.Call},
+			Roles: []string{"marmot", "beaver"},
+		},
+	}.Account()
+	nodeClient.accounts[permission.GlobalPermissionsAddress] = acm.ConcreteAccount{
+		Address: permission.GlobalPermissionsAddress,
+		Permissions: ptypes.AccountPermissions{
+			Base: ptypes.BasePermissions{Perms: permission.Call | permission.Name, SetBit: permission.AllPermFlags},
+		},
+	}.Account()
+
+	result, vars, err := permissionVariables(nodeClient, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "send,name" {
+		t.Errorf("expected account to hold send and name but got %s", result)
+	}
+	expected := map[string]string{"send": "true", "call": "false", "name": "true", "root": "false",
+		"roles": "marmot,beaver"}
+	values := make(map[string]string)
+	for _, v := range vars {
+		values[v.Name] = v.Value
+	}
+	if len(values) != int(permission.NumPermissions)+1 {
+		t.Errorf("expected a variable for every permission and the roles but got %v", values)
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("expected %s to be %s but got %s", name, value, values[name])
+		}
+	}
+
+	if _, _, err := permissionVariables(nodeClient, acm.Address{9}); err == nil {
+		t.Errorf("querying the permissions of a missing account should be an error")
+	}
+}
@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+// ErrJobTimeout is returned for a job that was cancelled because it ran for longer than its timeout or the run
+// passed its deadline, any call it was making to the chain having been abandoned
+type ErrJobTimeout struct {
+	JobName string
+	// The timeout of the job, or the deadline of the run when Deadline is set
+	Timeout  time.Duration
+	Deadline bool
+}
+
+func (err ErrJobTimeout) Error() string {
+	if err.Deadline {
+		return fmt.Sprintf("job %s was cancelled since the run passed its deadline of %v", err.JobName, err.Timeout)
+	}
+	return fmt.Sprintf("job %s was cancelled since it did not finish within its timeout of %v", err.JobName,
+		err.Timeout)
+}
+
+// Returns the timeout of the job, zero when it has none
+func jobTimeout(job *definitions.Job, do *definitions.Do) (time.Duration, error) {
+	job.Timeout, _ = util.PreProcess(job.Timeout, do)
+	if job.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(job.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("timeout for job %s must be a positive duration such as 30s but got '%s'", job.JobName,
+			job.Timeout)
+	}
+	return timeout, nil
+}
+
+// Returns the context the job should run with, which is done once the job has run for timeout if it is positive or
+// when parent, the context of the run or the group the job belongs to, is
+func jobContext(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(parent, timeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// Returns the error for a job whose context is done. When it was cancelled by parent rather than by its own timeout
+// the error of parent is returned for whichever of the run or the group the job belongs to made it to report.
+func jobTimeoutError(job *definitions.Job, parent context.Context, timeout time.Duration) error {
+	if err := parent.Err(); err != nil {
+		return err
+	}
+	return ErrJobTimeout{JobName: job.JobName, Timeout: timeout}
+}
+
+// Returns the context for a run with a deadline, which is done once it has run for deadline if it is positive
+func runContext(deadline time.Duration) (context.Context, context.CancelFunc) {
+	if deadline > 0 {
+		return context.WithTimeout(context.Background(), deadline)
+	}
+	return context.WithCancel(context.Background())
+}
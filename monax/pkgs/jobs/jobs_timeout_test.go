@@ -0,0 +1,251 @@
+package jobs
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+// Stands in for a node that never answers, calls to it are only answered once the client gives up on them or the node
+// is closed
+type hangingNode struct {
+	*httptest.Server
+	waiting int32
+	closed  chan struct{}
+}
+
+func newHangingNode() *hangingNode {
+	node := &hangingNode{closed: make(chan struct{})}
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&node.waiting, 1)
+		defer atomic.AddInt32(&node.waiting, -1)
+		// The server only notices the client going away once the body has been read
+		io.Copy(ioutil.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-node.closed:
+		}
+	}))
+	return node
+}
+
+// Answers any calls still waiting so that closing the server does not wait on them
+func (node *hangingNode) Close() {
+	close(node.closed)
+	node.Server.Close()
+}
+
+// Waits for the calls made to the node to have been abandoned by the client
+func (node *hangingNode) expectCallsCancelled(t *testing.T) {
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&node.waiting) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected calls to the node to be cancelled but %d are still waiting",
+				atomic.LoadInt32(&node.waiting))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func queryJob(name string) *definitions.Job {
+	return &definitions.Job{JobName: name, QueryAccount: &definitions.QueryAccount{
+		Account: "0000000000000000000000000000000000000001",
+		Field:   "balance",
+	}}
+}
+
+func TestJobTimeout(t *testing.T) {
+	server := newHangingNode()
+	defer server.Close()
+	do := definitions.NowDo()
+	do.Package = definitions.BlankPackage()
+	do.ChainURL = server.URL
+	job := queryJob("query")
+	job.Timeout = "50ms"
+
+	start := time.Now()
+	err := runJobWithRetries(job, do)
+	if err != (ErrJobTimeout{JobName: "query", Timeout: 50 * time.Millisecond}) {
+		t.Fatalf("expected the job to time out but got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("expected the job to be cancelled after its timeout but it took %v", time.Since(start))
+	}
+	if do.Context != nil {
+		t.Errorf("expected do to be handed back with the context it was given")
+	}
+	server.expectCallsCancelled(t)
+
+	job.Timeout = "soon"
+	if err := runJobWithRetries(job, do); err == nil || err.Error() != "timeout for job query must be a positive "+
+		"duration such as 30s but got 'soon'" {
+		t.Errorf("expected an invalid timeout to be rejected but got %v", err)
+	}
+}
+
+func TestJobTimeoutStopsRetries(t *testing.T) {
+	do := definitions.NowDo()
+	do.Package = definitions.BlankPackage()
+	// Nothing listens here so every attempt fails and would otherwise be retried long after the timeout
+	do.ChainURL = "tcp://127.0.0.1:1"
+	job := queryJob("query")
+	job.Timeout = "50ms"
+	job.Retry = &definitions.Retry{MaxAttempts: "10", Backoff: "1h"}
+
+	start := time.Now()
+	if _, ok := runJobWithRetries(job, do).(ErrJobTimeout); !ok {
+		t.Fatalf("expected the job to time out while waiting to retry")
+	}
+	if time.Since(start) > time.Second || job.JobAttempts != 1 {
+		t.Errorf("expected the retry to be cancelled but the job made %d attempts in %v", job.JobAttempts,
+			time.Since(start))
+	}
+}
+
+func TestParallelJobTimeout(t *testing.T) {
+	server := newHangingNode()
+	defer server.Close()
+	do := definitions.NowDo()
+	do.Package = definitions.BlankPackage()
+	do.ChainURL = server.URL
+	job := &definitions.Job{
+		JobName:  "group",
+		Timeout:  "50ms",
+		Parallel: &definitions.Parallel{Jobs: []*definitions.Job{queryJob("first"), queryJob("second")}},
+	}
+	do.Package.Jobs = []*definitions.Job{job}
+
+	// The group is reported rather than the sub-jobs it cancelled
+	err := runJobWithRetries(job, do)
+	if err != (ErrJobTimeout{JobName: "group", Timeout: 50 * time.Millisecond}) {
+		t.Fatalf("expected the group to time out but got %v", err)
+	}
+	server.expectCallsCancelled(t)
+}
+
+// Runs the jobs returning the reports of the summary written by the run by job name and the error the run returned
+func runJobsSummary(t *testing.T, do *definitions.Do) (map[string]JobReport, error) {
+	dir, err := ioutil.TempDir("", "bos-timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	do.YAMLPath = filepath.Join(dir, "epm.yaml")
+	do.DefaultOutput = filepath.Join(dir, "epm.output.json")
+	do.SummaryOutput = filepath.Join(dir, "summary.json")
+	do.Overwrite = true
+
+	runErr := RunJobs(do)
+	bs, err := ioutil.ReadFile(do.SummaryOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := struct {
+		Jobs []JobReport
+	}{}
+	if err := json.Unmarshal(bs, &summary); err != nil {
+		t.Fatal(err)
+	}
+	reports := make(map[string]JobReport)
+	for _, report := range summary.Jobs {
+		reports[report.Name] = report
+	}
+	return reports, runErr
+}
+
+func TestRunJobsJobTimeoutContinues(t *testing.T) {
+	server := newHangingNode()
+	defer server.Close()
+	do := definitions.NowDo()
+	do.ChainURL = server.URL
+	do.ContinueOnFailure = true
+	hangs := queryJob("hangs")
+	hangs.Timeout = "50ms"
+	do.Package = &definitions.Package{Jobs: []*definitions.Job{hangs, setJob("after")}}
+
+	reports, err := runJobsSummary(t, do)
+	if err == nil {
+		t.Fatal("expected the run to fail since a job timed out")
+	}
+	if report := reports["hangs"]; report.Status != JobFailed || !report.Timeout {
+		t.Errorf("expected the job to fail with a timeout but got %v", report)
+	}
+	if report := reports["after"]; report.Status != JobPassed {
+		t.Errorf("expected the job after the one that timed out to run but got %v", report)
+	}
+	server.expectCallsCancelled(t)
+}
+
+func TestRunJobsDeadline(t *testing.T) {
+	server := newHangingNode()
+	defer server.Close()
+	do := definitions.NowDo()
+	do.ChainURL = server.URL
+	do.Deadline = 100 * time.Millisecond
+	// Nothing can run after the deadline whatever the failure policy
+	do.ContinueOnFailure = true
+	do.Package = &definitions.Package{Jobs: []*definitions.Job{setJob("first"), queryJob("hangs"),
+		setJob("after")}}
+
+	start := time.Now()
+	reports, err := runJobsSummary(t, do)
+	if err != (ErrJobTimeout{JobName: "hangs", Timeout: 100 * time.Millisecond, Deadline: true}) {
+		t.Fatalf("expected the run to be cancelled at its deadline but got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("expected the run to stop at its deadline but it took %v", time.Since(start))
+	}
+	if report := reports["first"]; report.Status != JobPassed {
+		t.Errorf("expected the job before the deadline to pass but got %v", report)
+	}
+	if report := reports["hangs"]; report.Status != JobFailed || !report.Timeout {
+		t.Errorf("expected the job running at the deadline to fail with a timeout but got %v", report)
+	}
+	if report := reports["after"]; report.Status != JobSkipped ||
+		report.Message != "not run since the run passed its deadline of 100ms" {
+		t.Errorf("expected the job after the deadline to be skipped but got %v", report)
+	}
+	if do.Context != nil {
+		t.Errorf("expected do to be handed back without the context of the run")
+	}
+	server.expectCallsCancelled(t)
+}
+
+func TestJobTimeoutReport(t *testing.T) {
+	reports := []*JobReport{newJobReport(&definitions.Job{JobName: "call", Call: &definitions.Call{}}, time.Second,
+		ErrJobTimeout{JobName: "call", Timeout: time.Second})}
+	if !reports[0].Timeout || reports[0].Assertion {
+		t.Errorf("expected a job that timed out to be reported as a timeout but got %v", *reports[0])
+	}
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "report.xml")
+	if err = WriteJobReportJUnit("deploy.yaml", reports, time.Second, logFile); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suites := new(junitTestSuites)
+	if err = xml.Unmarshal(bs, suites); err != nil {
+		t.Fatal(err)
+	}
+	if c := suites.Suites[0].Cases[0]; c.Error == nil || c.Error.Type != "timeout" ||
+		c.Error.Message != "job call was cancelled since it did not finish within its timeout of 1s" {
+		t.Errorf("expected the timeout to be an error of type timeout but got %v", c)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 
+	acm "github.com/hyperledger/burrow/account"
 	"github.com/hyperledger/burrow/client"
 	"github.com/hyperledger/burrow/client/rpc"
 	"github.com/hyperledger/burrow/keys"
@@ -21,7 +23,11 @@ func SendJob(send *definitions.Send, do *definitions.Do) (string, error) {
 	// Process Variables
 	send.Source, _ = util.PreProcess(send.Source, do)
 	send.Destination, _ = util.PreProcess(send.Destination, do)
-	send.Amount, _ = util.PreProcess(send.Amount, do)
+	amount, err := resolveAmount(send.Amount, do)
+	if err != nil {
+		return "", err
+	}
+	send.Amount = amount
 
 	// Use Default
 	send.Source = useDefault(send.Source, do.Package.Account)
@@ -40,7 +46,7 @@ func SendJob(send *definitions.Send, do *definitions.Do) (string, error) {
 		"amount":      send.Amount,
 	}).Info("Sending Transaction")
 
-	monaxNodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
+	monaxNodeClient := util.NodeClient(do)
 	monaxKeyClient := keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger())
 	tx, err := rpc.Send(monaxNodeClient, monaxKeyClient, do.PublicKey, send.Source, send.Destination, send.Amount, send.Nonce)
 	if err != nil {
@@ -59,6 +65,11 @@ func SendJob(send *definitions.Send, do *definitions.Do) (string, error) {
 func RegisterNameJob(name *definitions.RegisterName, do *definitions.Do) (string, error) {
 	// Process Variables
 	name.DataFile, _ = util.PreProcess(name.DataFile, do)
+	amount, err := resolveAmount(name.Amount, do)
+	if err != nil {
+		return "", err
+	}
+	name.Amount = amount
 
 	// If a data file is given it should be in csv format and
 	// it will be read first. Once the file is parsed and sent
@@ -100,6 +111,7 @@ func RegisterNameJob(name *definitions.RegisterName, do *definitions.Do) (string
 				Name:   record[0],
 				Data:   record[1],
 				Amount: record[2],
+				Lease:  name.Lease,
 				Fee:    name.Fee,
 				Nonce:  name.Nonce,
 			}, do)
@@ -126,19 +138,47 @@ func RegisterNameJob(name *definitions.RegisterName, do *definitions.Do) (string
 	}
 }
 
+// Registers a single name for a lease of some number of blocks
+func RegisterNameLeaseJob(name *definitions.RegisterName, do *definitions.Do) (string, error) {
+	if name.DataFile != "" {
+		return "", fmt.Errorf("register-name registers a single name so cannot take a data_file, " +
+			"use a register job instead")
+	}
+	if name.Lease == "" {
+		return "", fmt.Errorf("register-name requires the number of blocks to lease name %s for", name.Name)
+	}
+	if name.Data == "" {
+		return "", fmt.Errorf("register-name requires data to register name %s with", name.Name)
+	}
+	return registerNameTx(name, do)
+}
+
 // Runs an individual nametx.
 func registerNameTx(name *definitions.RegisterName, do *definitions.Do) (string, error) {
 	// Process Variables
 	name.Source, _ = util.PreProcess(name.Source, do)
 	name.Name, _ = util.PreProcess(name.Name, do)
 	name.Data, _ = util.PreProcess(name.Data, do)
-	name.Amount, _ = util.PreProcess(name.Amount, do)
+	name.Lease, _ = util.PreProcess(name.Lease, do)
 	name.Fee, _ = util.PreProcess(name.Fee, do)
 
 	// Set Defaults
 	name.Source = useDefault(name.Source, do.Package.Account)
 	name.Fee = useDefault(name.Fee, do.DefaultFee)
-	name.Amount = useDefault(name.Amount, do.DefaultAmount)
+	amount, err := resolveAmount(useDefault(name.Amount, do.DefaultAmount), do)
+	if err != nil {
+		return "", err
+	}
+	name.Amount = amount
+
+	monaxNodeClient := util.NodeClient(do)
+	if name.Lease != "" {
+		var err error
+		name.Amount, err = leaseAmount(monaxNodeClient, name)
+		if err != nil {
+			return "", err
+		}
+	}
 
 	// Don't use pubKey if account override
 	var oldKey string
@@ -154,7 +194,6 @@ func registerNameTx(name *definitions.RegisterName, do *definitions.Do) (string,
 		"amount": name.Amount,
 	}).Info("NameReg Transaction")
 
-	monaxNodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
 	monaxKeyClient := keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger())
 	tx, err := rpc.Name(monaxNodeClient, monaxKeyClient, do.PublicKey, name.Source, name.Amount, name.Nonce, name.Fee, name.Name, name.Data)
 	if err != nil {
@@ -170,6 +209,60 @@ func registerNameTx(name *definitions.RegisterName, do *definitions.Do) (string,
 	return txFinalize(do, tx)
 }
 
+// Computes the amount to send to register name for its lease, including the fee, from the chain's name registration
+// costs. The credit left on a registration the source owns counts towards extending it, and a name registered to
+// someone else that has not expired cannot be registered at all.
+func leaseAmount(nodeClient client.NodeClient, name *definitions.RegisterName) (string, error) {
+	lease, err := strconv.ParseUint(name.Lease, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("lease of name %s should be a number of blocks: %v", name.Name, err)
+	}
+	fee, err := strconv.ParseUint(name.Fee, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("fee is misformatted: %v", err)
+	}
+	source, err := acm.AddressFromHexString(name.Source)
+	if err != nil {
+		return "", fmt.Errorf("source of name %s is not an address: %v", name.Name, err)
+	}
+	costs, err := nodeClient.NameRegCosts()
+	if err != nil {
+		return "", err
+	}
+	entry, err := nodeClient.NameRegEntry(name.Name)
+	if err != nil {
+		return "", err
+	}
+
+	costPerBlock := costs.BlockCostMultiplier * costs.ByteCostMultiplier * (uint64(len(name.Data)) + costs.EntryBaseCost)
+	blocks := lease
+	var credit uint64
+	if entry != nil && entry.Expires > costs.BlockHeight {
+		if entry.Owner != source {
+			return "", fmt.Errorf("name %s is registered to %s until block %v so cannot be registered by %s",
+				name.Name, entry.Owner, entry.Expires, source)
+		}
+		// Mirrors the chain which credits the remaining blocks at the base cost of the old data
+		remaining := entry.Expires - costs.BlockHeight
+		blocks += remaining
+		credit = remaining * (uint64(len(entry.Data)) + costs.EntryBaseCost)
+	} else if lease < costs.MinRegistrationPeriod {
+		return "", fmt.Errorf("names must be registered for at least %v blocks but the lease of %s is %v",
+			costs.MinRegistrationPeriod, name.Name, lease)
+	}
+	var value uint64
+	if blocks*costPerBlock > credit {
+		value = blocks*costPerBlock - credit
+	}
+	log.WithFields(log.Fields{
+		"name":           name.Name,
+		"lease":          lease,
+		"cost_per_block": costPerBlock,
+		"credit":         credit,
+	}).Debug("Name Lease")
+	return strconv.FormatUint(value+fee, 10), nil
+}
+
 func PermissionJob(perm *definitions.Permission, do *definitions.Do) (string, error) {
 	// Process Variables
 	perm.Source, _ = util.PreProcess(perm.Source, do)
@@ -198,7 +291,7 @@ func PermissionJob(perm *definitions.Permission, do *definitions.Do) (string, er
 	//arg := fmt.Sprintf("%s:%s", args[0], args[1])
 	//log.WithField(perm.Action, arg).Info("Setting Permissions")
 
-	monaxNodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
+	monaxNodeClient := util.NodeClient(do)
 	monaxKeyClient := keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger())
 	tx, err := rpc.Permissions(monaxNodeClient, monaxKeyClient, do.PublicKey, perm.Source, perm.Nonce, perm.Action,
 		perm.Target, perm.PermissionFlag, perm.Role, perm.Value)
@@ -220,7 +313,11 @@ func PermissionJob(perm *definitions.Permission, do *definitions.Do) (string, er
 func BondJob(bond *definitions.Bond, do *definitions.Do) (string, error) {
 	// Process Variables
 	bond.Account, _ = util.PreProcess(bond.Account, do)
-	bond.Amount, _ = util.PreProcess(bond.Amount, do)
+	amount, err := resolveAmount(bond.Amount, do)
+	if err != nil {
+		return "", err
+	}
+	bond.Amount = amount
 	bond.PublicKey, _ = util.PreProcess(bond.PublicKey, do)
 
 	// Use Defaults
@@ -233,7 +330,7 @@ func BondJob(bond *definitions.Bond, do *definitions.Do) (string, error) {
 		"amount":     bond.Amount,
 	}).Infof("Bond Transaction")
 
-	monaxNodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
+	monaxNodeClient := util.NodeClient(do)
 	monaxKeyClient := keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger())
 	tx, err := rpc.Bond(monaxNodeClient, monaxKeyClient, do.PublicKey, bond.Account, bond.Amount, bond.Nonce)
 	if err != nil {
@@ -331,16 +428,12 @@ func RebondJob(rebond *definitions.Rebond, do *definitions.Do) (string, error) {
 func txFinalize(do *definitions.Do, tx interface{}) (string, error) {
 	var result string
 
-	nodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
+	nodeClient := util.NodeClient(do)
 	keyClient := keys.NewKeyClient(do.Signer, loggers.NewNoopInfoTraceLogger())
-	_, chainID, _, err := nodeClient.ChainId()
+	res, err := signAndBroadcast(do, nodeClient, keyClient, tx.(txs.Tx))
 	if err != nil {
 		return "", err
 	}
-	res, err := rpc.SignAndBroadcast(chainID, nodeClient, keyClient, tx.(txs.Tx), true, true, true)
-	if err != nil {
-		return util.MintChainErrorHandler(do, err)
-	}
 
 	if err := util.ReadTxSignAndBroadcast(res, err); err != nil {
 		return "", err
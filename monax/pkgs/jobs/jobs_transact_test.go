@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+
+	acm "github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/client"
+	"github.com/hyperledger/burrow/execution"
+	"github.com/hyperledger/burrow/rpc"
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+type testNameRegClient struct {
+	client.NodeClient
+	entries map[string]*execution.NameRegEntry
+}
+
+func (tnc *testNameRegClient) NameRegEntry(name string) (*execution.NameRegEntry, error) {
+	return tnc.entries[name], nil
+}
+
+func (tnc *testNameRegClient) NameRegCosts() (*rpc.ResultNameRegCosts, error) {
+	return &rpc.ResultNameRegCosts{
+		BlockHeight:           100,
+		ByteCostMultiplier:    1,
+		BlockCostMultiplier:   2,
+		EntryBaseCost:         32,
+		MinRegistrationPeriod: 5,
+	}, nil
+}
+
+func TestLeaseAmount(t *testing.T) {
+	owner, other := acm.Address{1}, acm.Address{2}
+	nodeClient := &testNameRegClient{entries: map[string]*execution.NameRegEntry{
+		"mine":    {Name: "mine", Owner: owner, Data: "12345678", Expires: 110},
+		"theirs":  {Name: "theirs", Owner: other, Data: "data", Expires: 101},
+		"expired": {Name: "expired", Owner: other, Data: "data", Expires: 100},
+	}}
+	lease := func(name, data, blocks string) (string, error) {
+		return leaseAmount(nodeClient, &definitions.RegisterName{
+			Source: owner.String(),
+			Name:   name,
+			Data:   data,
+			Lease:  blocks,
+			Fee:    "7",
+		})
+	}
+
+	// 10 blocks of (8 + 32)*2 plus the fee
+	amount, err := lease("new", "abcdefgh", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount != "807" {
+		t.Errorf("expected to send 807 for a new name but got %s", amount)
+	}
+	amount, err = lease("expired", "abcdefgh", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount != "807" {
+		t.Errorf("expected to send 807 for an expired name but got %s", amount)
+	}
+	// the 10 blocks left at a base cost of 40 are credited against 20 blocks at 80
+	amount, err = lease("mine", "abcdefgh", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount != "1207" {
+		t.Errorf("expected to send 1207 to extend an owned name but got %s", amount)
+	}
+
+	_, err = lease("theirs", "abcdefgh", "10")
+	if err == nil || !strings.Contains(err.Error(), other.String()) {
+		t.Errorf("registering a name someone else owns should fail naming the owner but got %v", err)
+	}
+	if _, err = lease("new", "abcdefgh", "4"); err == nil {
+		t.Errorf("leasing a new name for less than the minimum registration period should fail")
+	}
+	if _, err = lease("new", "abcdefgh", "ten"); err == nil {
+		t.Errorf("lease should be a number")
+	}
+}
@@ -43,7 +43,11 @@ func SetAccountJob(account *definitions.Account, do *definitions.Do) (string, er
 func SetValJob(set *definitions.SetJob, do *definitions.Do) (string, error) {
 	var result string
 	set.Value, _ = util.PreProcess(set.Value, do)
-	log.WithField("=>", set.Value).Info("Setting Variable")
+	if set.Sensitive {
+		log.WithField("=>", redacted).Info("Setting Variable")
+	} else {
+		log.WithField("=>", set.Value).Info("Setting Variable")
+	}
 	result = set.Value
 	return result, nil
 }
@@ -0,0 +1,252 @@
+package jobs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+// Replaces sensitive values in the log and the output file
+const redacted = "[redacted]"
+
+// Gives every variable declared by the package and every $env.NAME and $file(path) reference made by its jobs a
+// value before any job is run, so a missing value fails the run rather than a job part way through it.
+//
+// Declared variables become set jobs so the jobs can use them as $name. Each takes the first value found in order
+// of precedence:
+//  1. --set name=value on the command line
+//  2. the environment variable named by env
+//  3. the trimmed contents of the file named by file
+//  4. the default given in the jobs file
+//
+// The values of sensitive variables read from the environment or a file are redacted from the log and the output
+// file.
+//
+// $env.NAME and $file(path) references can likewise be given values with --set env.NAME=value and
+// --set file(path)=value, otherwise they read the environment and the file.
+//
+// Any other $name must refer to a declared variable, a --set or a job, it would otherwise be left as its literal text.
+func resolveVariables(do *definitions.Do) error {
+	sets, err := parseSets(do.DefaultSets)
+	if err != nil {
+		return err
+	}
+
+	var variableJobs []*definitions.Job
+	declared := make(map[string]bool)
+	for _, variable := range do.Package.Variables {
+		if variable.Name == "" {
+			return fmt.Errorf("variables declared in the jobs file must have a name")
+		}
+		if declared[variable.Name] {
+			return fmt.Errorf("variable %s is declared more than once", variable.Name)
+		}
+		declared[variable.Name] = true
+		value, source, err := resolveVariable(variable, sets, do)
+		if err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"variable": variable.Name,
+			"source":   source,
+		}).Debug("Resolved Variable")
+		variableJobs = append(variableJobs, &definitions.Job{
+			JobName: variable.Name,
+			Set: &definitions.SetJob{
+				Value:     value,
+				Sensitive: variable.Sensitive && (source == "env" || source == "file"),
+			},
+		})
+	}
+
+	do.SourcedVariables = make(map[string]string)
+	source := func(str, user string) error {
+		for _, ref := range util.SourcedVariables(str) {
+			if _, ok := do.SourcedVariables[ref]; ok {
+				continue
+			}
+			value, err := resolveSourcedVariable(ref, sets, do)
+			if err != nil {
+				return fmt.Errorf("%s uses $%s: %v", user, ref, err)
+			}
+			do.SourcedVariables[ref] = value
+		}
+		return nil
+	}
+	// Names a plain $name reference can resolve to
+	defined := make(map[string]bool)
+	for name := range declared {
+		defined[name] = true
+	}
+	for key := range sets {
+		defined[key] = true
+	}
+	for _, job := range do.Package.AllJobs() {
+		defined[job.JobName] = true
+	}
+	for _, job := range do.Package.AllJobs() {
+		// Results are not part of the job definition so should not be matched
+		definition := *job
+		definition.JobResult = ""
+		definition.JobVars = nil
+		for _, str := range definitionStrings(definition) {
+			if err := source(str, "job "+job.JobName); err != nil {
+				return err
+			}
+			for _, name := range util.VariableReferences(str) {
+				if !defined[name] {
+					return fmt.Errorf("job %s uses $%s but there is no variable, --set or job called %s",
+						job.JobName, name, name)
+				}
+			}
+		}
+	}
+	// The chain is checked before any job has run so can only refer to the environment and files
+	if chain := do.Package.Chain; chain != nil {
+		for _, str := range []string{chain.Name, chain.ID, chain.GenesisHash} {
+			if err := source(str, "the chain section"); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Any other sets are run as set jobs before the jobs of the package, one for each key with its last value
+	var setJobs []*definitions.Job
+	setKeys := make(map[string]bool)
+	for _, set := range do.DefaultSets {
+		key := strings.SplitN(set, "=", 2)[0]
+		if declared[key] || isSourcedVariable(key) || setKeys[key] {
+			continue
+		}
+		setKeys[key] = true
+		setJobs = append(setJobs, &definitions.Job{
+			JobName: key,
+			Set: &definitions.SetJob{
+				Value: sets[key],
+			},
+		})
+	}
+	do.Package.Jobs = append(append(setJobs, variableJobs...), do.Package.Jobs...)
+	return nil
+}
+
+// Returns every string in the job definition, including those nested in its call data which may hold maps decoded
+// from YAML that cannot be marshalled to JSON
+func definitionStrings(definition definitions.Job) []string {
+	var strs []string
+	var collect func(value reflect.Value)
+	collect = func(value reflect.Value) {
+		switch value.Kind() {
+		case reflect.String:
+			strs = append(strs, value.String())
+		case reflect.Ptr, reflect.Interface:
+			if !value.IsNil() {
+				collect(value.Elem())
+			}
+		case reflect.Struct:
+			for i := 0; i < value.NumField(); i++ {
+				collect(value.Field(i))
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < value.Len(); i++ {
+				collect(value.Index(i))
+			}
+		case reflect.Map:
+			for _, key := range value.MapKeys() {
+				collect(value.MapIndex(key))
+			}
+		}
+	}
+	collect(reflect.ValueOf(definition))
+	return strs
+}
+
+// Parses key=value pairs given by --set, later values for the same key taking precedence
+func parseSets(defaultSets []string) (map[string]string, error) {
+	sets := make(map[string]string, len(defaultSets))
+	for _, set := range defaultSets {
+		keyValue := strings.SplitN(set, "=", 2)
+		if len(keyValue) != 2 || keyValue[0] == "" {
+			return nil, fmt.Errorf("could not read --set %s, sets must be given as key=value", set)
+		}
+		sets[keyValue[0]] = keyValue[1]
+	}
+	return sets, nil
+}
+
+// Returns the value of the variable along with where it came from
+func resolveVariable(variable *definitions.PackageVariable, sets map[string]string,
+	do *definitions.Do) (string, string, error) {
+
+	if value, ok := sets[variable.Name]; ok {
+		return value, "set", nil
+	}
+	if variable.Env != "" {
+		if value, ok := os.LookupEnv(variable.Env); ok {
+			return value, "env", nil
+		}
+	}
+	if variable.File != "" {
+		value, err := readVariableFile(variable.File, do)
+		if err == nil {
+			return value, "file", nil
+		}
+		if !os.IsNotExist(err) {
+			return "", "", fmt.Errorf("could not read variable %s: %v", variable.Name, err)
+		}
+	}
+	if variable.Default != "" {
+		return variable.Default, "default", nil
+	}
+	return "", "", fmt.Errorf("variable %s is not defined, give it a value with --set %s=value%s",
+		variable.Name, variable.Name, variableSources(variable))
+}
+
+func variableSources(variable *definitions.PackageVariable) string {
+	var sources []string
+	if variable.Env != "" {
+		sources = append(sources, fmt.Sprintf(" or in environment variable %s", variable.Env))
+	}
+	if variable.File != "" {
+		sources = append(sources, fmt.Sprintf(" or in file %s", variable.File))
+	}
+	return strings.Join(sources, "")
+}
+
+func isSourcedVariable(ref string) bool {
+	return strings.HasPrefix(ref, "env.") || strings.HasPrefix(ref, "file(")
+}
+
+func resolveSourcedVariable(ref string, sets map[string]string, do *definitions.Do) (string, error) {
+	if value, ok := sets[ref]; ok {
+		return value, nil
+	}
+	if strings.HasPrefix(ref, "env.") {
+		name := strings.TrimPrefix(ref, "env.")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	}
+	return readVariableFile(strings.TrimSuffix(strings.TrimPrefix(ref, "file("), ")"), do)
+}
+
+// Reads the trimmed contents of the file at path, which is relative to the jobs file unless absolute
+func readVariableFile(path string, do *definitions.Do) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(do.YAMLPath), path)
+	}
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bs)), nil
+}
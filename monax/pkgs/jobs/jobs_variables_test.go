@@ -0,0 +1,180 @@
+package jobs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/util"
+)
+
+func variablesDo(t *testing.T, variables []*definitions.PackageVariable, jobs ...*definitions.Job) (*definitions.Do, string) {
+	dir, err := ioutil.TempDir("", "bos-variables")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "secret"), []byte("  from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	do := definitions.NowDo()
+	do.YAMLPath = filepath.Join(dir, "epm.yaml")
+	do.DefaultOutput = filepath.Join(dir, "epm.output.json")
+	do.Package = &definitions.Package{
+		Variables: variables,
+		Jobs:      jobs,
+	}
+	return do, dir
+}
+
+func TestResolveVariables(t *testing.T) {
+	os.Setenv("BOS_TEST_VARIABLE", "from-env")
+	defer os.Unsetenv("BOS_TEST_VARIABLE")
+
+	do, dir := variablesDo(t, []*definitions.PackageVariable{
+		{Name: "cli", Env: "BOS_TEST_VARIABLE", File: "secret", Default: "from-default"},
+		{Name: "env", Env: "BOS_TEST_VARIABLE", File: "secret", Default: "from-default"},
+		{Name: "file", Env: "BOS_TEST_UNSET", File: "secret", Default: "from-default"},
+		{Name: "default", Env: "BOS_TEST_UNSET", File: "missing", Default: "from-default"},
+	}, &definitions.Job{JobName: "uses", Set: &definitions.SetJob{Value: "$env.BOS_TEST_VARIABLE $file(secret)"}})
+	defer os.RemoveAll(dir)
+	do.DefaultSets = []string{"cli=from-cli", "other=a=b"}
+
+	if err := resolveVariables(do); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"other":   "a=b",
+		"cli":     "from-cli",
+		"env":     "from-env",
+		"file":    "from-file",
+		"default": "from-default",
+	}
+	if len(do.Package.Jobs) != len(expected)+1 {
+		t.Fatalf("expected %v jobs but got %v", len(expected)+1, len(do.Package.Jobs))
+	}
+	for _, job := range do.Package.Jobs[:len(expected)] {
+		if job.Set == nil || job.Set.Value != expected[job.JobName] {
+			t.Errorf("expected variable %s to be %s but got %v", job.JobName, expected[job.JobName], job.Set)
+		}
+	}
+	got, err := util.PreProcess(do.Package.Jobs[len(expected)].Set.Value, do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "from-env from-file" {
+		t.Errorf("expected sourced variables to be replaced but got %s", got)
+	}
+
+	// the command line overrides references too
+	do, dir = variablesDo(t, nil,
+		&definitions.Job{JobName: "uses", Set: &definitions.SetJob{Value: "$env.BOS_TEST_UNSET"}})
+	defer os.RemoveAll(dir)
+	do.DefaultSets = []string{"env.BOS_TEST_UNSET=from-cli"}
+	if err := resolveVariables(do); err != nil {
+		t.Fatal(err)
+	}
+	if len(do.Package.Jobs) != 1 || do.SourcedVariables["env.BOS_TEST_UNSET"] != "from-cli" {
+		t.Errorf("expected $env.BOS_TEST_UNSET to be set from the command line but got %v", do.SourcedVariables)
+	}
+
+	// references are found in call data decoded from YAML
+	do, dir = variablesDo(t, nil, &definitions.Job{JobName: "call", Call: &definitions.Call{
+		Data: []interface{}{map[interface{}]interface{}{"owner": "$env.BOS_TEST_VARIABLE"}},
+	}})
+	defer os.RemoveAll(dir)
+	if err := resolveVariables(do); err != nil {
+		t.Fatal(err)
+	}
+	if do.SourcedVariables["env.BOS_TEST_VARIABLE"] != "from-env" {
+		t.Errorf("expected $env.BOS_TEST_VARIABLE in call data to be resolved but got %v", do.SourcedVariables)
+	}
+
+	// a key set more than once is one set job with the last value
+	do, dir = variablesDo(t, nil)
+	defer os.RemoveAll(dir)
+	do.DefaultSets = []string{"twice=first", "once=only", "twice=second"}
+	if err := resolveVariables(do); err != nil {
+		t.Fatal(err)
+	}
+	if len(do.Package.Jobs) != 2 || do.Package.Jobs[0].JobName != "twice" || do.Package.Jobs[0].Set.Value != "second" {
+		t.Errorf("expected one set job for twice with its last value but got %v", do.Package.Jobs)
+	}
+
+	// plain references may refer to variables, sets, jobs yet to run and the block height
+	do, dir = variablesDo(t, []*definitions.PackageVariable{{Name: "declared", Default: "a"}},
+		&definitions.Job{JobName: "uses", Set: &definitions.SetJob{Value: "$declared $cli $later.field $later[1] $block+1"}},
+		&definitions.Job{JobName: "later", Set: &definitions.SetJob{Value: "b"}})
+	defer os.RemoveAll(dir)
+	do.DefaultSets = []string{"cli=c"}
+	if err := resolveVariables(do); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResolveVariablesUndefined(t *testing.T) {
+	for _, test := range []struct {
+		variables []*definitions.PackageVariable
+		value     string
+	}{
+		{[]*definitions.PackageVariable{{Name: "missing", Env: "BOS_TEST_UNSET", File: "missing"}}, ""},
+		{[]*definitions.PackageVariable{{Env: "BOS_TEST_UNSET"}}, ""},
+		{[]*definitions.PackageVariable{{Name: "twice", Default: "a"}, {Name: "twice", Default: "b"}}, ""},
+		{nil, "$env.BOS_TEST_UNSET"},
+		{nil, "$file(missing)"},
+		{nil, "$undefined"},
+		{nil, "key:$undefined.field"},
+		{[]*definitions.PackageVariable{{Name: "declared", Default: "a"}}, "$declared $undefined[0]"},
+	} {
+		do, dir := variablesDo(t, test.variables,
+			&definitions.Job{JobName: "uses", Set: &definitions.SetJob{Value: test.value}})
+		if err := RunJobs(do); err == nil {
+			t.Errorf("expected running with variables %v and value %s to fail", test.variables, test.value)
+		}
+		if _, err := os.Stat(do.DefaultOutput); !os.IsNotExist(err) {
+			t.Errorf("expected no jobs to run when variables are undefined")
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSensitiveVariablesRedacted(t *testing.T) {
+	os.Setenv("BOS_TEST_VARIABLE", "from-env")
+	defer os.Unsetenv("BOS_TEST_VARIABLE")
+
+	do, dir := variablesDo(t, []*definitions.PackageVariable{
+		{Name: "env", Env: "BOS_TEST_VARIABLE", Sensitive: true},
+		{Name: "file", File: "secret", Sensitive: true},
+		{Name: "default", Env: "BOS_TEST_UNSET", Default: "from-default", Sensitive: true},
+		{Name: "public", Env: "BOS_TEST_VARIABLE"},
+	}, &definitions.Job{JobName: "uses", Set: &definitions.SetJob{Value: "$env"}})
+	defer os.RemoveAll(dir)
+	do.Overwrite = true
+
+	if err := RunJobs(do); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadFile(do.DefaultOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(map[string]string)
+	if err := json.Unmarshal(bs, &results); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"env":     redacted,
+		"file":    redacted,
+		"default": "from-default",
+		"public":  "from-env",
+		// only the values read from outside the jobs file are marked as sensitive
+		"uses": "from-env",
+	}
+	for name, value := range expected {
+		if results[name] != value {
+			t.Errorf("expected output of %s to be %s but got %s", name, value, results[name])
+		}
+	}
+}
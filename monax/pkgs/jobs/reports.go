@@ -0,0 +1,300 @@
+package jobs
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
+)
+
+// Outcomes of a job in a run report
+const (
+	JobPassed  = "passed"
+	JobFailed  = "failed"
+	JobSkipped = "skipped"
+)
+
+// What a run report records of each job
+type JobReport struct {
+	Name     string        `json:"name"`
+	Type     string        `json:"type"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"-"`
+	Attempts int           `json:"attempts,omitempty"`
+	// The error a failed job gave or why a job was skipped
+	Message string `json:"message,omitempty"`
+	// Whether the failure was that of an assertion rather than of running the job
+	Assertion bool `json:"-"`
+	// Whether the job failed by being cancelled when it or the run ran out of time
+	Timeout bool `json:"timeout,omitempty"`
+}
+
+func newJobReport(job *definitions.Job, duration time.Duration, err error) *JobReport {
+	report := &JobReport{
+		Name:     job.JobName,
+		Type:     jobType(job),
+		Status:   JobPassed,
+		Duration: duration,
+		Attempts: job.JobAttempts,
+	}
+	if err != nil {
+		_, report.Assertion = err.(ErrAssertionFailed)
+		_, report.Timeout = err.(ErrJobTimeout)
+		report.Status = JobFailed
+		report.Message = err.Error()
+	}
+	return report
+}
+
+// Reports each test a test-contract job ran as a job of its own named after the job and the test, along with the
+// job itself when it failed other than by its tests failing
+func newJobReports(job *definitions.Job, duration time.Duration, err error) []*JobReport {
+	if len(job.JobTests) == 0 {
+		return []*JobReport{newJobReport(job, duration, err)}
+	}
+	var reports []*JobReport
+	for _, test := range job.JobTests {
+		report := &JobReport{
+			Name:     job.JobName + "." + test.Name,
+			Type:     jobType(job),
+			Status:   JobPassed,
+			Duration: test.Duration,
+			Attempts: job.JobAttempts,
+		}
+		if !test.Passed {
+			report.Status = JobFailed
+			report.Message = strings.Join(append([]string{test.Message}, test.Logs...), "\n")
+			report.Assertion = true
+		}
+		reports = append(reports, report)
+	}
+	if _, ok := err.(ErrAssertionFailed); err != nil && !ok {
+		reports = append(reports, newJobReport(job, duration, err))
+	}
+	return reports
+}
+
+func skippedJobReport(job *definitions.Job, reason string) *JobReport {
+	return &JobReport{
+		Name:    job.JobName,
+		Type:    jobType(job),
+		Status:  JobSkipped,
+		Message: reason,
+	}
+}
+
+// Returns the key the job is given under in a jobs file, such as deploy or assert
+func jobType(job *definitions.Job) string {
+	jobValue := reflect.ValueOf(job).Elem()
+	for i := 0; i < jobValue.NumField(); i++ {
+		field := jobValue.Type().Field(i)
+		key := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if key == "" || key == "-" || key == "retry" || field.Type.Kind() != reflect.Ptr {
+			continue
+		}
+		if !jobValue.Field(i).IsNil() {
+			return key
+		}
+	}
+	return ""
+}
+
+// SetOutputs sets the files a run writes its reports to from values of the --output flag, each of which is a path
+// to write the job results JSON to or kind=path where kind is one of json, junit or summary
+func SetOutputs(do *definitions.Do, outputs []string) error {
+	given := make(map[string]bool)
+	for _, output := range outputs {
+		kind, path := "json", output
+		if i := strings.Index(output, "="); i >= 0 {
+			switch output[:i] {
+			case "json", "junit", "summary":
+				kind, path = output[:i], output[i+1:]
+			}
+		}
+		if path == "" {
+			return fmt.Errorf("output %s does not give a path", output)
+		}
+		if given[kind] {
+			return fmt.Errorf("%s output given more than once", kind)
+		}
+		given[kind] = true
+		switch kind {
+		case "json":
+			do.DefaultOutput = path
+		case "junit":
+			do.JUnitOutput = path
+		case "summary":
+			do.SummaryOutput = path
+		}
+	}
+	return nil
+}
+
+// Writes the reports of the run requested by do, which just log a failure to write since the outcome of the run is
+// unaffected
+func writeReports(do *definitions.Do, reports []*JobReport, duration time.Duration) {
+	if do.DryRun {
+		// Jobs that could not be simulated did not pass
+		unverifiable := simulationOf(do).unverifiableJobs()
+		for _, report := range reports {
+			if reason, ok := unverifiable[report.Name]; ok && report.Status == JobPassed {
+				report.Status = JobSkipped
+				report.Message = reason
+			}
+		}
+	}
+	suite := filepath.Base(do.YAMLPath)
+	if do.JUnitOutput != "" {
+		log.Warn(fmt.Sprintf("Writing JUnit report to [%s]", do.JUnitOutput))
+		if err := WriteJobReportJUnit(suite, reports, duration, do.JUnitOutput); err != nil {
+			log.WithError(err).Error("Could not write JUnit report")
+		}
+	}
+	if do.SummaryOutput != "" {
+		log.Warn(fmt.Sprintf("Writing summary to [%s]", do.SummaryOutput))
+		if err := WriteJobReportSummary(suite, do.DryRun, reports, duration, do.SummaryOutput); err != nil {
+			log.WithError(err).Error("Could not write summary")
+		}
+	}
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// WriteJobReportJUnit writes the reports as a JUnit XML test suite in which each job is a test case. Failed
+// assertions are given as failures and other jobs that failed as errors, of type timeout for jobs that timed out.
+func WriteJobReportJUnit(suiteName string, reports []*JobReport, duration time.Duration, logFile string) error {
+	suite := junitTestSuite{
+		Name:  suiteName,
+		Tests: len(reports),
+		Time:  seconds(duration),
+	}
+	for _, report := range reports {
+		testCase := junitTestCase{
+			Name:      report.Name,
+			ClassName: report.Type,
+			Time:      seconds(report.Duration),
+		}
+		switch report.Status {
+		case JobFailed:
+			failure := &junitFailure{
+				Message: strings.SplitN(report.Message, "\n", 2)[0],
+				Text:    report.Message,
+			}
+			if report.Timeout {
+				failure.Type = "timeout"
+			}
+			if report.Assertion {
+				suite.Failures++
+				testCase.Failure = failure
+			} else {
+				suite.Errors++
+				testCase.Error = failure
+			}
+		case JobSkipped:
+			suite.Skipped++
+			testCase.Skipped = &junitSkipped{Message: report.Message}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	res, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeReportFile(logFile, append([]byte(xml.Header), res...))
+}
+
+type jobSummary struct {
+	*JobReport
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// WriteJobReportSummary writes the reports as JSON with the number of jobs that passed, failed and were skipped
+func WriteJobReportSummary(suiteName string, dryRun bool, reports []*JobReport, duration time.Duration,
+	logFile string) error {
+
+	summary := struct {
+		File            string       `json:"file"`
+		DryRun          bool         `json:"dry_run"`
+		Passed          int          `json:"passed"`
+		Failed          int          `json:"failed"`
+		Skipped         int          `json:"skipped"`
+		DurationSeconds float64      `json:"duration_seconds"`
+		Jobs            []jobSummary `json:"jobs"`
+	}{
+		File:            suiteName,
+		DryRun:          dryRun,
+		DurationSeconds: duration.Seconds(),
+		Jobs:            make([]jobSummary, len(reports)),
+	}
+	for i, report := range reports {
+		switch report.Status {
+		case JobPassed:
+			summary.Passed++
+		case JobFailed:
+			summary.Failed++
+		case JobSkipped:
+			summary.Skipped++
+		}
+		summary.Jobs[i] = jobSummary{JobReport: report, DurationSeconds: report.Duration.Seconds()}
+	}
+
+	res, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeReportFile(logFile, res)
+}
+
+func seconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
+
+func writeReportFile(logFile string, contents []byte) error {
+	file, err := os.Create(logFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(contents)
+	return err
+}
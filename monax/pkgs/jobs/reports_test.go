@@ -0,0 +1,137 @@
+package jobs
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+)
+
+func testReports() []*JobReport {
+	return []*JobReport{
+		newJobReport(&definitions.Job{JobName: "deploy", Deploy: &definitions.Deploy{}, JobAttempts: 2},
+			1500*time.Millisecond, nil),
+		newJobReport(&definitions.Job{JobName: "check", Assert: &definitions.Assert{}}, time.Millisecond,
+			ErrAssertionFailed{"assertion failed: 2 is not 3"}),
+		newJobReport(&definitions.Job{JobName: "call", Retry: &definitions.Retry{}, Call: &definitions.Call{}},
+			time.Second, fmt.Errorf("encountered Exception from chain: execution reverted: not owner")),
+		skippedJobReport(&definitions.Job{JobName: "later", QueryVals: &definitions.QueryVals{}},
+			"not run since job call failed"),
+	}
+}
+
+func TestJobType(t *testing.T) {
+	reports := testReports()
+	for i, typ := range []string{"deploy", "assert", "call", "query-vals"} {
+		if reports[i].Type != typ {
+			t.Errorf("expected job %s to have type %s but got %s", reports[i].Name, typ, reports[i].Type)
+		}
+	}
+}
+
+func TestSetOutputs(t *testing.T) {
+	do := definitions.NowDo()
+	do.DefaultOutput = "epm.output.json"
+	err := SetOutputs(do, []string{"junit=out/report.xml", "summary=summary.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if do.DefaultOutput != "epm.output.json" || do.JUnitOutput != "out/report.xml" ||
+		do.SummaryOutput != "summary.json" {
+		t.Errorf("unexpected outputs %s, %s, %s", do.DefaultOutput, do.JUnitOutput, do.SummaryOutput)
+	}
+	if err = SetOutputs(do, []string{"results=1.json"}); err != nil || do.DefaultOutput != "results=1.json" {
+		t.Errorf("expected a path of unknown kind to be the job results JSON but got %s, %v", do.DefaultOutput, err)
+	}
+	if err = SetOutputs(do, []string{"junit=a.xml", "junit=b.xml"}); err == nil {
+		t.Errorf("expected an output given twice to be an error")
+	}
+	if err = SetOutputs(do, []string{"summary="}); err == nil {
+		t.Errorf("expected an output without a path to be an error")
+	}
+}
+
+func TestWriteJobReportJUnit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "report.xml")
+	if err = WriteJobReportJUnit("deploy.yaml", testReports(), 3*time.Second, logFile); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suites := new(junitTestSuites)
+	if err = xml.Unmarshal(bs, suites); err != nil {
+		t.Fatal(err)
+	}
+	suite := suites.Suites[0]
+	if suite.Name != "deploy.yaml" || suite.Tests != 4 || suite.Failures != 1 || suite.Errors != 1 ||
+		suite.Skipped != 1 || suite.Time != "3.000" {
+		t.Errorf("unexpected suite %v", suite)
+	}
+	if c := suite.Cases[0]; c.Name != "deploy" || c.ClassName != "deploy" || c.Time != "1.500" ||
+		c.Failure != nil || c.Error != nil || c.Skipped != nil {
+		t.Errorf("unexpected passing test case %v", c)
+	}
+	if c := suite.Cases[1]; c.Failure == nil || c.Failure.Text != "assertion failed: 2 is not 3" {
+		t.Errorf("expected failed assertion to be a failure but got %v", c)
+	}
+	if c := suite.Cases[2]; c.Error == nil ||
+		c.Error.Message != "encountered Exception from chain: execution reverted: not owner" {
+		t.Errorf("expected failed call to be an error with its revert reason but got %v", c)
+	}
+	if c := suite.Cases[3]; c.Skipped == nil || c.Skipped.Message != "not run since job call failed" {
+		t.Errorf("expected skipped test case but got %v", c)
+	}
+}
+
+func TestWriteJobReportSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "summary.json")
+	if err = WriteJobReportSummary("deploy.yaml", true, testReports(), 3*time.Second, logFile); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := new(struct {
+		File            string
+		DryRun          bool `json:"dry_run"`
+		Passed          int
+		Failed          int
+		Skipped         int
+		DurationSeconds float64 `json:"duration_seconds"`
+		Jobs            []map[string]interface{}
+	})
+	if err = json.Unmarshal(bs, summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.File != "deploy.yaml" || !summary.DryRun || summary.Passed != 1 || summary.Failed != 2 ||
+		summary.Skipped != 1 || summary.DurationSeconds != 3 || len(summary.Jobs) != 4 {
+		t.Errorf("unexpected summary %s", bs)
+	}
+	if job := summary.Jobs[0]; job["name"] != "deploy" || job["status"] != JobPassed ||
+		job["duration_seconds"] != 1.5 || job["attempts"] != 2.0 {
+		t.Errorf("unexpected job summary %v", job)
+	}
+	if job := summary.Jobs[2]; job["status"] != JobFailed ||
+		job["message"] != "encountered Exception from chain: execution reverted: not owner" {
+		t.Errorf("unexpected job summary %v", job)
+	}
+}
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/util"
 )
 
 // [zr] this should go (currently used by the nameReg writer)
@@ -42,7 +46,7 @@ func WriteJobResultCSV(name, result string) error {
 	return err
 }
 
-func WriteJobResultJSON(results map[string]string, logFile string) error {
+func WriteJobResultJSON(results map[string]interface{}, logFile string) error {
 
 	file, err := os.Create(logFile)
 	if err != nil {
@@ -60,3 +64,75 @@ func WriteJobResultJSON(results map[string]string, logFile string) error {
 
 	return nil
 }
+
+// WriteAnnotatedJobResultJSON writes the job results alongside annotations about the run such as whether it was
+// simulated or which jobs failed
+func WriteAnnotatedJobResultJSON(results map[string]interface{}, annotations map[string]interface{}, logFile string) error {
+	output := make(map[string]interface{}, len(results)+len(annotations))
+	for name, result := range results {
+		output[name] = result
+	}
+	for key, annotation := range annotations {
+		output[key] = annotation
+	}
+
+	file, err := os.Create(logFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	res, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(res); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Results holding several return values, arrays or tuples are written as the JSON structures they were decoded
+// into rather than as strings, and sensitive values are redacted
+func jobResultOutput(job *definitions.Job) interface{} {
+	if job.Set != nil && job.Set.Sensitive {
+		return redacted
+	}
+	structured := len(job.JobVars) > 1
+	for _, variable := range job.JobVars {
+		structured = structured || strings.Contains(variable.Type, "(") || strings.HasSuffix(variable.Type, "]")
+	}
+	if !structured {
+		return job.JobResult
+	}
+	if len(job.JobVars) == 1 {
+		return decodedOutput(job.JobVars[0].Value, job.JobVars[0].Type)
+	}
+	values := make([]interface{}, len(job.JobVars))
+	for i, variable := range job.JobVars {
+		values[i] = decodedOutput(variable.Value, variable.Type)
+	}
+	return values
+}
+
+// The JSON structure of a return value of ABI type typ
+func decodedOutput(value, typ string) interface{} {
+	switch {
+	case strings.Contains(typ, "("):
+		return json.RawMessage(value)
+	case strings.HasSuffix(typ, "]"):
+		elements, err := util.ArrayElements(value)
+		if err != nil {
+			return value
+		}
+		elemType := typ[:strings.LastIndex(typ, "[")]
+		values := make([]interface{}, len(elements))
+		for i, element := range elements {
+			values[i] = decodedOutput(element, elemType)
+		}
+		return values
+	default:
+		return value
+	}
+}
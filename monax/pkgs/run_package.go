@@ -12,6 +12,10 @@ import (
 )
 
 func RunPackage(do *definitions.Do) error {
+	// Transactions signed in an earlier run are broadcast in place of running the jobs
+	if do.Broadcast != "" {
+		return jobs.BroadcastEnvelopes(do)
+	}
 	var gotwd string
 	if do.Path == "" {
 		var err error
@@ -67,7 +71,7 @@ func RunPackage(do *definitions.Do) error {
 	}
 
 	if do.Path != gotwd {
-		for _, job := range do.Package.Jobs {
+		for _, job := range do.Package.AllJobs() {
 			if job.Deploy != nil {
 				job.Deploy.Contract = filepath.Join(do.Path, job.Deploy.Contract)
 			}
@@ -0,0 +1,8 @@
+pragma solidity >=0.0.0;
+
+contract c {
+	uint public x = 0;
+	function() {
+		x++;
+	}
+}
\ No newline at end of file
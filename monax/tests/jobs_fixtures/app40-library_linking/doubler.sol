@@ -0,0 +1,11 @@
+pragma solidity >=0.0.0;
+
+import "./maths.sol";
+
+contract Doubler {
+    uint public doubled;
+
+    function double(uint x) {
+        doubled = Maths.double(x);
+    }
+}
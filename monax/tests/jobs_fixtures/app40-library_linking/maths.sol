@@ -0,0 +1,7 @@
+pragma solidity >=0.0.0;
+
+library Maths {
+    function double(uint x) returns (uint) {
+        return 2 * x;
+    }
+}
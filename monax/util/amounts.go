@@ -0,0 +1,266 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Suffixes an amount can be given in regardless of the chain, so 10k is 10000 and 2.5M is 2500000
+var amountSuffixes = map[string]int64{
+	"k": 3,
+	"M": 6,
+	"G": 9,
+	"T": 12,
+	"P": 15,
+	"E": 18,
+}
+
+var maxAmount = new(big.Int).SetUint64(math.MaxUint64)
+
+// Evaluates an amount given in a jobs file, which may be an integer (1000), in exponent notation (1e6), carry a
+// suffix (10k) or one of the named denominations of the chain (5 token), and may sum, subtract, multiply and divide
+// such amounts with parentheses for grouping. The arithmetic is exact so the amount, and every amount it is computed
+// from, must come to a whole number that is neither negative nor beyond what an account can hold.
+func ParseAmount(amount string, denominations map[string]string) (uint64, error) {
+	units := make(map[string]*big.Rat, len(denominations))
+	for name, value := range denominations {
+		if !isDenominationName(name) {
+			return 0, fmt.Errorf("denomination name %q should be made up of letters and underscores", name)
+		}
+		// denominations are defined in terms of plain amounts so cannot refer to one another
+		p := &amountParser{input: value}
+		unit, err := p.parse()
+		if err != nil {
+			return 0, fmt.Errorf("denomination %s is not a valid amount: %v", name, err)
+		}
+		units[name] = unit
+	}
+	p := &amountParser{input: amount, denominations: units}
+	value, err := p.parse()
+	if err != nil {
+		return 0, err
+	}
+	return value.Num().Uint64(), nil
+}
+
+func isDenominationName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isLetter(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parses by recursive descent where an expression adds and subtracts terms, a term multiplies and divides factors, and
+// a factor is either a number followed by an optional unit or an expression in parentheses
+type amountParser struct {
+	input         string
+	pos           int
+	denominations map[string]*big.Rat
+}
+
+func (p *amountParser) parse() (*big.Rat, error) {
+	if strings.TrimSpace(p.input) == "" {
+		return nil, fmt.Errorf("amount is empty")
+	}
+	value, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d of amount %s", p.input[p.pos:], p.pos, p.input)
+	}
+	if !value.IsInt() {
+		return nil, fmt.Errorf("amount %s comes to %s which is not a whole number", p.input,
+			value.FloatString(6))
+	}
+	return value, nil
+}
+
+func (p *amountParser) expression() (*big.Rat, error) {
+	value, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			right, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			value = new(big.Rat).Add(value, right)
+		case '-':
+			p.pos++
+			right, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			value = new(big.Rat).Sub(value, right)
+		default:
+			return value, nil
+		}
+		if err := p.checkRange(value); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *amountParser) term() (*big.Rat, error) {
+	value, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case '*':
+			p.pos++
+			right, err := p.factor()
+			if err != nil {
+				return nil, err
+			}
+			value = new(big.Rat).Mul(value, right)
+		case '/':
+			p.pos++
+			right, err := p.factor()
+			if err != nil {
+				return nil, err
+			}
+			if right.Sign() == 0 {
+				return nil, fmt.Errorf("amount %s divides by zero", p.input)
+			}
+			value = new(big.Rat).Quo(value, right)
+		default:
+			return value, nil
+		}
+		if err := p.checkRange(value); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *amountParser) factor() (*big.Rat, error) {
+	if p.peek() == '(' {
+		p.pos++
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("amount %s is missing a closing parenthesis", p.input)
+		}
+		p.pos++
+		return value, nil
+	}
+	value, err := p.number()
+	if err != nil {
+		return nil, err
+	}
+	if unit := p.unit(); unit != nil {
+		value = new(big.Rat).Mul(value, unit)
+	}
+	return value, p.checkRange(value)
+}
+
+// A decimal number with an optional fractional part and exponent
+func (p *amountParser) number() (*big.Rat, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.pos == len(p.input) {
+			return nil, fmt.Errorf("amount %s ends where a number was expected", p.input)
+		}
+		return nil, fmt.Errorf("expected a number at position %d of amount %s", p.pos, p.input)
+	}
+	// an e is only an exponent when followed by digits, otherwise it is the E suffix or starts a denomination
+	if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+		end := p.pos + 1
+		if end < len(p.input) && p.input[end] == '+' {
+			end++
+		}
+		if end < len(p.input) && isDigit(p.input[end]) {
+			for end < len(p.input) && isDigit(p.input[end]) {
+				end++
+			}
+			p.pos = end
+		}
+	}
+	literal := p.input[start:p.pos]
+	// big.Rat would accept exponents so large that computing them exhausts memory
+	if i := strings.IndexAny(literal, "eE"); i >= 0 && len(strings.TrimPrefix(literal[i+1:], "+")) > 3 {
+		return nil, fmt.Errorf("exponent of %s in amount %s is too large", literal, p.input)
+	}
+	value, ok := new(big.Rat).SetString(literal)
+	if !ok {
+		return nil, fmt.Errorf("%s in amount %s is not a number", literal, p.input)
+	}
+	return value, nil
+}
+
+// A suffix or named denomination, or nil if none follows
+func (p *amountParser) unit() *big.Rat {
+	p.skipSpace()
+	start := p.pos
+	end := start
+	for end < len(p.input) && isLetter(p.input[end]) {
+		end++
+	}
+	name := p.input[start:end]
+	if name == "" {
+		return nil
+	}
+	if unit, ok := p.denominations[name]; ok {
+		p.pos = end
+		return unit
+	}
+	if exponent, ok := amountSuffixes[name]; ok {
+		p.pos = end
+		return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(exponent), nil))
+	}
+	return nil
+}
+
+// Amounts are checked as they are computed so that an expression cannot pass through a value no transaction could
+// carry even if it comes back into range
+func (p *amountParser) checkRange(value *big.Rat) error {
+	if value.Sign() < 0 {
+		return fmt.Errorf("amount %s goes negative", p.input)
+	}
+	if new(big.Int).Quo(value.Num(), value.Denom()).Cmp(maxAmount) > 0 {
+		return fmt.Errorf("amount %s overflows the largest amount of %d", p.input, uint64(math.MaxUint64))
+	}
+	return nil
+}
+
+func (p *amountParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *amountParser) skipSpace() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func isLetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b == '_'
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
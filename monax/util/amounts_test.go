@@ -0,0 +1,67 @@
+package util
+
+import (
+	"testing"
+)
+
+var denominations = map[string]string{
+	"token": "1e6",
+	"milli": "1k",
+}
+
+var ParseAmountTests = []struct {
+	in  string
+	out uint64
+}{
+	{"1000", 1000},
+	{"10k", 10000},
+	{"2.5M", 2500000},
+	{"1e6", 1000000},
+	{"1.5e+3", 1500},
+	{"1E", 1000000000000000000},
+	{"3 token", 3000000},
+	{"0.25token", 250000},
+	{"2 token + 500 milli", 2500000},
+	{"(1 + 2) * 3", 9},
+	{"10k / 4", 2500},
+	{"18446744073709551615", 18446744073709551615},
+	{"18E - 1", 17999999999999999999},
+}
+
+var ParseAmountErrors = []string{
+	"",
+	"abc",
+	"-5",
+	"5 - 10",
+	"10 / 3",
+	"0.5",
+	"18446744073709551616",
+	// overflows partway even though the result would be in range
+	"20E - 10E",
+	"1e9999",
+	"1 / 0",
+	"(1 + 2",
+	"5 coins",
+}
+
+func TestParseAmount(t *testing.T) {
+	for _, test := range ParseAmountTests {
+		out, err := ParseAmount(test.in, denominations)
+		if err != nil {
+			t.Errorf("ParseAmount(%q): %v", test.in, err)
+		} else if out != test.out {
+			t.Errorf("ParseAmount(%q) = %d, want %d", test.in, out, test.out)
+		}
+	}
+	for _, in := range ParseAmountErrors {
+		if out, err := ParseAmount(in, denominations); err == nil {
+			t.Errorf("ParseAmount(%q) = %d, want an error", in, out)
+		}
+	}
+	if _, err := ParseAmount("1", map[string]string{"bad name": "1"}); err == nil {
+		t.Errorf("a denomination name with a space should be rejected")
+	}
+	if _, err := ParseAmount("1", map[string]string{"token": "1 milli"}); err == nil {
+		t.Errorf("a denomination defined in terms of another should be rejected")
+	}
+}
@@ -1,19 +1,79 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/monax/bosmarmot/monax/definitions"
+	"github.com/monax/bosmarmot/monax/log"
 
 	acm "github.com/hyperledger/burrow/account"
 	"github.com/hyperledger/burrow/client"
 	"github.com/hyperledger/burrow/logging/loggers"
 )
 
+var (
+	nodeClientsMtx sync.Mutex
+	// Keyed by chain URL
+	nodeClients = make(map[string]client.NodeClient)
+)
+
+// Returns the client for do.ChainURL which is shared by every job so that connections to the node are reused, its
+// calls are made with the context of the job running
+func NodeClient(do *definitions.Do) client.NodeClient {
+	nodeClientsMtx.Lock()
+	defer nodeClientsMtx.Unlock()
+	nodeClient, ok := nodeClients[do.ChainURL]
+	if !ok {
+		var options []client.NodeClientOption
+		if do.MaxIdleConns > 0 {
+			options = append(options, client.WithMaxIdleConns(do.MaxIdleConns))
+		}
+		if do.IdleConnTimeout > 0 {
+			options = append(options, client.WithIdleConnTimeout(do.IdleConnTimeout))
+		}
+		if do.WebsocketPingPeriod > 0 {
+			options = append(options, client.WithWebsocketPingPeriod(do.WebsocketPingPeriod))
+		}
+		options = append(options, client.WithWebsocketStates(logWebsocketStates()))
+		nodeClient = client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger(), options...)
+		nodeClients[do.ChainURL] = nodeClient
+	}
+	return nodeClient.WithContext(Context(do))
+}
+
+// The context the job running should make its calls to the chain with, background when there is none
+func Context(do *definitions.Do) context.Context {
+	if do.Context == nil {
+		return context.Background()
+	}
+	return do.Context
+}
+
+// Logs the connection state changes of websocket clients, such as when a job waiting on an event loses its connection
+func logWebsocketStates() chan<- client.WebsocketStateChange {
+	states := make(chan client.WebsocketStateChange, 16)
+	go func() {
+		for change := range states {
+			entry := log.WithFields(log.Fields{
+				"chain": change.Address,
+				"state": change.State.String(),
+			})
+			if change.State == client.WebsocketDisconnected {
+				entry.Warn("Websocket Connection")
+			} else {
+				entry.Debug("Websocket Connection")
+			}
+		}
+	}()
+	return states
+}
+
 func GetBlockHeight(do *definitions.Do) (latestBlockHeight uint64, err error) {
-	nodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
+	nodeClient := NodeClient(do)
 	// NOTE: NodeInfo is no longer exposed through Status();
 	// other values are currently not use by the package manager
 	_, _, _, latestBlockHeight, _, err = nodeClient.Status()
@@ -30,7 +90,7 @@ func AccountsInfo(account, field string, do *definitions.Do) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("Account Addr %s is improper hex: %v", account, err)
 	}
-	nodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
+	nodeClient := NodeClient(do)
 
 	r, err := nodeClient.GetAccount(address)
 	if err != nil {
@@ -68,28 +128,43 @@ func AccountsInfo(account, field string, do *definitions.Do) (string, error) {
 }
 
 func NamesInfo(name, field string, do *definitions.Do) (string, error) {
-	nodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
-	owner, data, expirationBlock, err := nodeClient.GetName(name)
+	vars, err := NameVariables(name, do)
 	if err != nil {
 		return "", err
 	}
+	return NameField(name, field, vars)
+}
 
-	switch strings.ToLower(field) {
-	case "name":
+// Returns the owner, data and expires fields of the entry for name
+func NameVariables(name string, do *definitions.Do) ([]*definitions.Variable, error) {
+	nodeClient := NodeClient(do)
+	owner, data, expirationBlock, err := nodeClient.GetName(name)
+	if err != nil {
+		return nil, err
+	}
+	return []*definitions.Variable{
+		{Name: "owner", Value: owner.String()},
+		{Name: "data", Value: data},
+		{Name: "expires", Value: itoaU64(expirationBlock)},
+	}, nil
+}
+
+// Picks out field from the fields of the entry for name
+func NameField(name, field string, vars []*definitions.Variable) (string, error) {
+	field = strings.ToLower(field)
+	if field == "name" {
 		return name, nil
-	case "owner":
-		return owner.String(), nil
-	case "data":
-		return data, nil
-	case "expires":
-		return itoaU64(expirationBlock), nil
-	default:
-		return "", fmt.Errorf("Field %s not recognized", field)
 	}
+	for _, variable := range vars {
+		if variable.Name == field {
+			return variable.Value, nil
+		}
+	}
+	return "", fmt.Errorf("Field %s not recognized", field)
 }
 
 func ValidatorsInfo(field string, do *definitions.Do) (string, error) {
-	nodeClient := client.NewBurrowNodeClient(do.ChainURL, loggers.NewNoopInfoTraceLogger())
+	nodeClient := NodeClient(do)
 	_, bondedValidators, unbondingValidators, err := nodeClient.ListValidators()
 	if err != nil {
 		return "", err
@@ -5,6 +5,12 @@ import (
 	"os"
 )
 
+// Exit codes that let scripts tell a deployment that ran but did not behave as asserted from one that could not run
+const (
+	ExitCodeFailure         = 1
+	ExitCodeAssertionFailed = 2
+)
+
 func Exit(err error) {
 	status := 0
 	if err != nil {
@@ -15,8 +21,12 @@ func Exit(err error) {
 }
 
 func IfExit(err error) {
+	IfExitWithCode(err, ExitCodeFailure)
+}
+
+func IfExitWithCode(err error, code int) {
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(code)
 	}
 }
@@ -1,9 +1,11 @@
 package util
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -11,18 +13,67 @@ import (
 	"github.com/monax/bosmarmot/monax/log"
 )
 
+// $env.NAME and $file(path) read values from outside the jobs file
+var sourcedVariableRegex = regexp.MustCompile(`(^|\s|:)\$(env\.[a-zA-Z_][a-zA-Z0-9_]*|file\([^)]+\))`)
+
+// Returns the $env.NAME and $file(path) references made in s without their $
+func SourcedVariables(s string) []string {
+	var refs []string
+	for _, match := range sourcedVariableRegex.FindAllStringSubmatch(s, -1) {
+		refs = append(refs, match[2])
+	}
+	return refs
+}
+
+// $block.... $account.... etc. should be caught. hell$$o should not
+// :$libAddr needs to be caught
+// $job.name, $job.0 and $job.name[2] pick out one of several return values and an element of an array
+var variableRegex = regexp.MustCompile(`(^|\s|:)\$([a-zA-Z0-9_.]+(?:\[[0-9]+\])*)`)
+
+// Returns the names of the jobs and variables whose results s refers to, leaving out $block and the $env.NAME and
+// $file(path) references of SourcedVariables
+func VariableReferences(s string) []string {
+	var names []string
+	for _, match := range variableRegex.FindAllStringSubmatch(sourcedVariableRegex.ReplaceAllString(s, "$1"), -1) {
+		name := match[2]
+		if strings.Contains(name, "block") {
+			continue
+		}
+		if i := strings.Index(name, "["); i >= 0 {
+			name = name[:i]
+		}
+		names = append(names, strings.Split(name, ".")[0])
+	}
+	return names
+}
+
+func replaceSourcedVariables(toProcess string, do *definitions.Do) (string, error) {
+	var err error
+	processed := sourcedVariableRegex.ReplaceAllStringFunc(toProcess, func(match string) string {
+		submatch := sourcedVariableRegex.FindStringSubmatch(match)
+		value, ok := do.SourcedVariables[submatch[2]]
+		if !ok {
+			err = fmt.Errorf("variable $%s has not been resolved", submatch[2])
+			return match
+		}
+		return submatch[1] + value
+	})
+	return processed, err
+}
+
 func PreProcess(toProcess string, do *definitions.Do) (string, error) {
-	// $block.... $account.... etc. should be caught. hell$$o should not
-	// :$libAddr needs to be caught
-	catchEr := regexp.MustCompile(`(^|\s|:)\$([a-zA-Z0-9_.]+)`)
+	toProcess, err := replaceSourcedVariables(toProcess, do)
+	if err != nil {
+		return "", err
+	}
 	// If there's a match then run through the replacement process
-	if catchEr.MatchString(toProcess) {
+	if variableRegex.MatchString(toProcess) {
 		log.WithField("match", toProcess).Debug("Replacement Match Found")
 
 		// find what we need to catch.
 		processedString := toProcess
 
-		for _, jobMatch := range catchEr.FindAllStringSubmatch(toProcess, -1) {
+		for _, jobMatch := range variableRegex.FindAllStringSubmatch(toProcess, -1) {
 			jobName := jobMatch[2]
 			varName := "$" + jobName
 			var innerVarName string
@@ -42,6 +93,15 @@ func PreProcess(toProcess string, do *definitions.Do) (string, error) {
 				processedString = strings.Replace(processedString, toProcess, block, 1)
 			}
 
+			var indices []int
+			if i := strings.Index(jobName, "["); i >= 0 {
+				for _, index := range arrayIndexRegex.FindAllStringSubmatch(jobName[i:], -1) {
+					n, _ := strconv.Atoi(index[1])
+					indices = append(indices, n)
+				}
+				jobName = jobName[:i]
+			}
+
 			if strings.Contains(jobName, ".") { //for functions with multiple returns
 				wantsInnerValues = true
 				var splitStr = strings.Split(jobName, ".")
@@ -50,25 +110,33 @@ func PreProcess(toProcess string, do *definitions.Do) (string, error) {
 			}
 
 			// second we loop through the jobNames to do a result replace
-			for _, job := range do.Package.Jobs {
+			for _, job := range do.Package.AllJobs() {
 				if string(jobName) == job.JobName {
 					if wantsInnerValues {
-						for _, innerVal := range job.JobVars {
-							if innerVal.Name == innerVarName { //find the value we want from the bunch
-								processedString = strings.Replace(processedString, varName, innerVal.Value, 1)
-								log.WithFields(log.Fields{
-									"job":     string(jobName),
-									"varName": innerVarName,
-									"result":  innerVal.Value,
-								}).Debug("Fixing Inner Vars =>")
-							}
+						innerVal := findReturnValue(job.JobVars, innerVarName)
+						if innerVal == nil {
+							continue
+						}
+						value, err := arrayElement(innerVal.Value, indices)
+						if err != nil {
+							return "", fmt.Errorf("could not resolve %s: %v", varName, err)
 						}
+						processedString = strings.Replace(processedString, varName, value, 1)
+						log.WithFields(log.Fields{
+							"job":     string(jobName),
+							"varName": innerVarName,
+							"result":  value,
+						}).Debug("Fixing Inner Vars =>")
 					} else {
+						value, err := arrayElement(job.JobResult, indices)
+						if err != nil {
+							return "", fmt.Errorf("could not resolve %s: %v", varName, err)
+						}
 						log.WithFields(log.Fields{
 							"var": string(jobName),
-							"res": job.JobResult,
+							"res": value,
 						}).Debug("Fixing Variables =>")
-						processedString = strings.Replace(processedString, varName, job.JobResult, 1)
+						processedString = strings.Replace(processedString, varName, value, 1)
 					}
 				}
 			}
@@ -80,6 +148,80 @@ func PreProcess(toProcess string, do *definitions.Do) (string, error) {
 	return toProcess, nil
 }
 
+var arrayIndexRegex = regexp.MustCompile(`\[([0-9]+)\]`)
+
+// Finds a return value by its name in the ABI or else by its position among the return values
+func findReturnValue(vars []*definitions.Variable, name string) *definitions.Variable {
+	for _, variable := range vars {
+		if variable.Name == name {
+			return variable
+		}
+	}
+	if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < len(vars) {
+		return vars[i]
+	}
+	return nil
+}
+
+// Picks out the element at indices of the arrays nested in value
+func arrayElement(value string, indices []int) (string, error) {
+	for _, index := range indices {
+		elements, err := ArrayElements(value)
+		if err != nil {
+			return "", err
+		}
+		if index >= len(elements) {
+			return "", fmt.Errorf("index %v is out of range of the %v elements of %s", index, len(elements), value)
+		}
+		value = elements[index]
+	}
+	return value, nil
+}
+
+// ArrayElements splits an array return value, either of the form [a,b,c] or a JSON array as used for tuples,
+// into its elements, leaving any nested arrays intact
+func ArrayElements(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("%s is not an array", value)
+	}
+	if strings.Contains(value, "\"") {
+		var elements []json.RawMessage
+		if err := json.Unmarshal([]byte(value), &elements); err == nil {
+			strs := make([]string, len(elements))
+			for i, element := range elements {
+				var s string
+				if json.Unmarshal(element, &s) == nil {
+					strs[i] = s
+				} else {
+					strs[i] = string(element)
+				}
+			}
+			return strs, nil
+		}
+	}
+	inner := value[1 : len(value)-1]
+	if strings.TrimSpace(inner) == "" {
+		return []string{}, nil
+	}
+	var elements []string
+	depth, start := 0, 0
+	for i, c := range inner {
+		switch c {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				elements = append(elements, strings.TrimSpace(inner[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(elements, strings.TrimSpace(inner[start:])), nil
+}
+
 func replaceBlockVariable(toReplace string, do *definitions.Do) (string, error) {
 	log.WithFields(log.Fields{
 		"var": toReplace,
@@ -167,23 +309,48 @@ func PreProcessInputData(function string, data interface{}, do *definitions.Do,
 			case bool:
 				newString = strconv.FormatBool(s.Interface().(bool))
 			case int, int32, int64:
-				newString = strconv.FormatInt(int64(s.Interface().(int)), 10)
+				newString = strconv.FormatInt(reflect.ValueOf(s.Interface()).Int(), 10)
+			case uint64:
+				// YAML integers too large for an int
+				newString = strconv.FormatUint(s.Interface().(uint64), 10)
+			case float64:
+				newString = strconv.FormatFloat(s.Interface().(float64), 'f', -1, 64)
 			case []interface{}:
+				if isNested(s.Interface()) {
+					newString, err := structuredInput(s.Interface(), do)
+					if err != nil {
+						return "", nil, err
+					}
+					callDataArray = append(callDataArray, newString)
+					continue
+				}
 				var args []string
 				for _, index := range s.Interface().([]interface{}) {
 					value := reflect.ValueOf(index)
 					var stringified string
 					switch value.Kind() {
-					case reflect.Int:
+					case reflect.Int, reflect.Int64:
 						stringified = strconv.FormatInt(value.Int(), 10)
+					case reflect.Uint64:
+						stringified = strconv.FormatUint(value.Uint(), 10)
+					case reflect.Bool:
+						stringified = strconv.FormatBool(value.Bool())
 					case reflect.String:
 						stringified = value.String()
 					}
-					index, _ = PreProcess(stringified, do)
+					stringified, _ = PreProcess(stringified, do)
 					args = append(args, stringified)
 				}
 				newString = "[" + strings.Join(args, ",") + "]"
 				log.Debug(newString)
+			case map[interface{}]interface{}, map[string]interface{}:
+				// a tuple given by the names of its components
+				newString, err := structuredInput(s.Interface(), do)
+				if err != nil {
+					return "", nil, err
+				}
+				callDataArray = append(callDataArray, newString)
+				continue
 			default:
 				newString = s.Interface().(string)
 			}
@@ -194,11 +361,105 @@ func PreProcessInputData(function string, data interface{}, do *definitions.Do,
 	return function, callDataArray, nil
 }
 
-func PreProcessLibs(libs string, do *definitions.Do) (string, error) {
-	libraries, _ := PreProcess(libs, do)
-	if libraries != "" {
-		pairs := strings.Split(libraries, ",")
+// Whether a list input holds lists or maps, as for arrays of tuples
+func isNested(data interface{}) bool {
+	for _, element := range data.([]interface{}) {
+		switch element.(type) {
+		case []interface{}, map[interface{}]interface{}, map[string]interface{}:
+			return true
+		}
+	}
+	return false
+}
+
+// Encodes nested list and map inputs as JSON for the ABI packer, preprocessing any variables within
+func structuredInput(data interface{}, do *definitions.Do) (string, error) {
+	value, err := preProcessStructured(data, do)
+	if err != nil {
+		return "", err
+	}
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func preProcessStructured(data interface{}, do *definitions.Do) (interface{}, error) {
+	switch data := data.(type) {
+	case []interface{}:
+		values := make([]interface{}, len(data))
+		for i, element := range data {
+			value, err := preProcessStructured(element, do)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case map[interface{}]interface{}:
+		values := make(map[string]interface{}, len(data))
+		for key, element := range data {
+			value, err := preProcessStructured(element, do)
+			if err != nil {
+				return nil, err
+			}
+			values[fmt.Sprintf("%v", key)] = value
+		}
+		return values, nil
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(data))
+		for key, element := range data {
+			value, err := preProcessStructured(element, do)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, nil
+	case string:
+		return PreProcess(data, do)
+	default:
+		// numbers and bools are given to the packer as strings like every other input
+		return fmt.Sprintf("%v", data), nil
+	}
+}
+
+// Returns the libraries of a deploy job as a string of libName:LibAddr separated by spaces, libs being either
+// such a string separated by commas or a map of library name to address
+func PreProcessLibs(libs interface{}, do *definitions.Do) (string, error) {
+	var libraries string
+	switch libs := libs.(type) {
+	case nil:
+	case string:
+		libraries, _ = PreProcess(libs, do)
+		if libraries != "" {
+			pairs := strings.Split(libraries, ",")
+			libraries = strings.Join(pairs, " ")
+		}
+	case map[interface{}]interface{}, map[string]interface{}:
+		value, err := preProcessStructured(libs, do)
+		if err != nil {
+			return "", err
+		}
+		addresses := value.(map[string]interface{})
+		names := make([]string, 0, len(addresses))
+		for name := range addresses {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pairs := make([]string, len(names))
+		for i, name := range names {
+			address, ok := addresses[name].(string)
+			if !ok {
+				return "", fmt.Errorf("address of library %s should be a string but is %v", name, addresses[name])
+			}
+			pairs[i] = name + ":" + address
+		}
 		libraries = strings.Join(pairs, " ")
+	default:
+		return "", fmt.Errorf("libraries should be a string of libName:LibAddr or a map of library name to "+
+			"address but is %v", libs)
 	}
 	log.WithField("=>", libraries).Debug("Library String")
 	return libraries, nil